@@ -0,0 +1,79 @@
+// Package main re-encrypts stored OAuth tokens with the active key in
+// TOKEN_ENCRYPTION_KEYS.
+//
+// Usage:
+//
+//	go run ./cmd/rotate-tokens          # re-seal tokens
+//	go run ./cmd/rotate-tokens -dry-run # count what would change
+//
+// To rotate, put the new key first in TOKEN_ENCRYPTION_KEYS and keep the
+// old one after it, deploy, then run this command. Once it reports nothing
+// left to rotate, the old key can be removed. The first run after enabling
+// encryption seals every token that was stored as plaintext. Running it
+// again is a no-op.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/korsana/backend/internal/config"
+	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/services"
+	"github.com/korsana/backend/internal/tokencrypt"
+)
+
+func main() {
+	dryRun := flag.Bool("dry-run", false, "count tokens to rotate without writing")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	log := logger.Init(cfg.Environment, nil)
+
+	keys, err := tokencrypt.ParseKeyring(cfg.TokenEncryptionKeys)
+	if err != nil {
+		log.Error("Failed to parse TOKEN_ENCRYPTION_KEYS", "error", err)
+		os.Exit(1)
+	}
+	if keys == nil {
+		log.Error("TOKEN_ENCRYPTION_KEYS is not set")
+		os.Exit(1)
+	}
+
+	db, err := database.NewPostgresDB(cfg.DatabaseURL)
+	if err != nil {
+		log.Error("Failed to initialize database", "error", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+
+	result, err := services.RotateIntegrationTokens(ctx, db, keys, *dryRun)
+	if err != nil {
+		log.Error("Token rotation failed", "error", err, "result", result)
+		os.Exit(1)
+	}
+	log.Info("Token rotation finished",
+		"active_key", keys.ActiveKeyID(),
+		"dry_run", *dryRun,
+		"scanned", result.Scanned,
+		"rotated", result.Rotated,
+		"unchanged", result.Unchanged,
+		"raced", result.Raced,
+	)
+}
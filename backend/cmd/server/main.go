@@ -19,10 +19,14 @@ import (
 	"github.com/korsana/backend/internal/database"
 	"github.com/korsana/backend/internal/logger"
 	"github.com/korsana/backend/internal/services"
+	"github.com/korsana/backend/internal/tokencrypt"
+	"github.com/korsana/backend/pkg/coros"
+	"github.com/korsana/backend/pkg/garmin"
 	"github.com/korsana/backend/pkg/strava"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -62,14 +66,29 @@ func main() {
 
 	// 4. Initialize External Clients
 	stravaClient := strava.NewClient(cfg.StravaClientID, cfg.StravaClientSecret, cfg.StravaRedirectURI)
+	stravaClient.Governor = strava.NewGovernor(redisClient)
+	garminClient := garmin.NewClient(cfg.GarminClientID, cfg.GarminClientSecret, cfg.GarminRedirectURI)
+	corosClient := coros.NewClient(cfg.CorosClientID, cfg.CorosClientSecret, cfg.CorosRedirectURI)
+
+	tokenKeys, err := tokencrypt.ParseKeyring(cfg.TokenEncryptionKeys)
+	if err != nil {
+		log.Error("Failed to parse TOKEN_ENCRYPTION_KEYS", "error", err)
+		os.Exit(1)
+	}
+	if tokenKeys == nil {
+		log.Warn("TOKEN_ENCRYPTION_KEYS is not set; OAuth tokens will be stored unencrypted")
+	}
 
 	// 5. Initialize Services
 	authService := services.NewAuthService(db, cfg.SupabaseURL, cfg.SupabaseServiceRoleKey)
 	calendarService := services.NewCalendarService(db)
-	stravaService := services.NewStravaService(db, stravaClient, redisClient, calendarService)
+	stravaService := services.NewStravaService(db, stravaClient, redisClient, calendarService, tokenKeys)
+	garminService := services.NewGarminService(db, garminClient, redisClient, calendarService, tokenKeys)
+	corosService := services.NewCorosService(db, corosClient, redisClient, calendarService, tokenKeys)
 	goalsService := services.NewGoalsService(db)
 	activityService := services.NewActivityService(db)
-	metricsService := services.NewMetricsService(db)
+	activityImportService := services.NewActivityImportService(db, calendarService)
+	metricsService := services.NewMetricsService(db, cfg.RunEquivalence)
 	crossTrainingGoalsService := services.NewCrossTrainingGoalsService(db)
 	userProfileService := services.NewUserProfileService(db, cfg.SupabaseURL, cfg.SupabaseServiceRoleKey)
 	notificationService := services.NewNotificationService(
@@ -85,18 +104,37 @@ func main() {
 	integrationsService := services.NewIntegrationsService(db)
 	coachService := services.NewCoachService(db, redisClient, cfg, goalsService, calendarService, userProfileService)
 
+	// Background workers share a context that is cancelled on shutdown.
+	workerCtx, stopWorkers := context.WithCancel(logger.WithLogger(context.Background(), log))
+	defer stopWorkers()
+	if cfg.StravaWebhookVerifyToken != "" {
+		go services.NewStravaWebhookWorker(stravaService).Run(workerCtx)
+	}
+	if cfg.GarminNotificationToken != "" {
+		go services.NewGarminNotificationWorker(garminService).Run(workerCtx)
+	}
+	go services.NewSyncScheduler(db, stravaService, corosService).Run(workerCtx)
+	go services.NewStravaBackfillWorker(stravaService, func(ctx context.Context, userID uuid.UUID) {
+		if _, err := userProfileService.DetectPRsFromStrava(ctx, userID); err != nil {
+			logger.FromContext(ctx).Warn("PR detection after backfill failed", "user_id", userID, "error", err)
+		}
+	}).Run(workerCtx)
+
 	// 6. Initialize Handlers
-	stravaHandler := handlers.NewStravaHandler(stravaService, authService, userProfileService, notificationService, cfg.FrontendURL)
+	stravaHandler := handlers.NewStravaHandler(stravaService, authService, userProfileService, notificationService, cfg.FrontendURL, cfg.StravaWebhookVerifyToken)
 	goalsHandler := handlers.NewGoalsHandler(goalsService)
 	coachHandler := handlers.NewCoachHandler(coachService, db)
-	calendarHandler := handlers.NewCalendarHandler(calendarService)
+	calendarHandler := handlers.NewCalendarHandler(calendarService, metricsService)
 	profileHandler := handlers.NewProfileHandler(authService, stravaService, goalsService, userProfileService, notificationService, integrationsService)
-	activitiesHandler := handlers.NewActivitiesHandler(activityService)
+	activitiesHandler := handlers.NewActivitiesHandler(activityService, stravaService, activityImportService)
 	dashboardHandler := handlers.NewDashboardHandler(metricsService)
 	crossTrainingHandler := handlers.NewCrossTrainingHandler(db)
 	gearHandler := handlers.NewGearHandler(db)
 	predictorHandler := handlers.NewPredictorHandler(db)
 	crossTrainingGoalsHandler := handlers.NewCrossTrainingGoalsHandler(crossTrainingGoalsService)
+	integrationsHandler := handlers.NewIntegrationsHandler(integrationsService)
+	garminHandler := handlers.NewGarminHandler(garminService, cfg.FrontendURL, cfg.GarminNotificationToken)
+	corosHandler := handlers.NewCorosHandler(corosService, cfg.FrontendURL)
 
 	// 6. Setup Router
 	if cfg.Environment == "production" {
@@ -126,6 +164,7 @@ func main() {
 		}
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
+	r.GET("/health/strava", stravaHandler.RateLimitHealth)
 
 	// API Routes
 	api := r.Group("/api")
@@ -133,6 +172,17 @@ func main() {
 		// Strava OAuth callback (public — Strava redirects here after user approves)
 		api.GET("/strava/callback", stravaHandler.Callback)
 
+		// Strava push subscription (public — Strava calls these directly)
+		api.GET("/strava/webhook", stravaHandler.VerifyWebhook)
+		api.POST("/strava/webhook", stravaHandler.ReceiveWebhook)
+
+		// Garmin OAuth callback and ping/push notifications (public)
+		api.GET("/garmin/callback", garminHandler.Callback)
+		api.POST("/garmin/notifications", garminHandler.ReceiveNotification)
+
+		// COROS OAuth callback (public)
+		api.GET("/integrations/coros/callback", corosHandler.Callback)
+
 		// Protected Routes
 		protected := api.Group("")
 		protected.Use(middleware.AuthMiddleware(cfg))
@@ -143,9 +193,18 @@ func main() {
 				strava.GET("/auth", stravaHandler.AuthURL)
 				strava.POST("/sync", stravaHandler.SyncActivities)
 				strava.GET("/activities", stravaHandler.GetActivities)
+				strava.GET("/backfill", stravaHandler.GetBackfill)
+				strava.POST("/backfill", stravaHandler.StartBackfill)
 				strava.DELETE("", stravaHandler.Disconnect)
 			}
 
+			// Garmin
+			garminRoutes := protected.Group("/garmin")
+			{
+				garminRoutes.GET("/auth", garminHandler.AuthURL)
+				garminRoutes.DELETE("", garminHandler.Disconnect)
+			}
+
 			// Race Goals
 			goals := protected.Group("/goals")
 			{
@@ -197,6 +256,9 @@ func main() {
 				profile.GET("/zones", profileHandler.GetTrainingZones)
 				profile.PUT("/zones", profileHandler.UpdateTrainingZones)
 				profile.POST("/zones/calculate", profileHandler.CalculateZones)
+
+				profile.GET("/source-priorities", profileHandler.GetSourcePriorities)
+				profile.PUT("/source-priorities", profileHandler.UpdateSourcePriorities)
 			}
 
 			// Training Calendar
@@ -208,15 +270,35 @@ func main() {
 				calendar.PUT("/entry/:id", calendarHandler.UpdateEntry)
 				calendar.DELETE("/entry/:id", calendarHandler.DeleteEntry)
 				calendar.PATCH("/entry/:id/status", calendarHandler.UpdateStatus)
+				calendar.POST("/taper/accept", calendarHandler.AcceptTaper)
 			}
 
 			// Activities
 			protected.POST("/activities", activitiesHandler.CreateActivity)
+			protected.POST("/activities/import", activitiesHandler.ImportActivities)
+			protected.POST("/activities/import/strava-archive", activitiesHandler.ImportStravaArchive)
+			protected.POST("/activities/import/apple-health", activitiesHandler.ImportAppleHealth)
+			protected.GET("/activities/import/jobs/:id", activitiesHandler.GetImportJob)
 			protected.GET("/activities", activitiesHandler.GetActivities)
+			protected.GET("/activities/duplicates", activitiesHandler.GetDuplicates)
+			protected.POST("/activities/duplicates/:id/merge", activitiesHandler.MergeDuplicate)
+			protected.POST("/activities/duplicates/:id/unmerge", activitiesHandler.UnmergeDuplicate)
+			protected.POST("/activities/duplicates/:id/dismiss", activitiesHandler.DismissDuplicate)
 			protected.DELETE("/activities/:id", activitiesHandler.DeleteActivity)
+			protected.GET("/activities/:id/streams", activitiesHandler.GetActivityStreams)
+			protected.GET("/activities/:id/zones", dashboardHandler.GetActivityZones)
+
+			// Connected data sources
+			protected.GET("/integrations", integrationsHandler.List)
+			protected.GET("/sync/history", integrationsHandler.SyncHistory)
+			protected.GET("/integrations/coros/auth", corosHandler.AuthURL)
+			protected.POST("/integrations/coros/sync", corosHandler.Sync)
+			protected.DELETE("/integrations/coros", corosHandler.Disconnect)
 
 			// Dashboard metrics
 			protected.GET("/dashboard", dashboardHandler.Get)
+			protected.GET("/dashboard/load-projection", dashboardHandler.GetLoadProjection)
+			protected.GET("/dashboard/taper", dashboardHandler.GetTaperProposal)
 
 			// Cross-training sessions
 			protected.GET("/crosstraining", crossTrainingHandler.List)
@@ -268,6 +350,7 @@ func main() {
 		}
 	case sig := <-quit:
 		log.Info("Received signal, shutting down", "signal", sig.String())
+		stopWorkers()
 		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 		defer cancel()
 		if err := srv.Shutdown(ctx); err != nil {
@@ -0,0 +1,79 @@
+// Package main manages the Strava push subscription for this API app.
+//
+// Usage:
+//
+//	go run ./cmd/strava-webhook            # register (or show) the subscription
+//	go run ./cmd/strava-webhook -delete    # remove it
+//
+// Strava allows a single subscription per application. Registration calls
+// back into STRAVA_WEBHOOK_CALLBACK_URL synchronously, so the API server must
+// be deployed with the same STRAVA_WEBHOOK_VERIFY_TOKEN before running this.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/joho/godotenv"
+
+	"github.com/korsana/backend/internal/config"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+func main() {
+	remove := flag.Bool("delete", false, "delete the existing subscription instead of creating one")
+	flag.Parse()
+
+	_ = godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		os.Exit(1)
+	}
+
+	log := logger.Init(cfg.Environment, nil)
+
+	if cfg.StravaWebhookVerifyToken == "" {
+		log.Error("STRAVA_WEBHOOK_VERIFY_TOKEN is not set")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	client := strava.NewClient(cfg.StravaClientID, cfg.StravaClientSecret, cfg.StravaRedirectURI)
+
+	subs, err := client.ListPushSubscriptions(ctx)
+	if err != nil {
+		log.Error("Failed to list subscriptions", "error", err)
+		os.Exit(1)
+	}
+
+	if *remove {
+		for _, sub := range subs {
+			if err := client.DeletePushSubscription(ctx, sub.ID); err != nil {
+				log.Error("Failed to delete subscription", "id", sub.ID, "error", err)
+				os.Exit(1)
+			}
+			log.Info("Deleted subscription", "id", sub.ID, "callback_url", sub.CallbackURL)
+		}
+		return
+	}
+
+	if len(subs) > 0 {
+		log.Info("Subscription already exists", "id", subs[0].ID, "callback_url", subs[0].CallbackURL)
+		return
+	}
+
+	sub, err := client.CreatePushSubscription(ctx, cfg.StravaWebhookCallbackURL, cfg.StravaWebhookVerifyToken)
+	if err != nil {
+		log.Error("Failed to create subscription", "error", err)
+		os.Exit(1)
+	}
+	log.Info("Subscription created", "id", sub.ID, "callback_url", sub.CallbackURL)
+}
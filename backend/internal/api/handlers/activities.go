@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"errors"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
@@ -10,16 +12,37 @@ import (
 	"github.com/korsana/backend/internal/services"
 )
 
+const (
+	// maxImportUploadBytes caps a whole import request. A multi-hour FIT
+	// file is a few megabytes.
+	maxImportUploadBytes = 64 << 20
+
+	// maxImportFiles caps the files in one import request.
+	maxImportFiles = 50
+
+	// maxArchiveUploadBytes caps a Strava bulk export upload. Years of
+	// gzipped FIT files fit well under it.
+	maxArchiveUploadBytes = 2 << 30
+)
+
 // ActivitiesHandler handles activity-related HTTP requests
 type ActivitiesHandler struct {
 	activityService *services.ActivityService
+	stravaService   *services.StravaService
+	importService   *services.ActivityImportService
 }
 
 // NewActivitiesHandler creates a new ActivitiesHandler
 func NewActivitiesHandler(
 	activityService *services.ActivityService,
+	stravaService *services.StravaService,
+	importService *services.ActivityImportService,
 ) *ActivitiesHandler {
-	return &ActivitiesHandler{activityService: activityService}
+	return &ActivitiesHandler{
+		activityService: activityService,
+		stravaService:   stravaService,
+		importService:   importService,
+	}
 }
 
 // CreateActivity handles POST /api/activities
@@ -103,3 +126,211 @@ func (h *ActivitiesHandler) DeleteActivity(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "activity deleted successfully"})
 }
+
+// GetActivityStreams handles GET /api/activities/:id/streams. Strava runs
+// synced before streams were imported (or past the per-sync cap) are fetched
+// on first request.
+func (h *ActivitiesHandler) GetActivityStreams(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	activityID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	streams, err := h.activityService.GetActivityStreams(ctx, userID, activityID)
+	if errors.Is(err, services.ErrActivityStreamsNotFound) && h.stravaService != nil {
+		if err = h.stravaService.FetchActivityStreams(ctx, userID, activityID); err == nil {
+			streams, err = h.activityService.GetActivityStreams(ctx, userID, activityID)
+		}
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrActivityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrActivityStreamsNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "no streams recorded for this activity"})
+		default:
+			RespondError(c, http.StatusInternalServerError, "failed to load activity streams", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"streams": streams})
+}
+
+// ImportActivities handles POST /api/activities/import. Accepts one or more
+// activity files in the multipart "files" field and reports the outcome
+// per activity; a file that can't be read doesn't fail the others.
+func (h *ActivitiesHandler) ImportActivities(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportUploadBytes)
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "upload must be multipart form data of at most 64 MB"})
+		return
+	}
+	files := form.File["files"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one file is required"})
+		return
+	}
+	if len(files) > maxImportFiles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many files; upload at most 50 at a time"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	results := make([]services.ActivityImportResult, 0, len(files))
+	imported := 0
+	for _, fh := range files {
+		data, err := readUpload(fh)
+		if err != nil {
+			results = append(results, services.ActivityImportResult{FileName: fh.Filename, Error: "failed to read file"})
+			continue
+		}
+		fileResults, err := h.importService.ImportFile(ctx, userID, fh.Filename, data)
+		if err != nil {
+			results = append(results, services.ActivityImportResult{FileName: fh.Filename, Error: err.Error()})
+			continue
+		}
+		for _, r := range fileResults {
+			if r.Stored() {
+				imported++
+			}
+		}
+		results = append(results, fileResults...)
+	}
+
+	if imported > 0 {
+		if err := h.importService.RefreshSummaries(ctx, userID); err != nil {
+			RespondError(c, http.StatusInternalServerError, "failed to refresh weekly summaries", err)
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"imported": imported,
+		"results":  results,
+	})
+}
+
+// ImportStravaArchive handles POST /api/activities/import/strava-archive.
+// Accepts the ZIP Strava emails from a bulk export request in the multipart
+// "archive" field and starts a background import; poll the returned job
+// for progress.
+func (h *ActivitiesHandler) ImportStravaArchive(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveUploadBytes)
+	fh, err := c.FormFile("archive")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "archive file is required (at most 2 GB)"})
+		return
+	}
+	src, err := fh.Open()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to read archive", err)
+		return
+	}
+	defer src.Close()
+
+	job, err := h.importService.StartStravaArchiveImport(c.Request.Context(), userID, fh.Filename, src)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStravaArchive):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImportInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			RespondError(c, http.StatusInternalServerError, "failed to start archive import", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// ImportAppleHealth handles POST /api/activities/import/apple-health.
+// Accepts the Health app's export ZIP, or the export.xml inside it, in the
+// multipart "export" field and starts a background import of workouts,
+// resting heart rate and HRV.
+func (h *ActivitiesHandler) ImportAppleHealth(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxArchiveUploadBytes)
+	fh, err := c.FormFile("export")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "export file is required (at most 2 GB)"})
+		return
+	}
+	src, err := fh.Open()
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to read export", err)
+		return
+	}
+	defer src.Close()
+
+	job, err := h.importService.StartAppleHealthImport(c.Request.Context(), userID, fh.Filename, src)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidAppleHealthExport):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrImportInProgress):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			RespondError(c, http.StatusInternalServerError, "failed to start Apple Health import", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"job": job})
+}
+
+// GetImportJob handles GET /api/activities/import/jobs/:id
+func (h *ActivitiesHandler) GetImportJob(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	jobID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	job, err := h.importService.GetImportJob(c.Request.Context(), userID, jobID)
+	if err != nil {
+		if errors.Is(err, services.ErrImportJobNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to load import job", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"job": job})
+}
+
+func readUpload(fh *multipart.FileHeader) ([]byte, error) {
+	f, err := fh.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/services"
+)
+
+// mergeDuplicateRequest is the body of POST /api/activities/duplicates/:id/merge.
+type mergeDuplicateRequest struct {
+	WinnerActivityID uuid.UUID `json:"winner_activity_id" binding:"required"`
+}
+
+// GetDuplicates handles GET /api/activities/duplicates
+func (h *ActivitiesHandler) GetDuplicates(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	pairs, err := h.activityService.ListDuplicates(c.Request.Context(), userID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to list duplicates", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicates": pairs})
+}
+
+// MergeDuplicate handles POST /api/activities/duplicates/:id/merge. The
+// other activity of the pair is hidden behind winner_activity_id.
+func (h *ActivitiesHandler) MergeDuplicate(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	pairID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	var req mergeDuplicateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pair, err := h.activityService.MergeDuplicate(c.Request.Context(), userID, pairID, req.WinnerActivityID)
+	if err != nil {
+		respondDuplicateError(c, "failed to merge activities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicate": pair})
+}
+
+// UnmergeDuplicate handles POST /api/activities/duplicates/:id/unmerge
+func (h *ActivitiesHandler) UnmergeDuplicate(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	pairID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	pair, err := h.activityService.UnmergeDuplicate(c.Request.Context(), userID, pairID)
+	if err != nil {
+		respondDuplicateError(c, "failed to unmerge activities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicate": pair})
+}
+
+// DismissDuplicate handles POST /api/activities/duplicates/:id/dismiss,
+// which marks the pair as two separate activities.
+func (h *ActivitiesHandler) DismissDuplicate(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	pairID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	pair, err := h.activityService.DismissDuplicate(c.Request.Context(), userID, pairID)
+	if err != nil {
+		respondDuplicateError(c, "failed to dismiss duplicate", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"duplicate": pair})
+}
+
+func respondDuplicateError(c *gin.Context, msg string, err error) {
+	switch {
+	case errors.Is(err, services.ErrDuplicateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrInvalidDuplicateWinner):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrDuplicateResolved), errors.Is(err, services.ErrDuplicateNotMerged):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		RespondError(c, http.StatusInternalServerError, msg, err)
+	}
+}
@@ -1,22 +1,26 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/metrics"
 	"github.com/korsana/backend/internal/models"
 	"github.com/korsana/backend/internal/services"
 )
 
 type CalendarHandler struct {
 	calendarService *services.CalendarService
+	metricsService  *services.MetricsService
 }
 
-func NewCalendarHandler(calendarService *services.CalendarService) *CalendarHandler {
+func NewCalendarHandler(calendarService *services.CalendarService, metricsService *services.MetricsService) *CalendarHandler {
 	return &CalendarHandler{
 		calendarService: calendarService,
+		metricsService:  metricsService,
 	}
 }
 
@@ -292,3 +296,69 @@ func (h *CalendarHandler) UpdateStatus(c *gin.Context) {
 		"entry": entry,
 	})
 }
+
+type acceptTaperRequest struct {
+	// TSBMin and TSBMax are the window the taper was proposed for,
+	// defaulting as for GET /api/dashboard/taper.
+	TSBMin  *float64              `json:"tsb_min"`
+	TSBMax  *float64              `json:"tsb_max"`
+	Changes []metrics.TaperChange `json:"changes" binding:"required"`
+}
+
+// AcceptTaper applies a taper proposal if it still matches the server's
+// own, recomputed for the same window; a stale proposal gets 409 Conflict
+// POST /api/calendar/taper/accept
+func (h *CalendarHandler) AcceptTaper(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req acceptTaperRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	tsbMin, tsbMax := metrics.DefaultTaperTSBMin, metrics.DefaultTaperTSBMax
+	if req.TSBMin != nil {
+		tsbMin = *req.TSBMin
+	}
+	if req.TSBMax != nil {
+		tsbMax = *req.TSBMax
+	}
+	if !validTaperWindow(tsbMin, tsbMax) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": taperWindowError})
+		return
+	}
+
+	proposal, err := h.metricsService.ProposeTaper(c.Request.Context(), userID, tsbMin, tsbMax)
+	if errors.Is(err, services.ErrNoUpcomingRace) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to propose taper", err)
+		return
+	}
+	// The plan or training load moved since the client's proposal, or the
+	// changes were edited; either way the athlete should see the new one.
+	if !proposal.Matches(req.Changes) {
+		c.JSON(http.StatusConflict, gin.H{"error": "taper proposal has changed, review the new one"})
+		return
+	}
+
+	err = h.calendarService.ApplyTaper(c.Request.Context(), userID, proposal.Changes)
+	switch {
+	case errors.Is(err, services.ErrInvalidTaperChange):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, services.ErrTaperStale):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		RespondError(c, http.StatusInternalServerError, "failed to apply taper", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"updated": len(proposal.Changes)})
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/korsana/backend/internal/services"
+)
+
+// CorosHandler handles COROS connect, sync and disconnect requests
+type CorosHandler struct {
+	corosService *services.CorosService
+	frontendURL  string
+}
+
+// NewCorosHandler creates a new CorosHandler
+func NewCorosHandler(corosService *services.CorosService, frontendURL string) *CorosHandler {
+	return &CorosHandler{
+		corosService: corosService,
+		frontendURL:  frontendURL,
+	}
+}
+
+// AuthURL handles GET /api/integrations/coros/auth. Accepts the same
+// ?return_to= as the Strava flow.
+func (h *CorosHandler) AuthURL(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if returnTo != "" && !strings.HasPrefix(returnTo, "/") {
+		returnTo = ""
+	}
+
+	url, err := h.corosService.GetAuthURL(c.Request.Context(), userID, returnTo)
+	if err != nil {
+		if errors.Is(err, services.ErrCorosNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "COROS is not available yet"})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to generate auth URL", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// Callback handles the redirect from COROS after the user approves access.
+func (h *CorosHandler) Callback(c *gin.Context) {
+	code := c.Query("code")
+	state := c.Query("state")
+
+	if code == "" {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?coros_error=missing_code")
+		return
+	}
+	if state == "" {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?coros_error=missing_state")
+		return
+	}
+
+	st, err := h.corosService.ValidateOAuthState(c.Request.Context(), state)
+	if err != nil {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?coros_error=invalid_state")
+		return
+	}
+
+	dest := "/settings"
+	if strings.HasPrefix(st.ReturnTo, "/") {
+		dest = st.ReturnTo
+	}
+
+	if err := h.corosService.HandleCallback(c.Request.Context(), st.UserID, code); err != nil {
+		errParam := "connection_failed"
+		if errors.Is(err, services.ErrCorosAlreadyConnected) {
+			errParam = "already_connected"
+		}
+		c.Redirect(http.StatusFound, h.frontendURL+withRedirectParam(dest, "coros_error", errParam))
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.frontendURL+withRedirectParam(dest, "coros_connected", "true"))
+}
+
+// Sync handles POST /api/integrations/coros/sync
+func (h *CorosHandler) Sync(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 90*time.Second)
+	defer cancel()
+
+	result, err := h.corosService.SyncActivities(ctx, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrCorosConnectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "COROS is not connected. Connect it from Settings first."})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to sync COROS activities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// Disconnect handles DELETE /api/integrations/coros
+func (h *CorosHandler) Disconnect(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.corosService.DisconnectCoros(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrCorosConnectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "COROS is not connected"})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to disconnect COROS", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "COROS disconnected successfully"})
+}
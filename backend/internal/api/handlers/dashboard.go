@@ -1,10 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/korsana/backend/internal/metrics"
 	"github.com/korsana/backend/internal/services"
 )
 
@@ -33,3 +36,92 @@ func (h *DashboardHandler) Get(c *gin.Context) {
 
 	c.JSON(http.StatusOK, data)
 }
+
+// GetActivityZones handles GET /api/activities/:id/zones
+func (h *DashboardHandler) GetActivityZones(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	activityID, ok := ParseUUIDParam(c, "id")
+	if !ok {
+		return
+	}
+
+	zones, err := h.metricsService.ActivityTimeInZones(c.Request.Context(), userID, activityID)
+	if errors.Is(err, services.ErrActivityNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to compute time in zone", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"zones": zones})
+}
+
+// GetLoadProjection handles GET /api/dashboard/load-projection
+func (h *DashboardHandler) GetLoadProjection(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	projection, err := h.metricsService.ProjectLoad(c.Request.Context(), userID)
+	if errors.Is(err, services.ErrNoUpcomingRace) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to project training load", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, projection)
+}
+
+// GetTaperProposal handles GET /api/dashboard/taper?tsb_min=5&tsb_max=20
+func (h *DashboardHandler) GetTaperProposal(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	tsbMin, errMin := floatQuery(c, "tsb_min", metrics.DefaultTaperTSBMin)
+	tsbMax, errMax := floatQuery(c, "tsb_max", metrics.DefaultTaperTSBMax)
+	if errMin != nil || errMax != nil || !validTaperWindow(tsbMin, tsbMax) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": taperWindowError})
+		return
+	}
+
+	proposal, err := h.metricsService.ProposeTaper(c.Request.Context(), userID, tsbMin, tsbMax)
+	if errors.Is(err, services.ErrNoUpcomingRace) {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to propose taper", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, proposal)
+}
+
+const taperWindowError = "tsb_min and tsb_max must be numbers between -50 and 50, min not above max"
+
+// validTaperWindow reports whether a race-morning TSB window is one the
+// taper optimizer accepts.
+func validTaperWindow(tsbMin, tsbMax float64) bool {
+	return tsbMin <= tsbMax && tsbMin >= -50 && tsbMax <= 50
+}
+
+// floatQuery parses an optional numeric query parameter.
+func floatQuery(c *gin.Context, key string, def float64) (float64, error) {
+	raw := c.Query(key)
+	if raw == "" {
+		return def, nil
+	}
+	return strconv.ParseFloat(raw, 64)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/korsana/backend/internal/services"
+	"github.com/korsana/backend/pkg/garmin"
+)
+
+// GarminHandler handles Garmin Connect requests
+type GarminHandler struct {
+	garminService     *services.GarminService
+	frontendURL       string
+	notificationToken string
+}
+
+// NewGarminHandler creates a new GarminHandler
+func NewGarminHandler(garminService *services.GarminService, frontendURL, notificationToken string) *GarminHandler {
+	return &GarminHandler{
+		garminService:     garminService,
+		frontendURL:       frontendURL,
+		notificationToken: notificationToken,
+	}
+}
+
+// AuthURL handles GET /api/garmin/auth. Accepts the same ?return_to= as the
+// Strava flow.
+func (h *GarminHandler) AuthURL(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	returnTo := c.Query("return_to")
+	if returnTo != "" && !strings.HasPrefix(returnTo, "/") {
+		returnTo = ""
+	}
+
+	url, err := h.garminService.GetAuthURL(c.Request.Context(), userID, returnTo)
+	if err != nil {
+		if errors.Is(err, services.ErrGarminNotConfigured) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Garmin is not available yet"})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to generate auth URL", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+// Callback handles the redirect from Garmin after the user approves access.
+func (h *GarminHandler) Callback(c *gin.Context) {
+	code := c.Query("code")
+	state := c.Query("state")
+
+	if code == "" {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?garmin_error=missing_code")
+		return
+	}
+	if state == "" {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?garmin_error=missing_state")
+		return
+	}
+
+	st, err := h.garminService.ValidateOAuthState(c.Request.Context(), state)
+	if err != nil {
+		c.Redirect(http.StatusFound, h.frontendURL+"/settings?garmin_error=invalid_state")
+		return
+	}
+
+	dest := "/settings"
+	if strings.HasPrefix(st.ReturnTo, "/") {
+		dest = st.ReturnTo
+	}
+
+	if err := h.garminService.HandleCallback(c.Request.Context(), st.UserID, code, st.CodeVerifier); err != nil {
+		errParam := "connection_failed"
+		if errors.Is(err, services.ErrGarminAlreadyConnected) {
+			errParam = "already_connected"
+		}
+		c.Redirect(http.StatusFound, h.frontendURL+withRedirectParam(dest, "garmin_error", errParam))
+		return
+	}
+
+	c.Redirect(http.StatusFound, h.frontendURL+withRedirectParam(dest, "garmin_connected", "true"))
+}
+
+// Disconnect handles DELETE /api/garmin
+func (h *GarminHandler) Disconnect(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.garminService.DisconnectGarmin(c.Request.Context(), userID); err != nil {
+		if errors.Is(err, services.ErrGarminConnectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Garmin is not connected"})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to disconnect Garmin", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Garmin disconnected successfully"})
+}
+
+// ReceiveNotification accepts a Garmin ping or push notification. Records
+// are only queued here; the notification worker pulls and stores the data.
+// A 5xx makes Garmin retry the notification.
+func (h *GarminHandler) ReceiveNotification(c *gin.Context) {
+	if h.notificationToken == "" ||
+		subtle.ConstantTimeCompare([]byte(c.Query("token")), []byte(h.notificationToken)) != 1 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "notifications not enabled"})
+		return
+	}
+
+	var notification garmin.Notification
+	if err := c.ShouldBindJSON(&notification); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid notification payload"})
+		return
+	}
+
+	if err := h.garminService.EnqueueNotification(c.Request.Context(), notification); err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to queue notification", err)
+		return
+	}
+
+	c.Status(http.StatusOK)
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/korsana/backend/internal/services"
+)
+
+// IntegrationsHandler handles connected data source requests
+type IntegrationsHandler struct {
+	integrationsService *services.IntegrationsService
+}
+
+// NewIntegrationsHandler creates a new IntegrationsHandler
+func NewIntegrationsHandler(integrationsService *services.IntegrationsService) *IntegrationsHandler {
+	return &IntegrationsHandler{integrationsService: integrationsService}
+}
+
+// List handles GET /api/integrations
+func (h *IntegrationsHandler) List(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	integrations, err := h.integrationsService.ListIntegrations(c.Request.Context(), userID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to load integrations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"integrations": integrations})
+}
+
+// SyncHistory handles GET /api/sync/history?provider=&page=&per_page=
+func (h *IntegrationsHandler) SyncHistory(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	p := ParsePagination(c)
+	runs, total, err := h.integrationsService.ListSyncRuns(c.Request.Context(), userID, c.Query("provider"), p.PerPage, p.Offset)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to load sync history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"runs":     runs,
+		"page":     p.Page,
+		"per_page": p.PerPage,
+		"total":    total,
+	})
+}
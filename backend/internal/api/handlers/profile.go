@@ -9,6 +9,7 @@ import (
 
 	"github.com/korsana/backend/internal/models"
 	"github.com/korsana/backend/internal/services"
+	"github.com/korsana/backend/internal/services/sync"
 )
 
 type ProfileHandler struct {
@@ -52,7 +53,7 @@ func (h *ProfileHandler) GetProfile(c *gin.Context) {
 	conn, err := h.stravaService.GetConnection(c.Request.Context(), userID)
 	if err == nil && conn != nil {
 		stravaConnected = true
-		stravaAthleteID = conn.StravaAthleteID
+		stravaAthleteID = services.StravaAthleteID(conn)
 	}
 
 	var goalInfo map[string]any
@@ -432,6 +433,49 @@ func (h *ProfileHandler) UpdateTrainingZones(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "training zones updated"})
 }
 
+// GetSourcePriorities returns which source the user prefers for each field
+// of an activity recorded by several sources.
+func (h *ProfileHandler) GetSourcePriorities(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	priorities, err := h.userProfileService.GetSourcePriorities(c.Request.Context(), userID)
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to load source priorities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"priorities": priorities, "fields": sync.Fields})
+}
+
+// UpdateSourcePriorities replaces the user's source priorities.
+func (h *ProfileHandler) UpdateSourcePriorities(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	var req services.SourcePriorities
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	priorities, err := h.userProfileService.UpdateSourcePriorities(c.Request.Context(), userID, req)
+	if errors.Is(err, services.ErrInvalidSourcePriority) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to save source priorities", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"priorities": priorities, "fields": sync.Fields})
+}
+
 // UpdateEmail verifies the current password then changes the user's email.
 func (h *ProfileHandler) UpdateEmail(c *gin.Context) {
 	userID, ok := RequireUserID(c)
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"net/http"
 	"net/url"
@@ -12,6 +13,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/korsana/backend/internal/services"
+	"github.com/korsana/backend/pkg/strava"
 )
 
 type StravaHandler struct {
@@ -20,6 +22,7 @@ type StravaHandler struct {
 	userProfileService  *services.UserProfileService
 	notificationService *services.NotificationService
 	frontendURL         string
+	webhookVerifyToken  string
 }
 
 func NewStravaHandler(
@@ -28,6 +31,7 @@ func NewStravaHandler(
 	userProfileService *services.UserProfileService,
 	notificationService *services.NotificationService,
 	frontendURL string,
+	webhookVerifyToken string,
 ) *StravaHandler {
 	return &StravaHandler{
 		stravaService:       stravaService,
@@ -35,6 +39,7 @@ func NewStravaHandler(
 		userProfileService:  userProfileService,
 		notificationService: notificationService,
 		frontendURL:         frontendURL,
+		webhookVerifyToken:  webhookVerifyToken,
 	}
 }
 
@@ -89,7 +94,7 @@ func (h *StravaHandler) Callback(c *gin.Context) {
 		dest = returnTo
 	}
 
-	if err = h.stravaService.HandleCallback(c.Request.Context(), userID, code); err != nil {
+	if err = h.stravaService.HandleCallback(c.Request.Context(), userID, code, c.Query("scope")); err != nil {
 		errParam := "connection_failed"
 		if errors.Is(err, services.ErrStravaAlreadyConnected) {
 			errParam = "already_connected"
@@ -179,6 +184,70 @@ func (h *StravaHandler) maybeSendSyncFailureNotification(c *gin.Context, userID
 	}
 }
 
+// StartBackfill queues (or restarts) the full-history import for the
+// authenticated user's Strava connection.
+func (h *StravaHandler) StartBackfill(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.stravaService.StartBackfill(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrStravaConnectionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Strava is not connected. Connect it from Settings first."})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to start backfill", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetBackfill reports progress of the full-history import.
+func (h *StravaHandler) GetBackfill(c *gin.Context) {
+	userID, ok := RequireUserID(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.stravaService.GetBackfill(c.Request.Context(), userID)
+	if err != nil {
+		if errors.Is(err, services.ErrStravaBackfillNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no backfill has been started"})
+			return
+		}
+		RespondError(c, http.StatusInternalServerError, "failed to get backfill status", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// RateLimitHealth reports the app-wide Strava rate-limit budget: "ok",
+// "low" once background work is held back, "exhausted" when requests are
+// refused until a window resets, or "unknown" before Strava has reported it.
+func (h *StravaHandler) RateLimitHealth(c *gin.Context) {
+	budget, err := h.stravaService.RateLimitBudget(c.Request.Context())
+	if err != nil {
+		RespondError(c, http.StatusServiceUnavailable, "failed to read Strava rate-limit budget", err)
+		return
+	}
+	if budget == nil || budget.UpdatedAt == nil {
+		c.JSON(http.StatusOK, gin.H{"status": "unknown"})
+		return
+	}
+
+	status := "ok"
+	if exhausted, _ := budget.Exhausted(); exhausted {
+		status = "exhausted"
+	} else if !budget.AllowBackground() {
+		status = "low"
+	}
+	c.JSON(http.StatusOK, gin.H{"status": status, "budget": budget})
+}
+
 // GetActivities retrieves the user's synced activities with pagination.
 func (h *StravaHandler) GetActivities(c *gin.Context) {
 	userID, ok := RequireUserID(c)
@@ -211,17 +280,70 @@ func (h *StravaHandler) GetActivities(c *gin.Context) {
 	})
 }
 
-// Disconnect removes the Strava connection for the authenticated user.
+// Disconnect revokes and removes the Strava connection for the
+// authenticated user. ?purge=delete removes their Strava data and
+// ?purge=anonymize scrubs it; by default it is kept.
 func (h *StravaHandler) Disconnect(c *gin.Context) {
 	userID, ok := RequireUserID(c)
 	if !ok {
 		return
 	}
 
-	if err := h.stravaService.DisconnectStrava(c.Request.Context(), userID); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disconnect Strava"})
+	result, err := h.stravaService.DisconnectStrava(c.Request.Context(), userID, c.Query("purge"))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidStravaPurge):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "purge must be one of none, delete or anonymize"})
+		case errors.Is(err, services.ErrStravaConnectionNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Strava is not connected"})
+		default:
+			RespondError(c, http.StatusInternalServerError, "failed to disconnect Strava", err)
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Strava disconnected successfully",
+		"purge":      result.Purge,
+		"deleted":    result.Deleted,
+		"rebuilt":    result.Rebuilt,
+		"anonymized": result.Anonymized,
+	})
+}
+
+// VerifyWebhook answers Strava's subscription handshake by echoing
+// hub.challenge when hub.verify_token matches the configured token.
+func (h *StravaHandler) VerifyWebhook(c *gin.Context) {
+	token := c.Query("hub.verify_token")
+	if h.webhookVerifyToken == "" || c.Query("hub.mode") != "subscribe" ||
+		subtle.ConstantTimeCompare([]byte(token), []byte(h.webhookVerifyToken)) != 1 {
+		c.JSON(http.StatusForbidden, gin.H{"error": "invalid verify token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"hub.challenge": c.Query("hub.challenge")})
+}
+
+// ReceiveWebhook accepts a Strava push event. Strava expects a 200 within two
+// seconds, so the event is only queued here; the webhook worker does the
+// import. A 5xx makes Strava redeliver, which is what we want if the insert
+// failed.
+func (h *StravaHandler) ReceiveWebhook(c *gin.Context) {
+	if h.webhookVerifyToken == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not enabled"})
+		return
+	}
+
+	var event strava.WebhookEvent
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid webhook payload"})
+		return
+	}
+
+	if err := h.stravaService.EnqueueWebhookEvent(c.Request.Context(), event); err != nil {
+		RespondError(c, http.StatusInternalServerError, "failed to queue webhook event", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Strava disconnected successfully"})
+	c.Status(http.StatusOK)
 }
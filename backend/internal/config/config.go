@@ -3,6 +3,7 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
 )
 
@@ -26,6 +27,30 @@ type Config struct {
 	StravaClientSecret string
 	StravaRedirectURI  string
 
+	// Strava webhook subscription. Without a verify token the webhook
+	// endpoint rejects the subscription handshake and sync stays poll-only.
+	StravaWebhookVerifyToken string
+	StravaWebhookCallbackURL string
+
+	// Garmin Connect (Health API). Optional: without client credentials
+	// the Garmin endpoints report the integration as unavailable. The
+	// notification token is appended as ?token= to the endpoint URL
+	// registered with Garmin, since Garmin does not sign notifications.
+	GarminClientID          string
+	GarminClientSecret      string
+	GarminRedirectURI       string
+	GarminNotificationToken string
+
+	// COROS Open API. Optional, like Garmin.
+	CorosClientID     string
+	CorosClientSecret string
+	CorosRedirectURI  string
+
+	// OAuth token encryption keyring: comma-separated id=base64 pairs of
+	// 32-byte AES keys, active key first. Required in production; without
+	// it tokens are stored as plaintext. See cmd/rotate-tokens.
+	TokenEncryptionKeys string
+
 	// AI Provider APIs (use either Claude or Gemini)
 	ClaudeAPIKey string
 	GeminiAPIKey string
@@ -44,6 +69,11 @@ type Config struct {
 	// CORS
 	AllowedOrigins string
 
+	// Training load: run-equivalence factor overrides per sport, parsed
+	// from a comma-separated sport=factor list such as
+	// "cycling=0.8,swimming=0.5". Sports left out keep their defaults.
+	RunEquivalence map[string]float64
+
 	// Environment
 	Environment string // "development", "production"
 }
@@ -54,30 +84,45 @@ type Config struct {
 // request that depends on the value.
 func Load() (*Config, error) {
 	cfg := &Config{
-		Port:                   getEnv("PORT", "8080"),
-		DatabaseURL:            getEnv("DATABASE_URL", ""),
-		RedisURL:               getEnv("REDIS_URL", "redis://localhost:6379"),
-		SupabaseURL:            getEnv("SUPABASE_URL", ""),
-		SupabaseServiceRoleKey: getEnv("SUPABASE_SERVICE_ROLE_KEY", ""),
-		StravaClientID:         getEnv("STRAVA_CLIENT_ID", ""),
-		StravaClientSecret:     getEnv("STRAVA_CLIENT_SECRET", ""),
-		StravaRedirectURI:      getEnv("STRAVA_REDIRECT_URI", "http://localhost:8080/api/strava/callback"),
-		ClaudeAPIKey:           getEnv("CLAUDE_API_KEY", ""),
-		GeminiAPIKey:           getEnv("GEMINI_API_KEY", ""),
-		FrontendURL:            getEnv("FRONTEND_URL", "http://localhost:5174"),
-		SMTPHost:               getEnv("SMTP_HOST", ""),
-		SMTPPort:               getEnv("SMTP_PORT", "587"),
-		SMTPUsername:           getEnv("SMTP_USERNAME", ""),
-		SMTPPassword:           getEnv("SMTP_PASSWORD", ""),
-		SMTPFromEmail:          getEnv("SMTP_FROM_EMAIL", ""),
-		SMTPFromName:           getEnv("SMTP_FROM_NAME", "Korsana"),
-		AllowedOrigins:         getEnv("ALLOWED_ORIGINS", "http://localhost:5174"),
-		Environment:            getEnv("ENVIRONMENT", "development"),
+		Port:                     getEnv("PORT", "8080"),
+		DatabaseURL:              getEnv("DATABASE_URL", ""),
+		RedisURL:                 getEnv("REDIS_URL", "redis://localhost:6379"),
+		SupabaseURL:              getEnv("SUPABASE_URL", ""),
+		SupabaseServiceRoleKey:   getEnv("SUPABASE_SERVICE_ROLE_KEY", ""),
+		StravaClientID:           getEnv("STRAVA_CLIENT_ID", ""),
+		StravaClientSecret:       getEnv("STRAVA_CLIENT_SECRET", ""),
+		StravaRedirectURI:        getEnv("STRAVA_REDIRECT_URI", "http://localhost:8080/api/strava/callback"),
+		StravaWebhookVerifyToken: getEnv("STRAVA_WEBHOOK_VERIFY_TOKEN", ""),
+		StravaWebhookCallbackURL: getEnv("STRAVA_WEBHOOK_CALLBACK_URL", "http://localhost:8080/api/strava/webhook"),
+		GarminClientID:           getEnv("GARMIN_CLIENT_ID", ""),
+		GarminClientSecret:       getEnv("GARMIN_CLIENT_SECRET", ""),
+		GarminRedirectURI:        getEnv("GARMIN_REDIRECT_URI", "http://localhost:8080/api/garmin/callback"),
+		GarminNotificationToken:  getEnv("GARMIN_NOTIFICATION_TOKEN", ""),
+		CorosClientID:            getEnv("COROS_CLIENT_ID", ""),
+		CorosClientSecret:        getEnv("COROS_CLIENT_SECRET", ""),
+		CorosRedirectURI:         getEnv("COROS_REDIRECT_URI", "http://localhost:8080/api/integrations/coros/callback"),
+		TokenEncryptionKeys:      getEnv("TOKEN_ENCRYPTION_KEYS", ""),
+		ClaudeAPIKey:             getEnv("CLAUDE_API_KEY", ""),
+		GeminiAPIKey:             getEnv("GEMINI_API_KEY", ""),
+		FrontendURL:              getEnv("FRONTEND_URL", "http://localhost:5174"),
+		SMTPHost:                 getEnv("SMTP_HOST", ""),
+		SMTPPort:                 getEnv("SMTP_PORT", "587"),
+		SMTPUsername:             getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:             getEnv("SMTP_PASSWORD", ""),
+		SMTPFromEmail:            getEnv("SMTP_FROM_EMAIL", ""),
+		SMTPFromName:             getEnv("SMTP_FROM_NAME", "Korsana"),
+		AllowedOrigins:           getEnv("ALLOWED_ORIGINS", "http://localhost:5174"),
+		Environment:              getEnv("ENVIRONMENT", "development"),
 	}
 
 	if err := cfg.validate(); err != nil {
 		return nil, err
 	}
+	factors, err := parseRunEquivalence(getEnv("RUN_EQUIVALENCE_FACTORS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("RUN_EQUIVALENCE_FACTORS: %w", err)
+	}
+	cfg.RunEquivalence = factors
 	return cfg, nil
 }
 
@@ -107,12 +152,39 @@ func (c *Config) validate() error {
 		missing = append(missing, "CLAUDE_API_KEY or GEMINI_API_KEY (at least one)")
 	}
 
+	if c.Environment == "production" && strings.TrimSpace(c.TokenEncryptionKeys) == "" {
+		missing = append(missing, "TOKEN_ENCRYPTION_KEYS (required in production)")
+	}
+
 	if len(missing) > 0 {
 		return fmt.Errorf("missing required environment variables: %s", strings.Join(missing, ", "))
 	}
 	return nil
 }
 
+// parseRunEquivalence parses a comma-separated list of sport=factor pairs.
+// Factors must lie between 0 and 2.
+func parseRunEquivalence(raw string) (map[string]float64, error) {
+	factors := make(map[string]float64)
+	for _, pair := range strings.Split(raw, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		sport, value, ok := strings.Cut(pair, "=")
+		sport = strings.TrimSpace(sport)
+		if !ok || sport == "" {
+			return nil, fmt.Errorf("expected sport=factor, got %q", pair)
+		}
+		f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || f < 0 || f > 2 {
+			return nil, fmt.Errorf("invalid factor for %s: %q", sport, value)
+		}
+		factors[sport] = f
+	}
+	return factors, nil
+}
+
 // getEnv returns the value of an environment variable or a default value
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
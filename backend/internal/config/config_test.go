@@ -0,0 +1,71 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseRunEquivalence(t *testing.T) {
+	tests := []struct {
+		name    string
+		raw     string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{name: "unset", raw: "", want: map[string]float64{}},
+		{name: "single", raw: "cycling=0.8", want: map[string]float64{"cycling": 0.8}},
+		{name: "spaces and empty pairs", raw: " cycling = 0.8 ,, swimming=0.5, ", want: map[string]float64{"cycling": 0.8, "swimming": 0.5}},
+		{name: "bounds", raw: "walking=0,elliptical=2", want: map[string]float64{"walking": 0, "elliptical": 2}},
+		{name: "later pair wins", raw: "cycling=0.8,cycling=0.6", want: map[string]float64{"cycling": 0.6}},
+		{name: "missing equals", raw: "cycling0.8", wantErr: true},
+		{name: "missing sport", raw: "=0.8", wantErr: true},
+		{name: "missing factor", raw: "cycling=", wantErr: true},
+		{name: "not a number", raw: "cycling=fast", wantErr: true},
+		{name: "negative", raw: "cycling=-0.1", wantErr: true},
+		{name: "above two", raw: "cycling=2.5", wantErr: true},
+		{name: "one bad pair", raw: "cycling=0.8,swimming", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRunEquivalence(tt.raw)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsMalformedRunEquivalence(t *testing.T) {
+	t.Setenv("DATABASE_URL", "postgres://localhost/korsana")
+	t.Setenv("SUPABASE_URL", "https://example.supabase.co")
+	t.Setenv("SUPABASE_SERVICE_ROLE_KEY", "service-role")
+	t.Setenv("STRAVA_CLIENT_ID", "1")
+	t.Setenv("STRAVA_CLIENT_SECRET", "secret")
+	t.Setenv("GEMINI_API_KEY", "key")
+	t.Setenv("ENVIRONMENT", "development")
+
+	t.Setenv("RUN_EQUIVALENCE_FACTORS", "cycling=0.8,swimming=lots")
+	if _, err := Load(); err == nil || !strings.Contains(err.Error(), "RUN_EQUIVALENCE_FACTORS") {
+		t.Fatalf("expected a RUN_EQUIVALENCE_FACTORS error, got %v", err)
+	}
+
+	t.Setenv("RUN_EQUIVALENCE_FACTORS", "cycling=0.8")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cfg.RunEquivalence["cycling"] != 0.8 {
+		t.Fatalf("expected cycling=0.8, got %v", cfg.RunEquivalence)
+	}
+}
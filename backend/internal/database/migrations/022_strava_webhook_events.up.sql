@@ -0,0 +1,28 @@
+-- Strava push subscription events.
+-- The webhook handler only records the event and returns 200 (Strava
+-- requires a reply within 2 seconds); a background worker claims pending
+-- rows and does the actual fetch/upsert/delete. The unique key drops
+-- Strava's redeliveries so each event is processed once.
+
+CREATE TABLE IF NOT EXISTS strava_webhook_events (
+    id              UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    object_type     VARCHAR(20) NOT NULL,
+    object_id       BIGINT      NOT NULL,
+    aspect_type     VARCHAR(20) NOT NULL,
+    owner_id        BIGINT      NOT NULL,
+    subscription_id BIGINT,
+    event_time      BIGINT      NOT NULL,
+    updates         JSONB       NOT NULL DEFAULT '{}'::jsonb,
+    status          VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts        INTEGER     NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    available_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_at      TIMESTAMPTZ,
+    received_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at    TIMESTAMPTZ,
+    UNIQUE (object_type, object_id, aspect_type, owner_id, event_time)
+);
+
+CREATE INDEX IF NOT EXISTS idx_strava_webhook_events_pending
+    ON strava_webhook_events (available_at)
+    WHERE status IN ('pending', 'processing');
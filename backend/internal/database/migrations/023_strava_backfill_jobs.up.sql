@@ -0,0 +1,39 @@
+-- Full-history Strava backfill.
+-- The interactive sync only pulls the newest few pages, and later
+-- incremental syncs stop at the most recent stored activity, so older
+-- history was never reached. One job per connection walks
+-- /athlete/activities backwards from a fixed `before` anchor, one page at a
+-- time, persisting the next page so a restart resumes where it left off.
+
+CREATE TABLE IF NOT EXISTS strava_backfill_jobs (
+    id                   UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    connection_id        UUID        NOT NULL UNIQUE REFERENCES strava_connections(id) ON DELETE CASCADE,
+    user_id              UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status               VARCHAR(20) NOT NULL DEFAULT 'pending',
+    cursor_before        TIMESTAMPTZ NOT NULL,
+    next_page            INTEGER     NOT NULL DEFAULT 1,
+    pages_fetched        INTEGER     NOT NULL DEFAULT 0,
+    activities_imported  INTEGER     NOT NULL DEFAULT 0,
+    oldest_activity_at   TIMESTAMPTZ,
+    consecutive_failures INTEGER     NOT NULL DEFAULT 0,
+    last_error           TEXT,
+    next_run_at          TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_at           TIMESTAMPTZ,
+    started_at           TIMESTAMPTZ,
+    completed_at         TIMESTAMPTZ,
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at           TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_strava_backfill_jobs_user_id
+    ON strava_backfill_jobs (user_id);
+
+CREATE INDEX IF NOT EXISTS idx_strava_backfill_jobs_due
+    ON strava_backfill_jobs (next_run_at)
+    WHERE status IN ('pending', 'running');
+
+-- Existing connections get a job too, so athletes who connected before
+-- this migration have their older history imported without reconnecting.
+INSERT INTO strava_backfill_jobs (connection_id, user_id, cursor_before)
+SELECT id, user_id, NOW() FROM strava_connections
+ON CONFLICT (connection_id) DO NOTHING;
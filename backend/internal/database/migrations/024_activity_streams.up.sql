@@ -0,0 +1,26 @@
+-- Per-sample activity data (one row per activity).
+-- Metrics such as time-in-zone and aerobic decoupling need the
+-- second-by-second series, not the per-activity averages stored on
+-- activities. Every array is index-aligned with time_offsets; a stream the
+-- device didn't record is NULL. latlng is JSONB ([[lat, lng], ...]) because
+-- Postgres arrays can't hold ragged pairs cleanly.
+
+CREATE TABLE IF NOT EXISTS activity_streams (
+    activity_id     UUID             PRIMARY KEY REFERENCES activities(id) ON DELETE CASCADE,
+    user_id         UUID             NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    source          VARCHAR(50)      NOT NULL,
+    sample_count    INTEGER          NOT NULL,
+    time_offsets    INTEGER[]        NOT NULL,
+    distance_meters DOUBLE PRECISION[],
+    heart_rate      INTEGER[],
+    velocity_mps    DOUBLE PRECISION[],
+    cadence         INTEGER[],
+    altitude_meters DOUBLE PRECISION[],
+    latlng          JSONB,
+    moving          BOOLEAN[],
+    created_at      TIMESTAMPTZ      NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ      NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_streams_user_id
+    ON activity_streams (user_id);
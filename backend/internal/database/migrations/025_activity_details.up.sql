@@ -0,0 +1,49 @@
+-- Detailed activity data from Strava's /activities/{id} endpoint.
+-- workout_type is Strava's athlete-set flag ("race", "long_run",
+-- "workout"; NULL for a default run). details_synced_at marks activities
+-- whose detail payload (laps, splits, calories, device, description) has
+-- been imported, so enrichment only fetches each activity once.
+
+ALTER TABLE activities
+    ADD COLUMN IF NOT EXISTS workout_type      VARCHAR(20),
+    ADD COLUMN IF NOT EXISTS calories          DOUBLE PRECISION,
+    ADD COLUMN IF NOT EXISTS device_name       VARCHAR(255),
+    ADD COLUMN IF NOT EXISTS gear_id           VARCHAR(50),
+    ADD COLUMN IF NOT EXISTS description       TEXT,
+    ADD COLUMN IF NOT EXISTS details_synced_at TIMESTAMPTZ;
+
+CREATE TABLE IF NOT EXISTS activity_laps (
+    id                    UUID             PRIMARY KEY DEFAULT gen_random_uuid(),
+    activity_id           UUID             NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    lap_index             INTEGER          NOT NULL,
+    name                  VARCHAR(255),
+    distance_meters       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    elapsed_seconds       INTEGER          NOT NULL DEFAULT 0,
+    moving_seconds        INTEGER          NOT NULL DEFAULT 0,
+    start_time            TIMESTAMPTZ,
+    average_speed_mps     DOUBLE PRECISION,
+    max_speed_mps         DOUBLE PRECISION,
+    average_heart_rate    DOUBLE PRECISION,
+    max_heart_rate        DOUBLE PRECISION,
+    average_cadence       DOUBLE PRECISION,
+    elevation_gain_meters DOUBLE PRECISION,
+    pace_zone             INTEGER,
+    UNIQUE (activity_id, lap_index)
+);
+
+-- unit is 'metric' (per km) or 'standard' (per mile).
+CREATE TABLE IF NOT EXISTS activity_splits (
+    id                              UUID             PRIMARY KEY DEFAULT gen_random_uuid(),
+    activity_id                     UUID             NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    unit                            VARCHAR(10)      NOT NULL,
+    split_index                     INTEGER          NOT NULL,
+    distance_meters                 DOUBLE PRECISION NOT NULL DEFAULT 0,
+    elapsed_seconds                 INTEGER          NOT NULL DEFAULT 0,
+    moving_seconds                  INTEGER          NOT NULL DEFAULT 0,
+    elevation_difference_meters     DOUBLE PRECISION,
+    average_speed_mps               DOUBLE PRECISION,
+    average_grade_adjusted_speed_mps DOUBLE PRECISION,
+    average_heart_rate              DOUBLE PRECISION,
+    pace_zone                       INTEGER,
+    UNIQUE (activity_id, unit, split_index)
+);
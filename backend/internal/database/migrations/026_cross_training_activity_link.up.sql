@@ -0,0 +1,24 @@
+-- Link mirrored cross-training sessions to the activity they were created
+-- from, so any source can upsert its mirror and deleting the activity
+-- removes it. The old partial unique index on strava_activity_id could not
+-- be used as an ON CONFLICT target without repeating its predicate.
+
+ALTER TABLE cross_training_sessions
+    ADD COLUMN IF NOT EXISTS activity_id UUID REFERENCES activities(id) ON DELETE CASCADE;
+
+UPDATE cross_training_sessions c
+SET activity_id = a.id
+FROM activities a
+WHERE c.activity_id IS NULL
+  AND c.strava_activity_id IS NOT NULL
+  AND a.user_id = c.user_id
+  AND a.source = 'strava'
+  AND a.source_activity_id = c.strava_activity_id;
+
+-- Strava mirrors whose activity is gone are stale, and would block the
+-- strava_activity_id index if the activity were imported again.
+DELETE FROM cross_training_sessions
+WHERE activity_id IS NULL AND strava_activity_id IS NOT NULL;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_cross_training_activity_id
+    ON cross_training_sessions (activity_id);
@@ -0,0 +1,69 @@
+-- Move Strava tokens into connected_integrations so every source shares
+-- one store. external_user_id holds the Strava athlete ID as text.
+-- status tracks whether the tokens still work ('active') or the athlete
+-- has to reconnect ('reauth_required'); last_error explains the latter.
+
+ALTER TABLE connected_integrations
+    ADD COLUMN IF NOT EXISTS status     VARCHAR(20) NOT NULL DEFAULT 'active',
+    ADD COLUMN IF NOT EXISTS last_error TEXT,
+    ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
+
+-- An external account can only be linked to one user.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_connected_integrations_source_external
+    ON connected_integrations (source, external_user_id)
+    WHERE external_user_id IS NOT NULL;
+
+-- strava_connections allowed several athletes per user, but an integration
+-- holds one. Stop rather than choose between them: the extra connections
+-- have to be resolved by hand before this migration can run.
+DO $$
+DECLARE
+    dupes INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO dupes FROM (
+        SELECT user_id FROM strava_connections
+        WHERE user_id IS NOT NULL
+        GROUP BY user_id HAVING COUNT(*) > 1
+    ) d;
+    IF dupes > 0 THEN
+        RAISE EXCEPTION '% users have more than one strava_connections row; keep one per user before migrating', dupes;
+    END IF;
+END $$;
+
+-- Copy each user's Strava connection, keeping its ID so backfill jobs stay
+-- attached. A user's first integration becomes primary.
+INSERT INTO connected_integrations (
+    id, user_id, source, access_token, refresh_token, token_expires_at,
+    external_user_id, is_active, is_primary, connected_at, last_synced_at, updated_at
+)
+SELECT
+    sc.id, sc.user_id, 'strava', sc.access_token, sc.refresh_token, sc.token_expires_at,
+    sc.strava_athlete_id::text, true,
+    NOT EXISTS (
+        SELECT 1 FROM connected_integrations ci
+        WHERE ci.user_id = sc.user_id AND ci.is_primary
+    ),
+    COALESCE(sc.created_at, NOW()),
+    (SELECT MAX(a.synced_at) FROM activities a
+     WHERE a.user_id = sc.user_id AND a.source = 'strava'),
+    COALESCE(sc.updated_at, NOW())
+FROM strava_connections sc
+WHERE sc.user_id IS NOT NULL
+ON CONFLICT (user_id, source) DO NOTHING;
+
+-- Re-point backfill jobs at the integration rows, dropping jobs whose
+-- connection was not carried over because the user already had a Strava
+-- integration.
+ALTER TABLE strava_backfill_jobs
+    DROP CONSTRAINT IF EXISTS strava_backfill_jobs_connection_id_fkey;
+
+DELETE FROM strava_backfill_jobs j
+WHERE NOT EXISTS (
+    SELECT 1 FROM connected_integrations ci WHERE ci.id = j.connection_id
+);
+
+ALTER TABLE strava_backfill_jobs
+    ADD CONSTRAINT strava_backfill_jobs_connection_id_fkey
+    FOREIGN KEY (connection_id) REFERENCES connected_integrations(id) ON DELETE CASCADE;
+
+-- strava_connections stays until 037 has checked the copy.
@@ -0,0 +1,24 @@
+-- Garmin Health API notifications (ping or push).
+-- Garmin retries a notification that isn't acknowledged quickly, so the
+-- handler only queues each record and a background worker pulls/stores the
+-- activities. record_hash drops redelivered records.
+
+CREATE TABLE IF NOT EXISTS garmin_notifications (
+    id             UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    summary_type   VARCHAR(40) NOT NULL,
+    garmin_user_id TEXT        NOT NULL,
+    record         JSONB       NOT NULL,
+    record_hash    CHAR(64)    NOT NULL,
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts       INTEGER     NOT NULL DEFAULT 0,
+    last_error     TEXT,
+    available_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    claimed_at     TIMESTAMPTZ,
+    received_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    processed_at   TIMESTAMPTZ,
+    UNIQUE (summary_type, record_hash)
+);
+
+CREATE INDEX IF NOT EXISTS idx_garmin_notifications_pending
+    ON garmin_notifications (available_at)
+    WHERE status IN ('pending', 'processing');
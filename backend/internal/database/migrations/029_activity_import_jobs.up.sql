@@ -0,0 +1,32 @@
+-- Asynchronous imports of uploaded archives (the Strava bulk export).
+-- The archive is kept on the receiving server's disk while the job runs,
+-- so a job is not resumable: a running job whose progress stops updating
+-- is failed and the athlete uploads again. row_errors is a capped list of
+-- {row, activity_id, error} objects.
+
+CREATE TABLE IF NOT EXISTS activity_import_jobs (
+    id             UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id        UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    kind           VARCHAR(30) NOT NULL,
+    file_name      TEXT        NOT NULL,
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending',
+    total_rows     INTEGER     NOT NULL DEFAULT 0,
+    processed_rows INTEGER     NOT NULL DEFAULT 0,
+    imported_rows  INTEGER     NOT NULL DEFAULT 0,
+    skipped_rows   INTEGER     NOT NULL DEFAULT 0,
+    failed_rows    INTEGER     NOT NULL DEFAULT 0,
+    row_errors     JSONB       NOT NULL DEFAULT '[]',
+    last_error     TEXT,
+    started_at     TIMESTAMPTZ,
+    completed_at   TIMESTAMPTZ,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_import_jobs_user_id
+    ON activity_import_jobs (user_id, created_at DESC);
+
+-- One archive import in flight per athlete.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_activity_import_jobs_active
+    ON activity_import_jobs (user_id, kind)
+    WHERE status IN ('pending', 'running');
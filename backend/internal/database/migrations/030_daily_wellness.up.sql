@@ -0,0 +1,17 @@
+-- Daily wellness values from health platforms (Apple Health first).
+-- One row per athlete, calendar date and source; the date is the athlete's
+-- local date when the samples were recorded. resting_heart_rate and
+-- hrv_sdnn_ms are daily means of the source's samples, NULL when the
+-- source had none that day.
+
+CREATE TABLE IF NOT EXISTS daily_wellness (
+    id                 UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id            UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    date               DATE         NOT NULL,
+    source             VARCHAR(50)  NOT NULL,
+    resting_heart_rate INTEGER,
+    hrv_sdnn_ms        NUMERIC(6,1),
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (user_id, date, source)
+);
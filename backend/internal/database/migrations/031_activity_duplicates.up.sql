@@ -0,0 +1,37 @@
+-- Suspected duplicate activities for the athlete to review.
+-- Ingest only merges activities automatically when they start within two
+-- minutes and their distance is within 5%. Looser near-misses, and every
+-- manual entry, are recorded here as pairs. The pair is stored with the
+-- lower ID first so each pair has one row. A dismissed pair is never
+-- suggested again.
+--
+-- Merging hides the losing activity by setting merged_into instead of
+-- deleting it, so an unmerge can restore it with its laps and streams.
+-- The calendar entries and personal records moved to the winner are kept
+-- on the pair so they can be moved back.
+
+ALTER TABLE activities
+    ADD COLUMN IF NOT EXISTS merged_into UUID REFERENCES activities(id) ON DELETE SET NULL;
+
+CREATE TABLE IF NOT EXISTS activity_duplicates (
+    id                        UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id                   UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    activity_id               UUID         NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    duplicate_activity_id     UUID         NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    status                    VARCHAR(20)  NOT NULL DEFAULT 'pending',
+    start_delta_seconds       INTEGER      NOT NULL,
+    winner_activity_id        UUID,
+    moved_calendar_entry_ids  UUID[],
+    moved_personal_record_ids UUID[],
+    gear_copied               BOOLEAN      NOT NULL DEFAULT false,
+    resolved_at               TIMESTAMPTZ,
+    created_at                TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at                TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    UNIQUE (activity_id, duplicate_activity_id),
+    CHECK (activity_id < duplicate_activity_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_activity_duplicates_user_status
+    ON activity_duplicates (user_id, status);
+CREATE INDEX IF NOT EXISTS idx_activity_duplicates_duplicate_activity_id
+    ON activity_duplicates (duplicate_activity_id);
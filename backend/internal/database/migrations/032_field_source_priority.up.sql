@@ -0,0 +1,32 @@
+-- Field-level source merging.
+-- activity_source_records keeps each source's copy of an activity as the
+-- normalized sync payload, so one activity row can take each field group
+-- from the source the athlete prefers for it. The row's own source is
+-- still the best source overall. field_sources records which source
+-- supplied each field group, e.g. {"name": "strava", "heart_rate": "garmin"}.
+--
+-- user_source_priorities holds an athlete's ordered source list per field
+-- group, plus a 'default' list for the row itself and for fields without
+-- their own list. Sources missing from a list rank after it in the global
+-- order.
+
+ALTER TABLE activities
+    ADD COLUMN IF NOT EXISTS field_sources JSONB NOT NULL DEFAULT '{}';
+
+CREATE TABLE IF NOT EXISTS activity_source_records (
+    activity_id        UUID         NOT NULL REFERENCES activities(id) ON DELETE CASCADE,
+    source             VARCHAR(50)  NOT NULL,
+    source_activity_id VARCHAR(255) NOT NULL,
+    data               JSONB        NOT NULL,
+    created_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    updated_at         TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (activity_id, source, source_activity_id)
+);
+
+CREATE TABLE IF NOT EXISTS user_source_priorities (
+    user_id    UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    field      VARCHAR(30)  NOT NULL,
+    sources    TEXT[]       NOT NULL,
+    updated_at TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, field)
+);
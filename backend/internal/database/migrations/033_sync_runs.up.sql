@@ -0,0 +1,27 @@
+-- One row per sync attempt against a provider, successful or not, so an
+-- athlete (or support) can see why activities are missing without the
+-- server logs. mode is incremental, initial_backfill or webhook.
+-- error_class buckets the failure: rate_limited, token_revoked,
+-- not_connected, database, provider or unknown. The counts follow
+-- sync.Decision: updated covers updates, upgrades and field merges.
+
+CREATE TABLE IF NOT EXISTS sync_runs (
+    id            UUID         PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id       UUID         NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider      VARCHAR(50)  NOT NULL,
+    mode          VARCHAR(30)  NOT NULL,
+    status        VARCHAR(20)  NOT NULL,
+    pages_fetched INTEGER      NOT NULL DEFAULT 0,
+    inserted      INTEGER      NOT NULL DEFAULT 0,
+    updated       INTEGER      NOT NULL DEFAULT 0,
+    skipped       INTEGER      NOT NULL DEFAULT 0,
+    failed        INTEGER      NOT NULL DEFAULT 0,
+    error_class   VARCHAR(30),
+    error_message TEXT,
+    started_at    TIMESTAMPTZ  NOT NULL,
+    finished_at   TIMESTAMPTZ  NOT NULL,
+    duration_ms   BIGINT       NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_user_started
+    ON sync_runs(user_id, started_at DESC);
@@ -0,0 +1,9 @@
+-- The sync scheduler picks the connection that has gone longest without a
+-- sync run and checks Strava's quota by counting today's runs, so both
+-- lookups need sync_runs indexed by provider.
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_user_provider_started
+    ON sync_runs(user_id, provider, started_at DESC);
+
+CREATE INDEX IF NOT EXISTS idx_sync_runs_provider_started
+    ON sync_runs(provider, started_at);
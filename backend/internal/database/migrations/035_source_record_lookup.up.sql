@@ -0,0 +1,7 @@
+-- A re-imported activity is matched to the row holding its copy by source
+-- ID, and reconciliation looks rows up the same way, so source records
+-- need an index that doesn't start with activity_id. sync_runs gains the
+-- reconcile mode, which needs no schema change.
+
+CREATE INDEX IF NOT EXISTS idx_activity_source_records_source_id
+    ON activity_source_records(source, source_activity_id);
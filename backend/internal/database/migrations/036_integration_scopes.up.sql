@@ -0,0 +1,8 @@
+-- OAuth scopes the athlete granted, as the provider reported them when
+-- they connected. Strava lets athletes untick scopes on its consent
+-- screen, and without activity:read_all private activities are missing
+-- from its API, so reconciliation must not take them for deleted. NULL on
+-- connections made before scopes were recorded, which reconciliation
+-- treats as lacking activity:read_all until the athlete reconnects.
+
+ALTER TABLE connected_integrations ADD COLUMN IF NOT EXISTS granted_scopes TEXT;
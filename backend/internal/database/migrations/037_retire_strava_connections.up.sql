@@ -0,0 +1,28 @@
+-- Retire strava_connections now that 027 has copied it into
+-- connected_integrations. Every connection must have arrived, for the same
+-- user and athlete, before the table is set aside. It is renamed rather
+-- than dropped so the tokens can still be recovered; drop
+-- strava_connections_legacy by hand once the move has been verified.
+
+DO $$
+DECLARE
+    missing INTEGER;
+BEGIN
+    IF to_regclass('strava_connections') IS NULL THEN
+        RETURN;
+    END IF;
+    SELECT COUNT(*) INTO missing
+    FROM strava_connections sc
+    WHERE sc.user_id IS NOT NULL
+      AND NOT EXISTS (
+        SELECT 1 FROM connected_integrations ci
+        WHERE ci.user_id = sc.user_id
+          AND ci.source = 'strava'
+          AND ci.external_user_id = sc.strava_athlete_id::text
+      );
+    IF missing > 0 THEN
+        RAISE EXCEPTION '% strava_connections rows have no matching connected_integrations row', missing;
+    END IF;
+END $$;
+
+ALTER TABLE IF EXISTS strava_connections RENAME TO strava_connections_legacy;
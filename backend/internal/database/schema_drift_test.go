@@ -28,9 +28,19 @@ var schemaTargets = []struct {
 	model any
 }{
 	{"users", models.User{}},
-	{"strava_connections", models.StravaConnection{}},
+	{"strava_webhook_events", models.StravaWebhookEvent{}},
+	{"strava_backfill_jobs", models.StravaBackfillJob{}},
+	{"garmin_notifications", models.GarminNotification{}},
+	{"activity_import_jobs", models.ActivityImportJob{}},
 	{"race_goals", models.RaceGoal{}},
 	{"activities", models.Activity{}},
+	{"activity_duplicates", models.ActivityDuplicate{}},
+	{"activity_source_records", models.ActivitySourceRecord{}},
+	{"user_source_priorities", models.UserSourcePriority{}},
+	{"sync_runs", models.SyncRun{}},
+	{"activity_streams", models.ActivityStreams{}},
+	{"activity_laps", models.ActivityLap{}},
+	{"activity_splits", models.ActivitySplit{}},
 	{"connected_integrations", models.ConnectedIntegration{}},
 	{"coach_sessions", models.CoachSession{}},
 	{"coach_conversations", models.CoachConversation{}},
@@ -42,6 +52,7 @@ var schemaTargets = []struct {
 	{"integration_interest_requests", models.IntegrationInterestRequest{}},
 	{"personal_records", models.PersonalRecord{}},
 	{"training_zones", models.TrainingZone{}},
+	{"daily_wellness", models.DailyWellness{}},
 }
 
 // TestSchemaDrift asserts every db: tag on every registered model struct
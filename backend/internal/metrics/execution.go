@@ -1,8 +1,11 @@
 package metrics
 
 import (
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/korsana/backend/internal/models"
 )
 
@@ -21,7 +24,11 @@ type ExecutionResult struct {
 }
 
 // ExecutionScores computes planned vs actual execution scores for the last 30 days.
-func ExecutionScores(activities []models.Activity, entries []models.CalendarEntry) ExecutionResult {
+// Effort is judged against the athlete's zones: heart rate when the run
+// recorded it, pace otherwise. lapsByActivity is optional; when a tempo or
+// interval session has laps, its effort is judged on the work reps rather
+// than an average diluted by the warm-up, recoveries and cool-down.
+func ExecutionScores(activities []models.Activity, entries []models.CalendarEntry, lapsByActivity map[uuid.UUID][]models.ActivityLap, zones AthleteZones) ExecutionResult {
 	cutoff := time.Now().AddDate(0, 0, -30)
 
 	activityByID := make(map[string]*models.Activity)
@@ -58,14 +65,31 @@ func ExecutionScores(activities []models.Activity, entries []models.CalendarEntr
 			score += 20
 		}
 
+		effortHR := 0
 		if act.AverageHeartRate != nil {
-			hrScore := estimateZoneScore(entry.WorkoutType, *act.AverageHeartRate)
-			score += hrScore
-			if hrScore < 30 && issue == "" {
-				issue = "Effort off target"
+			effortHR = *act.AverageHeartRate
+		}
+		effortPace := act.AveragePaceSecondsPerKm
+		if isQualityWorkout(entry.WorkoutType) {
+			work := WorkLaps(lapsByActivity[act.ID])
+			if hr := LapsHeartRate(work); hr > 0 {
+				effortHR = int(hr)
+			}
+			if pace := lapsPace(work); pace > 0 {
+				effortPace = pace
 			}
-		} else {
-			score += 30
+		}
+
+		effortScore := 30.0
+		switch {
+		case effortHR > 0:
+			effortScore = estimateZoneScore(zones, entry.WorkoutType, effortHR)
+		case effortPace > 0:
+			effortScore = estimatePaceZoneScore(zones, entry.WorkoutType, effortPace)
+		}
+		score += effortScore
+		if effortScore < 30 && issue == "" {
+			issue = "Effort off target"
 		}
 
 		if score > 100 {
@@ -96,22 +120,39 @@ func ExecutionScores(activities []models.Activity, entries []models.CalendarEntr
 	}
 }
 
-func estimateZoneScore(workoutType string, avgHR int) float64 {
-	expectedHR := 0
-	switch workoutType {
-	case "Easy", "Recovery":
-		expectedHR = 135
-	case "Long Run", "Long":
-		expectedHR = 140
-	case "Tempo":
-		expectedHR = 155
-	case "Intervals":
-		expectedHR = 168
+// isQualityWorkout reports whether a calendar workout type has distinct work
+// reps. Calendar types are lower-case; older entries used title case.
+func isQualityWorkout(workoutType string) bool {
+	switch strings.ToLower(workoutType) {
+	case "tempo", "interval", "intervals":
+		return true
+	}
+	return false
+}
+
+// workoutZone is the zone a calendar workout type is run in.
+func workoutZone(workoutType string) int {
+	switch strings.ToLower(workoutType) {
+	case "tempo":
+		return 3
+	case "interval", "intervals":
+		return 4
 	default:
-		expectedHR = 140
+		return 2
 	}
+}
 
-	diff := absFloat(float64(avgHR - expectedHR))
+// estimateZoneScore scores an average heart rate against the middle of the
+// athlete's zone for the workout. Easy and recovery runs aim for the bottom
+// of the aerobic zone.
+func estimateZoneScore(zones AthleteZones, workoutType string, avgHR int) float64 {
+	expectedHR := zones.hrZoneTarget(workoutZone(workoutType))
+	switch strings.ToLower(workoutType) {
+	case "easy", "recovery":
+		expectedHR = float64(zones.complete().HR[1].Min)
+	}
+
+	diff := absFloat(float64(avgHR) - expectedHR)
 	if diff <= 5 {
 		return 60
 	} else if diff <= 10 {
@@ -121,3 +162,21 @@ func estimateZoneScore(workoutType string, avgHR int) float64 {
 	}
 	return 15
 }
+
+// estimatePaceZoneScore scores an average pace (sec/km) by how many of the
+// athlete's pace zones it landed from the workout's.
+func estimatePaceZoneScore(zones AthleteZones, workoutType string, paceSecPerKm float64) float64 {
+	off := zones.PaceZone(paceSecPerKm) - workoutZone(workoutType)
+	if off < 0 {
+		off = -off
+	}
+	switch off {
+	case 0:
+		return 60
+	case 1:
+		return 45
+	case 2:
+		return 30
+	}
+	return 15
+}
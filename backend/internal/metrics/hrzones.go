@@ -1,8 +1,12 @@
 package metrics
 
 import (
+	"fmt"
+	"strings"
 	"time"
 
+	"github.com/google/uuid"
+
 	"github.com/korsana/backend/internal/models"
 )
 
@@ -19,22 +23,30 @@ type HRZone struct {
 type HRZonesResult struct {
 	Zones        []HRZone `json:"zones"`
 	Z1Z2Combined float64  `json:"z1z2_combined"`
+	// HardHRThreshold is the heart rate from which a run counts as hard.
+	HardHRThreshold int `json:"hard_hr_threshold"`
+	// FromStreams counts the runs bucketed from heart-rate streams rather
+	// than their average.
+	FromStreams int `json:"from_streams"`
 }
 
-// HRZoneDistribution computes time in each HR zone for the last 7 days.
-func HRZoneDistribution(activities []models.Activity) HRZonesResult {
+// HRZoneDistribution computes time in each of the athlete's HR zones for
+// the last 7 days. Runs with a heart-rate stream are bucketed sample by
+// sample; the rest fall back to crediting their whole duration to the zone
+// of their average heart rate.
+func HRZoneDistribution(activities []models.Activity, streams map[uuid.UUID]*models.ActivityStreams, athlete AthleteZones) HRZonesResult {
 	cutoff := time.Now().AddDate(0, 0, -7)
+	fromStreams := 0
 
-	zones := []HRZone{
-		{Name: "Z1 Easy", BPM: "< 130"},
-		{Name: "Z2 Aerobic", BPM: "130-148"},
-		{Name: "Z3 Tempo", BPM: "148-162"},
-		{Name: "Z4 Threshold", BPM: "162-174"},
-		{Name: "Z5 Max", BPM: "174+"},
+	athlete = athlete.complete()
+	zones := make([]HRZone, len(athlete.HR))
+	for i, z := range athlete.HR {
+		zones[i] = HRZone{
+			Name: strings.TrimSpace(fmt.Sprintf("Z%d %s", z.Number, z.Label)),
+			BPM:  athlete.hrRangeLabel(i),
+		}
 	}
 
-	thresholds := []int{0, 130, 148, 162, 174, 999}
-
 	for _, a := range activities {
 		if a.ActivityType != models.ActivityTypeRun {
 			continue
@@ -42,17 +54,12 @@ func HRZoneDistribution(activities []models.Activity) HRZonesResult {
 		if a.StartTime.Before(cutoff) {
 			continue
 		}
-		if a.AverageHeartRate == nil {
-			continue
+		zt := ActivityTimeInZones(a, streams[a.ID], athlete)
+		for i, secs := range zt.HR {
+			zones[i].Minutes += secs / 60.0
 		}
-		hr := *a.AverageHeartRate
-		durationMin := float64(a.DurationSeconds) / 60.0
-
-		for i := range zones {
-			if hr >= thresholds[i] && hr < thresholds[i+1] {
-				zones[i].Minutes += durationMin
-				break
-			}
+		if zt.HRSource == ZoneTimeFromStreams {
+			fromStreams++
 		}
 	}
 
@@ -74,7 +81,9 @@ func HRZoneDistribution(activities []models.Activity) HRZonesResult {
 	zones[1].InRange = z1z2 >= 80
 
 	return HRZonesResult{
-		Zones:        zones,
-		Z1Z2Combined: z1z2,
+		Zones:           zones,
+		Z1Z2Combined:    z1z2,
+		HardHRThreshold: athlete.HardHR(),
+		FromStreams:     fromStreams,
 	}
 }
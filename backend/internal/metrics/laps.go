@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"github.com/korsana/backend/internal/models"
+)
+
+// workLapMinMeters ignores short laps (a lap button pressed at a crossing,
+// the stub at the end of a run) when picking out work reps.
+const workLapMinMeters = 200
+
+// workLapSpeedFactor is how much faster than the session's time-weighted
+// mean a lap must be to count as a work rep.
+const workLapSpeedFactor = 1.05
+
+// WorkLaps returns the laps that look like the hard part of a session:
+// at least 200 m and 5% faster than the time-weighted mean speed. Returns
+// nil when the session has fewer than two usable laps.
+func WorkLaps(laps []models.ActivityLap) []models.ActivityLap {
+	var totalDist, totalSecs float64
+	usable := 0
+	for _, lap := range laps {
+		if lap.DistanceMeters < workLapMinMeters || lap.MovingSeconds <= 0 {
+			continue
+		}
+		totalDist += lap.DistanceMeters
+		totalSecs += float64(lap.MovingSeconds)
+		usable++
+	}
+	if usable < 2 || totalSecs == 0 {
+		return nil
+	}
+	meanSpeed := totalDist / totalSecs
+
+	var work []models.ActivityLap
+	for _, lap := range laps {
+		if lap.DistanceMeters < workLapMinMeters || lap.MovingSeconds <= 0 {
+			continue
+		}
+		if lap.DistanceMeters/float64(lap.MovingSeconds) >= meanSpeed*workLapSpeedFactor {
+			work = append(work, lap)
+		}
+	}
+	return work
+}
+
+// LapsHeartRate is the time-weighted average heart rate across laps, or 0
+// if none of them recorded heart rate.
+func LapsHeartRate(laps []models.ActivityLap) float64 {
+	var weighted, secs float64
+	for _, lap := range laps {
+		if lap.AverageHeartRate == nil || lap.MovingSeconds <= 0 {
+			continue
+		}
+		weighted += *lap.AverageHeartRate * float64(lap.MovingSeconds)
+		secs += float64(lap.MovingSeconds)
+	}
+	if secs == 0 {
+		return 0
+	}
+	return weighted / secs
+}
+
+// lapsPace is the pace in sec/km across laps, or 0 if they cover no
+// distance.
+func lapsPace(laps []models.ActivityLap) float64 {
+	var dist, secs float64
+	for _, lap := range laps {
+		dist += lap.DistanceMeters
+		secs += float64(lap.MovingSeconds)
+	}
+	if dist == 0 {
+		return 0
+	}
+	return secs / (dist / 1000)
+}
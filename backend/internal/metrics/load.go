@@ -1,11 +1,17 @@
 package metrics
 
 import (
+	"math"
 	"time"
 
 	"github.com/korsana/backend/internal/models"
 )
 
+// DefaultLoadRestingHR is the resting heart rate TRIMP assumes for athletes
+// who haven't given or measured one. It predates the shared zone defaults
+// and is kept so that loads, fitness and form already shown don't shift.
+const DefaultLoadRestingHR = 55
+
 // LoadResult holds ATL/CTL/TSB and related fields.
 type LoadResult struct {
 	ATL       float64     `json:"atl"`
@@ -15,6 +21,9 @@ type LoadResult struct {
 	LoadRatio float64     `json:"load_ratio"`
 	RiskLevel string      `json:"risk_level"`
 	History   []LoadPoint `json:"history"`
+	// SportLoad is the run-equivalent load over the 42-day history, by
+	// sport, showing what drives fitness and fatigue.
+	SportLoad map[string]float64 `json:"sport_load"`
 }
 
 // LoadPoint is a single day entry in the ATL/CTL history.
@@ -32,40 +41,51 @@ type InjuryRiskResult struct {
 	MileageJumpScore float64 `json:"mileage_jump_score"`
 	LoadRatioScore   float64 `json:"load_ratio_score"`
 	ConsecutiveScore float64 `json:"consecutive_score"`
+	// HardHRThreshold is the average heart rate from which a run counts
+	// toward the hard-day signal.
+	HardHRThreshold int `json:"hard_hr_threshold"`
 }
 
-// CalculateATLCTL computes acute/chronic training load using TRIMP.
-func CalculateATLCTL(activities []models.Activity, restingHR, maxHR float64) LoadResult {
+// CalculateATLCTL computes acute/chronic training load from every sport
+// the athlete trains: activities of any type plus hand-logged
+// cross-training sessions, each scored with SessionLoad and scaled by its
+// run-equivalence factor so the result stays in running terms.
+func CalculateATLCTL(activities []models.Activity, manual []ManualSession, restingHR, maxHR float64, equiv RunEquivalence) LoadResult {
 	if maxHR == 0 {
-		maxHR = 190
+		maxHR = DefaultMaxHR
 	}
 	if restingHR == 0 {
-		restingHR = 55
+		restingHR = DefaultLoadRestingHR
 	}
 
 	today := time.Now().Truncate(24 * time.Hour)
 	cutoff := today.AddDate(0, 0, -84)
+	historyStart := today.AddDate(0, 0, -42)
 
 	dailyTSS := make(map[string]float64)
+	sportLoad := make(map[string]float64)
 	for _, a := range activities {
-		if a.ActivityType != models.ActivityTypeRun {
-			continue
-		}
 		if a.StartTime.Before(cutoff) {
 			continue
 		}
-		hr := float64(0)
-		if a.AverageHeartRate != nil {
-			hr = float64(*a.AverageHeartRate)
+		tss := activityLoad(a, restingHR, maxHR, equiv)
+		dailyTSS[a.StartTime.Format("2006-01-02")] += tss
+		if !a.StartTime.Before(historyStart) {
+			sportLoad[normalizeSport(a.ActivityType)] += tss
 		}
-		if hr == 0 {
-			durationMin := float64(a.DurationSeconds) / 60.0
-			dailyTSS[a.StartTime.Format("2006-01-02")] += durationMin * 0.5
+	}
+	for _, m := range manual {
+		if m.Date.Before(cutoff) {
 			continue
 		}
-		durationMin := float64(a.DurationSeconds) / 60.0
-		tss := CalculateTRIMP(hr, durationMin, restingHR, maxHR)
-		dailyTSS[a.StartTime.Format("2006-01-02")] += tss
+		tss := manualSessionLoad(m, restingHR, maxHR, equiv)
+		dailyTSS[m.Date.Format("2006-01-02")] += tss
+		if !m.Date.Before(historyStart) {
+			sportLoad[normalizeSport(m.Type)] += tss
+		}
+	}
+	for sport, load := range sportLoad {
+		sportLoad[sport] = round2(load)
 	}
 
 	var seedTotal float64
@@ -113,6 +133,7 @@ func CalculateATLCTL(activities []models.Activity, restingHR, maxHR float64) Loa
 		LoadRatio: loadRatio,
 		RiskLevel: riskLevel(loadRatio),
 		History:   history,
+		SportLoad: sportLoad,
 	}
 }
 
@@ -140,8 +161,9 @@ func riskLevel(ratio float64) string {
 	}
 }
 
-// InjuryRisk computes a composite injury risk score (0-100).
-func InjuryRisk(activities []models.Activity, atl, ctl float64) InjuryRiskResult {
+// InjuryRisk computes a composite injury risk score (0-100). Hard days are
+// runs averaging at least the athlete's threshold (Z4) heart rate.
+func InjuryRisk(activities []models.Activity, zones AthleteZones, atl, ctl float64) InjuryRiskResult {
 	today := time.Now()
 	cutoff4w := today.AddDate(0, 0, -28)
 
@@ -191,6 +213,7 @@ func InjuryRisk(activities []models.Activity, atl, ctl float64) InjuryRiskResult
 		}
 	}
 
+	hardHR := zones.HardHR()
 	hardDays := 0
 	for _, a := range activities {
 		if a.ActivityType != models.ActivityTypeRun {
@@ -199,7 +222,7 @@ func InjuryRisk(activities []models.Activity, atl, ctl float64) InjuryRiskResult
 		if !a.StartTime.After(cutoff4w) {
 			continue
 		}
-		if a.AverageHeartRate != nil && *a.AverageHeartRate > 162 {
+		if a.AverageHeartRate != nil && *a.AverageHeartRate >= hardHR {
 			hardDays++
 		}
 	}
@@ -236,11 +259,14 @@ func InjuryRisk(activities []models.Activity, atl, ctl float64) InjuryRiskResult
 		MileageJumpScore: round2(jumpScore),
 		LoadRatioScore:   round2(loadRatioScore),
 		ConsecutiveScore: round2(consScore),
+		HardHRThreshold:  hardHR,
 	}
 }
 
+// round2 rounds to two decimals, half away from zero, so negative TSB
+// rounds the same way as positive.
 func round2(v float64) float64 {
-	return float64(int(v*100+0.5)) / 100
+	return math.Round(v*100) / 100
 }
 
 func absFloat(x float64) float64 {
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"strings"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// maxProjectionDays bounds how far ahead ProjectLoad steps the model.
+const maxProjectionDays = 365
+
+// ProjectedLoadPoint is one future day of projected training load.
+type ProjectedLoadPoint struct {
+	Date string `json:"date"`
+	// PlannedLoad is the run-equivalent load estimated for the day's
+	// planned sessions.
+	PlannedLoad float64 `json:"planned_load"`
+	ATL         float64 `json:"atl"`
+	CTL         float64 `json:"ctl"`
+	TSB         float64 `json:"tsb"`
+}
+
+// LoadProjection is the ATL/CTL/TSB path from today to race morning if the
+// athlete completes the planned calendar.
+type LoadProjection struct {
+	RaceDate   string `json:"race_date"`
+	DaysToRace int    `json:"days_to_race"`
+	// Points runs from today to the day before the race.
+	Points []ProjectedLoadPoint `json:"points"`
+	// RaceMorning is form after the last day before the race.
+	RaceMorning LoadResult `json:"race_morning"`
+	// LowestTSB is the deepest fatigue on the way there.
+	LowestTSB     float64 `json:"lowest_tsb"`
+	LowestTSBDate string  `json:"lowest_tsb_date"`
+	// PlannedSessions counts the calendar entries that were projected.
+	PlannedSessions int `json:"planned_sessions"`
+}
+
+// ProjectLoad runs the ATL/CTL model forward from current, today's load as
+// CalculateATLCTL returned it, through each day before raceDate. Only
+// entries still planned are counted: completed ones are already in current
+// through their activities, as are entries outside today..raceDate. Planned
+// entries for today are added on top of today's load.
+func ProjectLoad(current LoadResult, entries []models.CalendarEntry, raceDate time.Time, zones AthleteZones, restingHR, maxHR float64, equiv RunEquivalence) LoadProjection {
+	if maxHR == 0 {
+		maxHR = DefaultMaxHR
+	}
+	if restingHR == 0 {
+		restingHR = DefaultLoadRestingHR
+	}
+
+	today := time.Now().Truncate(24 * time.Hour)
+	race := time.Date(raceDate.Year(), raceDate.Month(), raceDate.Day(), 0, 0, 0, 0, time.UTC)
+	days := int(race.Sub(today).Hours() / 24)
+	if days > maxProjectionDays {
+		days = maxProjectionDays
+	}
+
+	planned := make(map[string]float64)
+	count := 0
+	for _, e := range entries {
+		if e.Status != "planned" || e.Date.Before(today) || !e.Date.Before(race) {
+			continue
+		}
+		planned[e.Date.Format("2006-01-02")] += PlannedLoad(e, zones, restingHR, maxHR, equiv)
+		count++
+	}
+
+	atl, ctl := current.ATL, current.CTL
+	out := LoadProjection{
+		RaceDate:        race.Format("2006-01-02"),
+		DaysToRace:      days,
+		PlannedSessions: count,
+	}
+	if days < 0 {
+		out.DaysToRace = 0
+	}
+
+	// Today has already been stepped with what was done; the extra planned
+	// load shifts the averages by its weighted share.
+	key := today.Format("2006-01-02")
+	atl += planned[key] / 7.0
+	ctl += planned[key] / 42.0
+	out.Points = append(out.Points, projectedPoint(key, planned[key], atl, ctl))
+	out.LowestTSB, out.LowestTSBDate = round2(ctl-atl), key
+
+	for i := 1; i < days; i++ {
+		key := today.AddDate(0, 0, i).Format("2006-01-02")
+		tss := planned[key]
+		atl += (tss - atl) / 7.0
+		ctl += (tss - ctl) / 42.0
+		p := projectedPoint(key, tss, atl, ctl)
+		out.Points = append(out.Points, p)
+		if p.TSB < out.LowestTSB {
+			out.LowestTSB, out.LowestTSBDate = p.TSB, key
+		}
+	}
+
+	tsb := ctl - atl
+	ratio := 0.0
+	if ctl > 0 {
+		ratio = round2(atl / ctl)
+	}
+	out.RaceMorning = LoadResult{
+		ATL:       round2(atl),
+		CTL:       round2(ctl),
+		TSB:       round2(tsb),
+		FormLabel: formLabel(tsb),
+		LoadRatio: ratio,
+		RiskLevel: riskLevel(ratio),
+	}
+	return out
+}
+
+func projectedPoint(date string, load, atl, ctl float64) ProjectedLoadPoint {
+	return ProjectedLoadPoint{
+		Date:        date,
+		PlannedLoad: round2(load),
+		ATL:         round2(atl),
+		CTL:         round2(ctl),
+		TSB:         round2(ctl - atl),
+	}
+}
+
+// PlannedLoad estimates the run-equivalent load of a planned calendar
+// entry. Duration comes from the planned minutes, or from distance at the
+// planned pace or the middle of the workout's pace zone; intensity is the
+// heart rate in the middle of the workout's HR zone. Rest days and entries
+// with neither duration nor distance carry no load.
+func PlannedLoad(e models.CalendarEntry, zones AthleteZones, restingHR, maxHR float64, equiv RunEquivalence) float64 {
+	workoutType := strings.ToLower(e.WorkoutType)
+	if workoutType == "rest" {
+		return 0
+	}
+	minutes := plannedMinutes(e, zones)
+	if minutes == 0 {
+		return 0
+	}
+	zone := plannedZone(workoutType)
+
+	// Sports scored by session-RPE fall back to a moderate RPE rather than
+	// a guessed heart rate.
+	sport := plannedSport(workoutType)
+	hr := zones.hrZoneTarget(zone)
+	if usesSessionRPE(sport) {
+		hr = 0
+	}
+	return SessionLoad(sport, minutes, hr, 0, restingHR, maxHR) * equiv.Factor(sport)
+}
+
+// plannedMinutes is an entry's planned duration: its minutes, or its
+// distance at the planned pace or the middle of the workout's pace zone.
+// Zero when it has neither.
+func plannedMinutes(e models.CalendarEntry, zones AthleteZones) float64 {
+	switch {
+	case e.PlannedDurationMinutes != nil && *e.PlannedDurationMinutes > 0:
+		return float64(*e.PlannedDurationMinutes)
+	case e.PlannedDistanceMeters != nil && *e.PlannedDistanceMeters > 0:
+		pace := zones.paceZoneTarget(plannedZone(strings.ToLower(e.WorkoutType)))
+		if e.PlannedPacePerKm != nil && *e.PlannedPacePerKm > 0 {
+			pace = float64(*e.PlannedPacePerKm)
+		}
+		return float64(*e.PlannedDistanceMeters) / 1000 * pace / 60
+	}
+	return 0
+}
+
+// plannedZone is the zone a calendar workout type is expected to average.
+func plannedZone(workoutType string) int {
+	switch workoutType {
+	case "recovery":
+		return 1
+	case "race":
+		return 4
+	default:
+		return workoutZone(workoutType)
+	}
+}
+
+// plannedSport maps a calendar workout type to the activity type its load
+// is scored as.
+func plannedSport(workoutType string) string {
+	switch workoutType {
+	case "cycling":
+		return models.ActivityTypeCycling
+	case "swimming":
+		return models.ActivityTypeSwimming
+	case "lifting":
+		return models.ActivityTypeWeightLifting
+	case "cross_train":
+		return models.ActivityTypeWorkout
+	default:
+		return models.ActivityTypeRun
+	}
+}
@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+func TestProjectLoadDecaysWithoutPlannedSessions(t *testing.T) {
+	today := time.Now().Truncate(24 * time.Hour)
+	current := LoadResult{ATL: 70, CTL: 42}
+
+	// Seven empty days: ATL 70 x (6/7)^7 = 23.794, CTL 42 x (41/42)^7 =
+	// 35.481.
+	got := ProjectLoad(current, nil, today.AddDate(0, 0, 8), DefaultZones(0, 0, 0), 0, 0, DefaultRunEquivalence)
+
+	if got.DaysToRace != 8 || len(got.Points) != 8 {
+		t.Fatalf("expected 8 days and points, got %d and %d", got.DaysToRace, len(got.Points))
+	}
+	want := LoadResult{ATL: 23.79, CTL: 35.48, TSB: 11.69}
+	if got.RaceMorning.ATL != want.ATL || got.RaceMorning.CTL != want.CTL || got.RaceMorning.TSB != want.TSB {
+		t.Fatalf("expected race morning %+v, got %+v", want, got.RaceMorning)
+	}
+	if got.LowestTSB != -28 || got.LowestTSBDate != today.Format("2006-01-02") {
+		t.Fatalf("expected lowest TSB -28 today, got %v on %s", got.LowestTSB, got.LowestTSBDate)
+	}
+}
+
+func TestProjectLoadStepsPlannedSessions(t *testing.T) {
+	today := time.Now().Truncate(24 * time.Hour)
+	race := today.AddDate(0, 0, 4)
+	hour := 60
+	lifting := func(date time.Time, status string) models.CalendarEntry {
+		return models.CalendarEntry{Date: date, WorkoutType: "lifting", PlannedDurationMinutes: &hour, Status: status}
+	}
+	// Each planned lifting hour scores session-RPE 5 x 60 x 0.25 = 75, at
+	// a run equivalence of 0.5: 37.5.
+	entries := []models.CalendarEntry{
+		lifting(today, "planned"),
+		lifting(today.AddDate(0, 0, 1), "completed"),
+		lifting(today.AddDate(0, 0, 2), "planned"),
+		lifting(race, "planned"),
+		lifting(today.AddDate(0, 0, -1), "planned"),
+	}
+
+	got := ProjectLoad(LoadResult{}, entries, race, DefaultZones(0, 0, 0), 0, 0, DefaultRunEquivalence)
+
+	// Today: ATL 37.5/7 = 5.357, CTL 37.5/42 = 0.893.
+	// Day 1: ATL 4.592, CTL 0.872.
+	// Day 2: ATL 4.592 + (37.5 - 4.592)/7 = 9.293, CTL 0.872 + (37.5 - 0.872)/42 = 1.744.
+	// Day 3: ATL 7.965, CTL 1.702.
+	wantTSB := []float64{-4.46, -3.72, -7.55, -6.26}
+	if len(got.Points) != len(wantTSB) {
+		t.Fatalf("expected %d points, got %d", len(wantTSB), len(got.Points))
+	}
+	for i, p := range got.Points {
+		if p.TSB != wantTSB[i] {
+			t.Fatalf("day %d: expected TSB %v, got %v", i, wantTSB[i], p.TSB)
+		}
+	}
+	if got.Points[2].PlannedLoad != 37.5 || got.Points[1].PlannedLoad != 0 {
+		t.Fatalf("expected planned load only on days 0 and 2, got %+v", got.Points)
+	}
+	if got.PlannedSessions != 2 {
+		t.Fatalf("expected 2 planned sessions, got %d", got.PlannedSessions)
+	}
+	want := LoadResult{ATL: 7.97, CTL: 1.7, TSB: -6.26}
+	if got.RaceMorning.ATL != want.ATL || got.RaceMorning.CTL != want.CTL || got.RaceMorning.TSB != want.TSB {
+		t.Fatalf("expected race morning %+v, got %+v", want, got.RaceMorning)
+	}
+	if got.LowestTSB != -7.55 || got.LowestTSBDate != today.AddDate(0, 0, 2).Format("2006-01-02") {
+		t.Fatalf("expected lowest TSB -7.55 on day 2, got %v on %s", got.LowestTSB, got.LowestTSBDate)
+	}
+}
+
+func TestProjectLoadRaceInThePast(t *testing.T) {
+	today := time.Now().Truncate(24 * time.Hour)
+	got := ProjectLoad(LoadResult{ATL: 40, CTL: 50}, nil, today.AddDate(0, 0, -3), DefaultZones(0, 0, 0), 0, 0, DefaultRunEquivalence)
+	if got.DaysToRace != 0 {
+		t.Fatalf("expected 0 days to race, got %d", got.DaysToRace)
+	}
+	if got.RaceMorning.TSB != 10 {
+		t.Fatalf("expected today's TSB of 10, got %v", got.RaceMorning.TSB)
+	}
+}
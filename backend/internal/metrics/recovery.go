@@ -14,19 +14,23 @@ type RecoveryResult struct {
 	LastHardHR     int     `json:"last_hard_hr"`
 	HoursSince     float64 `json:"hours_since"`
 	NextQualityDay string  `json:"next_quality_day"`
+	// HardHRThreshold is the average heart rate from which a run counts as
+	// a hard session.
+	HardHRThreshold int `json:"hard_hr_threshold"`
 }
 
-// RecoveryStatus computes recovery percentage from the last hard session.
-func RecoveryStatus(activities []models.Activity, restingHR, maxHR float64) RecoveryResult {
+// RecoveryStatus computes recovery percentage from the last hard session,
+// a run averaging at least the athlete's threshold (Z4) heart rate.
+func RecoveryStatus(activities []models.Activity, zones AthleteZones, restingHR, maxHR float64) RecoveryResult {
 	if maxHR == 0 {
-		maxHR = 190
+		maxHR = DefaultMaxHR
 	}
 	if restingHR == 0 {
-		restingHR = 55
+		restingHR = DefaultLoadRestingHR
 	}
 
 	now := time.Now()
-	const hardHRThreshold = 162
+	hardHRThreshold := zones.HardHR()
 
 	var lastHard *models.Activity
 	for i := range activities {
@@ -44,8 +48,9 @@ func RecoveryStatus(activities []models.Activity, restingHR, maxHR float64) Reco
 
 	if lastHard == nil {
 		return RecoveryResult{
-			RecoveryPct:    100,
-			NextQualityDay: "Ready now",
+			RecoveryPct:     100,
+			NextQualityDay:  "Ready now",
+			HardHRThreshold: hardHRThreshold,
 		}
 	}
 
@@ -84,10 +89,11 @@ func RecoveryStatus(activities []models.Activity, restingHR, maxHR float64) Reco
 	}
 
 	return RecoveryResult{
-		RecoveryPct:    round2(recoveryPct),
-		LastHardDate:   lastHard.StartTime.Format("2006-01-02"),
-		LastHardHR:     lastHardHR,
-		HoursSince:     round2(hoursSince),
-		NextQualityDay: nextQuality,
+		RecoveryPct:     round2(recoveryPct),
+		LastHardDate:    lastHard.StartTime.Format("2006-01-02"),
+		LastHardHR:      lastHardHR,
+		HoursSince:      round2(hoursSince),
+		NextQualityDay:  nextQuality,
+		HardHRThreshold: hardHRThreshold,
 	}
 }
@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+const (
+	// sessionRPEScale converts session-RPE (RPE x minutes, Foster) to TRIMP
+	// units. At 0.25 an hour at RPE 5 scores about what an hour at 60% of
+	// heart-rate reserve does.
+	sessionRPEScale = 0.25
+
+	// defaultSessionRPE is assumed for sports scored by session-RPE when
+	// the athlete logged neither an RPE nor an intensity.
+	defaultSessionRPE = 5
+
+	// durationLoadPerMinute scores a session with no heart rate, RPE or
+	// intensity: the same as RPE 2, an easy effort.
+	durationLoadPerMinute = 0.5
+
+	// defaultRunEquivalence applies to sports without a factor of their own.
+	defaultRunEquivalence = 0.5
+)
+
+// RunEquivalence is the share of a sport's training stress that counts
+// toward running fitness and fatigue, by activity type. Running is 1.
+type RunEquivalence map[string]float64
+
+// DefaultRunEquivalence reflects how much of each sport's aerobic and
+// musculoskeletal load carries over to running.
+var DefaultRunEquivalence = RunEquivalence{
+	models.ActivityTypeRun:           1.0,
+	models.ActivityTypeCycling:       0.75,
+	models.ActivityTypeSwimming:      0.6,
+	models.ActivityTypeRowing:        0.7,
+	models.ActivityTypeElliptical:    0.8,
+	models.ActivityTypeStairMaster:   0.8,
+	models.ActivityTypeHiking:        0.7,
+	models.ActivityTypeWalking:       0.4,
+	models.ActivityTypeWeightLifting: 0.5,
+	models.ActivityTypeWorkout:       0.6,
+	models.ActivityTypeRecovery:      0.3,
+}
+
+// NewRunEquivalence returns the default factors with overrides applied.
+// Sport names match activity types; "weightlifting" is accepted for
+// weight_lifting as cross-training sessions spell it either way.
+func NewRunEquivalence(overrides map[string]float64) RunEquivalence {
+	e := make(RunEquivalence, len(DefaultRunEquivalence)+len(overrides))
+	for sport, f := range DefaultRunEquivalence {
+		e[sport] = f
+	}
+	for sport, f := range overrides {
+		e[normalizeSport(sport)] = f
+	}
+	return e
+}
+
+// Factor returns the run-equivalence factor for a sport.
+func (e RunEquivalence) Factor(sport string) float64 {
+	if f, ok := e[normalizeSport(sport)]; ok {
+		return f
+	}
+	if f, ok := DefaultRunEquivalence[normalizeSport(sport)]; ok {
+		return f
+	}
+	return defaultRunEquivalence
+}
+
+func normalizeSport(sport string) string {
+	sport = strings.ToLower(strings.TrimSpace(sport))
+	if sport == "weightlifting" || sport == "strength" {
+		return models.ActivityTypeWeightLifting
+	}
+	return sport
+}
+
+// usesSessionRPE reports whether a sport is scored by session-RPE first.
+// Heart rate lags and under-reads in strength work and on the elliptical,
+// so the athlete's perceived effort is the better measure there.
+func usesSessionRPE(sport string) bool {
+	switch sport {
+	case models.ActivityTypeWeightLifting, models.ActivityTypeElliptical, models.ActivityTypeWorkout:
+		return true
+	}
+	return false
+}
+
+// SessionLoad scores one session in TRIMP units before run equivalence.
+// Most sports use HR-TRIMP when an average heart rate is known; strength,
+// elliptical and generic workouts use session-RPE first. avgHR and rpe are
+// 0 when unknown. Sessions with neither fall back to a duration-only score,
+// except session-RPE sports, which assume a moderate RPE.
+func SessionLoad(sport string, durationMin, avgHR, rpe, restingHR, maxHR float64) float64 {
+	sport = normalizeSport(sport)
+	if durationMin <= 0 {
+		return 0
+	}
+	if usesSessionRPE(sport) {
+		if rpe > 0 {
+			return rpe * durationMin * sessionRPEScale
+		}
+		if avgHR > 0 {
+			return CalculateTRIMP(avgHR, durationMin, restingHR, maxHR)
+		}
+		return defaultSessionRPE * durationMin * sessionRPEScale
+	}
+	if avgHR > 0 {
+		return CalculateTRIMP(avgHR, durationMin, restingHR, maxHR)
+	}
+	if rpe > 0 {
+		return rpe * durationMin * sessionRPEScale
+	}
+	return durationMin * durationLoadPerMinute
+}
+
+// ManualSession is a cross-training session logged by hand, with no
+// activity behind it. Sessions mirrored from an activity are scored from
+// the activity instead.
+type ManualSession struct {
+	Type            string
+	Date            time.Time
+	DurationMinutes int
+	Intensity       string
+}
+
+// IntensityRPE maps a logged cross-training intensity to an RPE on the
+// CR-10 scale, or 0 when it isn't recognised.
+func IntensityRPE(intensity string) float64 {
+	switch strings.ToLower(strings.TrimSpace(intensity)) {
+	case "recovery", "very_light":
+		return 2
+	case "light", "easy":
+		return 3
+	case "moderate", "medium":
+		return 5
+	case "hard", "heavy":
+		return 7
+	case "max", "very_hard":
+		return 9
+	}
+	return 0
+}
+
+// ActivityRPE returns the session RPE an athlete logged on an activity's
+// custom fields, or 0 if there is none.
+func ActivityRPE(a models.Activity) float64 {
+	fields := a.CustomFields
+	if fields == nil && len(a.RawCustomFields) > 0 {
+		if err := json.Unmarshal(a.RawCustomFields, &fields); err != nil {
+			return 0
+		}
+	}
+	var rpe float64
+	switch v := fields["rpe"].(type) {
+	case float64:
+		rpe = v
+	case int:
+		rpe = float64(v)
+	}
+	if rpe < 0 || rpe > 10 {
+		return 0
+	}
+	return rpe
+}
+
+// activityLoad scores an activity and scales it to run equivalence.
+func activityLoad(a models.Activity, restingHR, maxHR float64, equiv RunEquivalence) float64 {
+	hr := 0.0
+	if a.AverageHeartRate != nil {
+		hr = float64(*a.AverageHeartRate)
+	}
+	load := SessionLoad(a.ActivityType, float64(a.DurationSeconds)/60.0, hr, ActivityRPE(a), restingHR, maxHR)
+	return load * equiv.Factor(a.ActivityType)
+}
+
+// manualSessionLoad scores a hand-logged session and scales it to run
+// equivalence.
+func manualSessionLoad(s ManualSession, restingHR, maxHR float64, equiv RunEquivalence) float64 {
+	load := SessionLoad(s.Type, float64(s.DurationMinutes), 0, IntensityRPE(s.Intensity), restingHR, maxHR)
+	return load * equiv.Factor(s.Type)
+}
@@ -0,0 +1,117 @@
+package metrics
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+func TestSessionLoadBySport(t *testing.T) {
+	// An hour at 50% of heart-rate reserve (125 bpm for 60/190):
+	// 60 x 0.5 x 0.64 x e^0.96.
+	const hrLoad = 50.1446
+
+	tests := []struct {
+		name  string
+		sport string
+		hr    float64
+		rpe   float64
+		want  float64
+	}{
+		{name: "run with HR", sport: models.ActivityTypeRun, hr: 125, rpe: 8, want: hrLoad},
+		{name: "run with RPE only", sport: models.ActivityTypeRun, rpe: 6, want: 90},
+		{name: "run with neither", sport: models.ActivityTypeRun, want: 30},
+		{name: "cycling with HR", sport: models.ActivityTypeCycling, hr: 125, want: hrLoad},
+		{name: "swimming with neither", sport: models.ActivityTypeSwimming, want: 30},
+		{name: "lifting prefers RPE", sport: models.ActivityTypeWeightLifting, hr: 125, rpe: 7, want: 105},
+		{name: "lifting with HR only", sport: models.ActivityTypeWeightLifting, hr: 125, want: hrLoad},
+		{name: "strength alias assumes moderate RPE", sport: "Strength", want: 75},
+		{name: "elliptical prefers RPE", sport: models.ActivityTypeElliptical, hr: 125, rpe: 4, want: 60},
+		{name: "workout with neither", sport: models.ActivityTypeWorkout, want: 75},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := SessionLoad(tt.sport, 60, tt.hr, tt.rpe, 60, 190)
+			if math.Abs(got-tt.want) > 0.001 {
+				t.Fatalf("expected %.4f, got %.4f", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestSessionLoadWithoutDuration(t *testing.T) {
+	if got := SessionLoad(models.ActivityTypeWeightLifting, 0, 0, 7, 60, 190); got != 0 {
+		t.Fatalf("expected 0, got %v", got)
+	}
+}
+
+func TestIntensityRPE(t *testing.T) {
+	tests := []struct {
+		intensity string
+		want      float64
+	}{
+		{intensity: "recovery", want: 2},
+		{intensity: "very_light", want: 2},
+		{intensity: "easy", want: 3},
+		{intensity: "Moderate", want: 5},
+		{intensity: " hard ", want: 7},
+		{intensity: "heavy", want: 7},
+		{intensity: "very_hard", want: 9},
+		{intensity: "max", want: 9},
+		{intensity: "", want: 0},
+		{intensity: "brutal", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := IntensityRPE(tt.intensity); got != tt.want {
+			t.Fatalf("IntensityRPE(%q): expected %v, got %v", tt.intensity, tt.want, got)
+		}
+	}
+}
+
+func TestRunEquivalenceFactor(t *testing.T) {
+	equiv := NewRunEquivalence(map[string]float64{"Weightlifting": 0.9, "cycling": 0.5})
+
+	tests := []struct {
+		sport string
+		want  float64
+	}{
+		{sport: models.ActivityTypeRun, want: 1},
+		{sport: models.ActivityTypeCycling, want: 0.5},
+		{sport: models.ActivityTypeWeightLifting, want: 0.9},
+		{sport: models.ActivityTypeSwimming, want: 0.6},
+		{sport: "kayaking", want: defaultRunEquivalence},
+	}
+
+	for _, tt := range tests {
+		if got := equiv.Factor(tt.sport); got != tt.want {
+			t.Fatalf("Factor(%q): expected %v, got %v", tt.sport, tt.want, got)
+		}
+	}
+}
+
+func TestActivityRPE(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want float64
+	}{
+		{name: "logged", raw: `{"rpe": 6}`, want: 6},
+		{name: "none", raw: `{"shoe": "trail"}`, want: 0},
+		{name: "out of range", raw: `{"rpe": 12}`, want: 0},
+		{name: "not a number", raw: `{"rpe": "hard"}`, want: 0},
+		{name: "malformed", raw: `{"rpe":`, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := models.Activity{RawCustomFields: json.RawMessage(tt.raw)}
+			if got := ActivityRPE(a); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,293 @@
+package metrics
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// Default race-morning TSB window the taper optimizer aims for: fresh
+// enough to race, without shedding so much load that fitness fades.
+const (
+	DefaultTaperTSBMin = 5.0
+	DefaultTaperTSBMax = 20.0
+)
+
+const (
+	// maxTaperWeeks matches the coaching rule: taper in the final three
+	// weeks.
+	maxTaperWeeks = 3
+
+	// minEasyScale is the least an easy session is cut to; a taper keeps
+	// the athlete running.
+	minEasyScale = 0.3
+)
+
+// taperReductions are the weekly volume reductions tried, within the 20-40%
+// the coaching rules allow.
+var taperReductions = []float64{0.20, 0.25, 0.30, 0.35, 0.40}
+
+// TaperWeek is one week of a proposed taper.
+type TaperWeek struct {
+	// WeeksOut counts back from the race: 1 is race week.
+	WeeksOut        int     `json:"weeks_out"`
+	Start           string  `json:"start"`
+	ReductionPct    float64 `json:"reduction_pct"`
+	PlannedMinutes  float64 `json:"planned_minutes"`
+	ProposedMinutes float64 `json:"proposed_minutes"`
+}
+
+// TaperChange is a proposed edit to one planned calendar entry. UpdatedAt
+// is the entry's updated_at when the proposal was made, so accepting it
+// can tell whether the entry changed since.
+type TaperChange struct {
+	EntryID                uuid.UUID `json:"entry_id"`
+	Date                   string    `json:"date"`
+	WorkoutType            string    `json:"workout_type"`
+	Title                  string    `json:"title"`
+	UpdatedAt              time.Time `json:"updated_at"`
+	FromDistanceMeters     *int      `json:"from_distance_meters"`
+	FromDurationMinutes    *int      `json:"from_duration_minutes"`
+	PlannedDistanceMeters  *int      `json:"planned_distance_meters"`
+	PlannedDurationMinutes *int      `json:"planned_duration_minutes"`
+}
+
+// TaperProposal is the optimizer's chosen taper and the calendar edits
+// that make it.
+type TaperProposal struct {
+	TargetTSBMin float64 `json:"target_tsb_min"`
+	TargetTSBMax float64 `json:"target_tsb_max"`
+	// TaperWeeks is 0 when the plan as it stands is the best option.
+	TaperWeeks int         `json:"taper_weeks"`
+	Weeks      []TaperWeek `json:"weeks"`
+	// InWindow reports whether the proposal lands race-morning TSB in the
+	// target window; when no taper can, it is the closest one.
+	InWindow bool `json:"in_window"`
+	// CurrentPlan and Proposed are race-morning form without and with the
+	// changes.
+	CurrentPlan LoadResult    `json:"current_plan"`
+	Proposed    LoadResult    `json:"proposed"`
+	Changes     []TaperChange `json:"changes"`
+}
+
+// taperCandidate is one taper tried: a reduction per week, race week
+// last.
+type taperCandidate struct {
+	reductions  []float64
+	entries     []models.CalendarEntry
+	weeks       []TaperWeek
+	raceMorning LoadResult
+}
+
+// OptimizeTaper searches taper lengths of up to three weeks and a volume
+// reduction per week, never easing off as race day nears, for the taper
+// that lands race-morning TSB in [tsbMin, tsbMax] with the most fitness
+// (CTL) kept. Quality sessions (tempo, intervals, tune-up races) take half
+// the week's cut and keep their pace, so intensity is preserved while easy
+// volume absorbs the rest. The search is exhaustive over a fixed grid, so
+// the same inputs always give the same proposal. Arguments are as for
+// ProjectLoad.
+func OptimizeTaper(current LoadResult, entries []models.CalendarEntry, raceDate time.Time, zones AthleteZones, restingHR, maxHR float64, equiv RunEquivalence, tsbMin, tsbMax float64) TaperProposal {
+	race := time.Date(raceDate.Year(), raceDate.Month(), raceDate.Day(), 0, 0, 0, 0, time.UTC)
+	today := time.Now().Truncate(24 * time.Hour)
+	weeks := int(math.Ceil(race.Sub(today).Hours() / 24 / 7))
+	if weeks > maxTaperWeeks {
+		weeks = maxTaperWeeks
+	}
+
+	evaluate := func(reductions []float64) taperCandidate {
+		c := taperCandidate{reductions: reductions}
+		c.entries, c.weeks = applyTaper(entries, race, reductions, zones)
+		c.raceMorning = ProjectLoad(current, c.entries, raceDate, zones, restingHR, maxHR, equiv).RaceMorning
+		return c
+	}
+
+	baseline := evaluate(nil)
+	best := baseline
+	var search func(reductions []float64)
+	search = func(reductions []float64) {
+		if len(reductions) > 0 {
+			if c := evaluate(reductions); taperBetter(c.raceMorning, best.raceMorning, tsbMin, tsbMax) {
+				best = c
+			}
+		}
+		if len(reductions) == weeks {
+			return
+		}
+		// Weeks are added moving away from the race, so each earlier week
+		// cuts no more than the one after it.
+		for _, r := range taperReductions {
+			if len(reductions) > 0 && r > reductions[0] {
+				break
+			}
+			search(append([]float64{r}, reductions...))
+		}
+	}
+	search(nil)
+
+	proposal := TaperProposal{
+		TargetTSBMin: tsbMin,
+		TargetTSBMax: tsbMax,
+		TaperWeeks:   len(best.reductions),
+		Weeks:        best.weeks,
+		InWindow:     tsbDistance(best.raceMorning.TSB, tsbMin, tsbMax) == 0,
+		CurrentPlan:  baseline.raceMorning,
+		Proposed:     best.raceMorning,
+		Changes:      []TaperChange{},
+	}
+	for i, e := range best.entries {
+		if !sameVolume(entries[i], e) {
+			proposal.Changes = append(proposal.Changes, TaperChange{
+				EntryID:                e.ID,
+				Date:                   e.Date.Format("2006-01-02"),
+				WorkoutType:            e.WorkoutType,
+				Title:                  e.Title,
+				UpdatedAt:              e.UpdatedAt,
+				FromDistanceMeters:     entries[i].PlannedDistanceMeters,
+				FromDurationMinutes:    entries[i].PlannedDurationMinutes,
+				PlannedDistanceMeters:  e.PlannedDistanceMeters,
+				PlannedDurationMinutes: e.PlannedDurationMinutes,
+			})
+		}
+	}
+	return proposal
+}
+
+// Matches reports whether changes are exactly the proposal's: the same
+// entries at the same volumes, proposed from the same version of each
+// entry.
+func (p TaperProposal) Matches(changes []TaperChange) bool {
+	if len(changes) != len(p.Changes) {
+		return false
+	}
+	proposed := make(map[uuid.UUID]TaperChange, len(p.Changes))
+	for _, c := range p.Changes {
+		proposed[c.EntryID] = c
+	}
+	for _, c := range changes {
+		want, ok := proposed[c.EntryID]
+		if !ok || !want.UpdatedAt.Equal(c.UpdatedAt) ||
+			!equalIntPtr(want.PlannedDistanceMeters, c.PlannedDistanceMeters) ||
+			!equalIntPtr(want.PlannedDurationMinutes, c.PlannedDurationMinutes) {
+			return false
+		}
+		delete(proposed, c.EntryID)
+	}
+	return true
+}
+
+// taperBetter reports whether race-morning form a beats b: closer to the
+// TSB window, then more CTL kept. Ties keep b, the taper found first.
+func taperBetter(a, b LoadResult, tsbMin, tsbMax float64) bool {
+	da, db := tsbDistance(a.TSB, tsbMin, tsbMax), tsbDistance(b.TSB, tsbMin, tsbMax)
+	if da != db {
+		return da < db
+	}
+	return a.CTL > b.CTL
+}
+
+// tsbDistance is how far tsb falls outside [lo, hi], or 0 inside it.
+func tsbDistance(tsb, lo, hi float64) float64 {
+	switch {
+	case tsb < lo:
+		return lo - tsb
+	case tsb > hi:
+		return tsb - hi
+	}
+	return 0
+}
+
+// applyTaper returns a copy of entries with the given weekly reductions
+// applied, race week last, and a summary of each taper week.
+func applyTaper(entries []models.CalendarEntry, race time.Time, reductions []float64, zones AthleteZones) ([]models.CalendarEntry, []TaperWeek) {
+	out := make([]models.CalendarEntry, len(entries))
+	copy(out, entries)
+	summary := make([]TaperWeek, 0, len(reductions))
+
+	for i, r := range reductions {
+		weeksOut := len(reductions) - i
+		start := race.AddDate(0, 0, -7*weeksOut)
+		end := start.AddDate(0, 0, 7)
+
+		var quality, easy []int
+		var qualityMin, easyMin float64
+		for j, e := range entries {
+			if e.Date.Before(start) || !e.Date.Before(end) {
+				continue
+			}
+			minutes := plannedMinutes(e, zones)
+			if minutes == 0 {
+				continue
+			}
+			if isTaperQuality(e.WorkoutType) {
+				quality = append(quality, j)
+				qualityMin += minutes
+			} else {
+				easy = append(easy, j)
+				easyMin += minutes
+			}
+		}
+
+		qualityScale := 1 - r/2
+		easyScale := 1.0
+		if easyMin > 0 {
+			target := (1-r)*(qualityMin+easyMin) - qualityScale*qualityMin
+			easyScale = math.Max(math.Min(target/easyMin, 1), minEasyScale)
+		} else {
+			qualityScale = 1 - r
+		}
+
+		week := TaperWeek{WeeksOut: weeksOut, Start: start.Format("2006-01-02"), PlannedMinutes: round2(qualityMin + easyMin)}
+		var proposed float64
+		for _, j := range quality {
+			out[j] = scaleEntry(entries[j], qualityScale)
+			proposed += plannedMinutes(out[j], zones)
+		}
+		for _, j := range easy {
+			out[j] = scaleEntry(entries[j], easyScale)
+			proposed += plannedMinutes(out[j], zones)
+		}
+		week.ProposedMinutes = round2(proposed)
+		if week.PlannedMinutes > 0 {
+			week.ReductionPct = round2((1 - proposed/(qualityMin+easyMin)) * 100)
+		}
+		summary = append(summary, week)
+	}
+	return out, summary
+}
+
+// isTaperQuality reports whether a session keeps its intensity through a
+// taper.
+func isTaperQuality(workoutType string) bool {
+	return isQualityWorkout(workoutType) || strings.EqualFold(workoutType, "race")
+}
+
+// scaleEntry scales an entry's planned distance, to the nearest 100 m, and
+// planned duration, to the minute.
+func scaleEntry(e models.CalendarEntry, scale float64) models.CalendarEntry {
+	if e.PlannedDistanceMeters != nil && *e.PlannedDistanceMeters > 0 {
+		d := int(math.Max(math.Round(float64(*e.PlannedDistanceMeters)*scale/100)*100, 100))
+		e.PlannedDistanceMeters = &d
+	}
+	if e.PlannedDurationMinutes != nil && *e.PlannedDurationMinutes > 0 {
+		m := int(math.Max(math.Round(float64(*e.PlannedDurationMinutes)*scale), 1))
+		e.PlannedDurationMinutes = &m
+	}
+	return e
+}
+
+func sameVolume(a, b models.CalendarEntry) bool {
+	return equalIntPtr(a.PlannedDistanceMeters, b.PlannedDistanceMeters) &&
+		equalIntPtr(a.PlannedDurationMinutes, b.PlannedDurationMinutes)
+}
+
+func equalIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
@@ -0,0 +1,145 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// raceWeek plans an hour a day for the seven days before a race a week
+// out, with a tempo run on the third day. For an athlete resting at 60
+// with a max of 190, from ATL 70 and CTL 60 a single race-week cut of
+// 20/25/30/35/40% lands race-morning TSB at about -12.0/-10.0/-7.8/-5.2/-3.0;
+// untouched it is -21.05.
+func raceWeek() (time.Time, []models.CalendarEntry) {
+	today := time.Now().Truncate(24 * time.Hour)
+	entries := make([]models.CalendarEntry, 0, 7)
+	for i := 0; i < 7; i++ {
+		minutes := 60
+		workoutType := "easy"
+		if i == 2 {
+			workoutType = "tempo"
+		}
+		entries = append(entries, models.CalendarEntry{
+			ID:                     uuid.New(),
+			Date:                   today.AddDate(0, 0, i),
+			WorkoutType:            workoutType,
+			PlannedDurationMinutes: &minutes,
+			Status:                 "planned",
+		})
+	}
+	return today.AddDate(0, 0, 7), entries
+}
+
+func TestOptimizeTaperPicksSmallestCutInWindow(t *testing.T) {
+	race, entries := raceWeek()
+	current := LoadResult{ATL: 70, CTL: 60}
+
+	got := OptimizeTaper(current, entries, race, DefaultZones(60, 190, 0), 60, 190, DefaultRunEquivalence, -9, 0)
+
+	if !got.InWindow || got.TaperWeeks != 1 {
+		t.Fatalf("expected a one-week taper in the window, got %d weeks (in window %v)", got.TaperWeeks, got.InWindow)
+	}
+	if got.CurrentPlan.TSB != -21.05 {
+		t.Fatalf("expected the current plan at TSB -21.05, got %v", got.CurrentPlan.TSB)
+	}
+	// 25% leaves TSB at -9.99; 30% is the smallest cut that reaches -9.
+	if got.Proposed.TSB != -7.84 {
+		t.Fatalf("expected the 30%% cut at TSB -7.84, got %v", got.Proposed.TSB)
+	}
+	if len(got.Changes) != len(entries) {
+		t.Fatalf("expected every session cut, got %d changes", len(got.Changes))
+	}
+	for _, c := range got.Changes {
+		if *c.FromDurationMinutes != 60 {
+			t.Fatalf("expected changes from 60 minutes, got %d", *c.FromDurationMinutes)
+		}
+		// Quality sessions take half the week's cut.
+		if c.WorkoutType == "tempo" && *c.PlannedDurationMinutes != 51 {
+			t.Fatalf("expected the tempo run cut to 51 minutes, got %d", *c.PlannedDurationMinutes)
+		}
+	}
+}
+
+func TestOptimizeTaperClosestWhenWindowUnreachable(t *testing.T) {
+	race, entries := raceWeek()
+	current := LoadResult{ATL: 70, CTL: 60}
+
+	got := OptimizeTaper(current, entries, race, DefaultZones(60, 190, 0), 60, 190, DefaultRunEquivalence, 30, 40)
+
+	if got.InWindow {
+		t.Fatalf("expected no taper to reach the window, got %+v", got.Proposed)
+	}
+	// The deepest cut, 40%, comes closest.
+	if got.TaperWeeks != 1 || got.Proposed.TSB != -3.01 {
+		t.Fatalf("expected the 40%% cut at TSB -3.01, got %d weeks at %v", got.TaperWeeks, got.Proposed.TSB)
+	}
+}
+
+func TestOptimizeTaperWithoutPlannedSessions(t *testing.T) {
+	race, entries := raceWeek()
+	current := LoadResult{ATL: 70, CTL: 60}
+	// Only the race itself and a session after it are on the calendar.
+	entries[0].Date = race
+	entries[1].Date = race.AddDate(0, 0, 1)
+
+	got := OptimizeTaper(current, entries[:2], race, DefaultZones(0, 0, 0), 0, 0, DefaultRunEquivalence, DefaultTaperTSBMin, DefaultTaperTSBMax)
+
+	if got.TaperWeeks != 0 || len(got.Changes) != 0 {
+		t.Fatalf("expected no taper, got %d weeks and %d changes", got.TaperWeeks, len(got.Changes))
+	}
+	if got.Proposed.TSB != got.CurrentPlan.TSB || got.Proposed.CTL != got.CurrentPlan.CTL {
+		t.Fatalf("expected the current plan proposed, got %+v and %+v", got.Proposed, got.CurrentPlan)
+	}
+	// Seven rest days leave TSB above the window, but there is nothing to
+	// cut.
+	if got.InWindow {
+		t.Fatalf("expected race-morning TSB %v above the default window", got.Proposed.TSB)
+	}
+}
+
+func TestTaperProposalMatches(t *testing.T) {
+	race, entries := raceWeek()
+	proposal := OptimizeTaper(LoadResult{ATL: 70, CTL: 60}, entries, race, DefaultZones(0, 0, 0), 0, 0, DefaultRunEquivalence, -9, 0)
+	edited := func(edit func(c []TaperChange) []TaperChange) []TaperChange {
+		changes := append([]TaperChange(nil), proposal.Changes...)
+		return edit(changes)
+	}
+	longer := 58
+
+	tests := []struct {
+		name    string
+		changes []TaperChange
+		want    bool
+	}{
+		{name: "as proposed", changes: proposal.Changes, want: true},
+		{name: "reordered", changes: edited(func(c []TaperChange) []TaperChange {
+			c[0], c[1] = c[1], c[0]
+			return c
+		}), want: true},
+		{name: "volume edited", changes: edited(func(c []TaperChange) []TaperChange {
+			c[0].PlannedDurationMinutes = &longer
+			return c
+		}), want: false},
+		{name: "entry dropped", changes: edited(func(c []TaperChange) []TaperChange { return c[1:] }), want: false},
+		{name: "entry repeated", changes: edited(func(c []TaperChange) []TaperChange {
+			c[1] = c[0]
+			return c
+		}), want: false},
+		{name: "older version of an entry", changes: edited(func(c []TaperChange) []TaperChange {
+			c[0].UpdatedAt = c[0].UpdatedAt.Add(-time.Minute)
+			return c
+		}), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := proposal.Matches(tt.changes); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
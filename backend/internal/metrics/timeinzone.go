@@ -0,0 +1,258 @@
+package metrics
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+const (
+	// maxSampleGapSeconds caps how long one stream sample counts for.
+	// Longer gaps are auto-pauses or dropouts, not time spent in a zone.
+	maxSampleGapSeconds = 10
+
+	// minPaceVelocity (m/s) is the slowest speed treated as running when
+	// bucketing pace; below it the athlete is standing or walking.
+	minPaceVelocity = 1.0
+
+	// intensityWindowDays is the trailing window IntensityDistribution
+	// classifies.
+	intensityWindowDays = 28
+)
+
+// ZoneSeconds is time spent in each of the five zones, Z1 first.
+type ZoneSeconds [5]float64
+
+func (z ZoneSeconds) total() float64 {
+	var t float64
+	for _, s := range z {
+		t += s
+	}
+	return t
+}
+
+func (z *ZoneSeconds) add(other ZoneSeconds) {
+	for i := range z {
+		z[i] += other[i]
+	}
+}
+
+// Time-in-zone sources: stream samples, or the whole activity credited to
+// the zone of its average.
+const (
+	ZoneTimeFromStreams = "streams"
+	ZoneTimeFromAverage = "average"
+	ZoneTimeNone        = "none"
+)
+
+// ActivityZoneTime is time in HR and pace zones for one activity.
+type ActivityZoneTime struct {
+	ActivityID uuid.UUID   `json:"activity_id"`
+	HR         ZoneSeconds `json:"hr_seconds"`
+	HRSource   string      `json:"hr_source"`
+	Pace       ZoneSeconds `json:"pace_seconds"`
+	PaceSource string      `json:"pace_source"`
+}
+
+// StreamTimeInZones buckets every stream sample into the athlete's zones.
+// Each sample covers the time since the previous one, capped at
+// maxSampleGapSeconds; samples flagged not moving are skipped. hasHR and
+// hasPace report whether any heart rate or running-speed time was found.
+func StreamTimeInZones(streams *models.ActivityStreams, zones AthleteZones) (hr, pace ZoneSeconds, hasHR, hasPace bool) {
+	n := len(streams.TimeOffsets)
+	hasHR = len(streams.HeartRate) == n && n > 1
+	hasPace = len(streams.Velocity) == n && n > 1
+	if !hasHR && !hasPace {
+		return hr, pace, false, false
+	}
+	moving := len(streams.Moving) == n
+
+	for i := 1; i < n; i++ {
+		if moving && !streams.Moving[i] {
+			continue
+		}
+		dt := float64(streams.TimeOffsets[i] - streams.TimeOffsets[i-1])
+		if dt <= 0 {
+			continue
+		}
+		dt = math.Min(dt, maxSampleGapSeconds)
+
+		if hasHR && streams.HeartRate[i] > 0 {
+			hr[zones.HRZone(float64(streams.HeartRate[i]))-1] += dt
+		}
+		if hasPace && streams.Velocity[i] >= minPaceVelocity {
+			pace[zones.PaceZone(1000/streams.Velocity[i])-1] += dt
+		}
+	}
+	return hr, pace, hasHR && hr.total() > 0, hasPace && pace.total() > 0
+}
+
+// ActivityTimeInZones computes an activity's time in zone from its streams
+// when it has them (streams may be nil). Without a stream, the whole
+// duration goes to the zone of the activity's average heart rate or
+// pace, which misreads interval sessions as steady tempo work.
+func ActivityTimeInZones(a models.Activity, streams *models.ActivityStreams, zones AthleteZones) ActivityZoneTime {
+	out := ActivityZoneTime{ActivityID: a.ID, HRSource: ZoneTimeNone, PaceSource: ZoneTimeNone}
+
+	var hasHR, hasPace bool
+	if streams != nil {
+		out.HR, out.Pace, hasHR, hasPace = StreamTimeInZones(streams, zones)
+	}
+	if hasHR {
+		out.HRSource = ZoneTimeFromStreams
+	} else {
+		out.HR = ZoneSeconds{}
+		if a.AverageHeartRate != nil && *a.AverageHeartRate > 0 {
+			out.HR[zones.HRZone(float64(*a.AverageHeartRate))-1] = float64(a.DurationSeconds)
+			out.HRSource = ZoneTimeFromAverage
+		}
+	}
+	if hasPace {
+		out.PaceSource = ZoneTimeFromStreams
+	} else {
+		out.Pace = ZoneSeconds{}
+		if a.AveragePaceSecondsPerKm > 0 {
+			out.Pace[zones.PaceZone(a.AveragePaceSecondsPerKm)-1] = float64(a.DurationSeconds)
+			out.PaceSource = ZoneTimeFromAverage
+		}
+	}
+	return out
+}
+
+// WeekZoneTime is a week's time in zone across runs.
+type WeekZoneTime struct {
+	WeekStart string      `json:"week_start"`
+	HR        ZoneSeconds `json:"hr_seconds"`
+	Pace      ZoneSeconds `json:"pace_seconds"`
+	// FromStreams is how many of the week's runs were bucketed from
+	// streams rather than their averages.
+	FromStreams int `json:"from_streams"`
+	Runs        int `json:"runs"`
+}
+
+// WeeklyTimeInZones sums run time in zone per Monday-start week, for the
+// given number of weeks up to this one, oldest first. streams holds whatever streams are
+// stored, by activity ID.
+func WeeklyTimeInZones(activities []models.Activity, streams map[uuid.UUID]*models.ActivityStreams, zones AthleteZones, weeks int) []WeekZoneTime {
+	thisWeek := weekStart(time.Now())
+	out := make([]WeekZoneTime, weeks)
+	index := make(map[string]int, weeks)
+	for i := range out {
+		key := thisWeek.AddDate(0, 0, -7*(weeks-1-i)).Format("2006-01-02")
+		out[i].WeekStart = key
+		index[key] = i
+	}
+
+	for _, a := range activities {
+		if a.ActivityType != models.ActivityTypeRun {
+			continue
+		}
+		i, ok := index[weekStart(activityDate(a)).Format("2006-01-02")]
+		if !ok {
+			continue
+		}
+		zt := ActivityTimeInZones(a, streams[a.ID], zones)
+		out[i].HR.add(zt.HR)
+		out[i].Pace.add(zt.Pace)
+		out[i].Runs++
+		if zt.HRSource == ZoneTimeFromStreams || zt.PaceSource == ZoneTimeFromStreams {
+			out[i].FromStreams++
+		}
+	}
+	return out
+}
+
+// Intensity distribution models, from the share of time in the low
+// (Z1-Z2), moderate (Z3) and high (Z4-Z5) intensity domains.
+const (
+	DistributionPolarized     = "polarized"
+	DistributionPyramidal     = "pyramidal"
+	DistributionThreshold     = "threshold"
+	DistributionHighIntensity = "high_intensity"
+	DistributionUnknown       = "insufficient_data"
+)
+
+// IntensityDistributionResult classifies the trailing window's training.
+type IntensityDistributionResult struct {
+	WindowDays  int     `json:"window_days"`
+	LowPct      float64 `json:"low_pct"`
+	ModeratePct float64 `json:"moderate_pct"`
+	HighPct     float64 `json:"high_pct"`
+	// PolarizationIndex is log10(low/moderate * high * 100) over fractions
+	// (Treff et al.); above 2 is polarized. Zero when it is undefined.
+	PolarizationIndex float64 `json:"polarization_index"`
+	Model             string  `json:"model"`
+	// FromStreamsPct is the share of the counted time that came from
+	// streams rather than activity averages.
+	FromStreamsPct float64 `json:"from_streams_pct"`
+}
+
+// IntensityDistribution classifies the last 28 days of running as
+// polarized (most time easy, more hard than moderate), pyramidal (time
+// falling off with intensity), threshold or high-intensity dominant. Heart
+// rate is used where a run has it, pace otherwise.
+func IntensityDistribution(activities []models.Activity, streams map[uuid.UUID]*models.ActivityStreams, zones AthleteZones) IntensityDistributionResult {
+	cutoff := time.Now().AddDate(0, 0, -intensityWindowDays)
+	var domains [3]float64
+	var total, fromStreams float64
+	for _, a := range activities {
+		if a.ActivityType != models.ActivityTypeRun || a.StartTime.Before(cutoff) {
+			continue
+		}
+		zt := ActivityTimeInZones(a, streams[a.ID], zones)
+		secs, source := zt.HR, zt.HRSource
+		if source == ZoneTimeNone {
+			secs, source = zt.Pace, zt.PaceSource
+		}
+		domains[0] += secs[0] + secs[1]
+		domains[1] += secs[2]
+		domains[2] += secs[3] + secs[4]
+		total += secs.total()
+		if source == ZoneTimeFromStreams {
+			fromStreams += secs.total()
+		}
+	}
+
+	result := IntensityDistributionResult{WindowDays: intensityWindowDays, Model: DistributionUnknown}
+	if total == 0 {
+		return result
+	}
+	low, moderate, high := domains[0]/total, domains[1]/total, domains[2]/total
+	result.LowPct = round2(low * 100)
+	result.ModeratePct = round2(moderate * 100)
+	result.HighPct = round2(high * 100)
+	result.FromStreamsPct = round2(fromStreams / total * 100)
+	if moderate > 0 && high > 0 {
+		result.PolarizationIndex = round2(math.Log10(low / moderate * high * 100))
+	}
+
+	switch {
+	case low >= moderate && low >= high && high > moderate:
+		result.Model = DistributionPolarized
+	case low >= moderate && low >= high:
+		result.Model = DistributionPyramidal
+	case moderate >= high:
+		result.Model = DistributionThreshold
+	default:
+		result.Model = DistributionHighIntensity
+	}
+	return result
+}
+
+func weekStart(t time.Time) time.Time {
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// activityDate is the local calendar date of an activity, falling back to
+// its UTC start date on rows without one.
+func activityDate(a models.Activity) time.Time {
+	if a.LocalDate != nil {
+		return *a.LocalDate
+	}
+	return a.StartTime.UTC()
+}
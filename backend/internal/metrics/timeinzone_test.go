@@ -0,0 +1,152 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+func TestStreamTimeInZones(t *testing.T) {
+	zones := DefaultZones(0, 0, 0)
+
+	tests := []struct {
+		name        string
+		streams     models.ActivityStreams
+		wantHR      ZoneSeconds
+		wantPace    ZoneSeconds
+		wantHasHR   bool
+		wantHasPace bool
+	}{
+		{
+			name:      "steady samples",
+			streams:   models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 1, 2, 3}, HeartRate: pq.Int64Array{140, 140, 170, 170}},
+			wantHR:    ZoneSeconds{0, 1, 0, 2, 0},
+			wantHasHR: true,
+		},
+		{
+			name:      "gap capped at ten seconds",
+			streams:   models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5, 65, 70}, HeartRate: pq.Int64Array{140, 140, 160, 160}},
+			wantHR:    ZoneSeconds{0, 5, 15, 0, 0},
+			wantHasHR: true,
+		},
+		{
+			name: "paused sample skipped",
+			streams: models.ActivityStreams{
+				TimeOffsets: pq.Int64Array{0, 5, 10, 15},
+				HeartRate:   pq.Int64Array{140, 140, 180, 140},
+				Moving:      pq.BoolArray{true, true, false, true},
+			},
+			wantHR:    ZoneSeconds{0, 10, 0, 0, 0},
+			wantHasHR: true,
+		},
+		{
+			name:      "heart rate dropout skipped",
+			streams:   models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5, 10}, HeartRate: pq.Int64Array{140, 0, 140}},
+			wantHR:    ZoneSeconds{0, 5, 0, 0, 0},
+			wantHasHR: true,
+		},
+		{
+			name:      "repeated timestamp skipped",
+			streams:   models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5, 5, 10}, HeartRate: pq.Int64Array{140, 140, 180, 140}},
+			wantHR:    ZoneSeconds{0, 10, 0, 0, 0},
+			wantHasHR: true,
+		},
+		{
+			name:        "pace below running speed skipped",
+			streams:     models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5, 10, 15}, Velocity: pq.Float64Array{3, 3, 0.5, 4}},
+			wantPace:    ZoneSeconds{0, 0, 5, 0, 5},
+			wantHasPace: true,
+		},
+		{
+			name:    "stream lengths disagree",
+			streams: models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5, 10}, HeartRate: pq.Int64Array{140, 140}},
+		},
+		{
+			name:    "all samples paused",
+			streams: models.ActivityStreams{TimeOffsets: pq.Int64Array{0, 5}, HeartRate: pq.Int64Array{140, 140}, Moving: pq.BoolArray{false, false}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hr, pace, hasHR, hasPace := StreamTimeInZones(&tt.streams, zones)
+			if hr != tt.wantHR || hasHR != tt.wantHasHR {
+				t.Fatalf("expected HR %v (%v), got %v (%v)", tt.wantHR, tt.wantHasHR, hr, hasHR)
+			}
+			if pace != tt.wantPace || hasPace != tt.wantHasPace {
+				t.Fatalf("expected pace %v (%v), got %v (%v)", tt.wantPace, tt.wantHasPace, pace, hasPace)
+			}
+		})
+	}
+}
+
+func TestIntensityDistributionModels(t *testing.T) {
+	zones := DefaultZones(0, 0, 0)
+
+	// Minutes at an average HR in Z1 (130), Z3 (155) and Z4 (170).
+	tests := []struct {
+		name          string
+		low, mod, hi  int
+		want          string
+		wantHighPct   float64
+		wantPolarized float64
+	}{
+		{name: "polarized", low: 80, mod: 5, hi: 15, want: DistributionPolarized, wantHighPct: 15, wantPolarized: 2.38},
+		{name: "polarized with low equal to high", low: 40, mod: 20, hi: 40, want: DistributionPolarized, wantHighPct: 40, wantPolarized: 1.9},
+		{name: "pyramidal", low: 70, mod: 20, hi: 10, want: DistributionPyramidal, wantHighPct: 10, wantPolarized: 1.54},
+		{name: "pyramidal with moderate equal to high", low: 60, mod: 20, hi: 20, want: DistributionPyramidal, wantHighPct: 20, wantPolarized: 1.78},
+		{name: "threshold", low: 30, mod: 45, hi: 25, want: DistributionThreshold, wantHighPct: 25, wantPolarized: 1.22},
+		{name: "high intensity", low: 30, mod: 20, hi: 50, want: DistributionHighIntensity, wantHighPct: 50, wantPolarized: 1.88},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			activities := []models.Activity{
+				runAtHR(130, tt.low, 1),
+				runAtHR(155, tt.mod, 2),
+				runAtHR(170, tt.hi, 3),
+			}
+			got := IntensityDistribution(activities, nil, zones)
+			if got.Model != tt.want {
+				t.Fatalf("expected %s, got %s (%+v)", tt.want, got.Model, got)
+			}
+			if got.HighPct != tt.wantHighPct || got.PolarizationIndex != tt.wantPolarized {
+				t.Fatalf("expected high %v%% and index %v, got %v%% and %v", tt.wantHighPct, tt.wantPolarized, got.HighPct, got.PolarizationIndex)
+			}
+		})
+	}
+}
+
+func TestIntensityDistributionCountsRecentRunsOnly(t *testing.T) {
+	zones := DefaultZones(0, 0, 0)
+	old := runAtHR(170, 60, intensityWindowDays+2)
+	ride := runAtHR(170, 60, 1)
+	ride.ActivityType = models.ActivityTypeCycling
+
+	got := IntensityDistribution([]models.Activity{old, ride}, nil, zones)
+	if got.Model != DistributionUnknown {
+		t.Fatalf("expected %s, got %s", DistributionUnknown, got.Model)
+	}
+
+	easy := runAtHR(130, 60, 1)
+	got = IntensityDistribution([]models.Activity{old, ride, easy}, nil, zones)
+	if got.LowPct != 100 || got.Model != DistributionPyramidal {
+		t.Fatalf("expected only the easy run counted, got %+v", got)
+	}
+}
+
+// runAtHR is a run of the given minutes at an average heart rate, daysAgo
+// days back.
+func runAtHR(bpm, minutes, daysAgo int) models.Activity {
+	return models.Activity{
+		ID:               uuid.New(),
+		ActivityType:     models.ActivityTypeRun,
+		DurationSeconds:  minutes * 60,
+		StartTime:        time.Now().AddDate(0, 0, -daysAgo),
+		AverageHeartRate: &bpm,
+	}
+}
@@ -0,0 +1,237 @@
+package metrics
+
+import (
+	"fmt"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// Zone is one training zone. HR zones are in bpm, pace zones in sec/km.
+// A nil Max leaves the zone open-ended.
+type Zone struct {
+	Number int    `json:"number"`
+	Label  string `json:"label"`
+	Min    int    `json:"min"`
+	Max    *int   `json:"max"`
+}
+
+// AthleteZones holds an athlete's five HR zones (Z1 lowest heart rate) and
+// five pace zones (Z1 slowest pace), as kept in training_zones. Build it
+// with DefaultZones or ZonesFromTraining; the zero value, or a zone type
+// with fewer than five zones, scores as DefaultZones(0, 0, 0).
+type AthleteZones struct {
+	HR   []Zone `json:"hr"`
+	Pace []Zone `json:"pace"`
+}
+
+// Defaults for athletes who haven't given a max or resting heart rate or
+// set a PR to derive a threshold pace from. The load model shares
+// DefaultMaxHR but not DefaultRestingHR; see DefaultLoadRestingHR.
+const (
+	DefaultMaxHR         = 190
+	DefaultRestingHR     = 60
+	DefaultThresholdPace = 300 // 5:00/km
+)
+
+var (
+	hrZoneLabels   = []string{"Recovery", "Aerobic", "Tempo", "Threshold", "Anaerobic"}
+	paceZoneLabels = []string{"Recovery Pace", "Aerobic Pace", "Tempo Pace", "Threshold Pace", "Anaerobic Pace"}
+)
+
+// KarvonenHRZones calculates five HR zones at 50/60/70/80/90% of heart-rate
+// reserve. Each zone ends a beat below the next; Z5 ends at max HR.
+func KarvonenHRZones(restingHR, maxHR int) []Zone {
+	hrr := float64(maxHR - restingHR)
+	rest := float64(restingHR)
+	bounds := []float64{0.50, 0.60, 0.70, 0.80, 0.90}
+
+	zones := make([]Zone, 0, len(bounds))
+	for i, pct := range bounds {
+		z := Zone{Number: i + 1, Label: hrZoneLabels[i], Min: int(rest + hrr*pct)}
+		top := maxHR
+		if i+1 < len(bounds) {
+			top = int(rest+hrr*bounds[i+1]) - 1
+		}
+		z.Max = &top
+		zones = append(zones, z)
+	}
+	return zones
+}
+
+// ThresholdPaceZones calculates five pace zones (sec/km) from a threshold
+// pace. A larger share of threshold is a slower pace, so Z1 (above 129%)
+// has no maximum and Z5 (under 99%) starts at 0.
+func ThresholdPaceZones(thresholdPace int) []Zone {
+	th := float64(thresholdPace)
+	mins := []int{int(th * 1.29), int(th * 1.14), int(th * 1.06), int(th * 0.99), 0}
+
+	zones := make([]Zone, 0, len(mins))
+	for i, lo := range mins {
+		z := Zone{Number: i + 1, Label: paceZoneLabels[i], Min: lo}
+		if i > 0 {
+			top := mins[i-1] - 1
+			z.Max = &top
+		}
+		zones = append(zones, z)
+	}
+	return zones
+}
+
+// ThresholdPaceFromPRs derives a threshold pace (sec/km) from an athlete's
+// PRs: 10K pace + 15 s/km, else 5K pace + 30 s/km, else
+// DefaultThresholdPace.
+func ThresholdPaceFromPRs(prs []models.PersonalRecord) int {
+	byLabel := make(map[string]models.PersonalRecord, len(prs))
+	for _, pr := range prs {
+		byLabel[pr.Label] = pr
+	}
+	if pr, ok := byLabel["10K"]; ok && pr.TimeSeconds > 0 {
+		return pr.TimeSeconds/10 + 15
+	}
+	if pr, ok := byLabel["5K"]; ok && pr.TimeSeconds > 0 {
+		return pr.TimeSeconds/5 + 30
+	}
+	return DefaultThresholdPace
+}
+
+// DefaultZones calculates the zones the profile would save for an athlete
+// with no zones of their own: Karvonen HR zones from resting and max HR,
+// and pace zones from a threshold pace. Zeros mean unknown and take the
+// defaults.
+func DefaultZones(restingHR, maxHR float64, thresholdPace int) AthleteZones {
+	if maxHR == 0 {
+		maxHR = DefaultMaxHR
+	}
+	if restingHR == 0 {
+		restingHR = DefaultRestingHR
+	}
+	if thresholdPace == 0 {
+		thresholdPace = DefaultThresholdPace
+	}
+	return AthleteZones{
+		HR:   KarvonenHRZones(int(restingHR), int(maxHR)),
+		Pace: ThresholdPaceZones(thresholdPace),
+	}
+}
+
+// ZonesFromTraining builds an athlete's zones from their training_zones
+// rows. A zone type without a complete, ordered set of five falls back to
+// DefaultZones for the given resting and max HR and threshold pace.
+func ZonesFromTraining(hr, pace []models.TrainingZone, restingHR, maxHR float64, thresholdPace int) AthleteZones {
+	zones := DefaultZones(restingHR, maxHR, thresholdPace)
+	if saved, ok := savedZones(hr, true); ok {
+		zones.HR = saved
+	}
+	if saved, ok := savedZones(pace, false); ok {
+		zones.Pace = saved
+	}
+	return zones
+}
+
+// savedZones converts five training_zones rows. HR zone minimums rise with
+// the zone number; pace zone minimums fall.
+func savedZones(rows []models.TrainingZone, ascending bool) ([]Zone, bool) {
+	if len(rows) != 5 {
+		return nil, false
+	}
+	zones := make([]Zone, 0, len(rows))
+	for i, row := range rows {
+		if row.ZoneNumber != i+1 || row.MinValue == nil {
+			return nil, false
+		}
+		z := Zone{Number: row.ZoneNumber, Min: *row.MinValue, Max: row.MaxValue}
+		if row.Label != nil {
+			z.Label = *row.Label
+		}
+		if i > 0 {
+			prev := zones[i-1].Min
+			if (ascending && z.Min <= prev) || (!ascending && z.Min >= prev) {
+				return nil, false
+			}
+		}
+		zones = append(zones, z)
+	}
+	return zones, true
+}
+
+// complete returns z with the default zones in place of a zone type that
+// has fewer than five.
+func (z AthleteZones) complete() AthleteZones {
+	if len(z.HR) >= 5 && len(z.Pace) >= 5 {
+		return z
+	}
+	defaults := DefaultZones(0, 0, 0)
+	if len(z.HR) < 5 {
+		z.HR = defaults.HR
+	}
+	if len(z.Pace) < 5 {
+		z.Pace = defaults.Pace
+	}
+	return z
+}
+
+// HRZone returns the zone number (1-5) a heart rate falls in. Rates below
+// Z1 count as Z1 and above Z5 as Z5.
+func (z AthleteZones) HRZone(bpm float64) int {
+	z = z.complete()
+	for i := len(z.HR) - 1; i > 0; i-- {
+		if bpm >= float64(z.HR[i].Min) {
+			return z.HR[i].Number
+		}
+	}
+	return 1
+}
+
+// PaceZone returns the zone number (1-5) a pace in sec/km falls in.
+func (z AthleteZones) PaceZone(secPerKm float64) int {
+	z = z.complete()
+	for i := 0; i < len(z.Pace)-1; i++ {
+		if secPerKm >= float64(z.Pace[i].Min) {
+			return z.Pace[i].Number
+		}
+	}
+	return len(z.Pace)
+}
+
+// HardHR is the heart rate at which a session counts as hard: the bottom
+// of the athlete's threshold zone (Z4).
+func (z AthleteZones) HardHR() int {
+	return z.complete().HR[3].Min
+}
+
+// hrZoneTarget is the heart rate at the middle of an HR zone. Z5 has no
+// meaningful middle, so its bottom is used.
+func (z AthleteZones) hrZoneTarget(number int) float64 {
+	z = z.complete()
+	zone := z.HR[number-1]
+	if zone.Max == nil || number == len(z.HR) {
+		return float64(zone.Min)
+	}
+	return float64(zone.Min+*zone.Max) / 2
+}
+
+// paceZoneTarget is the pace in sec/km at the middle of a pace zone. The
+// open-ended ends use their one bound: Z1's minimum and Z5's maximum.
+func (z AthleteZones) paceZoneTarget(number int) float64 {
+	z = z.complete()
+	zone := z.Pace[number-1]
+	switch {
+	case zone.Max == nil:
+		return float64(zone.Min)
+	case zone.Min == 0:
+		return float64(*zone.Max)
+	}
+	return float64(zone.Min+*zone.Max) / 2
+}
+
+// hrRangeLabel formats an HR zone's bounds for display, e.g. "136-149".
+func (z AthleteZones) hrRangeLabel(i int) string {
+	switch {
+	case i == 0 && len(z.HR) > 1:
+		return fmt.Sprintf("< %d", z.HR[1].Min)
+	case i == len(z.HR)-1 || z.HR[i].Max == nil:
+		return fmt.Sprintf("%d+", z.HR[i].Min)
+	default:
+		return fmt.Sprintf("%d-%d", z.HR[i].Min, *z.HR[i].Max)
+	}
+}
@@ -0,0 +1,148 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// With the defaults (resting 60, max 190, threshold 5:00/km) the zones are:
+// HR 125-137, 138-150, 151-163, 164-176, 177-190 and pace 387+, 341-386,
+// 318-340, 297-317, 0-296 (300 x 1.14 truncates to 341).
+func TestHRZoneBoundaries(t *testing.T) {
+	zones := DefaultZones(0, 0, 0)
+
+	tests := []struct {
+		bpm  float64
+		want int
+	}{
+		{bpm: 90, want: 1},
+		{bpm: 137, want: 1},
+		{bpm: 138, want: 2},
+		{bpm: 150, want: 2},
+		{bpm: 151, want: 3},
+		{bpm: 163, want: 3},
+		{bpm: 164, want: 4},
+		{bpm: 176, want: 4},
+		{bpm: 177, want: 5},
+		{bpm: 210, want: 5},
+	}
+
+	for _, tt := range tests {
+		if got := zones.HRZone(tt.bpm); got != tt.want {
+			t.Fatalf("HRZone(%v): expected Z%d, got Z%d", tt.bpm, tt.want, got)
+		}
+	}
+}
+
+func TestPaceZoneBoundaries(t *testing.T) {
+	zones := DefaultZones(0, 0, 0)
+
+	tests := []struct {
+		secPerKm float64
+		want     int
+	}{
+		{secPerKm: 480, want: 1},
+		{secPerKm: 387, want: 1},
+		{secPerKm: 386, want: 2},
+		{secPerKm: 341, want: 2},
+		{secPerKm: 340, want: 3},
+		{secPerKm: 318, want: 3},
+		{secPerKm: 317, want: 4},
+		{secPerKm: 297, want: 4},
+		{secPerKm: 296, want: 5},
+		{secPerKm: 180, want: 5},
+	}
+
+	for _, tt := range tests {
+		if got := zones.PaceZone(tt.secPerKm); got != tt.want {
+			t.Fatalf("PaceZone(%v): expected Z%d, got Z%d", tt.secPerKm, tt.want, got)
+		}
+	}
+}
+
+func TestHardHR(t *testing.T) {
+	tests := []struct {
+		name   string
+		zones  AthleteZones
+		wantHR int
+	}{
+		{name: "defaults", zones: DefaultZones(0, 0, 0), wantHR: 164},
+		{name: "athlete HR", zones: DefaultZones(50, 200, 0), wantHR: 170},
+		{name: "saved zones", zones: ZonesFromTraining(trainingZones("hr", 120, 135, 150, 160, 175), nil, 0, 0, 0), wantHR: 160},
+		{name: "zero value", zones: AthleteZones{}, wantHR: 164},
+		{name: "three HR zones", zones: AthleteZones{HR: KarvonenHRZones(60, 190)[:3]}, wantHR: 164},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.zones.HardHR(); got != tt.wantHR {
+				t.Fatalf("expected %d, got %d", tt.wantHR, got)
+			}
+		})
+	}
+}
+
+func TestZonesFromTrainingFallsBackOnIncompleteRows(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    []models.TrainingZone
+		wantMin int
+	}{
+		{name: "complete", rows: trainingZones("hr", 120, 135, 150, 160, 175), wantMin: 120},
+		{name: "four zones", rows: trainingZones("hr", 120, 135, 150, 160), wantMin: 125},
+		{name: "out of order", rows: trainingZones("hr", 120, 150, 135, 160, 175), wantMin: 125},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zones := ZonesFromTraining(tt.rows, nil, 0, 0, 0)
+			if got := zones.HR[0].Min; got != tt.wantMin {
+				t.Fatalf("expected Z1 from %d, got %d", tt.wantMin, got)
+			}
+		})
+	}
+}
+
+func TestThresholdPaceFromPRs(t *testing.T) {
+	tests := []struct {
+		name string
+		prs  []models.PersonalRecord
+		want int
+	}{
+		{name: "no PRs", want: DefaultThresholdPace},
+		{name: "10K", prs: []models.PersonalRecord{{Label: "5K", TimeSeconds: 1200}, {Label: "10K", TimeSeconds: 2500}}, want: 265},
+		{name: "5K", prs: []models.PersonalRecord{{Label: "5K", TimeSeconds: 1200}}, want: 270},
+		{name: "other distances only", prs: []models.PersonalRecord{{Label: "Marathon", TimeSeconds: 12600}}, want: DefaultThresholdPace},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ThresholdPaceFromPRs(tt.prs); got != tt.want {
+				t.Fatalf("expected %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// trainingZones builds training_zones rows with the given minimums.
+func trainingZones(zoneType string, mins ...int) []models.TrainingZone {
+	rows := make([]models.TrainingZone, len(mins))
+	for i := range mins {
+		rows[i] = models.TrainingZone{ZoneType: zoneType, ZoneNumber: i + 1, MinValue: &mins[i]}
+	}
+	return rows
+}
+
+func TestZeroValueZonesScoreAsDefaults(t *testing.T) {
+	var zones AthleteZones
+	if got := zones.PaceZone(296); got != 5 {
+		t.Fatalf("expected Z5, got Z%d", got)
+	}
+	if got := zones.HRZone(164); got != 4 {
+		t.Fatalf("expected Z4, got Z%d", got)
+	}
+	if got := estimateZoneScore(zones, "easy", 138); got != 60 {
+		t.Fatalf("expected an on-target easy run, got %v", got)
+	}
+}
@@ -1,10 +1,13 @@
 package models
 
 import (
+	"database/sql/driver"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // User represents a user in the system
@@ -15,16 +18,87 @@ type User struct {
 	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
 }
 
-// StravaConnection represents a user's Strava OAuth connection
-type StravaConnection struct {
-	ID              uuid.UUID `json:"id" db:"id"`
-	UserID          uuid.UUID `json:"user_id" db:"user_id"`
-	StravaAthleteID int64     `json:"strava_athlete_id" db:"strava_athlete_id"`
-	AccessToken     string    `json:"-" db:"access_token"`
-	RefreshToken    string    `json:"-" db:"refresh_token"`
-	TokenExpiresAt  time.Time `json:"token_expires_at" db:"token_expires_at"`
-	CreatedAt       time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
+// StravaWebhookEvent is a push subscription event queued for the webhook worker.
+// Status moves pending → processing → done, or back to pending with a later
+// available_at on a retryable failure, and finally failed after too many attempts.
+type StravaWebhookEvent struct {
+	ID             uuid.UUID       `json:"id" db:"id"`
+	ObjectType     string          `json:"object_type" db:"object_type"`
+	ObjectID       int64           `json:"object_id" db:"object_id"`
+	AspectType     string          `json:"aspect_type" db:"aspect_type"`
+	OwnerID        int64           `json:"owner_id" db:"owner_id"`
+	SubscriptionID *int64          `json:"subscription_id" db:"subscription_id"`
+	EventTime      int64           `json:"event_time" db:"event_time"`
+	Updates        json.RawMessage `json:"updates" db:"updates"`
+	Status         string          `json:"status" db:"status"`
+	Attempts       int             `json:"attempts" db:"attempts"`
+	LastError      *string         `json:"last_error,omitempty" db:"last_error"`
+	AvailableAt    time.Time       `json:"available_at" db:"available_at"`
+	ClaimedAt      *time.Time      `json:"claimed_at,omitempty" db:"claimed_at"`
+	ReceivedAt     time.Time       `json:"received_at" db:"received_at"`
+	ProcessedAt    *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+}
+
+// GarminNotification is one record from a Garmin ping/push notification,
+// queued for the Garmin worker. Status follows the same lifecycle as
+// StravaWebhookEvent.
+type GarminNotification struct {
+	ID           uuid.UUID       `json:"id" db:"id"`
+	SummaryType  string          `json:"summary_type" db:"summary_type"`
+	GarminUserID string          `json:"garmin_user_id" db:"garmin_user_id"`
+	Record       json.RawMessage `json:"record" db:"record"`
+	RecordHash   string          `json:"record_hash" db:"record_hash"`
+	Status       string          `json:"status" db:"status"`
+	Attempts     int             `json:"attempts" db:"attempts"`
+	LastError    *string         `json:"last_error,omitempty" db:"last_error"`
+	AvailableAt  time.Time       `json:"available_at" db:"available_at"`
+	ClaimedAt    *time.Time      `json:"claimed_at,omitempty" db:"claimed_at"`
+	ReceivedAt   time.Time       `json:"received_at" db:"received_at"`
+	ProcessedAt  *time.Time      `json:"processed_at,omitempty" db:"processed_at"`
+}
+
+// StravaBackfillJob tracks the full-history import for one Strava connection.
+// The cursor is a fixed before-anchor plus the next page to request, so the
+// worker can resume after a restart without re-walking earlier pages.
+type StravaBackfillJob struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	ConnectionID        uuid.UUID  `json:"connection_id" db:"connection_id"`
+	UserID              uuid.UUID  `json:"user_id" db:"user_id"`
+	Status              string     `json:"status" db:"status"` // pending, running, done, failed
+	CursorBefore        time.Time  `json:"cursor_before" db:"cursor_before"`
+	NextPage            int        `json:"next_page" db:"next_page"`
+	PagesFetched        int        `json:"pages_fetched" db:"pages_fetched"`
+	ActivitiesImported  int        `json:"activities_imported" db:"activities_imported"`
+	OldestActivityAt    *time.Time `json:"oldest_activity_at,omitempty" db:"oldest_activity_at"`
+	ConsecutiveFailures int        `json:"consecutive_failures" db:"consecutive_failures"`
+	LastError           *string    `json:"last_error,omitempty" db:"last_error"`
+	NextRunAt           time.Time  `json:"next_run_at" db:"next_run_at"`
+	ClaimedAt           *time.Time `json:"-" db:"claimed_at"`
+	StartedAt           *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt         *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt           time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// ActivityImportJob tracks an asynchronous archive import. RowErrors holds
+// up to a fixed number of per-row failures as JSON objects.
+type ActivityImportJob struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	UserID        uuid.UUID       `json:"user_id" db:"user_id"`
+	Kind          string          `json:"kind" db:"kind"` // strava_archive, apple_health
+	FileName      string          `json:"file_name" db:"file_name"`
+	Status        string          `json:"status" db:"status"` // pending, running, done, failed
+	TotalRows     int             `json:"total_rows" db:"total_rows"`
+	ProcessedRows int             `json:"processed_rows" db:"processed_rows"`
+	ImportedRows  int             `json:"imported_rows" db:"imported_rows"`
+	SkippedRows   int             `json:"skipped_rows" db:"skipped_rows"`
+	FailedRows    int             `json:"failed_rows" db:"failed_rows"`
+	RowErrors     json.RawMessage `json:"row_errors" db:"row_errors"`
+	LastError     *string         `json:"last_error,omitempty" db:"last_error"`
+	StartedAt     *time.Time      `json:"started_at,omitempty" db:"started_at"`
+	CompletedAt   *time.Time      `json:"completed_at,omitempty" db:"completed_at"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at" db:"updated_at"`
 }
 
 // RaceGoal represents a user's race goal (the "North Star")
@@ -48,7 +122,7 @@ type RaceGoal struct {
 type Activity struct {
 	ID                      uuid.UUID `json:"id" db:"id"`
 	UserID                  uuid.UUID `json:"user_id" db:"user_id"`
-	Source                  string    `json:"source" db:"source"` // "strava", "garmin", "manual"
+	Source                  string    `json:"source" db:"source"` // "strava", "garmin", "coros", "file", "apple_health", "manual"
 	SourceActivityID        string    `json:"source_activity_id" db:"source_activity_id"`
 	ActivityType            string    `json:"activity_type" db:"activity_type"` // "run", "long_run", "workout", "race"
 	Name                    string    `json:"name" db:"name"`
@@ -66,11 +140,191 @@ type Activity struct {
 	// later phases of the timezone unification work; nullable on legacy rows.
 	LocalDate    *time.Time     `json:"local_date,omitempty" db:"local_date"`
 	CustomFields map[string]any `json:"custom_fields,omitempty" db:"-"`
+	// RawCustomFields receives the custom_fields column so SELECT * scans
+	// into Activity; CustomFields is the decoded form used in responses.
+	RawCustomFields json.RawMessage `json:"-" db:"custom_fields"`
+
+	// Detail fields, populated from the source's full activity payload.
+	WorkoutType     *string    `json:"workout_type,omitempty" db:"workout_type"` // "race", "long_run", "workout"
+	Calories        *float64   `json:"calories,omitempty" db:"calories"`
+	DeviceName      *string    `json:"device_name,omitempty" db:"device_name"`
+	GearID          *string    `json:"gear_id,omitempty" db:"gear_id"`
+	Description     *string    `json:"description,omitempty" db:"description"`
+	DetailsSyncedAt *time.Time `json:"-" db:"details_synced_at"`
+	// MergedInto is set on the losing side of a merged duplicate pair. Such
+	// rows are hidden from lists, summaries and metrics until unmerged.
+	MergedInto *uuid.UUID `json:"merged_into,omitempty" db:"merged_into"`
+	// FieldSources names the source that supplied each field group when the
+	// activity was recorded by more than one.
+	FieldSources FieldSources `json:"field_sources,omitempty" db:"field_sources"`
+}
+
+// FieldSources maps a field group ("name", "heart_rate", ...) to the source
+// that supplied it. Stored as a JSONB object.
+type FieldSources map[string]string
+
+// Scan implements sql.Scanner.
+func (f *FieldSources) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*f = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("field_sources: unsupported type %T", src)
+	}
+	return json.Unmarshal(data, f)
+}
+
+// Value implements driver.Valuer.
+func (f FieldSources) Value() (driver.Value, error) {
+	if f == nil {
+		return []byte("{}"), nil
+	}
+	return json.Marshal(f)
+}
+
+// ActivitySourceRecord is one source's copy of an activity, kept so fields
+// can be merged across sources. Data is the normalized sync payload.
+type ActivitySourceRecord struct {
+	ActivityID       uuid.UUID       `json:"activity_id" db:"activity_id"`
+	Source           string          `json:"source" db:"source"`
+	SourceActivityID string          `json:"source_activity_id" db:"source_activity_id"`
+	Data             json.RawMessage `json:"data" db:"data"`
+	CreatedAt        time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time       `json:"updated_at" db:"updated_at"`
+}
+
+// UserSourcePriority is an athlete's source order for one field group, or
+// for the activity as a whole when Field is "default".
+type UserSourcePriority struct {
+	UserID    uuid.UUID      `json:"user_id" db:"user_id"`
+	Field     string         `json:"field" db:"field"`
+	Sources   pq.StringArray `json:"sources" db:"sources"`
+	UpdatedAt time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// ActivityDuplicate is a pair of activities suspected to be the same
+// session. ActivityID sorts before DuplicateActivityID. The Moved* fields
+// and GearCopied record what a merge changed, so it can be undone.
+type ActivityDuplicate struct {
+	ID                     uuid.UUID      `json:"id" db:"id"`
+	UserID                 uuid.UUID      `json:"user_id" db:"user_id"`
+	ActivityID             uuid.UUID      `json:"activity_id" db:"activity_id"`
+	DuplicateActivityID    uuid.UUID      `json:"duplicate_activity_id" db:"duplicate_activity_id"`
+	Status                 string         `json:"status" db:"status"` // pending, merged, dismissed
+	StartDeltaSeconds      int            `json:"start_delta_seconds" db:"start_delta_seconds"`
+	WinnerActivityID       *uuid.UUID     `json:"winner_activity_id,omitempty" db:"winner_activity_id"`
+	MovedCalendarEntryIDs  pq.StringArray `json:"-" db:"moved_calendar_entry_ids"`
+	MovedPersonalRecordIDs pq.StringArray `json:"-" db:"moved_personal_record_ids"`
+	GearCopied             bool           `json:"-" db:"gear_copied"`
+	ResolvedAt             *time.Time     `json:"resolved_at,omitempty" db:"resolved_at"`
+	CreatedAt              time.Time      `json:"created_at" db:"created_at"`
+	UpdatedAt              time.Time      `json:"updated_at" db:"updated_at"`
+}
+
+// Other returns the pair's activity that isn't id, and false when id is in
+// neither side.
+func (d *ActivityDuplicate) Other(id uuid.UUID) (uuid.UUID, bool) {
+	switch id {
+	case d.ActivityID:
+		return d.DuplicateActivityID, true
+	case d.DuplicateActivityID:
+		return d.ActivityID, true
+	}
+	return uuid.Nil, false
+}
+
+// Activity duplicate statuses (activity_duplicates.status).
+const (
+	DuplicateStatusPending   = "pending"
+	DuplicateStatusMerged    = "merged"
+	DuplicateStatusDismissed = "dismissed"
+)
+
+// Activity workout types (activities.workout_type). NULL means a default,
+// unflagged session.
+const (
+	WorkoutTypeRace    = "race"
+	WorkoutTypeLongRun = "long_run"
+	WorkoutTypeWorkout = "workout"
+)
+
+// IsRace reports whether the athlete flagged the activity as a race.
+func (a *Activity) IsRace() bool {
+	return a.WorkoutType != nil && *a.WorkoutType == WorkoutTypeRace
+}
+
+// ActivityLap is one lap of an activity, in the order recorded.
+type ActivityLap struct {
+	ID                  uuid.UUID  `json:"id" db:"id"`
+	ActivityID          uuid.UUID  `json:"activity_id" db:"activity_id"`
+	LapIndex            int        `json:"lap_index" db:"lap_index"`
+	Name                *string    `json:"name,omitempty" db:"name"`
+	DistanceMeters      float64    `json:"distance_meters" db:"distance_meters"`
+	ElapsedSeconds      int        `json:"elapsed_seconds" db:"elapsed_seconds"`
+	MovingSeconds       int        `json:"moving_seconds" db:"moving_seconds"`
+	StartTime           *time.Time `json:"start_time,omitempty" db:"start_time"`
+	AverageSpeedMps     *float64   `json:"average_speed_mps,omitempty" db:"average_speed_mps"`
+	MaxSpeedMps         *float64   `json:"max_speed_mps,omitempty" db:"max_speed_mps"`
+	AverageHeartRate    *float64   `json:"average_heart_rate,omitempty" db:"average_heart_rate"`
+	MaxHeartRate        *float64   `json:"max_heart_rate,omitempty" db:"max_heart_rate"`
+	AverageCadence      *float64   `json:"average_cadence,omitempty" db:"average_cadence"`
+	ElevationGainMeters *float64   `json:"elevation_gain_meters,omitempty" db:"elevation_gain_meters"`
+	PaceZone            *int       `json:"pace_zone,omitempty" db:"pace_zone"`
+}
+
+// Split units (activity_splits.unit).
+const (
+	SplitUnitMetric   = "metric"   // 1 km
+	SplitUnitStandard = "standard" // 1 mile
+)
+
+// ActivitySplit is one even-distance split of an activity.
+type ActivitySplit struct {
+	ID                           uuid.UUID `json:"id" db:"id"`
+	ActivityID                   uuid.UUID `json:"activity_id" db:"activity_id"`
+	Unit                         string    `json:"unit" db:"unit"`
+	SplitIndex                   int       `json:"split_index" db:"split_index"`
+	DistanceMeters               float64   `json:"distance_meters" db:"distance_meters"`
+	ElapsedSeconds               int       `json:"elapsed_seconds" db:"elapsed_seconds"`
+	MovingSeconds                int       `json:"moving_seconds" db:"moving_seconds"`
+	ElevationDifferenceMeters    *float64  `json:"elevation_difference_meters,omitempty" db:"elevation_difference_meters"`
+	AverageSpeedMps              *float64  `json:"average_speed_mps,omitempty" db:"average_speed_mps"`
+	AverageGradeAdjustedSpeedMps *float64  `json:"average_grade_adjusted_speed_mps,omitempty" db:"average_grade_adjusted_speed_mps"`
+	AverageHeartRate             *float64  `json:"average_heart_rate,omitempty" db:"average_heart_rate"`
+	PaceZone                     *int      `json:"pace_zone,omitempty" db:"pace_zone"`
+}
+
+// ActivityStreams holds the per-sample series recorded for one activity.
+// Every non-nil array is index-aligned with TimeOffsets. LatLng is a JSON
+// array of [lat, lng] pairs.
+type ActivityStreams struct {
+	ActivityID  uuid.UUID       `json:"activity_id" db:"activity_id"`
+	UserID      uuid.UUID       `json:"user_id" db:"user_id"`
+	Source      string          `json:"source" db:"source"`
+	SampleCount int             `json:"sample_count" db:"sample_count"`
+	TimeOffsets pq.Int64Array   `json:"time" db:"time_offsets"` // seconds since start
+	Distance    pq.Float64Array `json:"distance,omitempty" db:"distance_meters"`
+	HeartRate   pq.Int64Array   `json:"heartrate,omitempty" db:"heart_rate"`
+	Velocity    pq.Float64Array `json:"velocity,omitempty" db:"velocity_mps"`
+	Cadence     pq.Int64Array   `json:"cadence,omitempty" db:"cadence"`
+	Altitude    pq.Float64Array `json:"altitude,omitempty" db:"altitude_meters"`
+	LatLng      json.RawMessage `json:"latlng,omitempty" db:"latlng"`
+	Moving      pq.BoolArray    `json:"moving,omitempty" db:"moving"`
+	CreatedAt   time.Time       `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at" db:"updated_at"`
 }
 
 // ConnectedIntegration tracks a user's OAuth connection to an external data source.
 // Valid sources: "strava", "garmin", "coros".
-// Strava is always is_primary when connected. Only one primary per user (enforced by DB index).
+// The highest-priority active source is is_primary, so Strava is always primary
+// when connected. Only one primary per user (enforced by DB index).
+// For Strava, ExternalUserID is the athlete ID.
 type ConnectedIntegration struct {
 	ID             uuid.UUID  `json:"id" db:"id"`
 	UserID         uuid.UUID  `json:"user_id" db:"user_id"`
@@ -81,10 +335,69 @@ type ConnectedIntegration struct {
 	ExternalUserID *string    `json:"external_user_id" db:"external_user_id"`
 	IsActive       bool       `json:"is_active" db:"is_active"`
 	IsPrimary      bool       `json:"is_primary" db:"is_primary"`
-	ConnectedAt    time.Time  `json:"connected_at" db:"connected_at"`
-	LastSyncedAt   *time.Time `json:"last_synced_at" db:"last_synced_at"`
+	Status         string     `json:"status" db:"status"`
+	LastError      *string    `json:"last_error,omitempty" db:"last_error"`
+	// GrantedScopes is the comma-separated OAuth scope list the athlete
+	// granted, or nil for connections made before it was recorded.
+	GrantedScopes *string    `json:"granted_scopes,omitempty" db:"granted_scopes"`
+	ConnectedAt   time.Time  `json:"connected_at" db:"connected_at"`
+	LastSyncedAt  *time.Time `json:"last_synced_at" db:"last_synced_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Integration statuses (connected_integrations.status).
+const (
+	IntegrationStatusActive         = "active"
+	IntegrationStatusReauthRequired = "reauth_required" // the source rejected our refresh token
+)
+
+// SyncRun records one sync attempt against a provider.
+type SyncRun struct {
+	ID           uuid.UUID `json:"id" db:"id"`
+	UserID       uuid.UUID `json:"user_id" db:"user_id"`
+	Provider     string    `json:"provider" db:"provider"`
+	Mode         string    `json:"mode" db:"mode"`
+	Status       string    `json:"status" db:"status"`
+	PagesFetched int       `json:"pages_fetched" db:"pages_fetched"`
+	Inserted     int       `json:"inserted" db:"inserted"`
+	Updated      int       `json:"updated" db:"updated"`
+	Skipped      int       `json:"skipped" db:"skipped"`
+	Failed       int       `json:"failed" db:"failed"`
+	ErrorClass   *string   `json:"error_class,omitempty" db:"error_class"`
+	ErrorMessage *string   `json:"error_message,omitempty" db:"error_message"`
+	StartedAt    time.Time `json:"started_at" db:"started_at"`
+	FinishedAt   time.Time `json:"finished_at" db:"finished_at"`
+	DurationMs   int64     `json:"duration_ms" db:"duration_ms"`
 }
 
+// Sync run modes (sync_runs.mode).
+const (
+	SyncModeIncremental     = "incremental"
+	SyncModeInitialBackfill = "initial_backfill"
+	SyncModeWebhook         = "webhook"
+	// SyncModeReconcile re-reads a recent window to pick up activities
+	// edited or deleted at the source.
+	SyncModeReconcile = "reconcile"
+)
+
+// Sync run statuses (sync_runs.status). A partial run stopped early or
+// failed to store some activities.
+const (
+	SyncStatusSuccess = "success"
+	SyncStatusPartial = "partial"
+	SyncStatusFailed  = "failed"
+)
+
+// Sync error classes (sync_runs.error_class).
+const (
+	SyncErrorRateLimited  = "rate_limited"
+	SyncErrorTokenRevoked = "token_revoked"
+	SyncErrorNotConnected = "not_connected"
+	SyncErrorDatabase     = "database"
+	SyncErrorProvider     = "provider"
+	SyncErrorUnknown      = "unknown"
+)
+
 // CoachSession groups a set of coach messages into a named conversation.
 type CoachSession struct {
 	ID        uuid.UUID `json:"id" db:"id"`
@@ -205,6 +518,18 @@ type PersonalRecord struct {
 	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
 }
 
+// DailyWellness is one day of recovery markers from a health platform.
+type DailyWellness struct {
+	ID               uuid.UUID `json:"id" db:"id"`
+	UserID           uuid.UUID `json:"user_id" db:"user_id"`
+	Date             time.Time `json:"date" db:"date"`
+	Source           string    `json:"source" db:"source"` // apple_health
+	RestingHeartRate *int      `json:"resting_heart_rate" db:"resting_heart_rate"`
+	HRVSDNNMs        *float64  `json:"hrv_sdnn_ms" db:"hrv_sdnn_ms"`
+	CreatedAt        time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at" db:"updated_at"`
+}
+
 // TrainingZone represents HR or Pace zones (Z1-Z5)
 type TrainingZone struct {
 	ID               uuid.UUID `json:"id" db:"id"`
@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+var (
+	// ErrDuplicateNotFound is returned when a duplicate pair does not exist
+	// or belongs to a different user.
+	ErrDuplicateNotFound = errors.New("duplicate pair not found")
+
+	// ErrDuplicateResolved is returned when merging or dismissing a pair
+	// that is no longer pending, including one whose activity has since
+	// been merged into a third.
+	ErrDuplicateResolved = errors.New("duplicate pair is already resolved")
+
+	// ErrDuplicateNotMerged is returned when unmerging a pair that isn't
+	// merged.
+	ErrDuplicateNotMerged = errors.New("duplicate pair is not merged")
+
+	// ErrInvalidDuplicateWinner is returned when the chosen winner is not
+	// one of the pair's activities.
+	ErrInvalidDuplicateWinner = errors.New("winner must be one of the pair's activities")
+)
+
+// DuplicatePair is a pending pair with both activities, for review.
+type DuplicatePair struct {
+	models.ActivityDuplicate
+	Activities []models.Activity `json:"activities"`
+}
+
+// ListDuplicates returns the user's pending duplicate pairs, newest first.
+// Pairs where either side has been merged away are left out.
+func (s *ActivityService) ListDuplicates(ctx context.Context, userID uuid.UUID) ([]DuplicatePair, error) {
+	var duplicates []models.ActivityDuplicate
+	err := s.db.SelectContext(ctx, &duplicates, `
+		SELECT d.* FROM activity_duplicates d
+		JOIN activities a ON a.id = d.activity_id AND a.merged_into IS NULL
+		JOIN activities b ON b.id = d.duplicate_activity_id AND b.merged_into IS NULL
+		WHERE d.user_id = $1 AND d.status = $2
+		ORDER BY d.created_at DESC
+	`, userID, models.DuplicateStatusPending)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list duplicates: %w", err)
+	}
+	if len(duplicates) == 0 {
+		return []DuplicatePair{}, nil
+	}
+
+	ids := make(pq.StringArray, 0, 2*len(duplicates))
+	for _, d := range duplicates {
+		ids = append(ids, d.ActivityID.String(), d.DuplicateActivityID.String())
+	}
+	var activities []models.Activity
+	if err := s.db.SelectContext(ctx, &activities, `
+		SELECT * FROM activities WHERE user_id = $1 AND id = ANY($2::uuid[])
+	`, userID, ids); err != nil {
+		return nil, fmt.Errorf("failed to load duplicate activities: %w", err)
+	}
+	byID := make(map[uuid.UUID]models.Activity, len(activities))
+	for _, a := range activities {
+		byID[a.ID] = a
+	}
+
+	pairs := make([]DuplicatePair, 0, len(duplicates))
+	for _, d := range duplicates {
+		pairs = append(pairs, DuplicatePair{
+			ActivityDuplicate: d,
+			Activities:        []models.Activity{byID[d.ActivityID], byID[d.DuplicateActivityID]},
+		})
+	}
+	return pairs, nil
+}
+
+// MergeDuplicate keeps winnerID and hides the pair's other activity behind
+// it. Calendar entries and personal records pointing at the loser move to
+// the winner, and the loser's gear is copied when the winner has none.
+func (s *ActivityService) MergeDuplicate(ctx context.Context, userID, pairID, winnerID uuid.UUID) (*models.ActivityDuplicate, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pair, err := lockDuplicate(ctx, tx, userID, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Status != models.DuplicateStatusPending {
+		return nil, ErrDuplicateResolved
+	}
+	loserID, ok := pair.Other(winnerID)
+	if !ok {
+		return nil, ErrInvalidDuplicateWinner
+	}
+
+	var visible int
+	if err := tx.GetContext(ctx, &visible, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM activities
+			WHERE id IN ($1, $2) AND merged_into IS NULL
+			FOR UPDATE
+		) v
+	`, winnerID, loserID); err != nil {
+		return nil, err
+	}
+	if visible != 2 {
+		return nil, ErrDuplicateResolved
+	}
+
+	var calendarIDs, recordIDs pq.StringArray
+	if err := tx.GetContext(ctx, &calendarIDs, `
+		WITH moved AS (
+			UPDATE training_calendar SET completed_activity_id = $1, updated_at = NOW()
+			WHERE completed_activity_id = $2
+			RETURNING id
+		)
+		SELECT COALESCE(array_agg(id), '{}') FROM moved
+	`, winnerID, loserID); err != nil {
+		return nil, fmt.Errorf("failed to move calendar entries: %w", err)
+	}
+	if err := tx.GetContext(ctx, &recordIDs, `
+		WITH moved AS (
+			UPDATE personal_records SET activity_id = $1, updated_at = NOW()
+			WHERE activity_id = $2
+			RETURNING id
+		)
+		SELECT COALESCE(array_agg(id), '{}') FROM moved
+	`, winnerID, loserID); err != nil {
+		return nil, fmt.Errorf("failed to move personal records: %w", err)
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE activities w SET gear_id = l.gear_id
+		FROM activities l
+		WHERE w.id = $1 AND l.id = $2 AND w.gear_id IS NULL AND l.gear_id IS NOT NULL
+	`, winnerID, loserID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy gear: %w", err)
+	}
+	gearCopied, _ := res.RowsAffected()
+
+	if _, err := tx.ExecContext(ctx,
+		"UPDATE activities SET merged_into = $1 WHERE id = $2", winnerID, loserID); err != nil {
+		return nil, fmt.Errorf("failed to merge activity: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx,
+		"DELETE FROM cross_training_sessions WHERE activity_id = $1", loserID); err != nil {
+		return nil, fmt.Errorf("failed to remove cross-training mirror: %w", err)
+	}
+
+	var merged models.ActivityDuplicate
+	if err := tx.GetContext(ctx, &merged, `
+		UPDATE activity_duplicates
+		SET status = $2, winner_activity_id = $3,
+			moved_calendar_entry_ids = $4, moved_personal_record_ids = $5, gear_copied = $6,
+			resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+		RETURNING *
+	`, pairID, models.DuplicateStatusMerged, winnerID, calendarIDs, recordIDs, gearCopied > 0); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.refreshAfterMerge(ctx, userID)
+	return &merged, nil
+}
+
+// UnmergeDuplicate undoes MergeDuplicate and returns the pair to pending.
+// Calendar entries and records are only moved back if nothing has
+// re-pointed them since the merge.
+func (s *ActivityService) UnmergeDuplicate(ctx context.Context, userID, pairID uuid.UUID) (*models.ActivityDuplicate, error) {
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	pair, err := lockDuplicate(ctx, tx, userID, pairID)
+	if err != nil {
+		return nil, err
+	}
+	if pair.Status != models.DuplicateStatusMerged || pair.WinnerActivityID == nil {
+		return nil, ErrDuplicateNotMerged
+	}
+	winnerID := *pair.WinnerActivityID
+	loserID, _ := pair.Other(winnerID)
+
+	var loser models.Activity
+	if err := tx.GetContext(ctx, &loser, `
+		UPDATE activities SET merged_into = NULL
+		WHERE id = $1 AND merged_into = $2
+		RETURNING *
+	`, loserID, winnerID); err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("failed to restore activity: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE training_calendar SET completed_activity_id = $1, updated_at = NOW()
+		WHERE id = ANY($2::uuid[]) AND completed_activity_id = $3
+	`, loserID, pair.MovedCalendarEntryIDs, winnerID); err != nil {
+		return nil, fmt.Errorf("failed to restore calendar entries: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE personal_records SET activity_id = $1, updated_at = NOW()
+		WHERE id = ANY($2::uuid[]) AND activity_id = $3
+	`, loserID, pair.MovedPersonalRecordIDs, winnerID); err != nil {
+		return nil, fmt.Errorf("failed to restore personal records: %w", err)
+	}
+	if pair.GearCopied {
+		if _, err := tx.ExecContext(ctx, `
+			UPDATE activities w SET gear_id = NULL
+			FROM activities l
+			WHERE w.id = $1 AND l.id = $2 AND w.gear_id = l.gear_id
+		`, winnerID, loserID); err != nil {
+			return nil, fmt.Errorf("failed to restore gear: %w", err)
+		}
+	}
+	if loser.ID != uuid.Nil {
+		if err := sync.MirrorCrossTraining(ctx, tx, &loser); err != nil {
+			return nil, fmt.Errorf("failed to restore cross-training mirror: %w", err)
+		}
+	}
+
+	var pending models.ActivityDuplicate
+	if err := tx.GetContext(ctx, &pending, `
+		UPDATE activity_duplicates
+		SET status = $2, winner_activity_id = NULL,
+			moved_calendar_entry_ids = NULL, moved_personal_record_ids = NULL, gear_copied = false,
+			resolved_at = NULL, updated_at = NOW()
+		WHERE id = $1
+		RETURNING *
+	`, pairID, models.DuplicateStatusPending); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.refreshAfterMerge(ctx, userID)
+	return &pending, nil
+}
+
+// DismissDuplicate marks a pending pair as two separate activities. It is
+// not suggested again.
+func (s *ActivityService) DismissDuplicate(ctx context.Context, userID, pairID uuid.UUID) (*models.ActivityDuplicate, error) {
+	var dismissed models.ActivityDuplicate
+	err := s.db.GetContext(ctx, &dismissed, `
+		UPDATE activity_duplicates
+		SET status = $3, resolved_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND user_id = $2 AND status = $4
+		RETURNING *
+	`, pairID, userID, models.DuplicateStatusDismissed, models.DuplicateStatusPending)
+	if errors.Is(err, sql.ErrNoRows) {
+		var exists bool
+		if err := s.db.GetContext(ctx, &exists,
+			"SELECT EXISTS (SELECT 1 FROM activity_duplicates WHERE id = $1 AND user_id = $2)",
+			pairID, userID); err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, ErrDuplicateResolved
+		}
+		return nil, ErrDuplicateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &dismissed, nil
+}
+
+// lockDuplicate loads one of the user's pairs for update.
+func lockDuplicate(ctx context.Context, q sync.Querier, userID, pairID uuid.UUID) (*models.ActivityDuplicate, error) {
+	var pair models.ActivityDuplicate
+	err := q.GetContext(ctx, &pair, `
+		SELECT * FROM activity_duplicates WHERE id = $1 AND user_id = $2 FOR UPDATE
+	`, pairID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrDuplicateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &pair, nil
+}
+
+// refreshAfterMerge recomputes the weekly summaries a merge or unmerge
+// changed. The merge itself is already committed, so a failure is logged.
+func (s *ActivityService) refreshAfterMerge(ctx context.Context, userID uuid.UUID) {
+	if err := refreshWeeklySummaries(ctx, s.db, userID); err != nil {
+		logger.FromContext(ctx).Warn("activity duplicates: weekly summaries failed",
+			"user_id", userID,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+func TestImportRecordsDuplicateCandidates(t *testing.T) {
+	db := &archiveTestDB{inserted: map[string][]any{}}
+	svc := &ActivityImportService{db: db}
+	userID := uuid.New()
+
+	results, err := svc.ImportFile(context.Background(), userID, "run.fit", buildTestFIT())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || results[0].Decision != "insert" {
+		t.Fatalf("expected one insert, got %+v", results)
+	}
+
+	candidates := db.execsMatching("INSERT INTO activity_duplicates")
+	if len(candidates) != 1 {
+		t.Fatalf("expected one duplicate check, got %d", len(candidates))
+	}
+	args := candidates[0].args
+	if args[0] != userID || args[1] != *results[0].ActivityID {
+		t.Fatalf("expected the check for the stored activity, got %v", args[:2])
+	}
+}
+
+func TestActivityDuplicateOther(t *testing.T) {
+	a, b := uuid.New(), uuid.New()
+	pair := models.ActivityDuplicate{ActivityID: a, DuplicateActivityID: b}
+
+	if other, ok := pair.Other(a); !ok || other != b {
+		t.Fatalf("expected %v, got %v %v", b, other, ok)
+	}
+	if other, ok := pair.Other(b); !ok || other != a {
+		t.Fatalf("expected %v, got %v %v", a, other, ok)
+	}
+	if _, ok := pair.Other(uuid.New()); ok {
+		t.Fatal("expected an outside ID to be rejected")
+	}
+}
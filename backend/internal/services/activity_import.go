@@ -0,0 +1,333 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/activityfile"
+)
+
+// ErrUnsupportedActivityFile is returned for uploads whose extension has no
+// decoder.
+var ErrUnsupportedActivityFile = errors.New("unsupported activity file type")
+
+// ActivityImportResult is the outcome for one activity in an uploaded file.
+// A file that fails to decode yields a single result with Error set.
+type ActivityImportResult struct {
+	FileName   string     `json:"file_name"`
+	ActivityID *uuid.UUID `json:"activity_id,omitempty"`
+	Decision   string     `json:"decision,omitempty"` // sync.Decision
+	Error      string     `json:"error,omitempty"`
+}
+
+// Stored reports whether the activity was written, as opposed to failing or
+// being skipped in favor of a higher-priority copy.
+func (r ActivityImportResult) Stored() bool {
+	return r.Error == "" && r.Decision != string(sync.DecisionSkip)
+}
+
+// ActivityImportService imports activities from files exported by devices,
+// for athletes without an API integration or with history the APIs no
+// longer serve. Imported rows use the "file" source, so an API copy of the
+// same activity takes precedence.
+type ActivityImportService struct {
+	db           sync.Querier
+	calendarSvc  *CalendarService
+	archiveSlots chan struct{}
+}
+
+// NewActivityImportService creates a new ActivityImportService
+func NewActivityImportService(db *database.DB, calendarService *CalendarService) *ActivityImportService {
+	return &ActivityImportService{
+		db:           db,
+		calendarSvc:  calendarService,
+		archiveSlots: make(chan struct{}, stravaArchiveConcurrency),
+	}
+}
+
+// decodeActivityFile picks a decoder by file extension.
+func decodeActivityFile(name string, data []byte) ([]activityfile.Activity, error) {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".fit":
+		return activityfile.DecodeFIT(data)
+	case ".gpx":
+		return activityfile.DecodeGPX(data)
+	case ".tcx":
+		return activityfile.DecodeTCX(data)
+	default:
+		return nil, ErrUnsupportedActivityFile
+	}
+}
+
+// ImportFile decodes one uploaded file and stores each activity in it with
+// its laps and streams. Decode failures are returned as errors; per-activity
+// failures are reported in the results.
+func (s *ActivityImportService) ImportFile(ctx context.Context, userID uuid.UUID, name string, data []byte) ([]ActivityImportResult, error) {
+	activities, err := decodeActivityFile(name, data)
+	if err != nil {
+		return nil, err
+	}
+
+	// The content hash makes re-uploading the same file an update rather
+	// than a duplicate.
+	sum := sha256.Sum256(data)
+	results := make([]ActivityImportResult, 0, len(activities))
+	for i, act := range activities {
+		result := ActivityImportResult{FileName: name}
+		raw, ok := fileRawActivity(act, fmt.Sprintf("%x-%d", sum[:16], i))
+		if !ok {
+			result.Error = activityfile.ErrNoActivity.Error()
+			results = append(results, result)
+			continue
+		}
+
+		stored, err := s.storeFileActivity(ctx, userID, raw, act)
+		if err != nil {
+			logger.FromContext(ctx).Error("activity import: failed to store activity",
+				"file_name", name,
+				"error", err,
+			)
+			result.Error = "failed to store activity"
+			results = append(results, result)
+			continue
+		}
+		result.ActivityID = &stored.ActivityID
+		result.Decision = string(stored.Decision)
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// RefreshSummaries recomputes weekly summaries once a batch of imports is
+// done.
+func (s *ActivityImportService) RefreshSummaries(ctx context.Context, userID uuid.UUID) error {
+	return refreshWeeklySummaries(ctx, s.db, userID)
+}
+
+func (s *ActivityImportService) storeFileActivity(ctx context.Context, userID uuid.UUID, raw sync.RawActivity, act activityfile.Activity) (*sync.UpsertResult, error) {
+	result, err := sync.UpsertActivity(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, raw)
+	if err != nil {
+		return nil, err
+	}
+	if result.Activity == nil {
+		return result, nil
+	}
+
+	if err := sync.SaveActivityLaps(ctx, s.db, result.ActivityID, fileLaps(result.Activity, act.Laps)); err != nil {
+		return nil, err
+	}
+	streams, err := fileStreams(result.Activity, act)
+	if err != nil {
+		return nil, err
+	}
+	if streams != nil {
+		if err := sync.SaveActivityStreams(ctx, s.db, streams); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// mapFileSport converts activityfile sports to internal types.
+func mapFileSport(sport string) string {
+	switch sport {
+	case activityfile.SportRunning:
+		return models.ActivityTypeRun
+	case activityfile.SportCycling:
+		return models.ActivityTypeCycling
+	case activityfile.SportSwimming:
+		return models.ActivityTypeSwimming
+	case activityfile.SportWalking:
+		return models.ActivityTypeWalking
+	case activityfile.SportHiking:
+		return models.ActivityTypeHiking
+	case activityfile.SportRowing:
+		return models.ActivityTypeRowing
+	case activityfile.SportElliptical:
+		return models.ActivityTypeElliptical
+	case activityfile.SportStairClimbing:
+		return models.ActivityTypeStairMaster
+	case activityfile.SportStrength:
+		return models.ActivityTypeWeightLifting
+	default:
+		return models.ActivityTypeWorkout
+	}
+}
+
+// fileRawActivity normalizes a decoded file activity for
+// sync.UpsertActivity. It returns false when the file has no start time or
+// duration.
+func fileRawActivity(act activityfile.Activity, sourceID string) (sync.RawActivity, bool) {
+	duration := int(math.Round(act.MovingSeconds))
+	if duration == 0 {
+		duration = int(math.Round(act.ElapsedSeconds))
+	}
+	if act.StartTime.IsZero() || duration <= 0 {
+		return sync.RawActivity{}, false
+	}
+
+	internalType := mapFileSport(act.Sport)
+	name := act.Name
+	if name == "" {
+		name = internalType
+	}
+
+	raw := sync.RawActivity{
+		SourceID:     sourceID,
+		Source:       "file",
+		StartTime:    act.StartTime.UTC(),
+		Duration:     duration,
+		Distance:     act.DistanceMeters,
+		ActivityType: internalType,
+		Name:         name,
+	}
+	if act.LocalOffset != nil {
+		localDate := raw.StartTime.Add(*act.LocalOffset).Truncate(24 * time.Hour)
+		raw.LocalDate = &localDate
+	}
+	if models.DistanceBasedTypes[internalType] && act.DistanceMeters > 0 {
+		raw.AvgPace = float64(duration) / (act.DistanceMeters / 1000.0)
+	}
+	if act.AvgHeartRate > 0 {
+		hr := act.AvgHeartRate
+		raw.AvgHR = &hr
+	}
+	if act.MaxHeartRate > 0 {
+		mhr := act.MaxHeartRate
+		raw.MaxHR = &mhr
+	}
+	if act.ElevationGain > 0 {
+		elev := act.ElevationGain
+		raw.ElevGain = &elev
+	}
+	// Files already record run cadence per leg.
+	if act.AvgCadence > 0 {
+		cadence := act.AvgCadence
+		raw.AvgCadence = &cadence
+	}
+	return raw, true
+}
+
+// fileLaps converts decoded laps, indexed from 0.
+func fileLaps(activity *models.Activity, laps []activityfile.Lap) []models.ActivityLap {
+	out := make([]models.ActivityLap, 0, len(laps))
+	for i, lap := range laps {
+		l := models.ActivityLap{
+			ActivityID:          activity.ID,
+			LapIndex:            i,
+			DistanceMeters:      lap.DistanceMeters,
+			ElapsedSeconds:      int(math.Round(lap.ElapsedSeconds)),
+			MovingSeconds:       int(math.Round(lap.MovingSeconds)),
+			AverageSpeedMps:     positiveFloat(lap.AvgSpeed),
+			MaxSpeedMps:         positiveFloat(lap.MaxSpeed),
+			AverageHeartRate:    positiveFloat(float64(lap.AvgHeartRate)),
+			MaxHeartRate:        positiveFloat(float64(lap.MaxHeartRate)),
+			AverageCadence:      positiveFloat(lap.AvgCadence),
+			ElevationGainMeters: positiveFloat(lap.ElevationGain),
+		}
+		if !lap.StartTime.IsZero() {
+			start := lap.StartTime
+			l.StartTime = &start
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// fileStreams converts decoded samples to the storage model. A channel is
+// kept when any sample recorded it; gaps are filled with the previous
+// reading (or the first one, before it starts) so every stream stays
+// index-aligned with time. Returns nil when there are no samples.
+func fileStreams(activity *models.Activity, act activityfile.Activity) (*models.ActivityStreams, error) {
+	if len(act.Samples) == 0 {
+		return nil, nil
+	}
+
+	n := len(act.Samples)
+	times := make(pq.Int64Array, n)
+	for i, s := range act.Samples {
+		times[i] = int64(s.Time.Sub(act.StartTime) / time.Second)
+	}
+
+	out := &models.ActivityStreams{
+		ActivityID:  activity.ID,
+		UserID:      activity.UserID,
+		Source:      activity.Source,
+		SampleCount: n,
+		TimeOffsets: times,
+		Distance:    filledFloats(act.Samples, func(s activityfile.Sample) *float64 { return s.Distance }),
+		Velocity:    filledFloats(act.Samples, func(s activityfile.Sample) *float64 { return s.Speed }),
+		Altitude:    filledFloats(act.Samples, func(s activityfile.Sample) *float64 { return s.Altitude }),
+		HeartRate:   filledInts(act.Samples, func(s activityfile.Sample) *int { return s.HeartRate }),
+		Cadence:     filledInts(act.Samples, func(s activityfile.Sample) *int { return s.Cadence }),
+	}
+
+	positions := filled(act.Samples, func(s activityfile.Sample) *activityfile.LatLng { return s.Position })
+	if positions != nil {
+		latlng := make([][2]float64, n)
+		for i, p := range positions {
+			latlng[i] = [2]float64{p.Lat, p.Lng}
+		}
+		encoded, err := json.Marshal(latlng)
+		if err != nil {
+			return nil, err
+		}
+		out.LatLng = encoded
+	}
+	return out, nil
+}
+
+// filled returns one value per sample with gaps filled as described on
+// fileStreams, or nil when no sample has a value.
+func filled[T any](samples []activityfile.Sample, get func(activityfile.Sample) *T) []T {
+	first := -1
+	for i, s := range samples {
+		if get(s) != nil {
+			first = i
+			break
+		}
+	}
+	if first < 0 {
+		return nil
+	}
+
+	out := make([]T, len(samples))
+	last := *get(samples[first])
+	for i, s := range samples {
+		if v := get(s); v != nil {
+			last = *v
+		}
+		out[i] = last
+	}
+	return out
+}
+
+func filledFloats(samples []activityfile.Sample, get func(activityfile.Sample) *float64) pq.Float64Array {
+	return filled(samples, get)
+}
+
+func filledInts(samples []activityfile.Sample, get func(activityfile.Sample) *int) pq.Int64Array {
+	values := filled(samples, get)
+	if values == nil {
+		return nil
+	}
+	out := make(pq.Int64Array, len(values))
+	for i, v := range values {
+		out[i] = int64(v)
+	}
+	return out
+}
@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/activityfile"
+)
+
+type testFITField struct {
+	num, size, baseType byte
+	value               uint64
+}
+
+// testFITMessage encodes a definition followed by one data message.
+func testFITMessage(local byte, global uint16, fields ...testFITField) []byte {
+	out := []byte{0x40 | local, 0, 0, byte(global), byte(global >> 8), byte(len(fields))}
+	for _, f := range fields {
+		out = append(out, f.num, f.size, f.baseType)
+	}
+	out = append(out, local)
+	for _, f := range fields {
+		out = binary.LittleEndian.AppendUint64(out, f.value)[:len(out)+int(f.size)]
+	}
+	return out
+}
+
+// testFITFile wraps records in a 14-byte header and the trailing checksum.
+func testFITFile(records ...[]byte) []byte {
+	var body []byte
+	for _, r := range records {
+		body = append(body, r...)
+	}
+	out := []byte{14, 0x20, 0x54, 0x08}
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	out = append(out, '.', 'F', 'I', 'T', 0, 0)
+	out = append(out, body...)
+
+	var crc uint16
+	for _, b := range out {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return binary.LittleEndian.AppendUint16(out, crc)
+}
+
+// buildTestFIT returns a 20-second, 100 m COROS run recorded at UTC-5 with
+// three records, the last using a compressed timestamp header and the
+// second missing heart rate.
+func buildTestFIT() []byte {
+	const start = 1_000_000_000 // FIT seconds; a multiple of 32
+	record := func(ts, dist, hr uint64) []byte {
+		return testFITMessage(0, 20,
+			testFITField{253, 4, 0x86, ts},
+			testFITField{0, 4, 0x85, 0x1F000000},
+			testFITField{1, 4, 0x85, 0x0F000000},
+			testFITField{5, 4, 0x86, dist},
+			testFITField{3, 1, 0x02, hr},
+		)
+	}
+	// The last record drops its timestamp field and carries a compressed
+	// header instead: local type 0, 20 seconds past the 32-second boundary.
+	compressed := testFITMessage(0, 20,
+		testFITField{0, 4, 0x85, 0x1F000000},
+		testFITField{1, 4, 0x85, 0x0F000000},
+		testFITField{5, 4, 0x86, 10000},
+		testFITField{3, 1, 0x02, 160},
+	)
+	compressed[6+3*4] = 0x80 | 20
+
+	return testFITFile(
+		testFITMessage(0, 0,
+			testFITField{0, 1, 0x00, 4},
+			testFITField{1, 2, 0x84, 294},
+		),
+		record(start, 0, 140),
+		record(start+10, 5000, 0xFF),
+		compressed,
+		testFITMessage(1, 19,
+			testFITField{2, 4, 0x86, start},
+			testFITField{7, 4, 0x86, 20000},
+			testFITField{8, 4, 0x86, 20000},
+			testFITField{9, 4, 0x86, 10000},
+			testFITField{15, 1, 0x02, 150},
+		),
+		testFITMessage(2, 18,
+			testFITField{2, 4, 0x86, start},
+			testFITField{5, 1, 0x00, 1},
+			testFITField{7, 4, 0x86, 20000},
+			testFITField{8, 4, 0x86, 20000},
+			testFITField{9, 4, 0x86, 10000},
+			testFITField{16, 1, 0x02, 150},
+			testFITField{17, 1, 0x02, 160},
+			testFITField{18, 1, 0x02, 88},
+		),
+		testFITMessage(3, 34,
+			testFITField{253, 4, 0x86, start + 20},
+			testFITField{5, 4, 0x86, start + 20 - 5*3600},
+		),
+	)
+}
+
+func TestDecodeFIT(t *testing.T) {
+	activities, err := activityfile.DecodeFIT(buildTestFIT())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(activities) != 1 {
+		t.Fatalf("expected one session, got %d", len(activities))
+	}
+	act := activities[0]
+	if act.Manufacturer != "coros" || act.Sport != activityfile.SportRunning {
+		t.Fatalf("unexpected manufacturer %q / sport %q", act.Manufacturer, act.Sport)
+	}
+	if act.DistanceMeters != 100 || act.MovingSeconds != 20 || act.AvgCadence != 88 {
+		t.Fatalf("unexpected totals: %+v", act)
+	}
+	if act.LocalOffset == nil || *act.LocalOffset != -5*time.Hour {
+		t.Fatalf("expected a UTC-5 offset, got %v", act.LocalOffset)
+	}
+	if len(act.Laps) != 1 || len(act.Samples) != 3 {
+		t.Fatalf("expected 1 lap and 3 samples, got %d and %d", len(act.Laps), len(act.Samples))
+	}
+	last := act.Samples[2]
+	if got := last.Time.Sub(act.StartTime); got != 20*time.Second {
+		t.Fatalf("expected the compressed timestamp 20s after start, got %v", got)
+	}
+	if act.Samples[1].HeartRate != nil {
+		t.Fatalf("expected the invalid heart rate to be dropped, got %d", *act.Samples[1].HeartRate)
+	}
+	if last.Position == nil || last.Position.Lat < 43.5 || last.Position.Lat > 43.6 {
+		t.Fatalf("unexpected position %+v", last.Position)
+	}
+}
+
+func TestDecodeFITRejectsCorruptFile(t *testing.T) {
+	data := buildTestFIT()
+	data[20] ^= 0xFF
+	if _, err := activityfile.DecodeFIT(data); err == nil {
+		t.Fatal("expected a checksum error")
+	}
+}
+
+func TestImportFITFileReuploadUpdates(t *testing.T) {
+	// The mock resolves the (source, source_activity_id) lookup, as if the
+	// same file had been uploaded before.
+	existingID := uuid.New()
+	db := &mockStravaDB{existingUserID: existingID}
+	svc := &ActivityImportService{db: db}
+	userID := uuid.New()
+
+	results, err := svc.ImportFile(context.Background(), userID, "Morning_Run.FIT", buildTestFIT())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 1 || !results[0].Stored() || results[0].Decision != "update" {
+		t.Fatalf("expected one updated activity, got %+v", results)
+	}
+	if *results[0].ActivityID != existingID {
+		t.Fatalf("expected the existing row to be kept, got %v", results[0].ActivityID)
+	}
+	// Activity row, one lap and the streams.
+	if got := db.execCount.Load(); got < 3 {
+		t.Fatalf("expected laps and streams to be written, got %d writes", got)
+	}
+
+	if _, err := svc.ImportFile(context.Background(), userID, "notes.txt", []byte("hello")); err != ErrUnsupportedActivityFile {
+		t.Fatalf("expected ErrUnsupportedActivityFile, got %v", err)
+	}
+}
+
+func TestFileStreamsFillGaps(t *testing.T) {
+	act, err := activityfile.DecodeFIT(buildTestFIT())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	raw, ok := fileRawActivity(act[0], "abc-0")
+	if !ok {
+		t.Fatal("expected activity to be accepted")
+	}
+	if raw.Source != "file" || raw.ActivityType != models.ActivityTypeRun || raw.AvgPace != 200 {
+		t.Fatalf("unexpected raw activity %+v", raw)
+	}
+	if raw.LocalDate == nil || raw.LocalDate.Format("2006-01-02") != "2021-09-07" {
+		t.Fatalf("expected the UTC-5 local date, got %v", raw.LocalDate)
+	}
+
+	activity := &models.Activity{ID: uuid.New(), UserID: uuid.New(), Source: "file"}
+	streams, err := fileStreams(activity, act[0])
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streams.SampleCount != 3 || streams.TimeOffsets[2] != 20 {
+		t.Fatalf("unexpected time stream %v", streams.TimeOffsets)
+	}
+	if len(streams.HeartRate) != 3 || streams.HeartRate[1] != 140 {
+		t.Fatalf("expected the missing reading to carry forward, got %v", streams.HeartRate)
+	}
+	if streams.Velocity != nil {
+		t.Fatalf("expected no velocity stream, got %v", streams.Velocity)
+	}
+	var latlng [][2]float64
+	if err := json.Unmarshal(streams.LatLng, &latlng); err != nil || len(latlng) != 3 {
+		t.Fatalf("unexpected latlng %s (%v)", streams.LatLng, err)
+	}
+}
+
+const testGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx creator="StravaGPX Android" version="1.1" xmlns="http://www.topografix.com/GPX/1/1"
+  xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+ <metadata><time>2026-03-01T14:00:00Z</time></metadata>
+ <trk>
+  <name>Sunday Hills</name>
+  <type>running</type>
+  <trkseg>
+   <trkpt lat="40.000" lon="-105.0"><ele>100</ele><time>2026-03-01T14:00:00Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>120</gpxtpx:hr><gpxtpx:cad>85</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions></trkpt>
+   <trkpt lat="40.001" lon="-105.0"><ele>101</ele><time>2026-03-01T14:00:30Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>130</gpxtpx:hr><gpxtpx:cad>87</gpxtpx:cad></gpxtpx:TrackPointExtension></extensions></trkpt>
+   <trkpt lat="40.002" lon="-105.0"><ele>100</ele><time>2026-03-01T14:01:00Z</time></trkpt>
+   <trkpt lat="40.002" lon="-105.0"><ele>101</ele><time>2026-03-01T14:02:00Z</time></trkpt>
+   <trkpt lat="40.003" lon="-105.0"><ele>105</ele><time>2026-03-01T14:02:30Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions></trkpt>
+   <trkpt lat="40.004" lon="-105.0"><ele>110</ele><time>2026-03-01T14:03:00Z</time></trkpt>
+   <trkpt lat="40.005" lon="-105.0"><ele>115</ele><time>2026-03-01T14:03:30Z</time></trkpt>
+  </trkseg>
+ </trk>
+</gpx>`
+
+func TestDecodeGPXDerivesTotals(t *testing.T) {
+	activities, err := activityfile.DecodeGPX([]byte(testGPX))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	act := activities[0]
+	if act.Sport != activityfile.SportRunning || act.Name != "Sunday Hills" {
+		t.Fatalf("unexpected sport %q / name %q", act.Sport, act.Name)
+	}
+	// Five 0.001° steps of latitude, about 111.2 m each.
+	if act.DistanceMeters < 555 || act.DistanceMeters > 557 {
+		t.Fatalf("expected about 556 m, got %.1f", act.DistanceMeters)
+	}
+	// The minute spent at 40.002 is a pause.
+	if act.ElapsedSeconds != 210 || act.MovingSeconds != 150 {
+		t.Fatalf("expected 210s elapsed / 150s moving, got %v / %v", act.ElapsedSeconds, act.MovingSeconds)
+	}
+	// Raw climbs add up to 16 m; smoothing drops the 1 m jitter.
+	if act.ElevationGain < 9 || act.ElevationGain > 10 {
+		t.Fatalf("expected smoothed gain near 9.7 m, got %v", act.ElevationGain)
+	}
+	if act.AvgHeartRate != 133 || act.MaxHeartRate != 150 || act.AvgCadence != 86 {
+		t.Fatalf("unexpected HR %d/%d cadence %v", act.AvgHeartRate, act.MaxHeartRate, act.AvgCadence)
+	}
+	if act.LocalOffset != nil {
+		t.Fatalf("GPX has no local offset, got %v", *act.LocalOffset)
+	}
+
+	raw, ok := fileRawActivity(act, "gpx-0")
+	if !ok || raw.Duration != 150 || raw.LocalDate != nil {
+		t.Fatalf("unexpected raw activity %+v", raw)
+	}
+}
+
+const testTCX = "\xef\xbb\xbf  " + `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2"
+  xmlns:ns3="http://www.garmin.com/xmlschemas/ActivityExtension/v2">
+ <Activities>
+  <Activity Sport="Running">
+   <Id>2026-03-02T12:00:00.000Z</Id>
+   <Lap StartTime="2026-03-02T12:00:00.000Z">
+    <TotalTimeSeconds>300</TotalTimeSeconds>
+    <DistanceMeters>1000</DistanceMeters>
+    <Calories>70</Calories>
+    <AverageHeartRateBpm><Value>140</Value></AverageHeartRateBpm>
+    <MaximumHeartRateBpm><Value>150</Value></MaximumHeartRateBpm>
+    <Track>
+     <Trackpoint><Time>2026-03-02T12:00:00.000Z</Time><DistanceMeters>0</DistanceMeters>
+      <Extensions><ns3:TPX><ns3:RunCadence>88</ns3:RunCadence></ns3:TPX></Extensions></Trackpoint>
+     <Trackpoint><Time>2026-03-02T12:05:10.000Z</Time><DistanceMeters>1000</DistanceMeters>
+      <Extensions><ns3:TPX><ns3:RunCadence>90</ns3:RunCadence></ns3:TPX></Extensions></Trackpoint>
+    </Track>
+   </Lap>
+   <Lap StartTime="2026-03-02T12:05:10.000Z">
+    <TotalTimeSeconds>240</TotalTimeSeconds>
+    <DistanceMeters>1000</DistanceMeters>
+    <AverageHeartRateBpm><Value>160</Value></AverageHeartRateBpm>
+    <MaximumHeartRateBpm><Value>170</Value></MaximumHeartRateBpm>
+   </Lap>
+   <Creator><Name>Forerunner 965</Name></Creator>
+  </Activity>
+ </Activities>
+ <Author><Name>Garmin Connect</Name></Author>
+</TrainingCenterDatabase>`
+
+func TestDecodeTCXUsesLapTotals(t *testing.T) {
+	activities, err := activityfile.DecodeTCX([]byte(testTCX))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	act := activities[0]
+	if act.DistanceMeters != 2000 || act.MovingSeconds != 540 || act.Calories != 70 {
+		t.Fatalf("expected lap totals, got %v m / %v s / %d kcal", act.DistanceMeters, act.MovingSeconds, act.Calories)
+	}
+	// Weighted by lap time: (140*300 + 160*240) / 540.
+	if act.AvgHeartRate != 149 || act.MaxHeartRate != 170 {
+		t.Fatalf("unexpected HR %d/%d", act.AvgHeartRate, act.MaxHeartRate)
+	}
+	if act.AvgCadence != 89 {
+		t.Fatalf("expected per-leg run cadence 89, got %v", act.AvgCadence)
+	}
+	if len(act.Laps) != 2 || act.Laps[0].ElapsedSeconds != 310 || act.Laps[1].AvgSpeed != 1000.0/240 {
+		t.Fatalf("unexpected laps %+v", act.Laps)
+	}
+	if act.Manufacturer != "garmin" {
+		t.Fatalf("expected the author to name the maker, got %q", act.Manufacturer)
+	}
+}
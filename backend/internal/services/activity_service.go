@@ -2,6 +2,7 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,7 +10,9 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
 	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
 )
 
 // ErrActivityNotFound is returned when an activity does not exist or
@@ -172,6 +175,15 @@ func (s *ActivityService) CreateManualActivity(
 		}
 	}
 
+	// Manual entries skip the sync match entirely, so a run logged by hand
+	// and later synced is only caught here.
+	if err := sync.RecordDuplicateCandidates(ctx, s.db, activity); err != nil {
+		logger.FromContext(ctx).Warn("activity: failed to record duplicate candidates",
+			"activity_id", activity.ID,
+			"error", err,
+		)
+	}
+
 	return activity, nil
 }
 
@@ -191,7 +203,7 @@ func (s *ActivityService) GetUserActivities(
 
 	if activityType != "" {
 		err := s.db.GetContext(ctx, &total,
-			"SELECT COUNT(*) FROM activities WHERE user_id = $1 AND activity_type = $2",
+			"SELECT COUNT(*) FROM activities WHERE user_id = $1 AND activity_type = $2 AND merged_into IS NULL",
 			userID, activityType)
 		if err != nil {
 			return nil, 0, err
@@ -201,9 +213,10 @@ func (s *ActivityService) GetUserActivities(
 				   name, distance_meters, duration_seconds, start_time,
 				   average_pace_seconds_per_km, average_heart_rate,
 				   max_heart_rate, elevation_gain_meters,
-				   average_cadence, suffer_score, synced_at
+				   average_cadence, suffer_score, workout_type, calories,
+				   device_name, gear_id, description, synced_at
 			FROM activities
-			WHERE user_id = $1 AND activity_type = $2
+			WHERE user_id = $1 AND activity_type = $2 AND merged_into IS NULL
 			ORDER BY start_time DESC
 			LIMIT $3 OFFSET $4
 		`, userID, activityType, limit, offset)
@@ -212,7 +225,7 @@ func (s *ActivityService) GetUserActivities(
 		}
 	} else {
 		err := s.db.GetContext(ctx, &total,
-			"SELECT COUNT(*) FROM activities WHERE user_id = $1", userID)
+			"SELECT COUNT(*) FROM activities WHERE user_id = $1 AND merged_into IS NULL", userID)
 		if err != nil {
 			return nil, 0, err
 		}
@@ -221,9 +234,10 @@ func (s *ActivityService) GetUserActivities(
 				   name, distance_meters, duration_seconds, start_time,
 				   average_pace_seconds_per_km, average_heart_rate,
 				   max_heart_rate, elevation_gain_meters,
-				   average_cadence, suffer_score, synced_at
+				   average_cadence, suffer_score, workout_type, calories,
+				   device_name, gear_id, description, synced_at
 			FROM activities
-			WHERE user_id = $1
+			WHERE user_id = $1 AND merged_into IS NULL
 			ORDER BY start_time DESC
 			LIMIT $2 OFFSET $3
 		`, userID, limit, offset)
@@ -253,3 +267,29 @@ func (s *ActivityService) DeleteActivity(ctx context.Context, userID uuid.UUID,
 
 	return nil
 }
+
+// GetActivityStreams returns the stored per-sample streams for one of the
+// user's activities. Returns ErrActivityNotFound if the activity isn't the
+// user's, and ErrActivityStreamsNotFound if it has no streams stored.
+func (s *ActivityService) GetActivityStreams(ctx context.Context, userID, activityID uuid.UUID) (*models.ActivityStreams, error) {
+	var owned bool
+	if err := s.db.GetContext(ctx, &owned,
+		"SELECT EXISTS (SELECT 1 FROM activities WHERE id = $1 AND user_id = $2)",
+		activityID, userID); err != nil {
+		return nil, fmt.Errorf("failed to look up activity: %w", err)
+	}
+	if !owned {
+		return nil, ErrActivityNotFound
+	}
+
+	var streams models.ActivityStreams
+	err := s.db.GetContext(ctx, &streams,
+		"SELECT * FROM activity_streams WHERE activity_id = $1", activityID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrActivityStreamsNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load activity streams: %w", err)
+	}
+	return &streams, nil
+}
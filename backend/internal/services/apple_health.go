@@ -0,0 +1,357 @@
+package services
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/applehealth"
+)
+
+const (
+	appleHealthKind   = "apple_health"
+	appleHealthSource = "apple_health"
+
+	// appleHealthTimeout bounds one import. Most of a multi-gigabyte export
+	// is per-minute samples that are read and discarded.
+	appleHealthTimeout = 2 * time.Hour
+
+	// appleHealthHeartbeat is the longest the import goes without saving
+	// its counters while items arrive. Workouts are sparse in an export, so
+	// counting them alone would leave the progress far behind. Liveness
+	// comes from keepImportAlive, since the decoder can spend minutes
+	// discarding records without returning an item.
+	appleHealthHeartbeat = time.Minute
+
+	// appleHealthSniffBytes is how far into the document the <HealthData>
+	// root must appear. The export's inline DTD comes first.
+	appleHealthSniffBytes = 64 << 10
+)
+
+// ErrInvalidAppleHealthExport is returned for uploads that are neither the
+// Health app's export ZIP nor its export.xml.
+var ErrInvalidAppleHealthExport = errors.New("not an Apple Health export")
+
+// appleWorkoutTypes maps the imported HKWorkoutActivityTypes to internal
+// types. Other workouts are counted as skipped.
+var appleWorkoutTypes = map[string]string{
+	"Running":                     models.ActivityTypeRun,
+	"Cycling":                     models.ActivityTypeCycling,
+	"Swimming":                    models.ActivityTypeSwimming,
+	"TraditionalStrengthTraining": models.ActivityTypeWeightLifting,
+	"FunctionalStrengthTraining":  models.ActivityTypeWeightLifting,
+}
+
+// StartAppleHealthImport validates an uploaded Health export, either the
+// ZIP or the bare export.xml, and imports it in the background like a
+// Strava archive.
+func (s *ActivityImportService) StartAppleHealthImport(ctx context.Context, userID uuid.UUID, fileName string, src io.Reader) (*models.ActivityImportJob, error) {
+	if err := s.failStaleImportJobs(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "korsana-apple-health-*")
+	if err != nil {
+		return nil, err
+	}
+	exportPath := tmp.Name()
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(exportPath)
+		return nil, err
+	}
+
+	export, err := openAppleHealthExport(exportPath)
+	if err != nil {
+		os.Remove(exportPath)
+		return nil, err
+	}
+	cleanup := func() {
+		export.Close()
+		os.Remove(exportPath)
+	}
+
+	var job models.ActivityImportJob
+	err = s.db.GetContext(ctx, &job, `
+		INSERT INTO activity_import_jobs (user_id, kind, file_name)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING *
+	`, userID, appleHealthKind, fileName)
+	if errors.Is(err, sql.ErrNoRows) {
+		cleanup()
+		return nil, ErrImportInProgress
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	go func() {
+		defer cleanup()
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), appleHealthTimeout)
+		defer cancel()
+		s.runAppleHealthImport(runCtx, &job, export)
+	}()
+	return &job, nil
+}
+
+// appleHealthExport is the export.xml stream and whatever must be closed
+// with it.
+type appleHealthExport struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (e *appleHealthExport) Close() error {
+	for _, c := range e.closers {
+		c.Close()
+	}
+	return nil
+}
+
+// openAppleHealthExport opens export.xml from the upload, which may be the
+// export ZIP or the XML itself, and checks that it is a Health export.
+func openAppleHealthExport(exportPath string) (*appleHealthExport, error) {
+	export := &appleHealthExport{}
+	if archive, err := zip.OpenReader(exportPath); err == nil {
+		export.closers = append(export.closers, archive)
+		for _, f := range archive.File {
+			if path.Base(f.Name) != applehealth.ExportFile || path.Base(path.Dir(f.Name)) == "__MACOSX" {
+				continue
+			}
+			rc, err := f.Open()
+			if err != nil {
+				export.Close()
+				return nil, err
+			}
+			export.closers = append(export.closers, rc)
+			export.Reader = rc
+			break
+		}
+		if export.Reader == nil {
+			export.Close()
+			return nil, fmt.Errorf("%w: no %s", ErrInvalidAppleHealthExport, applehealth.ExportFile)
+		}
+	} else {
+		f, err := os.Open(exportPath)
+		if err != nil {
+			return nil, err
+		}
+		export.closers = append(export.closers, f)
+		export.Reader = f
+	}
+
+	br := bufio.NewReaderSize(export.Reader, appleHealthSniffBytes)
+	head, _ := br.Peek(appleHealthSniffBytes)
+	if !bytes.Contains(head, []byte("<HealthData")) {
+		export.Close()
+		return nil, ErrInvalidAppleHealthExport
+	}
+	export.Reader = br
+	return export, nil
+}
+
+// runAppleHealthImport streams the export once: workouts are upserted as
+// they are read and wellness samples are averaged per day, then written
+// when the document ends. A document that turns out to be truncated keeps
+// what was read before the damage.
+func (s *ActivityImportService) runAppleHealthImport(ctx context.Context, job *models.ActivityImportJob, export io.Reader) {
+	log := logger.FromContext(ctx).With("job_id", job.ID, "user_id", job.UserID)
+	ctx = logger.WithLogger(ctx, log)
+
+	release, ok := s.claimImportJob(ctx, job.ID)
+	if !ok {
+		return
+	}
+	defer release()
+	stopHeartbeat := s.keepImportAlive(ctx, job.ID)
+	defer stopHeartbeat()
+
+	progress := &archiveProgress{}
+	wellness := wellnessDays{}
+	lastSave := time.Now()
+	dec := applehealth.NewDecoder(export)
+
+	var jobErr error
+	for {
+		if jobErr = ctx.Err(); jobErr != nil {
+			break
+		}
+		item, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			jobErr = err
+			break
+		}
+
+		switch {
+		case item.Sample != nil:
+			wellness.add(*item.Sample)
+		case item.Workout != nil:
+			progress.processed++
+			s.importAppleWorkout(ctx, job.UserID, progress.processed, *item.Workout, progress)
+		}
+		if time.Since(lastSave) >= appleHealthHeartbeat {
+			s.saveImportProgress(ctx, job.ID, progress)
+			lastSave = time.Now()
+		}
+	}
+	s.saveImportProgress(ctx, job.ID, progress)
+
+	if ctx.Err() == nil {
+		if err := s.saveWellness(ctx, job.UserID, wellness); err != nil {
+			log.Error("apple health: failed to save wellness", "error", err)
+			if jobErr == nil {
+				jobErr = errors.New("failed to save resting heart rate and HRV")
+			}
+		}
+	}
+	if progress.imported > 0 {
+		if err := refreshWeeklySummaries(ctx, s.db, job.UserID); err != nil {
+			log.Warn("apple health: weekly summaries failed", "error", err)
+		}
+	}
+	s.finishImportJob(ctx, job.ID, jobErr)
+	log.Info("apple health import complete",
+		"imported", progress.imported,
+		"skipped", progress.skipped,
+		"failed", progress.failed,
+		"wellness_days", len(wellness),
+	)
+}
+
+// importAppleWorkout upserts one workout. row is its position among the
+// export's workouts, used in row errors.
+func (s *ActivityImportService) importAppleWorkout(ctx context.Context, userID uuid.UUID, row int, w applehealth.Workout, progress *archiveProgress) {
+	raw, ok := appleWorkoutRaw(w)
+	if !ok {
+		progress.skipped++
+		return
+	}
+	result, err := sync.UpsertActivity(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, raw)
+	if err != nil {
+		logger.FromContext(ctx).Error("apple health: failed to store workout",
+			"start_time", raw.StartTime,
+			"error", err,
+		)
+		progress.failed++
+		progress.addError(archiveRowError{Row: row, Error: "failed to store workout"})
+		return
+	}
+	if result.Activity == nil {
+		progress.skipped++
+		return
+	}
+	progress.imported++
+}
+
+// appleWorkoutRaw normalizes a Health workout for sync.UpsertActivity. It
+// returns false for workout types Korsana doesn't import.
+func appleWorkoutRaw(w applehealth.Workout) (sync.RawActivity, bool) {
+	internalType, ok := appleWorkoutTypes[w.ActivityType]
+	if !ok {
+		return sync.RawActivity{}, false
+	}
+	duration := int(math.Round(w.Duration.Seconds()))
+	if duration <= 0 {
+		return sync.RawActivity{}, false
+	}
+
+	// Health has no workout IDs in the export. The type, start and
+	// writing app identify a workout across re-exports, and keep copies
+	// written by different apps apart.
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s|%d|%s", w.ActivityType, w.Start.Unix(), w.SourceName)))
+	localDate := time.Date(w.Start.Year(), w.Start.Month(), w.Start.Day(), 0, 0, 0, 0, time.UTC)
+
+	raw := sync.RawActivity{
+		SourceID:     fmt.Sprintf("%x", sum[:16]),
+		Source:       appleHealthSource,
+		StartTime:    w.Start.UTC(),
+		LocalDate:    &localDate,
+		Duration:     duration,
+		Distance:     w.DistanceMeters,
+		ActivityType: internalType,
+		Name:         internalType,
+		ElevGain:     positiveFloat(w.ElevationGain),
+		AvgHR:        positiveInt(int(math.Round(w.AvgHeartRate))),
+		MaxHR:        positiveInt(int(math.Round(w.MaxHeartRate))),
+	}
+	if models.DistanceBasedTypes[internalType] && w.DistanceMeters > 0 {
+		raw.AvgPace = float64(duration) / (w.DistanceMeters / 1000.0)
+	}
+	return raw, true
+}
+
+// wellnessDay sums one day's samples.
+type wellnessDay struct {
+	restingSum, hrvSum float64
+	restingN, hrvN     int
+}
+
+// wellnessDays is keyed by the samples' local date, at midnight UTC.
+type wellnessDays map[time.Time]*wellnessDay
+
+func (d wellnessDays) add(sample applehealth.Sample) {
+	date := time.Date(sample.Start.Year(), sample.Start.Month(), sample.Start.Day(), 0, 0, 0, 0, time.UTC)
+	day := d[date]
+	if day == nil {
+		day = &wellnessDay{}
+		d[date] = day
+	}
+	switch sample.Type {
+	case applehealth.TypeRestingHeartRate:
+		day.restingSum += sample.Value
+		day.restingN++
+	case applehealth.TypeHRVSDNN:
+		day.hrvSum += sample.Value
+		day.hrvN++
+	}
+}
+
+// saveWellness upserts the daily means. A day present in an earlier import
+// is replaced, since a later export holds the same samples and more.
+func (s *ActivityImportService) saveWellness(ctx context.Context, userID uuid.UUID, days wellnessDays) error {
+	for date, day := range days {
+		var resting *int
+		var hrv *float64
+		if day.restingN > 0 {
+			resting = positiveInt(int(math.Round(day.restingSum / float64(day.restingN))))
+		}
+		if day.hrvN > 0 {
+			hrv = positiveFloat(math.Round(day.hrvSum/float64(day.hrvN)*10) / 10)
+		}
+		_, err := s.db.ExecContext(ctx, `
+			INSERT INTO daily_wellness (user_id, date, source, resting_heart_rate, hrv_sdnn_ms)
+			VALUES ($1, $2, $3, $4, $5)
+			ON CONFLICT (user_id, date, source) DO UPDATE SET
+				resting_heart_rate = EXCLUDED.resting_heart_rate,
+				hrv_sdnn_ms = EXCLUDED.hrv_sdnn_ms,
+				updated_at = NOW()
+		`, userID, date, appleHealthSource, resting, hrv)
+		if err != nil {
+			return fmt.Errorf("save wellness for %s: %w", date.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/applehealth"
+)
+
+// testAppleExport follows the layout of a real export: inline DTD, samples
+// grouped by type, then workouts. The evening resting HR sample is already
+// the next day in UTC.
+const testAppleExport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE HealthData [
+<!ELEMENT HealthData (ExportDate,Me,(Record|Correlation|Workout|ActivitySummary|ClinicalRecord)*)>
+<!ATTLIST HealthData
+  locale CDATA #REQUIRED
+>
+]>
+<HealthData locale="en_US">
+ <ExportDate value="2026-03-10 09:00:00 -0500"/>
+ <Me HKCharacteristicTypeIdentifierDateOfBirth="1990-01-01"/>
+ <Record type="HKQuantityTypeIdentifierStepCount" sourceName="iPhone" unit="count" startDate="2026-03-08 08:00:00 -0500" endDate="2026-03-08 08:10:00 -0500" value="812"/>
+ <Record type="HKQuantityTypeIdentifierRestingHeartRate" sourceName="Apple Watch" unit="count/min" startDate="2026-03-08 00:05:00 -0500" endDate="2026-03-08 00:05:00 -0500" value="50"/>
+ <Record type="HKQuantityTypeIdentifierRestingHeartRate" sourceName="Apple Watch" unit="count/min" startDate="2026-03-08 23:30:00 -0500" endDate="2026-03-08 23:30:00 -0500" value="53"/>
+ <Record type="HKQuantityTypeIdentifierHeartRateVariabilitySDNN" sourceName="Apple Watch" unit="ms" startDate="2026-03-08 06:00:00 -0500" endDate="2026-03-08 06:01:00 -0500" value="40.2">
+  <HeartRateVariabilityMetadataList>
+   <InstantaneousBeatsPerMinute bpm="61" time="6:00:01.02 AM"/>
+  </HeartRateVariabilityMetadataList>
+ </Record>
+ <Record type="HKQuantityTypeIdentifierHeartRateVariabilitySDNN" sourceName="Apple Watch" unit="ms" startDate="2026-03-08 14:00:00 -0500" endDate="2026-03-08 14:01:00 -0500" value="45.6"/>
+ <Workout workoutActivityType="HKWorkoutActivityTypeRunning" duration="30" durationUnit="min" sourceName="Apple Watch" startDate="2026-03-08 19:30:00 -0500" endDate="2026-03-08 20:02:00 -0500">
+  <MetadataEntry key="HKIndoorWorkout" value="0"/>
+  <MetadataEntry key="HKElevationAscended" value="4520 cm"/>
+  <WorkoutEvent type="HKWorkoutEventTypePause" date="2026-03-08 19:45:00 -0500"/>
+  <WorkoutStatistics type="HKQuantityTypeIdentifierHeartRate" startDate="2026-03-08 19:30:00 -0500" endDate="2026-03-08 20:02:00 -0500" average="148.6" minimum="92" maximum="171" unit="count/min"/>
+  <WorkoutStatistics type="HKQuantityTypeIdentifierDistanceWalkingRunning" startDate="2026-03-08 19:30:00 -0500" endDate="2026-03-08 20:02:00 -0500" sum="5.2" unit="km"/>
+  <WorkoutRoute sourceName="Apple Watch">
+   <FileReference path="/workout-routes/route_2026-03-08_8.02pm.gpx"/>
+  </WorkoutRoute>
+ </Workout>
+ <Workout workoutActivityType="HKWorkoutActivityTypeYoga" duration="20" durationUnit="min" sourceName="Apple Watch" startDate="2026-03-09 06:00:00 -0500" endDate="2026-03-09 06:20:00 -0500"/>
+ <Workout workoutActivityType="HKWorkoutActivityTypeTraditionalStrengthTraining" duration="45" durationUnit="min" totalEnergyBurned="250" totalEnergyBurnedUnit="kcal" sourceName="Apple Watch" startDate="2026-03-09 07:00:00 -0500" endDate="2026-03-09 07:45:00 -0500"/>
+</HealthData>
+`
+
+func TestAppleHealthDecoder(t *testing.T) {
+	dec := applehealth.NewDecoder(strings.NewReader(testAppleExport))
+	var workouts []applehealth.Workout
+	samples := 0
+	for {
+		item, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if item.Workout != nil {
+			workouts = append(workouts, *item.Workout)
+		} else {
+			samples++
+		}
+	}
+
+	if samples != 4 || len(workouts) != 3 {
+		t.Fatalf("expected 4 samples and 3 workouts, got %d and %d", samples, len(workouts))
+	}
+	run := workouts[0]
+	if run.ActivityType != "Running" || run.Duration != 30*time.Minute {
+		t.Fatalf("unexpected run: %+v", run)
+	}
+	if run.DistanceMeters != 5200 || run.AvgHeartRate != 148.6 || run.MaxHeartRate != 171 {
+		t.Fatalf("expected statistics from the children, got %+v", run)
+	}
+	if run.ElevationGain < 45.19 || run.ElevationGain > 45.21 || run.Indoor {
+		t.Fatalf("expected 45.2 m outdoors, got %v indoor=%v", run.ElevationGain, run.Indoor)
+	}
+}
+
+func TestAppleHealthDecoderRejectsOtherXML(t *testing.T) {
+	_, err := applehealth.NewDecoder(strings.NewReader(`<gpx version="1.1"></gpx>`)).Next()
+	if !errors.Is(err, applehealth.ErrInvalidExport) {
+		t.Fatalf("expected ErrInvalidExport, got %v", err)
+	}
+}
+
+func TestAppleHealthImport(t *testing.T) {
+	exportPath := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(exportPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("apple_health_export/export.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte(testAppleExport)) //nolint:errcheck
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	export, err := openAppleHealthExport(exportPath)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer export.Close()
+
+	db := &archiveTestDB{inserted: map[string][]any{}}
+	svc := &ActivityImportService{db: db}
+	job := &models.ActivityImportJob{ID: uuid.New(), UserID: uuid.New()}
+	svc.runAppleHealthImport(context.Background(), job, export)
+
+	byType := map[any][]any{}
+	for _, args := range db.inserted {
+		if args[2] != appleHealthSource {
+			t.Fatalf("expected the apple_health source, got %v", args[2])
+		}
+		byType[args[4]] = args
+	}
+	if len(byType) != 2 || byType[models.ActivityTypeRun] == nil || byType[models.ActivityTypeWeightLifting] == nil {
+		t.Fatalf("expected a run and a strength session, got %v", byType)
+	}
+	if ld := byType[models.ActivityTypeRun][9].(*time.Time); ld.Format("2006-01-02") != "2026-03-08" {
+		t.Fatalf("expected the run on its local date, got %v", ld)
+	}
+
+	wellness := db.execsMatching("INSERT INTO daily_wellness")
+	if len(wellness) != 1 {
+		t.Fatalf("expected one wellness day, got %d", len(wellness))
+	}
+	args := wellness[0].args
+	if args[1].(time.Time).Format("2006-01-02") != "2026-03-08" || *args[3].(*int) != 52 || *args[4].(*float64) != 42.9 {
+		t.Fatalf("unexpected wellness row: %v %v %v", args[1], args[3], args[4])
+	}
+
+	finish := db.execsMatching("completed_at = NOW()")
+	if len(finish) != 1 || finish[0].args[1] != "done" {
+		t.Fatalf("expected the job to finish done, got %+v", finish)
+	}
+	progress := db.execsMatching("processed_rows = $2")
+	if last := progress[len(progress)-1].args; last[1] != 3 || last[2] != 2 || last[3] != 1 {
+		t.Fatalf("expected 3 processed, 2 imported, 1 skipped, got %v", last)
+	}
+}
+
+func TestOpenAppleHealthExportRejectsOtherFiles(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "route.gpx")
+	if err := os.WriteFile(p, []byte(`<?xml version="1.0"?><gpx></gpx>`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openAppleHealthExport(p); !errors.Is(err, ErrInvalidAppleHealthExport) {
+		t.Fatalf("expected ErrInvalidAppleHealthExport, got %v", err)
+	}
+}
+
+func TestAppleHealthImportStaysAliveWhileDiscardingRecords(t *testing.T) {
+	defer func(prev time.Duration) { importJobHeartbeat = prev }(importJobHeartbeat)
+	importJobHeartbeat = time.Millisecond
+
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	db := &importJobTestDB{archiveTestDB: archiveTestDB{inserted: map[string][]any{}}, now: start, status: "pending", updatedAt: start}
+	svc := &ActivityImportService{db: db}
+	job := &models.ActivityImportJob{ID: uuid.New(), UserID: uuid.New()}
+
+	// The decoder is stuck inside a long block of heart rate records it
+	// discards, returning no item.
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func() {
+		svc.runAppleHealthImport(context.Background(), job, pr)
+		close(done)
+	}()
+	head := testAppleExport[:strings.Index(testAppleExport, " <Record")]
+	io.WriteString(pw, head) //nolint:errcheck
+	record := ` <Record type="HKQuantityTypeIdentifierHeartRate" sourceName="Apple Watch" unit="count/min" startDate="2026-03-08 08:00:00 -0500" endDate="2026-03-08 08:00:05 -0500" value="61"/>` + "\n"
+	io.WriteString(pw, strings.Repeat(record, 100)) //nolint:errcheck
+
+	for elapsed := time.Duration(0); elapsed <= 2*stravaArchiveStaleAfter; elapsed += 10 * time.Minute {
+		db.advance(10 * time.Minute)
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, beat := db.state(); beat {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected the running job to touch its heartbeat")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := svc.failStaleImportJobs(context.Background(), job.UserID); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status, _ := db.state(); status != "running" {
+			t.Fatalf("expected the import still running, got %s", status)
+		}
+	}
+
+	io.WriteString(pw, "</HealthData>\n") //nolint:errcheck
+	pw.Close()
+	<-done
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/metrics"
 	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/lib/pq"
 )
 
 type calendarQuerier interface {
@@ -18,6 +22,16 @@ type calendarQuerier interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 }
 
+// asCalendarMatcher returns a calendar service as a sync.CalendarMatcher, or
+// nil when none is configured. A nil *CalendarService must not be wrapped in
+// the interface, or sync would call through it.
+func asCalendarMatcher(calendar *CalendarService) sync.CalendarMatcher {
+	if calendar == nil {
+		return nil
+	}
+	return calendar
+}
+
 // AutoMatchActivity finds a planned calendar entry on the activity's date
 // and marks it completed if the activity type is compatible.
 func (s *CalendarService) AutoMatchActivity(
@@ -34,7 +48,11 @@ func (s *CalendarService) AutoMatchActivity(
 		date = activity.StartTime.UTC().Truncate(24 * time.Hour)
 	}
 
-	// Find all planned entries on this date and match the first compatible one.
+	workoutType := mapActivityToWorkoutType(activity)
+
+	// Find all planned entries on this date and match a compatible one,
+	// preferring the entry of the same kind (a race flagged on Strava
+	// completes the planned race, not the shakeout planned that morning).
 	var planned []models.CalendarEntry
 	err := s.db.SelectContext(ctx, &planned, `
 		SELECT * FROM training_calendar
@@ -43,21 +61,18 @@ func (s *CalendarService) AutoMatchActivity(
 	`, userID, date)
 
 	if err == nil {
-		for _, entry := range planned {
-			if isActivityCompatibleWithWorkout(activity.ActivityType, entry.WorkoutType) {
-				_, err = s.db.ExecContext(ctx, `
-					UPDATE training_calendar
-					SET status = 'completed', completed_activity_id = $1, updated_at = NOW()
-					WHERE id = $2 AND user_id = $3 AND status = 'planned'
-				`, activity.ID, entry.ID, userID)
-				return err
-			}
+		if entry := pickPlannedEntry(planned, activity.ActivityType, workoutType); entry != nil {
+			_, err = s.db.ExecContext(ctx, `
+				UPDATE training_calendar
+				SET status = 'completed', completed_activity_id = $1, updated_at = NOW()
+				WHERE id = $2 AND user_id = $3 AND status = 'planned'
+			`, activity.ID, entry.ID, userID)
+			return err
 		}
 	}
 
 	// Otherwise, there was no planned entry (or it was incompatible).
 	// We should create a new ad-hoc completed entry so this activity appears on the calendar.
-	workoutType := mapActivityToWorkoutType(activity.ActivityType)
 
 	newEntry := models.CalendarEntry{
 		ID:                  uuid.New(),
@@ -67,7 +82,7 @@ func (s *CalendarService) AutoMatchActivity(
 		Title:               activity.Name,
 		Status:              "completed",
 		CompletedActivityID: &activity.ID,
-		Source:              "strava",
+		Source:              activity.Source,
 		CreatedAt:           time.Now(),
 		UpdatedAt:           time.Now(),
 	}
@@ -115,11 +130,42 @@ func (s *CalendarService) AutoMatchActivity(
 	return err
 }
 
-func mapActivityToWorkoutType(activityType string) string {
-	if activityType == models.ActivityTypeRun {
+// pickPlannedEntry returns the planned entry an activity completes: the
+// first whose workout type equals the activity's, else the first compatible
+// one. Returns nil if none fit.
+func pickPlannedEntry(planned []models.CalendarEntry, activityType, workoutType string) *models.CalendarEntry {
+	var fallback *models.CalendarEntry
+	for i := range planned {
+		entry := &planned[i]
+		if !isActivityCompatibleWithWorkout(activityType, entry.WorkoutType) {
+			continue
+		}
+		if entry.WorkoutType == workoutType {
+			return entry
+		}
+		if fallback == nil {
+			fallback = entry
+		}
+	}
+	return fallback
+}
+
+func mapActivityToWorkoutType(activity *models.Activity) string {
+	if activity.ActivityType == models.ActivityTypeRun {
+		// Strava's workout flag is the athlete's own label for the run.
+		if activity.WorkoutType != nil {
+			switch *activity.WorkoutType {
+			case models.WorkoutTypeRace:
+				return "race"
+			case models.WorkoutTypeLongRun:
+				return "long"
+			case models.WorkoutTypeWorkout:
+				return "tempo"
+			}
+		}
 		return "easy" // Default unmatched runs to 'easy'
 	}
-	if activityType == models.ActivityTypeRecovery {
+	if activity.ActivityType == models.ActivityTypeRecovery {
 		return "recovery"
 	}
 	// Everything else is cross training
@@ -336,3 +382,73 @@ func (s *CalendarService) UpdateStatus(ctx context.Context, userID uuid.UUID, en
 	}
 	return &entry, nil
 }
+
+var (
+	// ErrTaperStale is returned when an entry in an accepted taper was
+	// edited, completed or deleted after the taper was proposed.
+	ErrTaperStale = errors.New("calendar changed since the taper was proposed")
+	// ErrInvalidTaperChange is returned for a malformed taper change.
+	ErrInvalidTaperChange = errors.New("invalid taper change")
+)
+
+// ApplyTaper writes an accepted taper proposal's volumes to the athlete's
+// planned entries. The changes land together in one statement, and only if
+// every entry is still planned and unedited since the proposal (its
+// updated_at matches); otherwise nothing changes and ErrTaperStale is
+// returned. A volume the entry never had stays unset.
+func (s *CalendarService) ApplyTaper(ctx context.Context, userID uuid.UUID, changes []metrics.TaperChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	ids := make(pq.StringArray, 0, len(changes))
+	distances := make(pq.Int64Array, 0, len(changes))
+	durations := make(pq.Int64Array, 0, len(changes))
+	seen := make(pq.StringArray, 0, len(changes))
+	unique := make(map[uuid.UUID]bool, len(changes))
+	for _, c := range changes {
+		if unique[c.EntryID] {
+			return fmt.Errorf("%w: entry %s changed twice", ErrInvalidTaperChange, c.EntryID)
+		}
+		unique[c.EntryID] = true
+		if (c.PlannedDistanceMeters != nil && *c.PlannedDistanceMeters <= 0) ||
+			(c.PlannedDurationMinutes != nil && *c.PlannedDurationMinutes <= 0) {
+			return fmt.Errorf("%w: entry %s: planned volume must be positive", ErrInvalidTaperChange, c.EntryID)
+		}
+		ids = append(ids, c.EntryID.String())
+		distances = append(distances, int64(derefInt(c.PlannedDistanceMeters)))
+		durations = append(durations, int64(derefInt(c.PlannedDurationMinutes)))
+		seen = append(seen, c.UpdatedAt.Format(time.RFC3339Nano))
+	}
+
+	result, err := s.db.ExecContext(ctx, `
+		WITH changes AS (
+			SELECT * FROM unnest($2::uuid[], $3::int[], $4::int[], $5::timestamptz[])
+				AS c(id, distance, duration, seen)
+		), unchanged AS (
+			SELECT t.id FROM training_calendar t
+			JOIN changes c ON c.id = t.id
+			WHERE t.user_id = $1 AND t.status = 'planned' AND t.updated_at = c.seen
+		)
+		UPDATE training_calendar t SET
+			planned_distance_meters = CASE WHEN t.planned_distance_meters IS NULL THEN NULL ELSE c.distance END,
+			planned_duration_minutes = CASE WHEN t.planned_duration_minutes IS NULL THEN NULL ELSE c.duration END,
+			updated_at = NOW()
+		FROM changes c
+		WHERE t.id = c.id AND t.user_id = $1
+		  AND (SELECT COUNT(*) FROM unchanged) = $6
+	`, userID, ids, distances, durations, seen, len(changes))
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrTaperStale
+	}
+	return nil
+}
+
+func derefInt(v *int) int {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -3,17 +3,25 @@ package services
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/metrics"
 	"github.com/korsana/backend/internal/models"
+	"github.com/lib/pq"
 )
 
 type mockCalendarQuerier struct {
 	getQuery string
 	getArgs  []any
+
+	execQuery    string
+	execArgs     []any
+	rowsAffected int64
 }
 
 func (m *mockCalendarQuerier) NamedExecContext(_ context.Context, _ string, _ any) (sql.Result, error) {
@@ -36,8 +44,10 @@ func (m *mockCalendarQuerier) SelectContext(_ context.Context, _ any, _ string,
 	return nil
 }
 
-func (m *mockCalendarQuerier) ExecContext(_ context.Context, _ string, _ ...any) (sql.Result, error) {
-	return nil, nil
+func (m *mockCalendarQuerier) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	m.execQuery = query
+	m.execArgs = append([]any(nil), args...)
+	return driver.RowsAffected(m.rowsAffected), nil
 }
 
 func TestCreateEntryScopesReadbackByUser(t *testing.T) {
@@ -69,3 +79,62 @@ func TestCreateEntryScopesReadbackByUser(t *testing.T) {
 		t.Fatalf("expected second query arg to be userID, got %#v", db.getArgs[1])
 	}
 }
+
+func TestPickPlannedEntryPrefersMatchingWorkoutType(t *testing.T) {
+	race := models.WorkoutTypeRace
+	activity := &models.Activity{ActivityType: models.ActivityTypeRun, WorkoutType: &race}
+
+	planned := []models.CalendarEntry{
+		{ID: uuid.New(), WorkoutType: "easy"},
+		{ID: uuid.New(), WorkoutType: "race"},
+	}
+
+	got := pickPlannedEntry(planned, activity.ActivityType, mapActivityToWorkoutType(activity))
+	if got == nil || got.ID != planned[1].ID {
+		t.Fatalf("expected the planned race to be completed, got %+v", got)
+	}
+
+	got = pickPlannedEntry(planned[:1], activity.ActivityType, mapActivityToWorkoutType(activity))
+	if got == nil || got.ID != planned[0].ID {
+		t.Fatalf("expected fallback to the first compatible entry, got %+v", got)
+	}
+}
+
+func TestApplyTaperWritesAllChangesOrReportsStale(t *testing.T) {
+	userID := uuid.New()
+	dist, dur := 8000, 45
+	seen := time.Date(2026, time.October, 1, 9, 30, 0, 123456000, time.UTC)
+	changes := []metrics.TaperChange{
+		{EntryID: uuid.New(), UpdatedAt: seen, PlannedDistanceMeters: &dist},
+		{EntryID: uuid.New(), UpdatedAt: seen, PlannedDurationMinutes: &dur},
+	}
+
+	db := &mockCalendarQuerier{rowsAffected: 2}
+	svc := &CalendarService{db: db}
+	if err := svc.ApplyTaper(context.Background(), userID, changes); err != nil {
+		t.Fatalf("expected nil error, got %v", err)
+	}
+	if db.execArgs[0] != userID || db.execArgs[5] != len(changes) {
+		t.Fatalf("expected the update scoped by user and guarded by the change count, got %#v", db.execArgs)
+	}
+	if got := db.execArgs[2].(pq.Int64Array); got[0] != 8000 || got[1] != 0 {
+		t.Fatalf("unexpected distances %v", got)
+	}
+	if got := db.execArgs[4].(pq.StringArray); got[0] != "2026-10-01T09:30:00.123456Z" {
+		t.Fatalf("expected updated_at passed at full precision, got %v", got)
+	}
+
+	db.rowsAffected = 0
+	if err := svc.ApplyTaper(context.Background(), userID, changes); !errors.Is(err, ErrTaperStale) {
+		t.Fatalf("expected ErrTaperStale, got %v", err)
+	}
+
+	db.execQuery = ""
+	dup := []metrics.TaperChange{changes[0], changes[0]}
+	if err := svc.ApplyTaper(context.Background(), userID, dup); !errors.Is(err, ErrInvalidTaperChange) {
+		t.Fatalf("expected ErrInvalidTaperChange, got %v", err)
+	}
+	if db.execQuery != "" {
+		t.Fatal("expected nothing written for an invalid taper")
+	}
+}
@@ -202,6 +202,8 @@ const (
 	maxContextFlaggedConcerns  = 5
 	maxContextWeeklySummaries  = 6
 	maxContextUpcomingEntries  = 7
+	maxContextKeySessions      = 5
+	maxKeySessionDescription   = 160
 )
 
 // ErrInvalidCoachMode is returned when the client sends an unsupported coach mode.
@@ -921,12 +923,18 @@ func (s *CoachService) buildTrainingContext(ctx context.Context, userID uuid.UUI
 	var parts []string
 
 	profileStr := ""
+	restingHR, maxHR := heartRateBounds(ctx, s.db, userID)
+	var hrZones, paceZones []models.TrainingZone
+	thresholdPace := metrics.DefaultThresholdPace
 	if s.userProfileService != nil {
-		if p, err := s.userProfileService.GetOrCreateProfile(ctx, userID); err == nil && p.DisplayName != nil {
-			profileStr += fmt.Sprintf("Runner Name: %s\n", *p.DisplayName)
+		if p, err := s.userProfileService.GetOrCreateProfile(ctx, userID); err == nil {
+			if p.DisplayName != nil {
+				profileStr += fmt.Sprintf("Runner Name: %s\n", *p.DisplayName)
+			}
 		}
 
 		if prs, err := s.userProfileService.GetPersonalRecords(ctx, userID); err == nil && len(prs) > 0 {
+			thresholdPace = metrics.ThresholdPaceFromPRs(prs)
 			prs = limitContextItems(prs, maxContextPersonalRecords)
 			profileStr += "Personal Records:\n"
 			for _, pr := range prs {
@@ -935,6 +943,7 @@ func (s *CoachService) buildTrainingContext(ctx context.Context, userID uuid.UUI
 		}
 
 		if zones, err := s.userProfileService.GetTrainingZones(ctx, userID, "hr"); err == nil && len(zones) > 0 {
+			hrZones = zones
 			zones = limitContextItems(zones, maxContextTrainingZones)
 			profileStr += "Current HR Training Zones:\n"
 			for _, z := range zones {
@@ -953,6 +962,7 @@ func (s *CoachService) buildTrainingContext(ctx context.Context, userID uuid.UUI
 				profileStr += fmt.Sprintf("- Z%d%s: %d - %s bpm\n", z.ZoneNumber, lbl, minV, maxV)
 			}
 		}
+		paceZones, _ = s.userProfileService.GetTrainingZones(ctx, userID, "pace")
 	}
 	if profileStr != "" {
 		parts = append(parts, profileStr)
@@ -991,7 +1001,7 @@ Training Phase: %s`,
 	var activities []models.Activity
 	query := `
 		SELECT * FROM activities
-		WHERE user_id = $1 AND start_time >= NOW() - INTERVAL '42 days'
+		WHERE user_id = $1 AND merged_into IS NULL AND start_time >= NOW() - INTERVAL '42 days'
 		ORDER BY start_time DESC
 	`
 	err = s.db.SelectContext(ctx, &activities, query, userID)
@@ -1002,9 +1012,12 @@ Training Phase: %s`,
 
 	// Compute current training state from activities
 	if len(activities) > 0 {
-		loadResult := metrics.CalculateATLCTL(activities, 0, 0)
-		recoveryResult := metrics.RecoveryStatus(activities, 0, 0)
-		injuryResult := metrics.InjuryRisk(activities, loadResult.ATL, loadResult.CTL)
+		zoneRestingHR, zoneMaxHR := athleteHeartRates(ctx, s.db, userID)
+		zones := metrics.ZonesFromTraining(hrZones, paceZones, zoneRestingHR, zoneMaxHR, thresholdPace)
+		manual := manualLoadSessions(ctx, s.db, userID, time.Now().AddDate(0, 0, -42))
+		loadResult := metrics.CalculateATLCTL(activities, manual, restingHR, maxHR, metrics.NewRunEquivalence(s.config.RunEquivalence))
+		recoveryResult := metrics.RecoveryStatus(activities, zones, restingHR, maxHR)
+		injuryResult := metrics.InjuryRisk(activities, zones, loadResult.ATL, loadResult.CTL)
 		parts = append(parts, fmt.Sprintf(
 			"Current Training State: Recovery %d%% (%s) · Injury Risk: %s · Form (TSB): %.1f",
 			int(recoveryResult.RecoveryPct),
@@ -1092,6 +1105,10 @@ Training Phase: %s`,
 			}
 		}
 		parts = append(parts, activityLines)
+
+		if keySessions := s.keySessionLines(ctx, activities); keySessions != "" {
+			parts = append(parts, keySessions)
+		}
 	} else {
 		parts = append(parts, "Recent Training: No activities recorded in the last 6 weeks.")
 	}
@@ -1162,7 +1179,7 @@ Training Phase: %s`,
 
 	// Longest run in last 3 weeks
 	var longestRun float64
-	longestQuery := `SELECT COALESCE(MAX(distance_meters), 0) FROM activities WHERE user_id = $1 AND start_time >= NOW() - INTERVAL '21 days'`
+	longestQuery := `SELECT COALESCE(MAX(distance_meters), 0) FROM activities WHERE user_id = $1 AND merged_into IS NULL AND start_time >= NOW() - INTERVAL '21 days'`
 	_ = s.db.GetContext(ctx, &longestRun, longestQuery, userID)
 	if longestRun > 0 {
 		parts = append(parts, fmt.Sprintf("Longest Run (last 3 weeks): %.1f km", longestRun/1000))
@@ -1258,6 +1275,71 @@ func min(a, b int) int {
 }
 
 // formatTime formats seconds into HH:MM:SS
+// keySessionLines lists the athlete's flagged races, long runs and workouts
+// (newest first) so the coach sees what those sessions actually contained.
+func (s *CoachService) keySessionLines(ctx context.Context, activities []models.Activity) string {
+	lines := ""
+	count := 0
+	for i := range activities {
+		act := &activities[i]
+		if act.WorkoutType == nil || count >= maxContextKeySessions {
+			continue
+		}
+		var laps []models.ActivityLap
+		if *act.WorkoutType == models.WorkoutTypeWorkout {
+			_ = s.db.SelectContext(ctx, &laps,
+				"SELECT * FROM activity_laps WHERE activity_id = $1 ORDER BY lap_index", act.ID)
+		}
+		lines += "\n- " + formatKeySession(act, laps)
+		count++
+	}
+	if lines == "" {
+		return ""
+	}
+	return "Key Sessions (athlete-flagged on Strava):" + lines
+}
+
+// formatKeySession renders one key session: date, type, name, totals, the
+// work reps for a workout, and the athlete's own description.
+func formatKeySession(act *models.Activity, laps []models.ActivityLap) string {
+	date := act.StartTime
+	if act.LocalDate != nil {
+		date = *act.LocalDate
+	}
+	duration := act.DurationSeconds
+	line := fmt.Sprintf("%s %s: %s, %.1f km in %s",
+		date.Format("2006-01-02"),
+		strings.ReplaceAll(*act.WorkoutType, "_", " "),
+		act.Name,
+		act.DistanceMeters/1000.0,
+		formatTime(&duration),
+	)
+
+	if work := metrics.WorkLaps(laps); len(work) > 0 {
+		var dist float64
+		var secs int
+		for _, lap := range work {
+			dist += lap.DistanceMeters
+			secs += lap.MovingSeconds
+		}
+		pace := float64(secs) / (dist / 1000.0)
+		line += fmt.Sprintf("; work: %d reps, avg %.2f km at %d:%02d/km",
+			len(work), dist/1000.0/float64(len(work)), int(pace)/60, int(pace)%60)
+		if hr := metrics.LapsHeartRate(work); hr > 0 {
+			line += fmt.Sprintf(", avg HR %d", int(hr))
+		}
+	}
+
+	if act.Description != nil && *act.Description != "" {
+		desc := strings.Join(strings.Fields(*act.Description), " ")
+		if runes := []rune(desc); len(runes) > maxKeySessionDescription {
+			desc = string(runes[:maxKeySessionDescription]) + "…"
+		}
+		line += fmt.Sprintf(" — %q", desc)
+	}
+	return line
+}
+
 func formatTime(seconds *int) string {
 	if seconds == nil {
 		return "Just finish"
@@ -1,8 +1,14 @@
 package services
 
 import (
+	"context"
+	"database/sql"
 	"errors"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/google/uuid"
 
 	"github.com/korsana/backend/internal/models"
 )
@@ -129,3 +135,76 @@ func TestExtractArtifactRejectsInvalidRaceStrategyShape(t *testing.T) {
 		t.Fatalf("expected invalid artifact to be dropped, got %#v", artifact)
 	}
 }
+
+func TestFormatKeySessionSummarizesWorkReps(t *testing.T) {
+	workout := models.WorkoutTypeWorkout
+	desc := "6x1k   off 90s\njog"
+	act := &models.Activity{
+		Name:            "Tuesday reps",
+		DistanceMeters:  12000,
+		DurationSeconds: 3600,
+		StartTime:       time.Date(2026, time.March, 3, 12, 0, 0, 0, time.UTC),
+		WorkoutType:     &workout,
+		Description:     &desc,
+	}
+
+	hr := func(v float64) *float64 { return &v }
+	laps := []models.ActivityLap{
+		{DistanceMeters: 2000, MovingSeconds: 660, AverageHeartRate: hr(130)},
+		{DistanceMeters: 1000, MovingSeconds: 225, AverageHeartRate: hr(165)},
+		{DistanceMeters: 400, MovingSeconds: 180, AverageHeartRate: hr(140)},
+		{DistanceMeters: 1000, MovingSeconds: 225, AverageHeartRate: hr(171)},
+		{DistanceMeters: 50, MovingSeconds: 10},
+	}
+
+	got := formatKeySession(act, laps)
+	want := `2026-03-03 workout: Tuesday reps, 12.0 km in 1:00:00; work: 2 reps, avg 1.00 km at 3:45/km, avg HR 168 — "6x1k off 90s jog"`
+	if got != want {
+		t.Fatalf("unexpected summary:\n got  %s\n want %s", got, want)
+	}
+}
+
+// heartRateTestDB serves a profile and a daily_wellness average to
+// heartRateBounds.
+type heartRateTestDB struct {
+	profile  models.UserProfile
+	wellness sql.NullFloat64
+}
+
+func (db *heartRateTestDB) GetContext(_ context.Context, dest any, query string, _ ...any) error {
+	switch d := dest.(type) {
+	case *models.UserProfile:
+		*d = db.profile
+	case *sql.NullFloat64:
+		if !strings.Contains(query, "daily_wellness") {
+			return errors.New("unexpected query")
+		}
+		*d = db.wellness
+	}
+	return nil
+}
+
+func TestHeartRateBounds(t *testing.T) {
+	intPtr := func(v int) *int { return &v }
+	measured := sql.NullFloat64{Float64: 48, Valid: true}
+
+	tests := []struct {
+		name      string
+		db        heartRateTestDB
+		wantRest  float64
+		wantMaxHR float64
+	}{
+		{name: "profile values", db: heartRateTestDB{profile: models.UserProfile{RestingHeartRate: intPtr(50), MaxHeartRate: intPtr(185)}, wellness: measured}, wantRest: 50, wantMaxHR: 185},
+		{name: "measured resting HR", db: heartRateTestDB{profile: models.UserProfile{MaxHeartRate: intPtr(185)}, wellness: measured}, wantRest: 48, wantMaxHR: 185},
+		{name: "defaults", db: heartRateTestDB{}, wantRest: 55, wantMaxHR: 190},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rest, maxHR := heartRateBounds(context.Background(), &tt.db, uuid.New())
+			if rest != tt.wantRest || maxHR != tt.wantMaxHR {
+				t.Fatalf("expected %v/%v, got %v/%v", tt.wantRest, tt.wantMaxHR, rest, maxHR)
+			}
+		})
+	}
+}
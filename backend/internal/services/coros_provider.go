@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/coros"
+)
+
+// corosHistoryWindow is how far back FetchAllActivities reaches.
+const corosHistoryWindow = 180 * 24 * time.Hour
+
+// CorosProvider adapts the COROS Open API to sync.DataProvider.
+type CorosProvider struct {
+	svc *CorosService
+}
+
+var _ sync.DataProvider = (*CorosProvider)(nil)
+
+// NewCorosProvider creates a provider backed by svc.
+func NewCorosProvider(svc *CorosService) *CorosProvider {
+	return &CorosProvider{svc: svc}
+}
+
+// GetProviderName implements sync.DataProvider.
+func (p *CorosProvider) GetProviderName() string {
+	return "coros"
+}
+
+// FetchRecentActivities implements sync.DataProvider. The workout list is
+// queried in coros.MaxListWindow chunks from since up to today.
+func (p *CorosProvider) FetchRecentActivities(ctx context.Context, integration *models.ConnectedIntegration, since time.Time) ([]sync.RawActivity, error) {
+	if integration.ExternalUserID == nil {
+		return nil, ErrCorosConnectionNotFound
+	}
+
+	var out []sync.RawActivity
+	now := time.Now()
+	// Date bounds are inclusive days, so each chunk ends a day before the
+	// next one starts.
+	for start := since; !start.After(now); start = start.Add(coros.MaxListWindow) {
+		end := start.Add(coros.MaxListWindow - 24*time.Hour)
+		if end.After(now) {
+			end = now
+		}
+		workouts, err := p.svc.client.ListWorkouts(ctx, integration.AccessToken, *integration.ExternalUserID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, w := range workouts {
+			if raw, ok := corosRawActivity(w); ok {
+				out = append(out, raw)
+			}
+		}
+	}
+	return out, nil
+}
+
+// FetchAllActivities implements sync.DataProvider.
+func (p *CorosProvider) FetchAllActivities(ctx context.Context, integration *models.ConnectedIntegration) ([]sync.RawActivity, error) {
+	return p.FetchRecentActivities(ctx, integration, time.Now().Add(-corosHistoryWindow))
+}
+
+// RefreshTokenIfNeeded implements sync.DataProvider.
+func (p *CorosProvider) RefreshTokenIfNeeded(ctx context.Context, integration *models.ConnectedIntegration) error {
+	fresh, err := p.svc.RefreshAccessToken(ctx, integration)
+	if err != nil {
+		return err
+	}
+	*integration = *fresh
+	return nil
+}
+
+// mapCorosMode converts COROS sport modes to internal types. Modes without
+// a counterpart (multisport, ski, cardio) become generic workouts.
+func mapCorosMode(mode int) string {
+	switch mode {
+	case 8, 15, 20: // run, trail run, track run
+		return models.ActivityTypeRun
+	case 9: // bike
+		return models.ActivityTypeCycling
+	case 10: // pool and open-water swim
+		return models.ActivityTypeSwimming
+	case 31: // walk
+		return models.ActivityTypeWalking
+	case 14, 16: // mountain climb, hike
+		return models.ActivityTypeHiking
+	case 23: // strength
+		return models.ActivityTypeWeightLifting
+	case 24: // rowing
+		return models.ActivityTypeRowing
+	default:
+		return models.ActivityTypeWorkout
+	}
+}
+
+// corosRawActivity normalizes a COROS workout for sync.UpsertActivity. It
+// returns false for records without an ID or start time.
+func corosRawActivity(w coros.Workout) (sync.RawActivity, bool) {
+	if w.LabelID == "" || w.StartTime == 0 {
+		return sync.RawActivity{}, false
+	}
+
+	internalType := mapCorosMode(w.Mode)
+	startTime := time.Unix(w.StartTime, 0).UTC()
+	offset := time.Duration(w.StartTimezone) * 15 * time.Minute
+	localDate := startTime.Add(offset).Truncate(24 * time.Hour)
+
+	duration := w.Duration
+	if duration == 0 && w.EndTime > w.StartTime {
+		duration = int(w.EndTime - w.StartTime)
+	}
+	name := w.Name
+	if name == "" {
+		name = internalType
+	}
+
+	raw := sync.RawActivity{
+		SourceID:     w.LabelID,
+		Source:       "coros",
+		StartTime:    startTime,
+		LocalDate:    &localDate,
+		Duration:     duration,
+		Distance:     w.Distance,
+		ActivityType: internalType,
+		Name:         name,
+	}
+
+	if models.DistanceBasedTypes[internalType] && w.Distance > 0 {
+		raw.AvgPace = float64(duration) / (w.Distance / 1000.0)
+	}
+	if w.AvgHeartRate > 0 {
+		hr := w.AvgHeartRate
+		raw.AvgHR = &hr
+	}
+	if w.MaxHeartRate > 0 {
+		mhr := w.MaxHeartRate
+		raw.MaxHR = &mhr
+	}
+	if w.ElevationGain > 0 {
+		elev := w.ElevationGain
+		raw.ElevGain = &elev
+	}
+	// Stored per leg, like Strava's run cadence.
+	if w.AvgCadence > 0 {
+		cadence := w.AvgCadence
+		if internalType == models.ActivityTypeRun {
+			cadence /= 2
+		}
+		raw.AvgCadence = &cadence
+	}
+	return raw, true
+}
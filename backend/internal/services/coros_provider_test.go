@@ -0,0 +1,124 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/coros"
+)
+
+func newTestCorosService(t *testing.T, handler http.Handler) (*CorosService, *models.ConnectedIntegration, *mockStravaDB) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	conn := &models.ConnectedIntegration{
+		ID:             uuid.New(),
+		UserID:         uuid.New(),
+		Source:         "coros",
+		ExternalUserID: ptr("open-1"),
+		AccessToken:    "coros-access",
+		RefreshToken:   ptr("coros-refresh"),
+		TokenExpiresAt: ptr(time.Now().Add(-time.Minute)),
+	}
+	db := &mockStravaDB{connections: map[uuid.UUID]*models.ConnectedIntegration{conn.ID: conn}}
+	client := coros.NewClient("client-id", "client-secret", "http://localhost/callback")
+	client.BaseURL = server.URL
+	return &CorosService{db: db, client: client}, conn, db
+}
+
+func TestCorosRawActivity(t *testing.T) {
+	raw, ok := corosRawActivity(coros.Workout{
+		LabelID:       "abc",
+		Mode:          8,
+		Name:          "Tempo",
+		StartTime:     time.Date(2026, time.April, 19, 20, 0, 0, 0, time.UTC).Unix(),
+		StartTimezone: 32, // UTC+8
+		Distance:      10000,
+		Duration:      2400,
+		AvgCadence:    180,
+	})
+	if !ok {
+		t.Fatal("expected workout to be accepted")
+	}
+	if raw.Source != "coros" || raw.SourceID != "abc" || raw.ActivityType != models.ActivityTypeRun {
+		t.Fatalf("unexpected identity %q/%q type %q", raw.Source, raw.SourceID, raw.ActivityType)
+	}
+	if raw.LocalDate == nil || raw.LocalDate.Format("2006-01-02") != "2026-04-20" {
+		t.Fatalf("expected the UTC+8 local date, got %v", raw.LocalDate)
+	}
+	if raw.AvgPace != 240 {
+		t.Fatalf("expected 240 s/km, got %v", raw.AvgPace)
+	}
+	if raw.AvgCadence == nil || *raw.AvgCadence != 90 {
+		t.Fatalf("expected per-leg cadence 90, got %v", raw.AvgCadence)
+	}
+}
+
+func TestCorosProviderRefreshesAndListsInWindows(t *testing.T) {
+	var listCalls int
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"result": "0000", "message": "OK"}) //nolint:errcheck
+	})
+	mux.HandleFunc("/v2/coros/sport/list", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("openId") != "open-1" || r.URL.Query().Get("token") != "coros-access" {
+			t.Errorf("unexpected credentials in %s", r.URL.RawQuery)
+		}
+		listCalls++
+		json.NewEncoder(w).Encode(map[string]any{ //nolint:errcheck
+			"result": "0000",
+			"data": []map[string]any{{
+				"labelId":   "w" + r.URL.Query().Get("startDate"),
+				"mode":      9,
+				"startTime": time.Now().Unix(),
+				"distance":  20000,
+				"duration":  3600,
+			}},
+		})
+	})
+	svc, conn, db := newTestCorosService(t, mux)
+	provider := NewCorosProvider(svc)
+
+	if err := provider.RefreshTokenIfNeeded(context.Background(), conn); err != nil {
+		t.Fatalf("unexpected refresh error: %v", err)
+	}
+	if !integrationTokenFresh(conn) || conn.AccessToken != "coros-access" {
+		t.Fatalf("expected expiry to be extended for the same token, got %v / %q", conn.TokenExpiresAt, conn.AccessToken)
+	}
+	if got := db.execCount.Load(); got != 1 {
+		t.Fatalf("expected one token update, got %d", got)
+	}
+
+	raws, err := provider.FetchRecentActivities(context.Background(), conn, time.Now().Add(-45*24*time.Hour))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if listCalls != 2 || len(raws) != 2 {
+		t.Fatalf("expected 2 windows and 2 workouts, got %d calls and %d workouts", listCalls, len(raws))
+	}
+	if raws[0].ActivityType != models.ActivityTypeCycling {
+		t.Fatalf("expected cycling, got %q", raws[0].ActivityType)
+	}
+}
+
+func TestCorosRejectedRefreshFlagsReauth(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/refresh-token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"result": "5001", "message": "invalid refresh token"}) //nolint:errcheck
+	})
+	svc, conn, db := newTestCorosService(t, mux)
+
+	if _, err := svc.RefreshAccessToken(context.Background(), conn); err == nil {
+		t.Fatal("expected the rejected refresh to fail")
+	}
+	if got := db.execCount.Load(); got != 1 {
+		t.Fatalf("expected the integration to be flagged reauth_required, got %d writes", got)
+	}
+}
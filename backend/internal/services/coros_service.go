@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/internal/tokencrypt"
+	"github.com/korsana/backend/pkg/coros"
+)
+
+const (
+	// corosUpgradeTimeout bounds the history upgrade started on first connect.
+	corosUpgradeTimeout = 10 * time.Minute
+
+	// corosSyncOverlap re-reads the day before the last sync so workouts
+	// uploaded late from the watch are not missed.
+	corosSyncOverlap = 24 * time.Hour
+)
+
+var (
+	// ErrCorosNotConfigured is returned when the server has no COROS app
+	// credentials.
+	ErrCorosNotConfigured = errors.New("coros integration is not configured")
+
+	// ErrCorosConnectionNotFound is returned when the user has no COROS
+	// connection on record.
+	ErrCorosConnectionNotFound = errors.New("coros connection not found")
+
+	// ErrCorosAlreadyConnected is returned when the COROS account is already
+	// linked to a different Korsana account.
+	ErrCorosAlreadyConnected = errors.New("coros account already connected to another user")
+)
+
+// CorosSyncResult summarizes a manual COROS sync.
+type CorosSyncResult struct {
+	Count int `json:"count"`
+}
+
+// CorosService handles COROS business logic. COROS has no push channel we
+// subscribe to, so new workouts come in through SyncActivities.
+type CorosService struct {
+	db           sync.Querier
+	client       *coros.Client
+	redis        *redis.Client
+	calendarSvc  *CalendarService
+	tokenKeys    *tokencrypt.Keyring
+	refreshGroup singleflight.Group
+}
+
+// NewCorosService creates a new COROS service
+func NewCorosService(db *database.DB, client *coros.Client, redisClient *redis.Client, calendarService *CalendarService, tokenKeys *tokencrypt.Keyring) *CorosService {
+	return &CorosService{
+		db:          db,
+		client:      client,
+		redis:       redisClient,
+		calendarSvc: calendarService,
+		tokenKeys:   tokenKeys,
+	}
+}
+
+// Configured reports whether COROS app credentials are set.
+func (s *CorosService) Configured() bool {
+	return s.client.Configured()
+}
+
+// GetAuthURL returns the COROS OAuth URL. returnTo is an optional frontend
+// path to redirect to after connecting.
+func (s *CorosService) GetAuthURL(ctx context.Context, userID uuid.UUID, returnTo string) (string, error) {
+	if !s.Configured() {
+		return "", ErrCorosNotConfigured
+	}
+	state, err := saveOAuthState(ctx, s.redis, "coros", OAuthState{UserID: userID, ReturnTo: returnTo})
+	if err != nil {
+		return "", err
+	}
+	return s.client.GetAuthorizationURL(state), nil
+}
+
+// ValidateOAuthState consumes the state parameter saved by GetAuthURL.
+func (s *CorosService) ValidateOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	return consumeOAuthState(ctx, s.redis, "coros", state)
+}
+
+// HandleCallback exchanges the authorization code and saves the connection.
+// On the first connect it upgrades the user's history in the background.
+func (s *CorosService) HandleCallback(ctx context.Context, userID uuid.UUID, code string) error {
+	tokenResp, err := s.client.ExchangeToken(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getIntegrationByExternalID(ctx, s.db, s.tokenKeys, "coros", tokenResp.OpenID)
+	if err == nil && existing.UserID != userID {
+		return ErrCorosAlreadyConnected
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, lookupErr := getIntegration(ctx, s.db, s.tokenKeys, userID, "coros")
+	firstConnect := errors.Is(lookupErr, sql.ErrNoRows)
+
+	integration, err := connectIntegration(ctx, s.db, s.tokenKeys, userID, "coros", tokenResp.OpenID, corosTokens(tokenResp))
+	if err != nil {
+		return err
+	}
+
+	if firstConnect {
+		go s.upgradeHistory(context.WithoutCancel(ctx), userID, integration)
+	}
+	return nil
+}
+
+func (s *CorosService) upgradeHistory(ctx context.Context, userID uuid.UUID, integration *models.ConnectedIntegration) {
+	ctx, cancel := context.WithTimeout(ctx, corosUpgradeTimeout)
+	defer cancel()
+
+	log := logger.FromContext(ctx).With("user_id", userID)
+	if err := sync.UpgradeHistoricalActivities(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, NewCorosProvider(s), integration); err != nil {
+		log.Error("coros: history upgrade failed", "error", err)
+		return
+	}
+	if err := refreshWeeklySummaries(ctx, s.db, userID); err != nil {
+		log.Warn("coros: weekly summaries after upgrade failed", "error", err)
+	}
+	if err := markIntegrationSynced(ctx, s.db, integration.ID); err != nil {
+		log.Warn("coros: failed to record sync time", "error", err)
+	}
+}
+
+// corosTokens converts a COROS token response for the integration store.
+func corosTokens(resp *coros.TokenResponse) integrationTokens {
+	lifetime := coros.AccessTokenLifetime
+	if resp.ExpiresIn > 0 {
+		lifetime = time.Duration(resp.ExpiresIn) * time.Second
+	}
+	refreshToken := resp.RefreshToken
+	expiresAt := time.Now().Add(lifetime)
+	return integrationTokens{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: &refreshToken,
+		ExpiresAt:    &expiresAt,
+	}
+}
+
+// GetConnection retrieves the COROS integration for a user
+func (s *CorosService) GetConnection(ctx context.Context, userID uuid.UUID) (*models.ConnectedIntegration, error) {
+	conn, err := getIntegration(ctx, s.db, s.tokenKeys, userID, "coros")
+	if err != nil {
+		return nil, ErrCorosConnectionNotFound
+	}
+	return conn, nil
+}
+
+// RefreshAccessToken extends the COROS access token when it is close to
+// expiry. COROS keeps the token itself and only pushes its expiry out, so
+// just token_expires_at changes. A rejected refresh flags the integration
+// reauth_required.
+func (s *CorosService) RefreshAccessToken(ctx context.Context, conn *models.ConnectedIntegration) (*models.ConnectedIntegration, error) {
+	if integrationTokenFresh(conn) {
+		return conn, nil
+	}
+
+	result, err, _ := s.refreshGroup.Do(conn.ID.String(), func() (any, error) {
+		latest, loadErr := getIntegrationByID(ctx, s.db, s.tokenKeys, conn.ID)
+		if loadErr == nil {
+			conn = latest
+		}
+		if integrationTokenFresh(conn) {
+			return conn, nil
+		}
+		if conn.RefreshToken == nil {
+			return nil, errors.New("coros integration has no refresh token")
+		}
+
+		if refreshErr := s.client.RefreshToken(ctx, *conn.RefreshToken); refreshErr != nil {
+			var apiErr *coros.APIError
+			if errors.As(refreshErr, &apiErr) && (apiErr.Result != "" || apiErr.StatusCode == http.StatusUnauthorized) {
+				if markErr := markIntegrationReauthRequired(ctx, s.db, conn.ID, refreshErr); markErr != nil {
+					logger.FromContext(ctx).Warn("coros: failed to flag integration for reauth",
+						"integration_id", conn.ID,
+						"error", markErr,
+					)
+				}
+				return nil, fmt.Errorf("%w: %w", ErrIntegrationReauthRequired, refreshErr)
+			}
+			return nil, refreshErr
+		}
+
+		expiresAt := time.Now().Add(coros.AccessTokenLifetime)
+		tokens := integrationTokens{AccessToken: conn.AccessToken, ExpiresAt: &expiresAt}
+		if execErr := updateIntegrationTokens(ctx, s.db, s.tokenKeys, conn.ID, tokens); execErr != nil {
+			return nil, execErr
+		}
+
+		updated := *conn
+		updated.TokenExpiresAt = &expiresAt
+		updated.Status = models.IntegrationStatusActive
+		updated.LastError = nil
+		updated.UpdatedAt = time.Now()
+		return &updated, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.ConnectedIntegration), nil
+}
+
+// SyncActivities imports workouts since the last sync (with a day of
+// overlap), or the full available history if the user never synced. Every
+// call is recorded in sync_runs.
+func (s *CorosService) SyncActivities(ctx context.Context, userID uuid.UUID) (_ *CorosSyncResult, err error) {
+	run := startSyncRun(userID, "coros", models.SyncModeIncremental)
+	defer func() { run.finish(ctx, s.db, err) }()
+
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := NewCorosProvider(s)
+	if err := provider.RefreshTokenIfNeeded(ctx, conn); err != nil {
+		return nil, err
+	}
+
+	var raws []sync.RawActivity
+	if conn.LastSyncedAt != nil {
+		raws, err = provider.FetchRecentActivities(ctx, conn, conn.LastSyncedAt.Add(-corosSyncOverlap))
+	} else {
+		run.Mode = models.SyncModeInitialBackfill
+		raws, err = provider.FetchAllActivities(ctx, conn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	count := 0
+	for _, raw := range raws {
+		result, err := sync.UpsertActivity(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, raw)
+		run.tally(result, err)
+		if err != nil {
+			logger.FromContext(ctx).Error("coros sync: failed to upsert activity",
+				"source_id", raw.SourceID,
+				"error", err,
+			)
+			continue
+		}
+		if result.Activity != nil {
+			count++
+		}
+	}
+
+	if count > 0 {
+		if err := refreshWeeklySummaries(ctx, s.db, userID); err != nil {
+			logger.FromContext(ctx).Warn("coros sync: weekly summaries failed", "error", err)
+		}
+	}
+	if err := markIntegrationSynced(ctx, s.db, conn.ID); err != nil {
+		logger.FromContext(ctx).Warn("coros sync: failed to record sync time", "error", err)
+	}
+	return &CorosSyncResult{Count: count}, nil
+}
+
+// DisconnectCoros revokes the app's access at COROS (best effort) and
+// removes the connection.
+func (s *CorosService) DisconnectCoros(ctx context.Context, userID uuid.UUID) error {
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.client.Deauthorize(ctx, conn.AccessToken); err != nil {
+		logger.FromContext(ctx).Warn("coros: deauthorize failed", "user_id", userID, "error", err)
+	}
+	return disconnectIntegration(ctx, s.db, userID, "coros")
+}
@@ -134,6 +134,7 @@ func (s *CrossTrainingGoalsService) GetWeeklyProgress(
 		SELECT activity_type, COUNT(*) as count
 		FROM activities
 		WHERE user_id = $1
+		  AND merged_into IS NULL
 		  AND activity_type != 'run'
 		  AND start_time >= date_trunc('week', NOW())
 		  AND start_time < date_trunc('week', NOW()) + INTERVAL '7 days'
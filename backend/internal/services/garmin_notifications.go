@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/garmin"
+)
+
+const (
+	garminNotificationPollInterval   = 10 * time.Second
+	garminNotificationProcessTimeout = 60 * time.Second
+	garminNotificationClaimTimeout   = 5 * time.Minute
+	garminNotificationMaxAttempts    = 5
+	garminNotificationRetryBaseDelay = 30 * time.Second
+)
+
+// garminQueuedSummaryTypes are the notification keys that get queued; any
+// other summary type Garmin sends is acknowledged and dropped.
+var garminQueuedSummaryTypes = []string{
+	garmin.SummaryActivities,
+	garmin.SummaryActivityDetails,
+	garmin.SummaryDeregistrations,
+	garmin.SummaryUserPermissions,
+}
+
+// EnqueueNotification queues every record of a Garmin notification for the
+// notification worker. Redelivered records hit the unique hash and are
+// dropped.
+func (s *GarminService) EnqueueNotification(ctx context.Context, notification garmin.Notification) error {
+	queued := false
+	for _, summaryType := range garminQueuedSummaryTypes {
+		for _, record := range notification[summaryType] {
+			var fields garmin.Record
+			if err := json.Unmarshal(record, &fields); err != nil || fields.UserID == "" {
+				logger.FromContext(ctx).Warn("garmin notification: skipping record without user id",
+					"summary_type", summaryType,
+				)
+				continue
+			}
+
+			sum := sha256.Sum256(record)
+			if _, err := s.db.ExecContext(ctx, `
+				INSERT INTO garmin_notifications (summary_type, garmin_user_id, record, record_hash)
+				VALUES ($1, $2, $3, $4)
+				ON CONFLICT (summary_type, record_hash) DO NOTHING
+			`, summaryType, fields.UserID, []byte(record), hex.EncodeToString(sum[:])); err != nil {
+				return err
+			}
+			queued = true
+		}
+	}
+
+	if queued {
+		select {
+		case s.notificationWake <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// claimNotification atomically moves the oldest due record to processing.
+// Same claiming scheme as the Strava webhook queue.
+func (s *GarminService) claimNotification(ctx context.Context) (*models.GarminNotification, error) {
+	var n models.GarminNotification
+	err := s.db.GetContext(ctx, &n, `
+		UPDATE garmin_notifications
+		SET status = 'processing', claimed_at = NOW(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM garmin_notifications
+			WHERE (status = 'pending' AND available_at <= NOW())
+			   OR (status = 'processing' AND claimed_at < NOW() - make_interval(secs => $1))
+			ORDER BY available_at ASC
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, garminNotificationClaimTimeout.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// finishNotification records the outcome of one processing attempt.
+func (s *GarminService) finishNotification(ctx context.Context, n *models.GarminNotification, procErr error) error {
+	if procErr == nil {
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE garmin_notifications
+			SET status = 'done', processed_at = NOW(), last_error = NULL
+			WHERE id = $1
+		`, n.ID)
+		return err
+	}
+
+	status := "failed"
+	delay := time.Duration(0)
+	if isRetryableGarminError(procErr) && n.Attempts < garminNotificationMaxAttempts {
+		status = "pending"
+		delay = garminNotificationRetryBaseDelay * time.Duration(n.Attempts)
+		var apiErr *garmin.APIError
+		if errors.As(procErr, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE garmin_notifications
+		SET status = $1, last_error = $2, available_at = NOW() + make_interval(secs => $3)
+		WHERE id = $4
+	`, status, procErr.Error(), delay.Seconds(), n.ID)
+	return err
+}
+
+// isRetryableGarminError reports whether a failed import is worth another
+// attempt: rate limits, server errors and transport failures.
+func isRetryableGarminError(err error) bool {
+	var apiErr *garmin.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// ProcessNotification applies one queued record. Records for Garmin users
+// with no Korsana connection are acknowledged and dropped.
+func (s *GarminService) ProcessNotification(ctx context.Context, n *models.GarminNotification) error {
+	conn, err := getIntegrationByExternalID(ctx, s.db, s.tokenKeys, "garmin", n.GarminUserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var record garmin.Record
+	if err := json.Unmarshal(n.Record, &record); err != nil {
+		return fmt.Errorf("decode garmin record: %w", err)
+	}
+
+	switch n.SummaryType {
+	case garmin.SummaryDeregistrations:
+		return disconnectIntegration(ctx, s.db, conn.UserID, "garmin")
+	case garmin.SummaryUserPermissions:
+		if record.AllowsActivityExport() {
+			return nil
+		}
+		return markIntegrationReauthRequired(ctx, s.db, conn.ID, errors.New("activity export permission revoked in Garmin Connect"))
+	case garmin.SummaryActivities, garmin.SummaryActivityDetails:
+		return s.importGarminRecord(ctx, conn, n.SummaryType, record, n.Record)
+	default:
+		return nil
+	}
+}
+
+// importGarminRecord stores the activities behind one record: pulled from
+// the callback URL for a ping, or decoded from the record itself for a push.
+// Each record is recorded in sync_runs as a webhook run.
+func (s *GarminService) importGarminRecord(ctx context.Context, conn *models.ConnectedIntegration, summaryType string, record garmin.Record, payload json.RawMessage) (err error) {
+	run := startSyncRun(conn.UserID, "garmin", models.SyncModeWebhook)
+	defer func() { run.finish(ctx, s.db, err) }()
+
+	var activities []garmin.Activity
+	if record.IsPing() {
+		fresh, err := s.RefreshAccessToken(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if activities, err = s.pullGarminCallback(ctx, fresh.AccessToken, summaryType, record.CallbackURL); err != nil {
+			return err
+		}
+	} else {
+		act, err := decodeGarminPush(summaryType, payload)
+		if err != nil {
+			return err
+		}
+		activities = []garmin.Activity{act}
+	}
+
+	stored, err := s.storeGarminActivities(ctx, conn.UserID, activities, run)
+	if err != nil {
+		return err
+	}
+	if stored > 0 {
+		if err := refreshWeeklySummaries(ctx, s.db, conn.UserID); err != nil {
+			return err
+		}
+	}
+	return markIntegrationSynced(ctx, s.db, conn.ID)
+}
+
+func (s *GarminService) pullGarminCallback(ctx context.Context, accessToken, summaryType, callbackURL string) ([]garmin.Activity, error) {
+	if summaryType == garmin.SummaryActivityDetails {
+		var details []garmin.ActivityDetail
+		if err := s.client.FetchCallback(ctx, accessToken, callbackURL, &details); err != nil {
+			return nil, err
+		}
+		return garminDetailSummaries(details), nil
+	}
+
+	var activities []garmin.Activity
+	if err := s.client.FetchCallback(ctx, accessToken, callbackURL, &activities); err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+func decodeGarminPush(summaryType string, payload json.RawMessage) (garmin.Activity, error) {
+	if summaryType == garmin.SummaryActivityDetails {
+		var detail garmin.ActivityDetail
+		if err := json.Unmarshal(payload, &detail); err != nil {
+			return garmin.Activity{}, fmt.Errorf("decode garmin activity detail: %w", err)
+		}
+		return garminDetailSummaries([]garmin.ActivityDetail{detail})[0], nil
+	}
+
+	var act garmin.Activity
+	if err := json.Unmarshal(payload, &act); err != nil {
+		return garmin.Activity{}, fmt.Errorf("decode garmin activity: %w", err)
+	}
+	return act, nil
+}
+
+// garminDetailSummaries extracts the summaries of activity details. The
+// activity ID sits on the detail record, not inside its summary.
+func garminDetailSummaries(details []garmin.ActivityDetail) []garmin.Activity {
+	out := make([]garmin.Activity, 0, len(details))
+	for _, d := range details {
+		act := d.Summary
+		if act.ActivityID == 0 {
+			act.ActivityID = d.ActivityID
+		}
+		out = append(out, act)
+	}
+	return out
+}
+
+// GarminNotificationWorker drains the garmin_notifications queue, waking on
+// every enqueue and polling for retries.
+type GarminNotificationWorker struct {
+	svc          *GarminService
+	pollInterval time.Duration
+}
+
+// NewGarminNotificationWorker creates a worker bound to the Garmin service.
+func NewGarminNotificationWorker(svc *GarminService) *GarminNotificationWorker {
+	return &GarminNotificationWorker{
+		svc:          svc,
+		pollInterval: garminNotificationPollInterval,
+	}
+}
+
+// Run processes notifications until ctx is cancelled.
+func (w *GarminNotificationWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.svc.notificationWake:
+		}
+	}
+}
+
+func (w *GarminNotificationWorker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := w.processNext(ctx)
+		if err != nil {
+			logger.FromContext(ctx).Error("garmin notification worker: queue error", "error", err)
+			return
+		}
+		if !processed {
+			return
+		}
+	}
+}
+
+func (w *GarminNotificationWorker) processNext(ctx context.Context) (bool, error) {
+	n, err := w.svc.claimNotification(ctx)
+	if err != nil || n == nil {
+		return false, err
+	}
+
+	log := logger.FromContext(ctx).With(
+		"notification_id", n.ID,
+		"summary_type", n.SummaryType,
+		"garmin_user_id", n.GarminUserID,
+	)
+	nCtx, cancel := context.WithTimeout(logger.WithLogger(ctx, log), garminNotificationProcessTimeout)
+	procErr := w.svc.ProcessNotification(nCtx, n)
+	cancel()
+
+	if procErr != nil {
+		log.Warn("garmin notification worker: record failed", "attempt", n.Attempts, "error", procErr)
+	}
+	if err := w.svc.finishNotification(ctx, n, procErr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
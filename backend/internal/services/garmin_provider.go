@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/garmin"
+)
+
+// garminBackfillHistory is how far back FetchAllActivities asks Garmin to
+// re-deliver activities.
+const garminBackfillHistory = 2 * 365 * 24 * time.Hour
+
+// GarminProvider adapts the Garmin Health API to sync.DataProvider.
+//
+// Garmin only serves pulls for data uploaded after the user connected;
+// anything older has to be requested as a backfill, which Garmin delivers
+// later as ordinary notifications. Those go through the same
+// sync.UpsertActivity path, so upgrades of existing rows still happen, just
+// asynchronously.
+type GarminProvider struct {
+	svc *GarminService
+}
+
+var _ sync.DataProvider = (*GarminProvider)(nil)
+
+// NewGarminProvider creates a provider backed by svc.
+func NewGarminProvider(svc *GarminService) *GarminProvider {
+	return &GarminProvider{svc: svc}
+}
+
+// GetProviderName implements sync.DataProvider.
+func (p *GarminProvider) GetProviderName() string {
+	return "garmin"
+}
+
+// FetchRecentActivities implements sync.DataProvider. It walks upload-time
+// windows from since (or the connection time, if later) up to now.
+func (p *GarminProvider) FetchRecentActivities(ctx context.Context, integration *models.ConnectedIntegration, since time.Time) ([]sync.RawActivity, error) {
+	if since.Before(integration.ConnectedAt) {
+		since = integration.ConnectedAt
+	}
+
+	var out []sync.RawActivity
+	now := time.Now()
+	for start := since; start.Before(now); start = start.Add(garmin.MaxUploadWindow) {
+		end := start.Add(garmin.MaxUploadWindow)
+		if end.After(now) {
+			end = now
+		}
+		activities, err := p.svc.client.ListActivities(ctx, integration.AccessToken, start, end)
+		if err != nil {
+			return nil, err
+		}
+		for _, act := range activities {
+			if raw, ok := garminRawActivity(act); ok {
+				out = append(out, raw)
+			}
+		}
+	}
+	return out, nil
+}
+
+// FetchAllActivities implements sync.DataProvider. It requests a backfill of
+// the last garminBackfillHistory and returns what can be pulled right away;
+// the backfilled history arrives through the notification worker.
+func (p *GarminProvider) FetchAllActivities(ctx context.Context, integration *models.ConnectedIntegration) ([]sync.RawActivity, error) {
+	now := time.Now()
+	for end := now; end.After(now.Add(-garminBackfillHistory)); end = end.Add(-garmin.MaxBackfillWindow) {
+		start := end.Add(-garmin.MaxBackfillWindow)
+		if err := p.svc.client.RequestBackfill(ctx, integration.AccessToken, start, end); err != nil {
+			logger.FromContext(ctx).Warn("garmin provider: backfill request failed",
+				"integration_id", integration.ID,
+				"start", start,
+				"error", err,
+			)
+			break
+		}
+	}
+	return p.FetchRecentActivities(ctx, integration, integration.ConnectedAt)
+}
+
+// RefreshTokenIfNeeded implements sync.DataProvider.
+func (p *GarminProvider) RefreshTokenIfNeeded(ctx context.Context, integration *models.ConnectedIntegration) error {
+	fresh, err := p.svc.RefreshAccessToken(ctx, integration)
+	if err != nil {
+		return err
+	}
+	*integration = *fresh
+	return nil
+}
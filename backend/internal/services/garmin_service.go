@@ -0,0 +1,342 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/internal/tokencrypt"
+	"github.com/korsana/backend/pkg/garmin"
+)
+
+// garminUpgradeTimeout bounds the history upgrade started on first connect.
+const garminUpgradeTimeout = 10 * time.Minute
+
+var (
+	// ErrGarminNotConfigured is returned when the server has no Garmin app
+	// credentials.
+	ErrGarminNotConfigured = errors.New("garmin integration is not configured")
+
+	// ErrGarminConnectionNotFound is returned when the user has no Garmin
+	// connection on record.
+	ErrGarminConnectionNotFound = errors.New("garmin connection not found")
+
+	// ErrGarminAlreadyConnected is returned when the Garmin user is already
+	// linked to a different Korsana account.
+	ErrGarminAlreadyConnected = errors.New("garmin account already connected to another user")
+)
+
+// GarminService handles Garmin Connect business logic. Garmin delivers
+// activities through ping/push notifications rather than a list endpoint,
+// so most imports arrive via the notification worker.
+type GarminService struct {
+	db           sync.Querier
+	client       *garmin.Client
+	redis        *redis.Client
+	calendarSvc  *CalendarService
+	tokenKeys    *tokencrypt.Keyring
+	refreshGroup singleflight.Group
+
+	// notificationWake nudges the notification worker when records are
+	// queued. Buffered with capacity 1.
+	notificationWake chan struct{}
+}
+
+// NewGarminService creates a new Garmin service
+func NewGarminService(db *database.DB, client *garmin.Client, redisClient *redis.Client, calendarService *CalendarService, tokenKeys *tokencrypt.Keyring) *GarminService {
+	return &GarminService{
+		db:               db,
+		client:           client,
+		redis:            redisClient,
+		calendarSvc:      calendarService,
+		tokenKeys:        tokenKeys,
+		notificationWake: make(chan struct{}, 1),
+	}
+}
+
+// Configured reports whether Garmin app credentials are set.
+func (s *GarminService) Configured() bool {
+	return s.client.Configured()
+}
+
+// GetAuthURL returns the Garmin OAuth URL. returnTo is an optional
+// frontend path to redirect to after connecting.
+func (s *GarminService) GetAuthURL(ctx context.Context, userID uuid.UUID, returnTo string) (string, error) {
+	if !s.Configured() {
+		return "", ErrGarminNotConfigured
+	}
+
+	verifier, err := newPKCEVerifier()
+	if err != nil {
+		return "", err
+	}
+	state, err := saveOAuthState(ctx, s.redis, "garmin", OAuthState{UserID: userID, ReturnTo: returnTo, CodeVerifier: verifier})
+	if err != nil {
+		return "", err
+	}
+	return s.client.GetAuthorizationURL(state, verifier), nil
+}
+
+// ValidateOAuthState consumes the state parameter saved by GetAuthURL.
+func (s *GarminService) ValidateOAuthState(ctx context.Context, state string) (*OAuthState, error) {
+	return consumeOAuthState(ctx, s.redis, "garmin", state)
+}
+
+// HandleCallback exchanges the authorization code and saves the connection.
+// On the first connect it starts a background upgrade of the user's history
+// (see GarminProvider.FetchAllActivities).
+func (s *GarminService) HandleCallback(ctx context.Context, userID uuid.UUID, code, codeVerifier string) error {
+	tokenResp, err := s.client.ExchangeToken(ctx, code, codeVerifier)
+	if err != nil {
+		return err
+	}
+	garminUserID, err := s.client.GetUserID(ctx, tokenResp.AccessToken)
+	if err != nil {
+		return err
+	}
+
+	existing, err := getIntegrationByExternalID(ctx, s.db, s.tokenKeys, "garmin", garminUserID)
+	if err == nil && existing.UserID != userID {
+		return ErrGarminAlreadyConnected
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	_, lookupErr := getIntegration(ctx, s.db, s.tokenKeys, userID, "garmin")
+	firstConnect := errors.Is(lookupErr, sql.ErrNoRows)
+
+	integration, err := connectIntegration(ctx, s.db, s.tokenKeys, userID, "garmin", garminUserID, garminTokens(tokenResp))
+	if err != nil {
+		return err
+	}
+
+	if firstConnect {
+		go s.upgradeHistory(context.WithoutCancel(ctx), userID, integration)
+	}
+	return nil
+}
+
+// upgradeHistory runs sync.UpgradeHistoricalActivities for a newly
+// connected integration. It runs detached from the OAuth request.
+func (s *GarminService) upgradeHistory(ctx context.Context, userID uuid.UUID, integration *models.ConnectedIntegration) {
+	ctx, cancel := context.WithTimeout(ctx, garminUpgradeTimeout)
+	defer cancel()
+
+	log := logger.FromContext(ctx).With("user_id", userID)
+	if err := sync.UpgradeHistoricalActivities(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, NewGarminProvider(s), integration); err != nil {
+		log.Error("garmin: history upgrade failed", "error", err)
+		return
+	}
+	if err := refreshWeeklySummaries(ctx, s.db, userID); err != nil {
+		log.Warn("garmin: weekly summaries after upgrade failed", "error", err)
+	}
+}
+
+// garminTokens converts a Garmin token response for the integration store.
+func garminTokens(resp *garmin.TokenResponse) integrationTokens {
+	refreshToken := resp.RefreshToken
+	expiresAt := time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	return integrationTokens{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: &refreshToken,
+		ExpiresAt:    &expiresAt,
+	}
+}
+
+// GetConnection retrieves the Garmin integration for a user
+func (s *GarminService) GetConnection(ctx context.Context, userID uuid.UUID) (*models.ConnectedIntegration, error) {
+	conn, err := getIntegration(ctx, s.db, s.tokenKeys, userID, "garmin")
+	if err != nil {
+		return nil, ErrGarminConnectionNotFound
+	}
+	return conn, nil
+}
+
+// RefreshAccessToken refreshes the Garmin access token if it expires within
+// five minutes. Concurrent refreshes of one integration share a single
+// request, since Garmin invalidates the old refresh token on use.
+func (s *GarminService) RefreshAccessToken(ctx context.Context, conn *models.ConnectedIntegration) (*models.ConnectedIntegration, error) {
+	if integrationTokenFresh(conn) {
+		return conn, nil
+	}
+
+	result, err, _ := s.refreshGroup.Do(conn.ID.String(), func() (any, error) {
+		latest, loadErr := getIntegrationByID(ctx, s.db, s.tokenKeys, conn.ID)
+		if loadErr == nil {
+			conn = latest
+		}
+		if integrationTokenFresh(conn) {
+			return conn, nil
+		}
+		if conn.RefreshToken == nil {
+			return nil, errors.New("garmin integration has no refresh token")
+		}
+
+		tokenResp, refreshErr := s.client.RefreshToken(ctx, *conn.RefreshToken)
+		if refreshErr != nil {
+			var apiErr *garmin.APIError
+			if errors.As(refreshErr, &apiErr) && (apiErr.StatusCode == http.StatusBadRequest || apiErr.StatusCode == http.StatusUnauthorized) {
+				if markErr := markIntegrationReauthRequired(ctx, s.db, conn.ID, refreshErr); markErr != nil {
+					logger.FromContext(ctx).Warn("garmin: failed to flag integration for reauth",
+						"integration_id", conn.ID,
+						"error", markErr,
+					)
+				}
+				return nil, fmt.Errorf("%w: %w", ErrIntegrationReauthRequired, refreshErr)
+			}
+			return nil, refreshErr
+		}
+
+		tokens := garminTokens(tokenResp)
+		if execErr := updateIntegrationTokens(ctx, s.db, s.tokenKeys, conn.ID, tokens); execErr != nil {
+			return nil, execErr
+		}
+
+		updated := *conn
+		updated.AccessToken = tokens.AccessToken
+		updated.RefreshToken = tokens.RefreshToken
+		updated.TokenExpiresAt = tokens.ExpiresAt
+		updated.Status = models.IntegrationStatusActive
+		updated.LastError = nil
+		updated.UpdatedAt = time.Now()
+		return &updated, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(*models.ConnectedIntegration), nil
+}
+
+// DisconnectGarmin deregisters the user with Garmin (best effort, so a
+// revoked token doesn't block the disconnect) and removes the connection.
+func (s *GarminService) DisconnectGarmin(ctx context.Context, userID uuid.UUID) error {
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if fresh, err := s.RefreshAccessToken(ctx, conn); err == nil {
+		if err := s.client.DeleteUserRegistration(ctx, fresh.AccessToken); err != nil {
+			logger.FromContext(ctx).Warn("garmin: deregistration failed", "user_id", userID, "error", err)
+		}
+	}
+	return disconnectIntegration(ctx, s.db, userID, "garmin")
+}
+
+// mapGarminType converts Garmin activity types to internal types
+func mapGarminType(garminType string) string {
+	switch garminType {
+	case "RUNNING", "TRAIL_RUNNING", "TREADMILL_RUNNING", "INDOOR_RUNNING",
+		"TRACK_RUNNING", "STREET_RUNNING", "VIRTUAL_RUN", "ULTRA_RUN":
+		return models.ActivityTypeRun
+	case "CYCLING", "ROAD_BIKING", "MOUNTAIN_BIKING", "GRAVEL_CYCLING",
+		"INDOOR_CYCLING", "VIRTUAL_RIDE", "E_BIKE_FITNESS", "E_BIKE_MOUNTAIN":
+		return models.ActivityTypeCycling
+	case "LAP_SWIMMING", "OPEN_WATER_SWIMMING", "SWIMMING":
+		return models.ActivityTypeSwimming
+	case "WALKING", "CASUAL_WALKING", "SPEED_WALKING":
+		return models.ActivityTypeWalking
+	case "HIKING":
+		return models.ActivityTypeHiking
+	case "ROWING", "INDOOR_ROWING":
+		return models.ActivityTypeRowing
+	case "ELLIPTICAL":
+		return models.ActivityTypeElliptical
+	case "STAIR_CLIMBING", "FLOOR_CLIMBING":
+		return models.ActivityTypeStairMaster
+	case "STRENGTH_TRAINING":
+		return models.ActivityTypeWeightLifting
+	case "YOGA", "PILATES", "BREATHWORK":
+		return models.ActivityTypeRecovery
+	default:
+		return models.ActivityTypeWorkout
+	}
+}
+
+// garminRawActivity normalizes a Garmin activity summary for
+// sync.UpsertActivity. It returns false for multisport parents, whose legs
+// are delivered as their own activities, and for records without an ID or
+// start time.
+func garminRawActivity(act garmin.Activity) (sync.RawActivity, bool) {
+	if act.IsParent || act.ActivityID == 0 || act.StartTimeInSeconds == 0 {
+		return sync.RawActivity{}, false
+	}
+
+	internalType := mapGarminType(act.ActivityType)
+	startTime := time.Unix(act.StartTimeInSeconds, 0).UTC()
+	localDate := startTime.Add(time.Duration(act.StartTimeOffsetInSeconds) * time.Second).Truncate(24 * time.Hour)
+
+	name := act.ActivityName
+	if name == "" {
+		name = internalType
+	}
+	raw := sync.RawActivity{
+		SourceID:     strconv.FormatInt(act.ActivityID, 10),
+		Source:       "garmin",
+		StartTime:    startTime,
+		LocalDate:    &localDate,
+		Duration:     act.DurationInSeconds,
+		Distance:     act.DistanceInMeters,
+		ActivityType: internalType,
+		Name:         name,
+	}
+
+	if models.DistanceBasedTypes[internalType] && act.DistanceInMeters > 0 {
+		raw.AvgPace = float64(act.DurationInSeconds) / (act.DistanceInMeters / 1000.0)
+	}
+	if act.AverageHeartRateInBeatsPerMinute > 0 {
+		hr := act.AverageHeartRateInBeatsPerMinute
+		raw.AvgHR = &hr
+	}
+	if act.MaxHeartRateInBeatsPerMinute > 0 {
+		mhr := act.MaxHeartRateInBeatsPerMinute
+		raw.MaxHR = &mhr
+	}
+	if act.TotalElevationGainInMeters > 0 {
+		elev := act.TotalElevationGainInMeters
+		raw.ElevGain = &elev
+	}
+	// Strava reports run cadence per leg; Garmin counts both feet.
+	switch {
+	case act.AverageRunCadenceInStepsPerMinute > 0:
+		cadence := act.AverageRunCadenceInStepsPerMinute / 2
+		raw.AvgCadence = &cadence
+	case act.AverageBikeCadenceInRPM > 0:
+		cadence := act.AverageBikeCadenceInRPM
+		raw.AvgCadence = &cadence
+	}
+	return raw, true
+}
+
+// storeGarminActivities upserts a batch of Garmin activities, counting each
+// outcome on run, and returns how many were inserted or updated.
+func (s *GarminService) storeGarminActivities(ctx context.Context, userID uuid.UUID, activities []garmin.Activity, run *syncRun) (int, error) {
+	stored := 0
+	for _, act := range activities {
+		raw, ok := garminRawActivity(act)
+		if !ok {
+			continue
+		}
+		result, err := sync.UpsertActivity(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, raw)
+		run.tally(result, err)
+		if err != nil {
+			return stored, err
+		}
+		if result.Activity != nil {
+			stored++
+		}
+	}
+	return stored, nil
+}
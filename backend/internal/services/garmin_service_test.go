@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/garmin"
+)
+
+// newFakeGarmin starts a local stand-in for apis.garmin.com serving the
+// given activities from the activities pull endpoint.
+func newFakeGarmin(t *testing.T, activities []garmin.Activity) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var pulls atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wellness-api/rest/activities", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer garmin-access" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		pulls.Add(1)
+		json.NewEncoder(w).Encode(activities) //nolint:errcheck
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, &pulls
+}
+
+func newConnectedGarminService(apiURL string) (*GarminService, *mockStravaDB) {
+	connID := uuid.New()
+	db := &mockStravaDB{
+		existingUserID: uuid.New(),
+		connections: map[uuid.UUID]*models.ConnectedIntegration{
+			connID: {
+				ID:             connID,
+				UserID:         uuid.New(),
+				Source:         "garmin",
+				ExternalUserID: ptr("garmin-user"),
+				AccessToken:    "garmin-access",
+				RefreshToken:   ptr("garmin-refresh"),
+				TokenExpiresAt: ptr(time.Now().Add(time.Hour)),
+				ConnectedAt:    time.Now().Add(-50 * time.Hour),
+			},
+		},
+	}
+	client := garmin.NewClient("client-id", "client-secret", "http://localhost/callback")
+	client.APIURL = apiURL
+	return &GarminService{db: db, client: client}, db
+}
+
+func TestGarminRawActivity(t *testing.T) {
+	act := garmin.Activity{
+		ActivityID:                        9001,
+		ActivityName:                      "Morning Run",
+		ActivityType:                      "TRAIL_RUNNING",
+		StartTimeInSeconds:                time.Date(2026, time.April, 19, 2, 30, 0, 0, time.UTC).Unix(),
+		StartTimeOffsetInSeconds:          -5 * 3600,
+		DurationInSeconds:                 1800,
+		DistanceInMeters:                  6000,
+		AverageHeartRateInBeatsPerMinute:  150,
+		AverageRunCadenceInStepsPerMinute: 172,
+	}
+
+	raw, ok := garminRawActivity(act)
+	if !ok {
+		t.Fatal("expected activity to be accepted")
+	}
+	if raw.Source != "garmin" || raw.SourceID != "9001" || raw.ActivityType != models.ActivityTypeRun {
+		t.Fatalf("unexpected identity %q/%q type %q", raw.Source, raw.SourceID, raw.ActivityType)
+	}
+	// 02:30 UTC at UTC-5 is still the previous evening locally.
+	if raw.LocalDate == nil || raw.LocalDate.Format("2006-01-02") != "2026-04-18" {
+		t.Fatalf("unexpected local date %v", raw.LocalDate)
+	}
+	if raw.AvgPace != 300 {
+		t.Fatalf("expected 300 s/km, got %v", raw.AvgPace)
+	}
+	if raw.AvgCadence == nil || *raw.AvgCadence != 86 {
+		t.Fatalf("expected per-leg cadence 86, got %v", raw.AvgCadence)
+	}
+
+	act.IsParent = true
+	if _, ok := garminRawActivity(act); ok {
+		t.Fatal("expected multisport parent to be skipped")
+	}
+}
+
+func TestGarminPingPullsActivitiesFromCallback(t *testing.T) {
+	server, pulls := newFakeGarmin(t, []garmin.Activity{{
+		ActivityID:         1,
+		ActivityType:       "RUNNING",
+		StartTimeInSeconds: time.Now().Add(-time.Hour).Unix(),
+		DurationInSeconds:  1500,
+		DistanceInMeters:   5000,
+	}})
+	svc, db := newConnectedGarminService(server.URL)
+
+	record, _ := json.Marshal(map[string]any{
+		"userId":      "garmin-user",
+		"callbackURL": server.URL + "/wellness-api/rest/activities?uploadStartTimeInSeconds=1&uploadEndTimeInSeconds=2",
+	})
+	err := svc.ProcessNotification(context.Background(), &models.GarminNotification{
+		SummaryType:  garmin.SummaryActivities,
+		GarminUserID: "garmin-user",
+		Record:       record,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pulls.Load(); got != 1 {
+		t.Fatalf("expected one pull from the callback URL, got %d", got)
+	}
+	if db.execCount.Load() == 0 {
+		t.Fatal("expected the activity to be stored")
+	}
+}
+
+func TestGarminPingRejectsForeignCallbackHost(t *testing.T) {
+	server, _ := newFakeGarmin(t, nil)
+	svc, _ := newConnectedGarminService(server.URL)
+
+	var leaked atomic.Bool
+	other := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		leaked.Store(true)
+	}))
+	defer other.Close()
+
+	record, _ := json.Marshal(map[string]any{
+		"userId":      "garmin-user",
+		"callbackURL": other.URL + "/wellness-api/rest/activities",
+	})
+	err := svc.ProcessNotification(context.Background(), &models.GarminNotification{
+		SummaryType:  garmin.SummaryActivities,
+		GarminUserID: "garmin-user",
+		Record:       record,
+	})
+	if err == nil {
+		t.Fatal("expected a foreign callback host to be refused")
+	}
+	if leaked.Load() {
+		t.Fatal("access token was sent to a foreign host")
+	}
+}
+
+func TestGarminProviderWalksUploadWindowsSinceConnect(t *testing.T) {
+	server, pulls := newFakeGarmin(t, nil)
+	svc, db := newConnectedGarminService(server.URL)
+	var integration *models.ConnectedIntegration
+	for _, conn := range db.connections {
+		integration = conn
+	}
+
+	// Uploads before the connection can't be pulled, so the 50 hours since
+	// connecting take three 24-hour windows regardless of since.
+	if _, err := NewGarminProvider(svc).FetchRecentActivities(context.Background(), integration, time.Now().Add(-30*24*time.Hour)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := pulls.Load(); got != 3 {
+		t.Fatalf("expected 3 upload windows, got %d", got)
+	}
+}
@@ -4,16 +4,23 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/korsana/backend/internal/database"
 	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/internal/tokencrypt"
 )
 
 var ErrIntegrationSourceUnsupported = errors.New("unsupported integration source")
 
+// ErrIntegrationReauthRequired wraps a token refresh the source rejected.
+// The connection has been flagged and the user has to reconnect.
+var ErrIntegrationReauthRequired = errors.New("integration requires reauthorization")
+
 type integrationsQuerier interface {
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
 	SelectContext(ctx context.Context, dest any, query string, args ...any) error
@@ -68,6 +75,218 @@ func (s *IntegrationsService) UpsertInterestRequest(ctx context.Context, userID
 	return &request, nil
 }
 
+// ListIntegrations returns every source the user has connected, primary
+// first. Tokens are never serialized.
+func (s *IntegrationsService) ListIntegrations(ctx context.Context, userID uuid.UUID) ([]models.ConnectedIntegration, error) {
+	integrations := []models.ConnectedIntegration{}
+	err := s.db.SelectContext(ctx, &integrations, `
+		SELECT * FROM connected_integrations
+		WHERE user_id = $1
+		ORDER BY is_primary DESC, connected_at ASC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return integrations, nil
+}
+
+// The helpers below are the single store for OAuth connections. Source
+// services (Strava, Garmin, COROS) call them with their own database handle
+// and token keyring; tokens are sealed on the way in and opened on the way
+// out, so callers only ever see plaintext.
+
+func getIntegration(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, userID uuid.UUID, source string) (*models.ConnectedIntegration, error) {
+	var integration models.ConnectedIntegration
+	err := db.GetContext(ctx, &integration,
+		"SELECT * FROM connected_integrations WHERE user_id = $1 AND source = $2", userID, source)
+	if err != nil {
+		return nil, err
+	}
+	return openIntegration(keys, &integration)
+}
+
+func getIntegrationByID(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, id uuid.UUID) (*models.ConnectedIntegration, error) {
+	var integration models.ConnectedIntegration
+	err := db.GetContext(ctx, &integration, "SELECT * FROM connected_integrations WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	return openIntegration(keys, &integration)
+}
+
+func getIntegrationByExternalID(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, source, externalUserID string) (*models.ConnectedIntegration, error) {
+	var integration models.ConnectedIntegration
+	err := db.GetContext(ctx, &integration,
+		"SELECT * FROM connected_integrations WHERE source = $1 AND external_user_id = $2", source, externalUserID)
+	if err != nil {
+		return nil, err
+	}
+	return openIntegration(keys, &integration)
+}
+
+// integrationTokens is what an OAuth exchange or refresh hands back.
+// Scopes is the grant's comma-separated scope list, for sources that report
+// one; connectIntegration stores it with the tokens.
+type integrationTokens struct {
+	AccessToken  string
+	RefreshToken *string
+	ExpiresAt    *time.Time
+	Scopes       *string
+}
+
+// seal returns the tokens encrypted for storage.
+func (t integrationTokens) seal(keys *tokencrypt.Keyring) (integrationTokens, error) {
+	sealed := t
+	var err error
+	if sealed.AccessToken, err = keys.Seal(t.AccessToken); err != nil {
+		return sealed, fmt.Errorf("failed to encrypt access token: %w", err)
+	}
+	if t.RefreshToken != nil {
+		refresh, err := keys.Seal(*t.RefreshToken)
+		if err != nil {
+			return sealed, fmt.Errorf("failed to encrypt refresh token: %w", err)
+		}
+		sealed.RefreshToken = &refresh
+	}
+	return sealed, nil
+}
+
+// openIntegration decrypts a stored row's tokens in place.
+func openIntegration(keys *tokencrypt.Keyring, integration *models.ConnectedIntegration) (*models.ConnectedIntegration, error) {
+	access, err := keys.Open(integration.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s access token: %w", integration.Source, err)
+	}
+	integration.AccessToken = access
+	if integration.RefreshToken != nil {
+		refresh, err := keys.Open(*integration.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt %s refresh token: %w", integration.Source, err)
+		}
+		integration.RefreshToken = &refresh
+	}
+	return integration, nil
+}
+
+// connectIntegration stores a fresh OAuth grant for (user, source), marks
+// it active and re-elects the user's primary source.
+func connectIntegration(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, userID uuid.UUID, source, externalUserID string, tokens integrationTokens) (*models.ConnectedIntegration, error) {
+	sealed, err := tokens.seal(keys)
+	if err != nil {
+		return nil, err
+	}
+	var integration models.ConnectedIntegration
+	err = db.GetContext(ctx, &integration, `
+		INSERT INTO connected_integrations (
+			user_id, source, access_token, refresh_token, token_expires_at,
+			external_user_id, granted_scopes, is_active, status, connected_at, updated_at
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, true, 'active', NOW(), NOW())
+		ON CONFLICT (user_id, source) DO UPDATE SET
+			access_token = EXCLUDED.access_token,
+			refresh_token = EXCLUDED.refresh_token,
+			token_expires_at = EXCLUDED.token_expires_at,
+			external_user_id = EXCLUDED.external_user_id,
+			granted_scopes = EXCLUDED.granted_scopes,
+			is_active = true,
+			status = 'active',
+			last_error = NULL,
+			updated_at = NOW()
+		RETURNING *
+	`, userID, source, sealed.AccessToken, sealed.RefreshToken, sealed.ExpiresAt, externalUserID, tokens.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := electPrimaryIntegration(ctx, db, userID); err != nil {
+		return nil, err
+	}
+	integration.AccessToken, integration.RefreshToken = tokens.AccessToken, tokens.RefreshToken
+	return &integration, nil
+}
+
+// integrationTokenFresh reports whether the access token is good for at least
+// another 5 minutes.
+func integrationTokenFresh(conn *models.ConnectedIntegration) bool {
+	return conn.TokenExpiresAt != nil && time.Now().Before(conn.TokenExpiresAt.Add(-5*time.Minute))
+}
+
+// updateIntegrationTokens saves refreshed tokens. A successful refresh also
+// clears a previous reauth_required status.
+func updateIntegrationTokens(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, id uuid.UUID, tokens integrationTokens) error {
+	sealed, err := tokens.seal(keys)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		UPDATE connected_integrations
+		SET access_token = $1, refresh_token = COALESCE($2, refresh_token),
+			token_expires_at = $3, status = 'active', last_error = NULL, updated_at = NOW()
+		WHERE id = $4
+	`, sealed.AccessToken, sealed.RefreshToken, sealed.ExpiresAt, id)
+	return err
+}
+
+// markIntegrationReauthRequired records that the source rejected our
+// credentials; the user has to reconnect.
+func markIntegrationReauthRequired(ctx context.Context, db integrationsQuerier, id uuid.UUID, cause error) error {
+	_, err := db.ExecContext(ctx, `
+		UPDATE connected_integrations
+		SET status = 'reauth_required', last_error = $2, updated_at = NOW()
+		WHERE id = $1
+	`, id, cause.Error())
+	return err
+}
+
+func markIntegrationSynced(ctx context.Context, db integrationsQuerier, id uuid.UUID) error {
+	_, err := db.ExecContext(ctx,
+		"UPDATE connected_integrations SET last_synced_at = NOW() WHERE id = $1", id)
+	return err
+}
+
+// disconnectIntegration removes the user's connection to source and hands
+// the primary flag to the next source in line.
+func disconnectIntegration(ctx context.Context, db integrationsQuerier, userID uuid.UUID, source string) error {
+	if _, err := db.ExecContext(ctx,
+		"DELETE FROM connected_integrations WHERE user_id = $1 AND source = $2", userID, source); err != nil {
+		return err
+	}
+	return electPrimaryIntegration(ctx, db, userID)
+}
+
+// electPrimaryIntegration makes the user's highest-priority active source
+// primary. The partial unique index allows one primary per user, so the
+// old flag is cleared before the new one is set.
+func electPrimaryIntegration(ctx context.Context, db integrationsQuerier, userID uuid.UUID) error {
+	var active []models.ConnectedIntegration
+	if err := db.SelectContext(ctx, &active, `
+		SELECT * FROM connected_integrations
+		WHERE user_id = $1 AND is_active
+		ORDER BY connected_at ASC
+	`, userID); err != nil {
+		return err
+	}
+	if len(active) == 0 {
+		return nil
+	}
+
+	best := active[0]
+	for _, integration := range active[1:] {
+		if sync.HigherPriority(integration.Source, best.Source) {
+			best = integration
+		}
+	}
+	if best.IsPrimary {
+		return nil
+	}
+
+	if _, err := db.ExecContext(ctx,
+		"UPDATE connected_integrations SET is_primary = false WHERE user_id = $1 AND is_primary", userID); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx,
+		"UPDATE connected_integrations SET is_primary = true WHERE id = $1", best.ID)
+	return err
+}
+
 func normalizeIntegrationSource(source string) string {
 	return strings.ToLower(strings.TrimSpace(source))
 }
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/metrics"
+	"github.com/korsana/backend/internal/models"
+)
+
+// ErrNoUpcomingRace is returned when the athlete has no active race goal
+// dated today or later to project toward.
+var ErrNoUpcomingRace = errors.New("no upcoming race goal")
+
+// loadModelWindowDays is how much history CalculateATLCTL reads.
+const loadModelWindowDays = 84
+
+// ProjectLoad projects the athlete's ATL, CTL and TSB from today to the
+// morning of their active race goal, assuming every planned calendar entry
+// before race day is completed as planned. Returns ErrNoUpcomingRace when
+// there is no active goal ahead.
+func (s *MetricsService) ProjectLoad(ctx context.Context, userID uuid.UUID) (*metrics.LoadProjection, error) {
+	plan, err := s.racePlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	projection := metrics.ProjectLoad(plan.Load, plan.Entries, plan.Goal.RaceDate,
+		plan.Zones, plan.RestingHR, plan.MaxHR, s.runEquivalence)
+	return &projection, nil
+}
+
+// ProposeTaper finds the taper that best lands race-morning TSB in
+// [tsbMin, tsbMax] for the athlete's active race goal, as changes to the
+// planned calendar entries. Returns ErrNoUpcomingRace when there is no
+// active goal ahead.
+func (s *MetricsService) ProposeTaper(ctx context.Context, userID uuid.UUID, tsbMin, tsbMax float64) (*metrics.TaperProposal, error) {
+	plan, err := s.racePlan(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	proposal := metrics.OptimizeTaper(plan.Load, plan.Entries, plan.Goal.RaceDate,
+		plan.Zones, plan.RestingHR, plan.MaxHR, s.runEquivalence, tsbMin, tsbMax)
+	return &proposal, nil
+}
+
+// racePlan is the athlete's load today and the calendar still planned
+// between now and their race.
+type racePlan struct {
+	loadState
+	Goal    models.RaceGoal
+	Entries []models.CalendarEntry
+}
+
+func (s *MetricsService) racePlan(ctx context.Context, userID uuid.UUID) (*racePlan, error) {
+	var goal models.RaceGoal
+	err := s.db.GetContext(ctx, &goal,
+		`SELECT * FROM race_goals WHERE user_id = $1 AND is_active = true LIMIT 1`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNoUpcomingRace
+	}
+	if err != nil {
+		return nil, fmt.Errorf("fetch race goal: %w", err)
+	}
+	today := time.Now().Truncate(24 * time.Hour)
+	if goal.RaceDate.Before(today) {
+		return nil, ErrNoUpcomingRace
+	}
+
+	state, err := s.currentLoad(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.CalendarEntry
+	err = s.db.SelectContext(ctx, &entries, `
+		SELECT * FROM training_calendar
+		WHERE user_id = $1 AND status = 'planned' AND date >= $2 AND date < $3
+		ORDER BY date ASC
+	`, userID, today, goal.RaceDate)
+	if err != nil {
+		return nil, fmt.Errorf("fetch calendar: %w", err)
+	}
+	return &racePlan{loadState: *state, Goal: goal, Entries: entries}, nil
+}
+
+// loadState is the athlete's training load today with the zones and
+// heart-rate bounds it was scored against.
+type loadState struct {
+	Load      metrics.LoadResult
+	Zones     metrics.AthleteZones
+	RestingHR float64
+	MaxHR     float64
+}
+
+// currentLoad computes today's training load from the model's window of
+// activities and hand-logged sessions.
+func (s *MetricsService) currentLoad(ctx context.Context, userID uuid.UUID) (*loadState, error) {
+	cutoff := time.Now().AddDate(0, 0, -loadModelWindowDays)
+	activities, err := s.recentActivities(ctx, userID, cutoff)
+	if err != nil {
+		return nil, err
+	}
+	state := &loadState{}
+	state.RestingHR, state.MaxHR = heartRateBounds(ctx, s.db, userID)
+	state.Zones = s.athleteZones(ctx, userID)
+	manual := manualLoadSessions(ctx, s.db, userID, cutoff)
+	state.Load = metrics.CalculateATLCTL(activities, manual, state.RestingHR, state.MaxHR, s.runEquivalence)
+	return state, nil
+}
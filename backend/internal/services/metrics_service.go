@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,14 +13,21 @@ import (
 	"github.com/korsana/backend/internal/models"
 )
 
+// dashboardZoneWeeks is how many weeks of time in zone the dashboard
+// reports; their streams also cover the HR zone widget and the intensity
+// distribution window.
+const dashboardZoneWeeks = 5
+
 // MetricsService computes dashboard analytics.
 type MetricsService struct {
-	db *database.DB
+	db             *database.DB
+	runEquivalence metrics.RunEquivalence
 }
 
-// NewMetricsService creates a new MetricsService.
-func NewMetricsService(db *database.DB) *MetricsService {
-	return &MetricsService{db: db}
+// NewMetricsService creates a new MetricsService. runEquivalence overrides
+// the default per-sport run-equivalence factors used for training load.
+func NewMetricsService(db *database.DB, runEquivalence map[string]float64) *MetricsService {
+	return &MetricsService{db: db, runEquivalence: metrics.NewRunEquivalence(runEquivalence)}
 }
 
 // CrossTrainingSession represents a cross-training session.
@@ -65,15 +74,18 @@ type ManualPredictorEntry struct {
 
 // DashboardData is the full dashboard API response.
 type DashboardData struct {
-	TrainingLoad  metrics.LoadResult       `json:"training_load"`
-	InjuryRisk    metrics.InjuryRiskResult `json:"injury_risk"`
-	Predictor     PredictorData            `json:"predictor"`
-	LongRun       metrics.LongRunResult    `json:"long_run"`
-	Recovery      metrics.RecoveryResult   `json:"recovery"`
-	HRZones       metrics.HRZonesResult    `json:"hr_zones"`
-	Execution     metrics.ExecutionResult  `json:"execution"`
-	CrossTraining CrossTrainingDashboard   `json:"cross_training"`
-	Shoes         []GearShoe               `json:"shoes"`
+	TrainingLoad  metrics.LoadResult                  `json:"training_load"`
+	InjuryRisk    metrics.InjuryRiskResult            `json:"injury_risk"`
+	Predictor     PredictorData                       `json:"predictor"`
+	LongRun       metrics.LongRunResult               `json:"long_run"`
+	Recovery      metrics.RecoveryResult              `json:"recovery"`
+	HRZones       metrics.HRZonesResult               `json:"hr_zones"`
+	Zones         metrics.AthleteZones                `json:"zones"`
+	WeeklyZones   []metrics.WeekZoneTime              `json:"weekly_zones"`
+	Intensity     metrics.IntensityDistributionResult `json:"intensity_distribution"`
+	Execution     metrics.ExecutionResult             `json:"execution"`
+	CrossTraining CrossTrainingDashboard              `json:"cross_training"`
+	Shoes         []GearShoe                          `json:"shoes"`
 }
 
 // PredictorData holds predictor widget data.
@@ -94,19 +106,9 @@ type CrossTrainingDashboard struct {
 // ComputeDashboard fetches data and computes all dashboard metrics.
 func (s *MetricsService) ComputeDashboard(ctx context.Context, userID uuid.UUID) (*DashboardData, error) {
 	cutoff := time.Now().AddDate(0, 0, -90)
-	var activities []models.Activity
-	err := s.db.SelectContext(ctx, &activities, `
-		SELECT id, user_id, source, source_activity_id, activity_type,
-			   name, distance_meters, duration_seconds, start_time,
-			   average_pace_seconds_per_km, average_heart_rate,
-			   max_heart_rate, elevation_gain_meters, average_cadence,
-			   suffer_score, synced_at
-		FROM activities
-		WHERE user_id = $1 AND start_time >= $2
-		ORDER BY start_time ASC
-	`, userID, cutoff)
+	activities, err := s.recentActivities(ctx, userID, cutoff)
 	if err != nil {
-		return nil, fmt.Errorf("fetch activities: %w", err)
+		return nil, err
 	}
 
 	calCutoff := time.Now().AddDate(0, 0, -30)
@@ -123,16 +125,7 @@ func (s *MetricsService) ComputeDashboard(ctx context.Context, userID uuid.UUID)
 		return nil, fmt.Errorf("fetch calendar: %w", err)
 	}
 
-	var profile models.UserProfile
-	_ = s.db.GetContext(ctx, &profile, `SELECT * FROM user_profiles WHERE user_id = $1`, userID)
-	restingHR := 55.0
-	maxHR := 190.0
-	if profile.RestingHeartRate != nil {
-		restingHR = float64(*profile.RestingHeartRate)
-	}
-	if profile.MaxHeartRate != nil {
-		maxHR = float64(*profile.MaxHeartRate)
-	}
+	restingHR, maxHR := heartRateBounds(ctx, s.db, userID)
 
 	var goal models.RaceGoal
 	_ = s.db.GetContext(ctx, &goal, `SELECT * FROM race_goals WHERE user_id = $1 AND is_active = true LIMIT 1`, userID)
@@ -176,12 +169,17 @@ func (s *MetricsService) ComputeDashboard(ctx context.Context, userID uuid.UUID)
 		}
 	}
 
-	loadResult := metrics.CalculateATLCTL(activities, restingHR, maxHR)
-	riskResult := metrics.InjuryRisk(activities, loadResult.ATL, loadResult.CTL)
+	zones := s.athleteZones(ctx, userID)
+	manual := manualLoadSessions(ctx, s.db, userID, cutoff)
+	loadResult := metrics.CalculateATLCTL(activities, manual, restingHR, maxHR, s.runEquivalence)
+	riskResult := metrics.InjuryRisk(activities, zones, loadResult.ATL, loadResult.CTL)
 	longRunResult := metrics.LongRunConfidence(activities, raceDistKm)
-	recoveryResult := metrics.RecoveryStatus(activities, restingHR, maxHR)
-	hrZonesResult := metrics.HRZoneDistribution(activities)
-	executionResult := metrics.ExecutionScores(activities, entries)
+	recoveryResult := metrics.RecoveryStatus(activities, zones, restingHR, maxHR)
+	streams := s.streamsByActivity(ctx, userID, time.Now().AddDate(0, 0, -dashboardZoneWeeks*7))
+	hrZonesResult := metrics.HRZoneDistribution(activities, streams, zones)
+	weeklyZones := metrics.WeeklyTimeInZones(activities, streams, zones, dashboardZoneWeeks)
+	intensityResult := metrics.IntensityDistribution(activities, streams, zones)
+	executionResult := metrics.ExecutionScores(activities, entries, s.lapsByActivity(ctx, userID, calCutoff), zones)
 
 	best := metrics.AutoDetectBestEfforts(activities)
 
@@ -288,6 +286,9 @@ func (s *MetricsService) ComputeDashboard(ctx context.Context, userID uuid.UUID)
 		LongRun:      longRunResult,
 		Recovery:     recoveryResult,
 		HRZones:      hrZonesResult,
+		Zones:        zones,
+		WeeklyZones:  weeklyZones,
+		Intensity:    intensityResult,
 		Execution:    executionResult,
 		Predictor: PredictorData{
 			SourceDistance: sourceDistLabel,
@@ -304,6 +305,196 @@ func (s *MetricsService) ComputeDashboard(ctx context.Context, userID uuid.UUID)
 	}, nil
 }
 
+// recentActivities loads the user's activities since cutoff, oldest first,
+// with the columns the dashboard metrics read.
+func (s *MetricsService) recentActivities(ctx context.Context, userID uuid.UUID, cutoff time.Time) ([]models.Activity, error) {
+	var activities []models.Activity
+	err := s.db.SelectContext(ctx, &activities, `
+		SELECT id, user_id, source, source_activity_id, activity_type,
+			   name, distance_meters, duration_seconds, start_time,
+			   average_pace_seconds_per_km, average_heart_rate,
+			   max_heart_rate, elevation_gain_meters, average_cadence,
+			   suffer_score, synced_at, local_date, custom_fields
+		FROM activities
+		WHERE user_id = $1 AND merged_into IS NULL AND start_time >= $2
+		ORDER BY start_time ASC
+	`, userID, cutoff)
+	if err != nil {
+		return nil, fmt.Errorf("fetch activities: %w", err)
+	}
+	return activities, nil
+}
+
+// manualLoadSessions loads the cross-training sessions the athlete logged by
+// hand since cutoff. Sessions mirrored from an activity are left out: the
+// activity itself carries their load. A failed load yields no sessions.
+func manualLoadSessions(ctx context.Context, db *database.DB, userID uuid.UUID, cutoff time.Time) []metrics.ManualSession {
+	var rows []struct {
+		Type            string    `db:"type"`
+		Date            time.Time `db:"date"`
+		DurationMinutes int       `db:"duration_minutes"`
+		Intensity       *string   `db:"intensity"`
+	}
+	_ = db.SelectContext(ctx, &rows, `
+		SELECT type, date, duration_minutes, intensity
+		FROM cross_training_sessions
+		WHERE user_id = $1 AND activity_id IS NULL AND date >= $2
+	`, userID, cutoff)
+	sessions := make([]metrics.ManualSession, 0, len(rows))
+	for _, r := range rows {
+		m := metrics.ManualSession{Type: r.Type, Date: r.Date, DurationMinutes: r.DurationMinutes}
+		if r.Intensity != nil {
+			m.Intensity = *r.Intensity
+		}
+		sessions = append(sessions, m)
+	}
+	return sessions
+}
+
 func metricsRound2(v float64) float64 {
 	return float64(int(v*100+0.5)) / 100
 }
+
+// lapsByActivity loads the laps of the user's activities since cutoff,
+// grouped by activity. Laps only sharpen execution scoring, so a failed
+// load yields an empty map.
+func (s *MetricsService) lapsByActivity(ctx context.Context, userID uuid.UUID, cutoff time.Time) map[uuid.UUID][]models.ActivityLap {
+	var laps []models.ActivityLap
+	_ = s.db.SelectContext(ctx, &laps, `
+		SELECT l.* FROM activity_laps l
+		JOIN activities a ON a.id = l.activity_id
+		WHERE a.user_id = $1 AND a.start_time >= $2
+		ORDER BY l.activity_id, l.lap_index
+	`, userID, cutoff)
+
+	out := make(map[uuid.UUID][]models.ActivityLap)
+	for _, lap := range laps {
+		out[lap.ActivityID] = append(out[lap.ActivityID], lap)
+	}
+	return out
+}
+
+// heartRateQuerier is what heartRateBounds needs from the database.
+type heartRateQuerier interface {
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+}
+
+// heartRateBounds returns the athlete's resting and max HR for the load
+// model, with its defaults for what athleteHeartRates doesn't know. The
+// dashboard and the coach both use it so they quote the same load for an
+// athlete.
+func heartRateBounds(ctx context.Context, db heartRateQuerier, userID uuid.UUID) (restingHR, maxHR float64) {
+	restingHR, maxHR = athleteHeartRates(ctx, db, userID)
+	if restingHR == 0 {
+		restingHR = metrics.DefaultLoadRestingHR
+	}
+	if maxHR == 0 {
+		maxHR = metrics.DefaultMaxHR
+	}
+	return restingHR, maxHR
+}
+
+// athleteHeartRates returns the athlete's resting and max HR from their
+// profile, a missing resting HR from recent wellness data, and 0 for
+// either when neither says. Zone calculation applies its own defaults.
+func athleteHeartRates(ctx context.Context, db heartRateQuerier, userID uuid.UUID) (restingHR, maxHR float64) {
+	var profile models.UserProfile
+	_ = db.GetContext(ctx, &profile, `SELECT * FROM user_profiles WHERE user_id = $1`, userID)
+	if profile.RestingHeartRate != nil {
+		restingHR = float64(*profile.RestingHeartRate)
+	} else if measured, ok := recentRestingHR(ctx, db, userID); ok {
+		restingHR = measured
+	}
+	if profile.MaxHeartRate != nil {
+		maxHR = float64(*profile.MaxHeartRate)
+	}
+	return restingHR, maxHR
+}
+
+// streamsByActivity loads the heart-rate and velocity streams of the
+// user's runs since cutoff, by activity. Streams only sharpen time in zone,
+// so a failed load yields an empty map.
+func (s *MetricsService) streamsByActivity(ctx context.Context, userID uuid.UUID, cutoff time.Time) map[uuid.UUID]*models.ActivityStreams {
+	var rows []models.ActivityStreams
+	_ = s.db.SelectContext(ctx, &rows, `
+		SELECT st.activity_id, st.time_offsets, st.heart_rate, st.velocity_mps, st.moving
+		FROM activity_streams st
+		JOIN activities a ON a.id = st.activity_id
+		WHERE a.user_id = $1 AND a.activity_type = $2 AND a.merged_into IS NULL AND a.start_time >= $3
+	`, userID, models.ActivityTypeRun, cutoff)
+
+	out := make(map[uuid.UUID]*models.ActivityStreams, len(rows))
+	for i := range rows {
+		out[rows[i].ActivityID] = &rows[i]
+	}
+	return out
+}
+
+// ActivityTimeInZones returns one of the user's activities' time in HR and
+// pace zones, from its streams when they are stored. Returns
+// ErrActivityNotFound if the activity isn't the user's.
+func (s *MetricsService) ActivityTimeInZones(ctx context.Context, userID, activityID uuid.UUID) (*metrics.ActivityZoneTime, error) {
+	var activity models.Activity
+	err := s.db.GetContext(ctx, &activity,
+		"SELECT * FROM activities WHERE id = $1 AND user_id = $2", activityID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrActivityNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load activity: %w", err)
+	}
+
+	var streams *models.ActivityStreams
+	var stored models.ActivityStreams
+	err = s.db.GetContext(ctx, &stored, `
+		SELECT activity_id, time_offsets, heart_rate, velocity_mps, moving
+		FROM activity_streams WHERE activity_id = $1
+	`, activityID)
+	switch {
+	case err == nil:
+		streams = &stored
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, fmt.Errorf("load activity streams: %w", err)
+	}
+
+	zt := metrics.ActivityTimeInZones(activity, streams, s.athleteZones(ctx, userID))
+	return &zt, nil
+}
+
+// athleteZones loads the athlete's saved HR and pace zones. A zone type
+// they have no complete set of is calculated as the profile would: HR
+// zones from their resting and max HR, pace zones from their PRs.
+func (s *MetricsService) athleteZones(ctx context.Context, userID uuid.UUID) metrics.AthleteZones {
+	var rows []models.TrainingZone
+	_ = s.db.SelectContext(ctx, &rows, `
+		SELECT * FROM training_zones WHERE user_id = $1 ORDER BY zone_type, zone_number
+	`, userID)
+	var hr, pace []models.TrainingZone
+	for _, row := range rows {
+		switch row.ZoneType {
+		case "hr":
+			hr = append(hr, row)
+		case "pace":
+			pace = append(pace, row)
+		}
+	}
+	var prs []models.PersonalRecord
+	_ = s.db.SelectContext(ctx, &prs, `SELECT * FROM personal_records WHERE user_id = $1`, userID)
+	restingHR, maxHR := athleteHeartRates(ctx, s.db, userID)
+	return metrics.ZonesFromTraining(hr, pace, restingHR, maxHR, metrics.ThresholdPaceFromPRs(prs))
+}
+
+// recentRestingHR averages the resting heart rate measured by health
+// platforms over the last two weeks, for athletes who haven't entered one
+// in their profile.
+func recentRestingHR(ctx context.Context, db heartRateQuerier, userID uuid.UUID) (float64, bool) {
+	var avg sql.NullFloat64
+	err := db.GetContext(ctx, &avg, `
+		SELECT AVG(resting_heart_rate) FROM daily_wellness
+		WHERE user_id = $1 AND date >= $2 AND resting_heart_rate IS NOT NULL
+	`, userID, time.Now().AddDate(0, 0, -14))
+	if err != nil || !avg.Valid {
+		return 0, false
+	}
+	return avg.Float64, true
+}
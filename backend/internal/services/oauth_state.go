@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+// OAuthState is what a connect flow needs back when the provider redirects
+// to the callback: who started it, where to send them afterwards, and the
+// PKCE verifier for providers that use one.
+type OAuthState struct {
+	UserID       uuid.UUID
+	ReturnTo     string
+	CodeVerifier string
+}
+
+// saveOAuthState stores st under a new random state parameter, namespaced
+// by source, and returns the parameter. States are single-use and expire
+// after oauthStateTTL.
+func saveOAuthState(ctx context.Context, rdb *redis.Client, source string, st OAuthState) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	state := hex.EncodeToString(b)
+
+	// Value format: "verifier|userID|returnTo" (verifier and returnTo may be empty)
+	value := st.CodeVerifier + "|" + st.UserID.String() + "|" + st.ReturnTo
+	if err := rdb.Set(ctx, oauthStateKey(source, state), value, oauthStateTTL).Err(); err != nil {
+		return "", err
+	}
+	return state, nil
+}
+
+// consumeOAuthState returns and deletes the state saved by saveOAuthState.
+func consumeOAuthState(ctx context.Context, rdb *redis.Client, source, state string) (*OAuthState, error) {
+	val, err := rdb.GetDel(ctx, oauthStateKey(source, state)).Result()
+	if err != nil {
+		return nil, errors.New("invalid or expired state")
+	}
+
+	parts := strings.SplitN(val, "|", 3)
+	if len(parts) < 3 {
+		return nil, errors.New("malformed state")
+	}
+	userID, err := uuid.Parse(parts[1])
+	if err != nil {
+		return nil, errors.New("invalid user ID in state")
+	}
+	return &OAuthState{UserID: userID, ReturnTo: parts[2], CodeVerifier: parts[0]}, nil
+}
+
+func oauthStateKey(source, state string) string {
+	return fmt.Sprintf("%s_oauth_state:%s", source, state)
+}
+
+// newPKCEVerifier returns a random RFC 7636 code verifier.
+func newPKCEVerifier() (string, error) {
+	v := make([]byte, 32)
+	if _, err := rand.Read(v); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(v), nil
+}
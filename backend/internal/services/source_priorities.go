@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+// ErrInvalidSourcePriority is returned when a priority setting names an
+// unknown field group or source, or lists a source twice.
+var ErrInvalidSourcePriority = errors.New("invalid source priority")
+
+// SourcePriorities is an athlete's source order for activities recorded by
+// more than one source. Default decides which source owns the activity and
+// applies to every field group without an entry in Fields.
+type SourcePriorities struct {
+	Default []string            `json:"default"`
+	Fields  map[string][]string `json:"fields"`
+}
+
+// GetSourcePriorities returns the athlete's settings, with the global order
+// as the default when they haven't set one.
+func (s *UserProfileService) GetSourcePriorities(ctx context.Context, userID uuid.UUID) (*SourcePriorities, error) {
+	p, err := sync.LoadPriorities(ctx, s.db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load source priorities: %w", err)
+	}
+	out := &SourcePriorities{Default: p.Default, Fields: p.Fields}
+	if out.Default == nil {
+		out.Default = sync.SourceOrder()
+	}
+	if out.Fields == nil {
+		out.Fields = map[string][]string{}
+	}
+	return out, nil
+}
+
+// UpdateSourcePriorities replaces the athlete's settings. An empty Default
+// restores the global order. Activities pick up the new order the next time
+// any of their sources syncs.
+func (s *UserProfileService) UpdateSourcePriorities(ctx context.Context, userID uuid.UUID, p SourcePriorities) (*SourcePriorities, error) {
+	if err := validateSourceOrder(p.Default); err != nil {
+		return nil, err
+	}
+	for field, order := range p.Fields {
+		if !slices.Contains(sync.Fields, field) {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSourcePriority, field)
+		}
+		if len(order) == 0 {
+			return nil, fmt.Errorf("%w: %s has no sources", ErrInvalidSourcePriority, field)
+		}
+		if err := validateSourceOrder(order); err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := s.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_source_priorities WHERE user_id = $1", userID); err != nil {
+		return nil, fmt.Errorf("failed to clear source priorities: %w", err)
+	}
+	rows := map[string][]string{}
+	for field, order := range p.Fields {
+		rows[field] = order
+	}
+	if len(p.Default) > 0 {
+		rows[sync.PriorityDefault] = p.Default
+	}
+	for field, order := range rows {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO user_source_priorities (user_id, field, sources) VALUES ($1, $2, $3)
+		`, userID, field, pq.StringArray(order)); err != nil {
+			return nil, fmt.Errorf("failed to save source priorities: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return s.GetSourcePriorities(ctx, userID)
+}
+
+func validateSourceOrder(order []string) error {
+	seen := make(map[string]bool, len(order))
+	for _, source := range order {
+		if !sync.KnownSource(source) {
+			return fmt.Errorf("%w: unknown source %q", ErrInvalidSourcePriority, source)
+		}
+		if seen[source] {
+			return fmt.Errorf("%w: %s listed twice", ErrInvalidSourcePriority, source)
+		}
+		seen[source] = true
+	}
+	return nil
+}
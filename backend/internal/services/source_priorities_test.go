@@ -0,0 +1,256 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+// sourceMergeTestDB holds one Strava-owned activity that Garmin has also
+// recorded, and the athlete's priorities.
+type sourceMergeTestDB struct {
+	archiveTestDB
+	current    models.Activity
+	records    []models.ActivitySourceRecord
+	priorities []models.UserSourcePriority
+}
+
+func (db *sourceMergeTestDB) GetContext(_ context.Context, dest any, query string, _ ...any) error {
+	switch d := dest.(type) {
+	case *uuid.UUID:
+		if strings.Contains(query, "source_activity_id") {
+			*d = db.current.ID
+			return nil
+		}
+	case *models.Activity:
+		*d = db.current
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (db *sourceMergeTestDB) SelectContext(_ context.Context, dest any, _ string, _ ...any) error {
+	switch d := dest.(type) {
+	case *[]models.ActivitySourceRecord:
+		*d = db.records
+	case *[]models.UserSourcePriority:
+		*d = db.priorities
+	}
+	return nil
+}
+
+func sourceRecord(t *testing.T, activityID uuid.UUID, raw sync.RawActivity) models.ActivitySourceRecord {
+	t.Helper()
+	data, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return models.ActivitySourceRecord{
+		ActivityID:       activityID,
+		Source:           raw.Source,
+		SourceActivityID: raw.SourceID,
+		Data:             data,
+	}
+}
+
+func TestUpsertActivityMergesFieldsBySourcePriority(t *testing.T) {
+	userID, activityID := uuid.New(), uuid.New()
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	garminHR, garminMax, garminCadence := 152, 171, 88.0
+
+	db := &sourceMergeTestDB{
+		current: models.Activity{
+			ID: activityID, UserID: userID, Source: "strava", SourceActivityID: "s1",
+			ActivityType: models.ActivityTypeRun, Name: "Morning Run", StartTime: start,
+			DistanceMeters: 10000, DurationSeconds: 3000,
+		},
+		priorities: []models.UserSourcePriority{
+			{Field: sync.FieldHeartRate, Sources: pq.StringArray{"garmin", "strava"}},
+			{Field: sync.FieldCadence, Sources: pq.StringArray{"garmin"}},
+		},
+	}
+	db.records = []models.ActivitySourceRecord{
+		sourceRecord(t, activityID, sync.RawActivity{
+			Source: "garmin", SourceID: "g1", StartTime: start, ActivityType: models.ActivityTypeRun,
+			Name: "Running", Distance: 10050, Duration: 3010,
+			AvgHR: &garminHR, MaxHR: &garminMax, AvgCadence: &garminCadence,
+		}),
+	}
+
+	stravaHR := 140
+	result, err := sync.UpsertActivity(context.Background(), db, nil, userID, sync.RawActivity{
+		Source: "strava", SourceID: "s1", StartTime: start, ActivityType: models.ActivityTypeRun,
+		Name: "Tempo Tuesday", Distance: 10000, Duration: 3000, AvgHR: &stravaHR,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Decision != sync.DecisionUpdate || result.Activity == nil {
+		t.Fatalf("expected an update, got %+v", result)
+	}
+
+	a := result.Activity
+	if a.Name != "Tempo Tuesday" || a.DistanceMeters != 10000 {
+		t.Fatalf("expected name and distance from strava, got %q %.0f", a.Name, a.DistanceMeters)
+	}
+	if a.AverageHeartRate == nil || *a.AverageHeartRate != garminHR || a.AverageCadence == nil {
+		t.Fatalf("expected heart rate and cadence from garmin, got %v %v", a.AverageHeartRate, a.AverageCadence)
+	}
+	want := models.FieldSources{
+		sync.FieldName:      "strava",
+		sync.FieldDistance:  "strava",
+		sync.FieldHeartRate: "garmin",
+		sync.FieldCadence:   "garmin",
+	}
+	if len(a.FieldSources) != len(want) {
+		t.Fatalf("expected provenance %v, got %v", want, a.FieldSources)
+	}
+	for field, source := range want {
+		if a.FieldSources[field] != source {
+			t.Fatalf("expected %s from %s, got %v", field, source, a.FieldSources)
+		}
+	}
+	if got := len(db.execsMatching("INSERT INTO activity_source_records")); got != 1 {
+		t.Fatalf("expected the strava copy to be saved, got %d writes", got)
+	}
+}
+
+func TestSaveDetailsFollowsFieldPriorities(t *testing.T) {
+	userID, activityID := uuid.New(), uuid.New()
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	garminCalories := 640.0
+	garmin := sync.RawActivity{
+		Source: "garmin", SourceID: "g1", StartTime: start, ActivityType: models.ActivityTypeRun,
+		Name: "Running", Distance: 10050, Duration: 3010,
+		Details: sync.Details{DeviceName: "Forerunner 265", Calories: &garminCalories},
+	}
+	strava := sync.RawActivity{
+		Source: "strava", SourceID: "s1", StartTime: start, ActivityType: models.ActivityTypeRun,
+		Name: "Hill Reps", Distance: 10000, Duration: 3000,
+	}
+
+	db := &sourceMergeTestDB{
+		current: models.Activity{
+			ID: activityID, UserID: userID, Source: "garmin", SourceActivityID: "g1",
+			ActivityType: models.ActivityTypeRun, Name: "Running", StartTime: start,
+			DistanceMeters: 10050, DurationSeconds: 3010,
+		},
+		priorities: []models.UserSourcePriority{
+			{Field: sync.FieldDevice, Sources: pq.StringArray{"garmin", "strava"}},
+		},
+	}
+	db.records = []models.ActivitySourceRecord{
+		sourceRecord(t, activityID, garmin),
+		sourceRecord(t, activityID, strava),
+	}
+
+	stravaCalories := 600.0
+	a, err := sync.SaveDetails(context.Background(), db, userID, activityID, "strava", "s1", sync.Details{
+		Description: "6 x 90s up the park hill", DeviceName: "Strava App", Calories: &stravaCalories,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if a == nil {
+		t.Fatal("expected the rebuilt activity, got nil")
+	}
+	if a.Description == nil || *a.Description != "6 x 90s up the park hill" {
+		t.Fatalf("expected the description from strava, got %v", a.Description)
+	}
+	if a.DeviceName == nil || *a.DeviceName != "Forerunner 265" || a.Calories == nil || *a.Calories != garminCalories {
+		t.Fatalf("expected device and calories from garmin, got %v %v", a.DeviceName, a.Calories)
+	}
+	if a.FieldSources[sync.FieldDescription] != "strava" || a.FieldSources[sync.FieldDevice] != "garmin" {
+		t.Fatalf("expected description from strava and device from garmin, got %v", a.FieldSources)
+	}
+	if a.Source != "garmin" || a.Name != "Hill Reps" {
+		t.Fatalf("expected garmin to keep the row with strava's name, got %s %q", a.Source, a.Name)
+	}
+
+	saved := db.execsMatching("INSERT INTO activity_source_records")
+	if len(saved) != 1 || saved[0].args[1] != "strava" {
+		t.Fatalf("expected only the strava copy to be saved, got %d writes", len(saved))
+	}
+	var stored sync.RawActivity
+	if err := json.Unmarshal(saved[0].args[3].([]byte), &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.DeviceName != "Strava App" || stored.Name != "Hill Reps" {
+		t.Fatalf("expected strava's copy to keep its summary and gain its details, got %+v", stored)
+	}
+}
+
+// fuzzyMatchTestDB has one stored activity, from nearbySource, that the
+// fuzzy start-time and distance match finds unless its source is excluded.
+type fuzzyMatchTestDB struct {
+	archiveTestDB
+	nearbyID     uuid.UUID
+	nearbySource string
+}
+
+func (db *fuzzyMatchTestDB) GetContext(ctx context.Context, dest any, query string, args ...any) error {
+	if !strings.Contains(query, "ABS(EXTRACT") {
+		return db.archiveTestDB.GetContext(ctx, dest, query, args...)
+	}
+	if strings.Contains(query, "source <> $4") && args[3] == db.nearbySource {
+		return sql.ErrNoRows
+	}
+	row := reflect.ValueOf(dest).Elem()
+	row.FieldByName("ID").Set(reflect.ValueOf(db.nearbyID))
+	row.FieldByName("Source").SetString(db.nearbySource)
+	return nil
+}
+
+func TestUpsertActivityFuzzyMatchPairsDifferentSourcesOnly(t *testing.T) {
+	start := time.Date(2026, 3, 1, 7, 0, 0, 0, time.UTC)
+	raw := sync.RawActivity{Source: "strava", SourceID: "s2", StartTime: start.Add(time.Minute),
+		ActivityType: models.ActivityTypeRun, Name: "Second Run", Distance: 5000, Duration: 1500}
+
+	tests := []struct {
+		name         string
+		nearbySource string
+		wantInsert   bool
+	}{
+		{name: "same source", nearbySource: "strava", wantInsert: true},
+		{name: "other source", nearbySource: "garmin", wantInsert: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &fuzzyMatchTestDB{
+				archiveTestDB: archiveTestDB{existing: map[string]uuid.UUID{}, inserted: map[string][]any{}},
+				nearbyID:      uuid.New(),
+				nearbySource:  tt.nearbySource,
+			}
+			result, err := sync.UpsertActivity(context.Background(), db, nil, uuid.New(), raw)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := result.Decision == sync.DecisionInsert; got != tt.wantInsert {
+				t.Fatalf("expected insert %v, got decision %s", tt.wantInsert, result.Decision)
+			}
+		})
+	}
+}
+
+func TestValidateSourceOrder(t *testing.T) {
+	if err := validateSourceOrder([]string{"garmin", "strava"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, order := range [][]string{{"polar"}, {"garmin", "garmin"}} {
+		if err := validateSourceOrder(order); !errors.Is(err, ErrInvalidSourcePriority) {
+			t.Fatalf("expected %v to be rejected, got %v", order, err)
+		}
+	}
+}
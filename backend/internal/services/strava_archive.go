@@ -0,0 +1,515 @@
+package services
+
+import (
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/activityfile"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+const (
+	stravaArchiveKind = "strava_archive"
+
+	// stravaArchiveTimeout bounds one archive import. A decade of daily
+	// activities is a few thousand rows.
+	stravaArchiveTimeout = 2 * time.Hour
+
+	// stravaArchiveProgressEvery is the number of rows between progress
+	// writes. The writes double as the job's heartbeat.
+	stravaArchiveProgressEvery = 25
+
+	// stravaArchiveStaleAfter is how long a job may go without a progress
+	// write before it is assumed lost with a restarted server.
+	stravaArchiveStaleAfter = 15 * time.Minute
+
+	// stravaArchiveMaxRowErrors caps the row errors kept on a job.
+	stravaArchiveMaxRowErrors = 200
+
+	// stravaArchiveMaxFileBytes caps one decompressed activity file.
+	stravaArchiveMaxFileBytes = 64 << 20
+
+	// stravaArchiveConcurrency is how many archives a server imports at
+	// once; later uploads wait as pending.
+	stravaArchiveConcurrency = 2
+)
+
+// importJobHeartbeat is how often a job that makes no progress writes of
+// its own, one pending for an import slot or one reading past a long run
+// of discarded records, touches updated_at to show it is alive. It is well
+// inside stravaArchiveStaleAfter.
+var importJobHeartbeat = time.Minute
+
+var (
+	// ErrInvalidStravaArchive is returned for uploads that are not a Strava
+	// bulk export ZIP.
+	ErrInvalidStravaArchive = errors.New("not a Strava export archive")
+
+	// ErrImportInProgress is returned when the athlete already has an
+	// archive import pending or running.
+	ErrImportInProgress = errors.New("an archive import is already in progress")
+
+	// ErrImportJobNotFound is returned when an import job does not exist or
+	// belongs to a different user.
+	ErrImportJobNotFound = errors.New("import job not found")
+)
+
+// archiveRowError is one entry of ActivityImportJob.RowErrors.
+type archiveRowError struct {
+	Row        int    `json:"row"`
+	ActivityID int64  `json:"activity_id,omitempty"`
+	Error      string `json:"error"`
+}
+
+// archiveProgress accumulates a job's counters between progress writes.
+type archiveProgress struct {
+	processed, imported, skipped, failed int
+	rowErrors                            []archiveRowError
+}
+
+func (p *archiveProgress) addError(rowErr archiveRowError) {
+	if len(p.rowErrors) < stravaArchiveMaxRowErrors {
+		p.rowErrors = append(p.rowErrors, rowErr)
+	}
+}
+
+// StartStravaArchiveImport validates an uploaded Strava bulk export and
+// imports it in the background. The archive is copied to a temporary file
+// that the import removes when it finishes. Progress is read with
+// GetImportJob.
+func (s *ActivityImportService) StartStravaArchiveImport(ctx context.Context, userID uuid.UUID, fileName string, src io.Reader) (*models.ActivityImportJob, error) {
+	if err := s.failStaleImportJobs(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	tmp, err := os.CreateTemp("", "korsana-strava-archive-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	archivePath := tmp.Name()
+	_, err = io.Copy(tmp, src)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(archivePath)
+		return nil, err
+	}
+
+	archive, entries, rowErrs, err := openStravaArchive(archivePath)
+	if err != nil {
+		os.Remove(archivePath)
+		return nil, err
+	}
+	cleanup := func() {
+		archive.Close()
+		os.Remove(archivePath)
+	}
+
+	var job models.ActivityImportJob
+	err = s.db.GetContext(ctx, &job, `
+		INSERT INTO activity_import_jobs (user_id, kind, file_name, total_rows)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+		RETURNING *
+	`, userID, stravaArchiveKind, fileName, len(entries)+len(rowErrs))
+	if errors.Is(err, sql.ErrNoRows) {
+		cleanup()
+		return nil, ErrImportInProgress
+	}
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+
+	go func() {
+		defer cleanup()
+		runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stravaArchiveTimeout)
+		defer cancel()
+		s.runStravaArchiveImport(runCtx, &job, archive, entries, rowErrs)
+	}()
+	return &job, nil
+}
+
+// openStravaArchive opens the ZIP and parses its activities.csv.
+func openStravaArchive(archivePath string) (*zip.ReadCloser, []strava.ArchiveEntry, []strava.ArchiveRowError, error) {
+	archive, err := zip.OpenReader(archivePath)
+	if err != nil {
+		return nil, nil, nil, ErrInvalidStravaArchive
+	}
+	index, err := archive.Open(strava.ArchiveActivitiesFile)
+	if err != nil {
+		archive.Close()
+		return nil, nil, nil, fmt.Errorf("%w: no %s", ErrInvalidStravaArchive, strava.ArchiveActivitiesFile)
+	}
+	defer index.Close()
+
+	entries, rowErrs, err := strava.ReadArchiveActivities(index)
+	if err != nil {
+		archive.Close()
+		return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidStravaArchive, err)
+	}
+	return archive, entries, rowErrs, nil
+}
+
+// GetImportJob returns one of the user's import jobs.
+func (s *ActivityImportService) GetImportJob(ctx context.Context, userID, jobID uuid.UUID) (*models.ActivityImportJob, error) {
+	if err := s.failStaleImportJobs(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	var job models.ActivityImportJob
+	err := s.db.GetContext(ctx, &job, `
+		SELECT * FROM activity_import_jobs WHERE id = $1 AND user_id = $2
+	`, jobID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrImportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// failStaleImportJobs fails the user's jobs that stopped reporting
+// progress, which happens when the server importing them restarted. The
+// archive lived on that server, so the athlete has to upload it again.
+func (s *ActivityImportService) failStaleImportJobs(ctx context.Context, userID uuid.UUID) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE activity_import_jobs
+		SET status = 'failed', last_error = 'import was interrupted; upload the archive again',
+			completed_at = NOW(), updated_at = NOW()
+		WHERE user_id = $1
+		  AND status IN ('pending', 'running')
+		  AND updated_at < NOW() - make_interval(secs => $2)
+	`, userID, stravaArchiveStaleAfter.Seconds())
+	return err
+}
+
+// runStravaArchiveImport imports every parsed row, writing progress as it
+// goes, then refreshes weekly summaries.
+func (s *ActivityImportService) runStravaArchiveImport(ctx context.Context, job *models.ActivityImportJob, archive *zip.ReadCloser, entries []strava.ArchiveEntry, rowErrs []strava.ArchiveRowError) {
+	log := logger.FromContext(ctx).With("job_id", job.ID, "user_id", job.UserID)
+	ctx = logger.WithLogger(ctx, log)
+
+	release, ok := s.claimImportJob(ctx, job.ID)
+	if !ok {
+		return
+	}
+	defer release()
+
+	progress := &archiveProgress{}
+	for _, rowErr := range rowErrs {
+		progress.processed++
+		progress.failed++
+		progress.addError(archiveRowError{Row: rowErr.Row, Error: rowErr.Err.Error()})
+	}
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	for i, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			s.saveImportProgress(ctx, job.ID, progress)
+			s.finishImportJob(ctx, job.ID, err)
+			return
+		}
+		s.importArchiveEntry(ctx, job.UserID, entry, files, progress)
+		progress.processed++
+		if (i+1)%stravaArchiveProgressEvery == 0 {
+			s.saveImportProgress(ctx, job.ID, progress)
+		}
+	}
+	s.saveImportProgress(ctx, job.ID, progress)
+
+	if progress.imported > 0 {
+		if err := refreshWeeklySummaries(ctx, s.db, job.UserID); err != nil {
+			log.Warn("strava archive: weekly summaries failed", "error", err)
+		}
+	}
+	s.finishImportJob(ctx, job.ID, nil)
+	log.Info("strava archive import complete",
+		"imported", progress.imported,
+		"skipped", progress.skipped,
+		"failed", progress.failed,
+	)
+}
+
+// importArchiveEntry stores one CSV row with the laps and streams of its
+// original file. A row whose file can't be read is still imported from
+// the CSV and listed in the row errors.
+func (s *ActivityImportService) importArchiveEntry(ctx context.Context, userID uuid.UUID, entry strava.ArchiveEntry, files map[string]*zip.File, progress *archiveProgress) {
+	rowErr := archiveRowError{Row: entry.Row, ActivityID: entry.Activity.ID}
+
+	raw, err := stravaRawActivity(entry.Activity)
+	if err != nil {
+		progress.failed++
+		rowErr.Error = err.Error()
+		progress.addError(rowErr)
+		return
+	}
+	// The archive has no time zone. Leave LocalDate to the file, or to the
+	// start_time fallback, rather than storing the UTC date.
+	raw.LocalDate = nil
+
+	// Activities already synced from the API keep that copy, which has
+	// fields the archive lacks (workout type, gear, local date); the
+	// file only fills in streams the API sync didn't fetch.
+	var existingID uuid.UUID
+	err = s.db.GetContext(ctx, &existingID, `
+		SELECT id FROM activities
+		WHERE user_id = $1 AND source = 'strava' AND source_activity_id = $2
+	`, userID, raw.SourceID)
+	exists := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		progress.failed++
+		rowErr.Error = "failed to check for an existing copy"
+		progress.addError(rowErr)
+		return
+	}
+
+	var act activityfile.Activity
+	if entry.Filename != "" {
+		decoded, fileErr := readArchiveActivityFile(files, entry.Filename)
+		if fileErr != nil {
+			rowErr.Error = fmt.Sprintf("%s: %v; imported without streams", entry.Filename, fileErr)
+			progress.addError(rowErr)
+		} else {
+			act = decoded
+		}
+	}
+	if act.LocalOffset != nil {
+		localDate := raw.StartTime.Add(*act.LocalOffset).Truncate(24 * time.Hour)
+		raw.LocalDate = &localDate
+	}
+
+	if exists {
+		progress.skipped++
+		if len(act.Samples) > 0 {
+			if err := s.fillMissingStreams(ctx, userID, existingID, act); err != nil {
+				logger.FromContext(ctx).Warn("strava archive: failed to add streams",
+					"activity_id", existingID,
+					"error", err,
+				)
+			}
+		}
+		return
+	}
+
+	result, err := s.storeFileActivity(ctx, userID, raw, act)
+	if err != nil {
+		logger.FromContext(ctx).Error("strava archive: failed to store activity",
+			"strava_activity_id", entry.Activity.ID,
+			"error", err,
+		)
+		progress.failed++
+		rowErr.Error = "failed to store activity"
+		progress.addError(rowErr)
+		return
+	}
+	if result.Activity == nil {
+		progress.skipped++
+		return
+	}
+	progress.imported++
+}
+
+// fillMissingStreams stores the file's streams on an API-synced activity
+// that has none.
+func (s *ActivityImportService) fillMissingStreams(ctx context.Context, userID, activityID uuid.UUID, act activityfile.Activity) error {
+	var count int
+	if err := s.db.GetContext(ctx, &count, `
+		SELECT COUNT(*) FROM activity_streams WHERE activity_id = $1
+	`, activityID); err != nil || count > 0 {
+		return err
+	}
+	streams, err := fileStreams(&models.Activity{ID: activityID, UserID: userID, Source: "strava"}, act)
+	if err != nil || streams == nil {
+		return err
+	}
+	return sync.SaveActivityStreams(ctx, s.db, streams)
+}
+
+// readArchiveActivityFile decodes an original upload from the archive.
+// Strava gzips most of them ("123.fit.gz").
+func readArchiveActivityFile(files map[string]*zip.File, name string) (activityfile.Activity, error) {
+	f, ok := files[name]
+	if !ok {
+		return activityfile.Activity{}, errors.New("file missing from archive")
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return activityfile.Activity{}, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if strings.EqualFold(path.Ext(name), ".gz") {
+		gz, err := gzip.NewReader(rc)
+		if err != nil {
+			return activityfile.Activity{}, err
+		}
+		defer gz.Close()
+		r = gz
+		name = strings.TrimSuffix(name, path.Ext(name))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, stravaArchiveMaxFileBytes+1))
+	if err != nil {
+		return activityfile.Activity{}, err
+	}
+	if len(data) > stravaArchiveMaxFileBytes {
+		return activityfile.Activity{}, errors.New("file is too large")
+	}
+
+	activities, err := decodeActivityFile(name, data)
+	if err != nil {
+		return activityfile.Activity{}, err
+	}
+	return activities[0], nil
+}
+
+// claimImportJob waits for an import slot and marks the job running. The
+// caller must call release when it is done; ok is false when the job
+// should not run, in which case there is nothing to release.
+func (s *ActivityImportService) claimImportJob(ctx context.Context, jobID uuid.UUID) (release func(), ok bool) {
+	release = func() {}
+	if s.archiveSlots != nil {
+		// The wait can outlast stravaArchiveStaleAfter while other imports
+		// run, so the pending job keeps touching its heartbeat.
+		ticker := time.NewTicker(importJobHeartbeat)
+		defer ticker.Stop()
+	wait:
+		for {
+			select {
+			case s.archiveSlots <- struct{}{}:
+				release = func() { <-s.archiveSlots }
+				break wait
+			case <-ticker.C:
+				if !s.touchImportJob(ctx, jobID, "pending") {
+					return nil, false
+				}
+			case <-ctx.Done():
+				s.finishImportJob(ctx, jobID, ctx.Err())
+				return nil, false
+			}
+		}
+	}
+
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE activity_import_jobs
+		SET status = 'running', started_at = NOW(), updated_at = NOW()
+		WHERE id = $1 AND status = 'pending'
+	`, jobID)
+	if err != nil {
+		logger.FromContext(ctx).Error("activity import: failed to start job", "error", err)
+		release()
+		return nil, false
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		// Failed as stale while waiting for a slot.
+		release()
+		return nil, false
+	}
+	return release, true
+}
+
+// touchImportJob marks a job in status as alive. It returns false once the
+// job has left that status, such as when it was failed as stale.
+func (s *ActivityImportService) touchImportJob(ctx context.Context, jobID uuid.UUID, status string) bool {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE activity_import_jobs SET updated_at = NOW()
+		WHERE id = $1 AND status = $2
+	`, jobID, status)
+	if err != nil {
+		// A missed beat is not fatal; the next one may land.
+		logger.FromContext(ctx).Warn("activity import: failed to write heartbeat", "error", err)
+		return true
+	}
+	n, err := res.RowsAffected()
+	return err != nil || n > 0
+}
+
+// keepImportAlive touches a running job's heartbeat until the returned
+// stop is called, for imports whose progress writes can be far apart.
+func (s *ActivityImportService) keepImportAlive(ctx context.Context, jobID uuid.UUID) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(importJobHeartbeat)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.touchImportJob(ctx, jobID, "running")
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-finished
+	}
+}
+
+// saveImportProgress writes the job's counters. Jobs that stream their
+// input don't know their total up front, so total_rows never trails
+// processed_rows.
+func (s *ActivityImportService) saveImportProgress(ctx context.Context, jobID uuid.UUID, p *archiveProgress) {
+	rowErrors, err := json.Marshal(p.rowErrors)
+	if err != nil || p.rowErrors == nil {
+		rowErrors = []byte("[]")
+	}
+	_, err = s.db.ExecContext(context.WithoutCancel(ctx), `
+		UPDATE activity_import_jobs
+		SET total_rows = GREATEST(total_rows, $2),
+			processed_rows = $2, imported_rows = $3, skipped_rows = $4,
+			failed_rows = $5, row_errors = $6, updated_at = NOW()
+		WHERE id = $1
+	`, jobID, p.processed, p.imported, p.skipped, p.failed, rowErrors)
+	if err != nil {
+		logger.FromContext(ctx).Warn("activity import: failed to save progress", "error", err)
+	}
+}
+
+// finishImportJob marks the job done, or failed with jobErr.
+func (s *ActivityImportService) finishImportJob(ctx context.Context, jobID uuid.UUID, jobErr error) {
+	status, lastError := "done", (*string)(nil)
+	if jobErr != nil {
+		status = "failed"
+		msg := jobErr.Error()
+		lastError = &msg
+	}
+	_, err := s.db.ExecContext(context.WithoutCancel(ctx), `
+		UPDATE activity_import_jobs
+		SET status = $2, last_error = $3, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $1
+	`, jobID, status, lastError)
+	if err != nil {
+		logger.FromContext(ctx).Error("activity import: failed to finish job",
+			"status", status,
+			"error", err,
+		)
+	}
+}
@@ -0,0 +1,258 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// archiveTestDB stores inserted activities by source ID and resolves the
+// exact-match lookups against them.
+type archiveTestDB struct {
+	existing map[string]uuid.UUID
+	inserted map[string][]any
+	execs    []archiveTestExec
+}
+
+type archiveTestExec struct {
+	query string
+	args  []any
+}
+
+func (db *archiveTestDB) GetContext(_ context.Context, dest any, query string, args ...any) error {
+	switch target := dest.(type) {
+	case *uuid.UUID:
+		if strings.Contains(query, "RETURNING id") {
+			db.inserted[args[3].(string)] = args
+			*target = uuid.New()
+			return nil
+		}
+		if id, ok := db.existing[args[len(args)-1].(string)]; ok {
+			*target = id
+			return nil
+		}
+		return sql.ErrNoRows
+	case *int:
+		*target = 0
+		return nil
+	default:
+		return sql.ErrNoRows
+	}
+}
+
+func (db *archiveTestDB) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	db.execs = append(db.execs, archiveTestExec{query: query, args: args})
+	return driver.RowsAffected(1), nil
+}
+
+func (db *archiveTestDB) SelectContext(_ context.Context, _ any, _ string, _ ...any) error {
+	return nil
+}
+
+// execsMatching returns the statements containing fragment.
+func (db *archiveTestDB) execsMatching(fragment string) []archiveTestExec {
+	var out []archiveTestExec
+	for _, e := range db.execs {
+		if strings.Contains(e.query, fragment) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+const testArchiveCSV = `Activity ID,Activity Date,Activity Name,Activity Type,Elapsed Time,Distance,Filename,Elapsed Time,Moving Time,Distance,Average Heart Rate,Relative Effort
+1001,"Sep 8, 2021, 1:46:40 AM",Morning Run,Run,20,0.10,activities/1001.fit.gz,20,20,100.4,150,12
+1002,"Mar 1, 2026, 2:00:00 PM",Lift,Weight Training,1800,0,,1800,1800,0,,
+1003,not a date,Broken,Run,60,1,,60,60,1000,,
+1004,"Mar 2, 2026, 2:00:00 PM",Synced Run,Run,1500,5.0,activities/1004.gpx,1500,1500,5000,,
+`
+
+func writeTestArchive(t *testing.T) string {
+	t.Helper()
+	var fit bytes.Buffer
+	gz := gzip.NewWriter(&fit)
+	gz.Write(buildTestFIT()) //nolint:errcheck
+	gz.Close()
+
+	archivePath := filepath.Join(t.TempDir(), "export.zip")
+	f, err := os.Create(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, data := range map[string][]byte{
+		"activities.csv":         []byte(testArchiveCSV),
+		"activities/1001.fit.gz": fit.Bytes(),
+	} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data) //nolint:errcheck
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	return archivePath
+}
+
+func TestStravaArchiveImport(t *testing.T) {
+	archive, entries, rowErrs, err := openStravaArchive(writeTestArchive(t))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer archive.Close()
+	if len(entries) != 3 || len(rowErrs) != 1 || rowErrs[0].Row != 4 {
+		t.Fatalf("expected 3 rows and an error on row 4, got %d rows and %+v", len(entries), rowErrs)
+	}
+	if entries[0].Activity.Distance != 100.4 {
+		t.Fatalf("expected the meters column to win, got %v", entries[0].Activity.Distance)
+	}
+
+	syncedID := uuid.New()
+	db := &archiveTestDB{
+		existing: map[string]uuid.UUID{"1004": syncedID},
+		inserted: map[string][]any{},
+	}
+	svc := &ActivityImportService{db: db}
+	files := map[string]*zip.File{}
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	progress := &archiveProgress{}
+	for _, entry := range entries {
+		svc.importArchiveEntry(context.Background(), uuid.New(), entry, files, progress)
+	}
+
+	if progress.imported != 2 || progress.skipped != 1 || progress.failed != 0 {
+		t.Fatalf("expected 2 imported and 1 skipped, got %+v", progress)
+	}
+	if len(progress.rowErrors) != 1 || progress.rowErrors[0].ActivityID != 1004 {
+		t.Fatalf("expected the missing GPX to be reported, got %+v", progress.rowErrors)
+	}
+
+	run := db.inserted["1001"]
+	if run == nil || run[2] != "strava" {
+		t.Fatalf("expected row 1001 stored as a strava activity, got %v", run)
+	}
+	if ld, _ := run[9].(*time.Time); ld == nil || ld.Format("2006-01-02") != "2021-09-07" {
+		t.Fatalf("expected the FIT file's local date, got %v", run[9])
+	}
+	if lift := db.inserted["1002"]; lift == nil || lift[4] != "weight_lifting" || lift[9].(*time.Time) != nil {
+		t.Fatalf("expected a weight_lifting row without a local date, got %v", lift)
+	}
+	if got := len(db.execsMatching("INSERT INTO activity_streams")); got != 1 {
+		t.Fatalf("expected streams from the FIT file only, got %d", got)
+	}
+}
+
+// importJobTestDB keeps one import job's status and updated_at against a
+// fake clock, answering the job statements the way Postgres would.
+type importJobTestDB struct {
+	archiveTestDB
+	mu        sync.Mutex
+	now       time.Time
+	status    string
+	updatedAt time.Time
+}
+
+func (db *importJobTestDB) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	switch {
+	case strings.Contains(query, "make_interval"):
+		staleAfter := time.Duration(args[1].(float64)) * time.Second
+		if (db.status == "pending" || db.status == "running") && db.updatedAt.Before(db.now.Add(-staleAfter)) {
+			db.status, db.updatedAt = "failed", db.now
+			return driver.RowsAffected(1), nil
+		}
+	case strings.Contains(query, "SET status = 'running'"):
+		if db.status == "pending" {
+			db.status, db.updatedAt = "running", db.now
+			return driver.RowsAffected(1), nil
+		}
+	case strings.Contains(query, "SET updated_at = NOW()"):
+		if db.status == args[1] {
+			db.updatedAt = db.now
+			return driver.RowsAffected(1), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+func (db *importJobTestDB) advance(d time.Duration) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.now = db.now.Add(d)
+}
+
+func (db *importJobTestDB) state() (string, bool) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.status, db.updatedAt.Equal(db.now)
+}
+
+func TestQueuedArchiveImportSurvivesStalePolls(t *testing.T) {
+	defer func(prev time.Duration) { importJobHeartbeat = prev }(importJobHeartbeat)
+	importJobHeartbeat = time.Millisecond
+
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	db := &importJobTestDB{now: start, status: "pending", updatedAt: start}
+	svc := &ActivityImportService{db: db, archiveSlots: make(chan struct{}, stravaArchiveConcurrency)}
+	// Two long imports hold both slots.
+	for i := 0; i < stravaArchiveConcurrency; i++ {
+		svc.archiveSlots <- struct{}{}
+	}
+
+	claimed := make(chan bool, 1)
+	go func() {
+		release, ok := svc.claimImportJob(context.Background(), uuid.New())
+		if ok {
+			release()
+		}
+		claimed <- ok
+	}()
+
+	// The athlete polls every ten minutes while the job waits, well past
+	// stravaArchiveStaleAfter in all.
+	for elapsed := time.Duration(0); elapsed <= 2*stravaArchiveStaleAfter; elapsed += 10 * time.Minute {
+		db.advance(10 * time.Minute)
+		deadline := time.Now().Add(time.Second)
+		for {
+			if _, beat := db.state(); beat {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("expected the pending job to touch its heartbeat")
+			}
+			time.Sleep(time.Millisecond)
+		}
+		if err := svc.failStaleImportJobs(context.Background(), uuid.New()); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if status, _ := db.state(); status != "pending" {
+			t.Fatalf("expected the queued job still pending, got %s", status)
+		}
+	}
+
+	<-svc.archiveSlots
+	if !<-claimed {
+		t.Fatal("expected the queued job to run once a slot freed")
+	}
+	if status, _ := db.state(); status != "running" {
+		t.Fatalf("expected the job running, got %s", status)
+	}
+}
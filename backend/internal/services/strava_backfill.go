@@ -0,0 +1,312 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+const (
+	// stravaBackfillPerPage is Strava's per_page maximum; bigger pages mean
+	// fewer requests against the 15-minute window.
+	stravaBackfillPerPage = 200
+
+	// stravaBackfillPageInterval spaces backfill requests so a replica uses
+	// at most ~45 of Strava's 100 requests per 15 minutes, leaving room for
+	// interactive syncs and webhook fetches.
+	stravaBackfillPageInterval = 20 * time.Second
+
+	stravaBackfillRateLimitWait  = 15 * time.Minute
+	stravaBackfillClaimTimeout   = 5 * time.Minute
+	stravaBackfillRetryBaseDelay = time.Minute
+	stravaBackfillRetryMaxDelay  = time.Hour
+	stravaBackfillMaxFailures    = 8
+)
+
+// restartBackfillSet resets a job to page 1 with a fresh anchor. Shared by
+// the reconnect path in HandleCallback and StartBackfill.
+const restartBackfillSet = `
+	status = 'pending', cursor_before = NOW(), next_page = 1,
+	pages_fetched = 0, activities_imported = 0, oldest_activity_at = NULL,
+	consecutive_failures = 0, last_error = NULL, next_run_at = NOW(),
+	claimed_at = NULL, started_at = NULL, completed_at = NULL, updated_at = NOW()`
+
+// ErrStravaBackfillNotFound is returned when the user has no backfill job.
+var ErrStravaBackfillNotFound = errors.New("strava backfill not found")
+
+// GetBackfill returns the backfill job for the user's Strava connection.
+func (s *StravaService) GetBackfill(ctx context.Context, userID uuid.UUID) (*models.StravaBackfillJob, error) {
+	var job models.StravaBackfillJob
+	err := s.db.GetContext(ctx, &job, `
+		SELECT j.* FROM strava_backfill_jobs j
+		JOIN connected_integrations c ON c.id = j.connection_id
+		WHERE c.user_id = $1
+	`, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrStravaBackfillNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// StartBackfill queues a full-history import for the user's connection. A
+// job that already finished or failed is restarted from the newest page;
+// a pending or running job is returned unchanged.
+func (s *StravaService) StartBackfill(ctx context.Context, userID uuid.UUID) (*models.StravaBackfillJob, error) {
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var job models.StravaBackfillJob
+	err = s.db.GetContext(ctx, &job, `
+		INSERT INTO strava_backfill_jobs (connection_id, user_id, cursor_before)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (connection_id) DO UPDATE SET `+restartBackfillSet+`
+		WHERE strava_backfill_jobs.status IN ('done', 'failed')
+		RETURNING *
+	`, conn.ID, conn.UserID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Conflict with an in-flight job: the WHERE filtered the update out.
+		return s.GetBackfill(ctx, userID)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// claimBackfillJob leases the job that has waited longest. Ordering by
+// next_run_at and pushing it to NOW() after every page round-robins the
+// worker across athletes instead of finishing one history at a time.
+func (s *StravaService) claimBackfillJob(ctx context.Context) (*models.StravaBackfillJob, error) {
+	var job models.StravaBackfillJob
+	err := s.db.GetContext(ctx, &job, `
+		UPDATE strava_backfill_jobs
+		SET status = 'running', claimed_at = NOW(),
+			started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM strava_backfill_jobs
+			WHERE status IN ('pending', 'running')
+			  AND next_run_at <= NOW()
+			  AND (claimed_at IS NULL OR claimed_at < NOW() - make_interval(secs => $1))
+			ORDER BY next_run_at ASC
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, stravaBackfillClaimTimeout.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// stravaBackfillPage is the outcome of importing one page.
+type stravaBackfillPage struct {
+	imported int
+	oldest   *time.Time
+	done     bool
+}
+
+// importBackfillPage fetches and stores the job's next page. The cursor is
+// not touched here; a failed page is simply requested again.
+func (s *StravaService) importBackfillPage(ctx context.Context, job *models.StravaBackfillJob) (*stravaBackfillPage, error) {
+	conn, err := s.getConnectionByID(ctx, job.ConnectionID)
+	if err != nil {
+		return nil, err
+	}
+	conn, err = s.RefreshAccessToken(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	activities, err := s.stravaClient.ListActivities(ctx, conn.AccessToken, strava.ActivityListParams{
+		Before:  job.CursorBefore,
+		Page:    job.NextPage,
+		PerPage: stravaBackfillPerPage,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	page := &stravaBackfillPage{done: len(activities) < stravaBackfillPerPage}
+	for _, act := range activities {
+		result, err := s.storeStravaActivity(ctx, conn.UserID, act)
+		if errors.Is(err, errStravaActivityUnparseable) {
+			logger.FromContext(ctx).Warn("strava backfill: skipping activity, bad date format",
+				"activity_id", act.ID,
+				"error", err,
+			)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stored := result.Activity
+		if stored == nil {
+			continue
+		}
+		page.imported++
+		if page.oldest == nil || stored.StartTime.Before(*page.oldest) {
+			startTime := stored.StartTime
+			page.oldest = &startTime
+		}
+	}
+	return page, nil
+}
+
+// advanceBackfill moves the cursor past a successfully imported page.
+func (s *StravaService) advanceBackfill(ctx context.Context, job *models.StravaBackfillJob, page *stravaBackfillPage) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE strava_backfill_jobs
+		SET next_page = next_page + 1,
+			pages_fetched = pages_fetched + 1,
+			activities_imported = activities_imported + $2,
+			oldest_activity_at = LEAST(oldest_activity_at, $3),
+			consecutive_failures = 0,
+			last_error = NULL,
+			claimed_at = NULL,
+			next_run_at = NOW(),
+			status = CASE WHEN $4 THEN 'done' ELSE 'running' END,
+			completed_at = CASE WHEN $4 THEN NOW() ELSE NULL END,
+			updated_at = NOW()
+		WHERE id = $1
+	`, job.ID, page.imported, page.oldest, page.done)
+	return err
+}
+
+// backfillRetry decides what happens to a job after a failed page: the
+// status it moves to, how long to wait, and whether the failure counts
+// towards stravaBackfillMaxFailures. A 429 only waits out the window.
+func backfillRetry(job *models.StravaBackfillJob, err error) (status string, delay time.Duration, countFailure bool) {
+	var apiErr *strava.APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+		delay = stravaBackfillRateLimitWait
+		if apiErr.RetryAfter > 0 {
+			delay = apiErr.RetryAfter
+		}
+		return "running", delay, false
+	}
+
+	if !isRetryableStravaError(err) || job.ConsecutiveFailures+1 >= stravaBackfillMaxFailures {
+		return "failed", 0, true
+	}
+
+	delay = stravaBackfillRetryBaseDelay << job.ConsecutiveFailures
+	if delay > stravaBackfillRetryMaxDelay {
+		delay = stravaBackfillRetryMaxDelay
+	}
+	return "running", delay, true
+}
+
+func (s *StravaService) failBackfillPage(ctx context.Context, job *models.StravaBackfillJob, pageErr error) error {
+	status, delay, countFailure := backfillRetry(job, pageErr)
+	failures := job.ConsecutiveFailures
+	if countFailure {
+		failures++
+	}
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE strava_backfill_jobs
+		SET status = $2,
+			consecutive_failures = $3,
+			last_error = $4,
+			claimed_at = NULL,
+			next_run_at = NOW() + make_interval(secs => $5),
+			updated_at = NOW()
+		WHERE id = $1
+	`, job.ID, status, failures, pageErr.Error(), delay.Seconds())
+	return err
+}
+
+// StravaBackfillWorker walks every pending backfill job one page per tick.
+// The tick is the pacing: across all athletes a replica issues at most one
+// list request per stravaBackfillPageInterval.
+type StravaBackfillWorker struct {
+	svc          *StravaService
+	pageInterval time.Duration
+	onComplete   func(ctx context.Context, userID uuid.UUID)
+}
+
+// NewStravaBackfillWorker creates a worker. onComplete, if non-nil, runs
+// after an athlete's history has been fully imported (e.g. PR detection).
+func NewStravaBackfillWorker(svc *StravaService, onComplete func(ctx context.Context, userID uuid.UUID)) *StravaBackfillWorker {
+	return &StravaBackfillWorker{
+		svc:          svc,
+		pageInterval: stravaBackfillPageInterval,
+		onComplete:   onComplete,
+	}
+}
+
+// Run processes backfill pages until ctx is cancelled.
+func (w *StravaBackfillWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pageInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := w.processNext(ctx); err != nil {
+				logger.FromContext(ctx).Error("strava backfill worker: queue error", "error", err)
+			}
+		}
+	}
+}
+
+func (w *StravaBackfillWorker) processNext(ctx context.Context) error {
+	// Backfills can wait; leave a low budget to interactive syncs and
+	// webhooks rather than claim a job and fail its page.
+	if !w.svc.allowsBackgroundWork(ctx) {
+		return nil
+	}
+	job, err := w.svc.claimBackfillJob(ctx)
+	if err != nil || job == nil {
+		return err
+	}
+
+	log := logger.FromContext(ctx).With(
+		"job_id", job.ID,
+		"user_id", job.UserID,
+		"page", job.NextPage,
+	)
+	pageCtx, cancel := context.WithTimeout(logger.WithLogger(ctx, log), time.Minute)
+	defer cancel()
+
+	page, pageErr := w.svc.importBackfillPage(pageCtx, job)
+	if pageErr != nil {
+		log.Warn("strava backfill worker: page failed", "error", pageErr)
+		return w.svc.failBackfillPage(ctx, job, pageErr)
+	}
+	if err := w.svc.advanceBackfill(ctx, job, page); err != nil {
+		return err
+	}
+
+	if page.done {
+		log.Info("strava backfill complete",
+			"pages", job.PagesFetched+1,
+			"activities", job.ActivitiesImported+page.imported,
+		)
+		if err := w.svc.computeWeeklySummaries(pageCtx, job.UserID); err != nil {
+			log.Warn("strava backfill worker: weekly summaries failed", "error", err)
+		}
+		if w.onComplete != nil {
+			w.onComplete(pageCtx, job.UserID)
+		}
+	}
+	return nil
+}
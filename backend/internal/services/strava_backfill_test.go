@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/models"
+	pkgstrava "github.com/korsana/backend/pkg/strava"
+)
+
+func TestImportBackfillPageUsesPersistedCursor(t *testing.T) {
+	anchor := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	var got pkgstrava.ActivityListParams
+	client := &mockStravaClient{
+		listActivitiesFn: func(_ context.Context, _ string, params pkgstrava.ActivityListParams) ([]pkgstrava.Activity, error) {
+			got = params
+			return nil, nil
+		},
+	}
+	svc, db := newConnectedStravaService(client)
+	var connID uuid.UUID
+	for id := range db.connections {
+		connID = id
+	}
+
+	page, err := svc.importBackfillPage(context.Background(), &models.StravaBackfillJob{
+		ConnectionID: connID,
+		CursorBefore: anchor,
+		NextPage:     7,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !got.Before.Equal(anchor) || got.Page != 7 || got.PerPage != stravaBackfillPerPage {
+		t.Fatalf("unexpected list params: %+v", got)
+	}
+	if !page.done || page.imported != 0 {
+		t.Fatalf("expected empty final page, got %+v", page)
+	}
+}
+
+func TestBackfillRetry(t *testing.T) {
+	cases := []struct {
+		name         string
+		failures     int
+		err          error
+		wantStatus   string
+		wantDelay    time.Duration
+		wantCounting bool
+	}{
+		{
+			name:       "rate limit waits out the window",
+			err:        &pkgstrava.APIError{StatusCode: http.StatusTooManyRequests},
+			wantStatus: "running",
+			wantDelay:  stravaBackfillRateLimitWait,
+		},
+		{
+			name:       "rate limit honours retry-after",
+			failures:   stravaBackfillMaxFailures - 1,
+			err:        &pkgstrava.APIError{StatusCode: http.StatusTooManyRequests, RetryAfter: 90 * time.Second},
+			wantStatus: "running",
+			wantDelay:  90 * time.Second,
+		},
+		{
+			name:         "transient error backs off exponentially",
+			failures:     2,
+			err:          errors.New("connection reset"),
+			wantStatus:   "running",
+			wantDelay:    4 * stravaBackfillRetryBaseDelay,
+			wantCounting: true,
+		},
+		{
+			name:         "revoked token fails the job",
+			err:          &pkgstrava.APIError{StatusCode: http.StatusUnauthorized},
+			wantStatus:   "failed",
+			wantCounting: true,
+		},
+		{
+			name:         "too many failures",
+			failures:     stravaBackfillMaxFailures - 1,
+			err:          &pkgstrava.APIError{StatusCode: http.StatusBadGateway},
+			wantStatus:   "failed",
+			wantCounting: true,
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			job := &models.StravaBackfillJob{ConsecutiveFailures: tc.failures}
+			status, delay, counting := backfillRetry(job, tc.err)
+			if status != tc.wantStatus || delay != tc.wantDelay || counting != tc.wantCounting {
+				t.Fatalf("backfillRetry = (%q, %v, %v), want (%q, %v, %v)",
+					status, delay, counting, tc.wantStatus, tc.wantDelay, tc.wantCounting)
+			}
+		})
+	}
+}
+
+func TestBackfillWorkerDefersWhenRateLimitBudgetIsLow(t *testing.T) {
+	errClaimed := errors.New("job claimed")
+	window := func(limit, usage int) pkgstrava.RateLimitWindow {
+		return pkgstrava.RateLimitWindow{Limit: limit, Usage: usage}
+	}
+	cases := []struct {
+		name   string
+		budget *pkgstrava.Budget
+		want   error
+	}{
+		{name: "untracked budget", want: errClaimed},
+		{name: "plenty left", budget: &pkgstrava.Budget{Short: window(200, 40), Long: window(2000, 500)}, want: errClaimed},
+		{name: "short window low", budget: &pkgstrava.Budget{Short: window(200, 150), Long: window(2000, 500)}},
+		{name: "daily read limit low", budget: &pkgstrava.Budget{ReadLong: window(1000, 900)}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			svc := &StravaService{
+				db:           &mockStravaDB{getErr: errClaimed},
+				stravaClient: &mockStravaClient{budget: tc.budget},
+			}
+			err := NewStravaBackfillWorker(svc, nil).processNext(context.Background())
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("expected %v, got %v", tc.want, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+// activityLapsFromStrava converts Strava's laps. LapIndex is the 0-based
+// position in the activity; Strava's own lap_index isn't always contiguous.
+func activityLapsFromStrava(activity *models.Activity, laps []strava.Lap) []models.ActivityLap {
+	out := make([]models.ActivityLap, 0, len(laps))
+	for i, lap := range laps {
+		l := models.ActivityLap{
+			ActivityID:          activity.ID,
+			LapIndex:            i,
+			DistanceMeters:      lap.Distance,
+			ElapsedSeconds:      lap.ElapsedTime,
+			MovingSeconds:       lap.MovingTime,
+			AverageSpeedMps:     positiveFloat(lap.AverageSpeed),
+			MaxSpeedMps:         positiveFloat(lap.MaxSpeed),
+			AverageHeartRate:    positiveFloat(lap.AverageHeartrate),
+			MaxHeartRate:        positiveFloat(lap.MaxHeartrate),
+			AverageCadence:      positiveFloat(lap.AverageCadence),
+			ElevationGainMeters: positiveFloat(lap.TotalElevationGain),
+			PaceZone:            positiveInt(lap.PaceZone),
+		}
+		if lap.Name != "" {
+			name := lap.Name
+			l.Name = &name
+		}
+		if start, err := time.Parse(time.RFC3339, lap.StartDate); err == nil {
+			l.StartTime = &start
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+// activitySplitsFromStrava converts one unit's splits, indexed from 0.
+func activitySplitsFromStrava(activity *models.Activity, unit string, splits []strava.Split) []models.ActivitySplit {
+	out := make([]models.ActivitySplit, 0, len(splits))
+	for i, split := range splits {
+		elevDiff := split.ElevationDifference
+		out = append(out, models.ActivitySplit{
+			ActivityID:                   activity.ID,
+			Unit:                         unit,
+			SplitIndex:                   i,
+			DistanceMeters:               split.Distance,
+			ElapsedSeconds:               split.ElapsedTime,
+			MovingSeconds:                split.MovingTime,
+			ElevationDifferenceMeters:    &elevDiff,
+			AverageSpeedMps:              positiveFloat(split.AverageSpeed),
+			AverageGradeAdjustedSpeedMps: positiveFloat(split.AverageGradeAdjustedSpeed),
+			AverageHeartRate:             positiveFloat(split.AverageHeartrate),
+			PaceZone:                     positiveInt(split.PaceZone),
+		})
+	}
+	return out
+}
+
+func positiveFloat(v float64) *float64 {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+func positiveInt(v int) *int {
+	if v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// storeStravaDetails saves the fields only the single-activity endpoint
+// returns onto Strava's copy of an activity already stored by
+// storeStravaActivity; the row takes them where the athlete's priorities
+// say so. Laps and splits are saved only when Strava owns the row.
+func (s *StravaService) storeStravaDetails(ctx context.Context, activity *models.Activity, detail *strava.DetailedActivity) error {
+	details := sync.Details{Description: detail.Description, DeviceName: detail.DeviceName}
+	if detail.Calories > 0 {
+		details.Calories = &detail.Calories
+	}
+	merged, err := sync.SaveDetails(ctx, s.db, activity.UserID, activity.ID,
+		"strava", strconv.FormatInt(detail.ID, 10), details)
+	if err != nil {
+		return err
+	}
+	if merged != nil {
+		activity.Calories = merged.Calories
+		activity.DeviceName = merged.DeviceName
+		activity.Description = merged.Description
+		activity.FieldSources = merged.FieldSources
+	}
+	if activity.Source != "strava" {
+		return nil
+	}
+
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE activities SET details_synced_at = NOW() WHERE id = $1", activity.ID); err != nil {
+		return err
+	}
+	if err := sync.SaveActivityLaps(ctx, s.db, activity.ID, activityLapsFromStrava(activity, detail.Laps)); err != nil {
+		return err
+	}
+	if err := sync.SaveActivitySplits(ctx, s.db, activity.ID, models.SplitUnitMetric,
+		activitySplitsFromStrava(activity, models.SplitUnitMetric, detail.SplitsMetric)); err != nil {
+		return err
+	}
+	return sync.SaveActivitySplits(ctx, s.db, activity.ID, models.SplitUnitStandard,
+		activitySplitsFromStrava(activity, models.SplitUnitStandard, detail.SplitsStandard))
+}
+
+// fetchStravaDetails downloads the full activity and stores its details. A
+// 404 is not an error; the webhook delete will clean the activity up.
+func (s *StravaService) fetchStravaDetails(ctx context.Context, accessToken string, activity *models.Activity) error {
+	stravaID, err := strconv.ParseInt(activity.SourceActivityID, 10, 64)
+	if err != nil {
+		return err
+	}
+	detail, err := s.stravaClient.GetActivity(ctx, accessToken, stravaID)
+	if err != nil {
+		var apiErr *strava.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return err
+	}
+	return s.storeStravaDetails(ctx, activity, detail)
+}
+
+// missingEnrichment reports which per-activity downloads a stored run still
+// needs. Non-runs get neither; their totals are all the app uses. Nor do
+// rows another source owns, which a Strava copy was merged into: their
+// source_activity_id is not a Strava ID, and the owner supplies the detail.
+func (s *StravaService) missingEnrichment(ctx context.Context, activity *models.Activity) (details, streams bool) {
+	if activity.Source != "strava" || activity.ActivityType != models.ActivityTypeRun {
+		return false, false
+	}
+	var missing struct {
+		Details bool `db:"details"`
+		Streams bool `db:"streams"`
+	}
+	if err := s.db.GetContext(ctx, &missing, `
+		SELECT details_synced_at IS NULL AS details,
+			NOT EXISTS (SELECT 1 FROM activity_streams WHERE activity_id = a.id) AS streams
+		FROM activities a WHERE a.id = $1
+	`, activity.ID); err != nil {
+		return false, false
+	}
+	return missing.Details, missing.Streams
+}
+
+// enrichStoredActivities downloads details and streams for up to limit
+// stored runs that lack them. Failures are logged; enrichment never fails
+// the sync that stored the activities, and a 429 ends the pass.
+func (s *StravaService) enrichStoredActivities(ctx context.Context, accessToken string, stored []*models.Activity, limit int) {
+	enriched := 0
+	for _, activity := range stored {
+		if enriched >= limit {
+			return
+		}
+		needDetails, needStreams := s.missingEnrichment(ctx, activity)
+		if !needDetails && !needStreams {
+			continue
+		}
+		enriched++
+
+		var err error
+		if needDetails {
+			err = s.fetchStravaDetails(ctx, accessToken, activity)
+		}
+		if err == nil && needStreams {
+			_, err = s.fetchStravaStreams(ctx, accessToken, activity)
+		}
+		if err != nil {
+			logger.FromContext(ctx).Warn("strava sync: failed to enrich activity",
+				"activity_id", activity.ID,
+				"source_activity_id", activity.SourceActivityID,
+				"error", err,
+			)
+			var apiErr *strava.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusTooManyRequests {
+				return
+			}
+		}
+	}
+}
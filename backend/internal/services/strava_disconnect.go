@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+// What DisconnectStrava does with the athlete's Strava data.
+const (
+	// StravaPurgeNone keeps every activity as it is.
+	StravaPurgeNone = "none"
+	// StravaPurgeDelete removes everything Strava supplied. Activities
+	// another source also recorded are kept and rebuilt from that source.
+	StravaPurgeDelete = "delete"
+	// StravaPurgeAnonymize keeps the training numbers but strips what ties
+	// them to the Strava account or a place: activity IDs, titles,
+	// descriptions, gear, devices and GPS tracks.
+	StravaPurgeAnonymize = "anonymize"
+)
+
+// ErrInvalidStravaPurge is returned for an unknown purge mode.
+var ErrInvalidStravaPurge = errors.New("invalid purge mode")
+
+// StravaDisconnectResult reports what a disconnect did with the data.
+type StravaDisconnectResult struct {
+	Purge      string `json:"purge"`
+	Deleted    int    `json:"deleted"`
+	Rebuilt    int    `json:"rebuilt"`
+	Anonymized int    `json:"anonymized"`
+}
+
+// DisconnectStrava revokes the app's access at Strava (best effort, so an
+// already-revoked token doesn't block the disconnect), applies purge to the
+// athlete's Strava data and removes the connection. With a purge mode it
+// also runs when the connection is already gone, so a failed purge can be
+// retried.
+func (s *StravaService) DisconnectStrava(ctx context.Context, userID uuid.UUID, purge string) (*StravaDisconnectResult, error) {
+	if purge == "" {
+		purge = StravaPurgeNone
+	}
+	if purge != StravaPurgeNone && purge != StravaPurgeDelete && purge != StravaPurgeAnonymize {
+		return nil, fmt.Errorf("%w %q", ErrInvalidStravaPurge, purge)
+	}
+
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil && (purge == StravaPurgeNone || !errors.Is(err, ErrStravaConnectionNotFound)) {
+		return nil, err
+	}
+	if conn != nil {
+		if fresh, err := s.RefreshAccessToken(ctx, conn); err == nil {
+			if err := s.stravaClient.Deauthorize(ctx, fresh.AccessToken); err != nil {
+				logger.FromContext(ctx).Warn("strava: deauthorize failed", "user_id", userID, "error", err)
+			}
+		}
+	}
+
+	// Data goes before the connection: a failed purge leaves the connection
+	// in place for the retry. The grant is already revoked, so nothing new
+	// arrives in between.
+	result, err := s.purgeStravaData(ctx, userID, purge)
+	if err != nil {
+		return nil, err
+	}
+	if conn != nil {
+		if err := disconnectIntegration(ctx, s.db, userID, "strava"); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (s *StravaService) purgeStravaData(ctx context.Context, userID uuid.UUID, purge string) (*StravaDisconnectResult, error) {
+	result := &StravaDisconnectResult{Purge: purge}
+	switch purge {
+	case StravaPurgeDelete:
+		deleted, rebuilt, err := sync.RemoveSource(ctx, s.db, userID, "strava")
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete strava data: %w", err)
+		}
+		result.Deleted, result.Rebuilt = deleted, rebuilt
+		// Anonymizing keeps every number, so only a delete changes totals.
+		if deleted+rebuilt > 0 {
+			if err := s.computeWeeklySummaries(ctx, userID); err != nil {
+				logger.FromContext(ctx).Warn("strava: weekly summaries failed after purge", "user_id", userID, "error", err)
+			}
+		}
+	case StravaPurgeAnonymize:
+		n, err := s.anonymizeStravaData(ctx, userID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to anonymize strava data: %w", err)
+		}
+		result.Anonymized = n
+	}
+	return result, nil
+}
+
+// anonymizeStravaData scrubs the athlete's Strava data in place and returns
+// how many activities it touched. Strava activity IDs are replaced with the
+// activity's own ID, and titles that came from Strava with the activity
+// type. A later reconnect matches the scrubbed rows by start time and
+// distance, so it doesn't import them twice.
+func (s *StravaService) anonymizeStravaData(ctx context.Context, userID uuid.UUID) (int, error) {
+	res, err := s.db.ExecContext(ctx, `
+		UPDATE activities SET
+			source_activity_id = CASE WHEN source = 'strava' THEN id::text ELSE source_activity_id END,
+			name = CASE WHEN COALESCE(field_sources->>'name', source) = 'strava'
+				THEN initcap(replace(activity_type, '_', ' ')) ELSE name END,
+			gear_id = CASE WHEN COALESCE(field_sources->>'gear', source) = 'strava' THEN NULL ELSE gear_id END,
+			description = CASE WHEN COALESCE(field_sources->>'description', source) = 'strava'
+				THEN NULL ELSE description END,
+			device_name = CASE WHEN COALESCE(field_sources->>'device', source) = 'strava'
+				THEN NULL ELSE device_name END
+		WHERE user_id = $1
+		  AND (source = 'strava' OR EXISTS (
+			SELECT 1 FROM jsonb_each_text(field_sources) f WHERE f.value = 'strava'
+		  ))
+	`, userID)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+
+	steps := []struct {
+		name  string
+		query string
+	}{
+		{"source records", `
+			UPDATE activity_source_records r SET
+				source_activity_id = r.activity_id::text,
+				data = (r.data - 'gear_id' - 'description' - 'device_name') || jsonb_build_object(
+					'source_id', r.activity_id::text,
+					'name', initcap(replace(r.data->>'activity_type', '_', ' '))),
+				updated_at = NOW()
+			FROM activities a
+			WHERE a.id = r.activity_id AND a.user_id = $1 AND r.source = 'strava'`},
+		{"streams", `
+			UPDATE activity_streams SET latlng = NULL, updated_at = NOW()
+			WHERE user_id = $1 AND source = 'strava' AND latlng IS NOT NULL`},
+		{"calendar", `
+			UPDATE training_calendar t SET title = a.name, updated_at = NOW()
+			FROM activities a
+			WHERE a.id = t.completed_activity_id AND t.user_id = $1 AND t.source = 'strava'`},
+		{"cross-training", `
+			UPDATE cross_training_sessions SET strava_activity_id = NULL, updated_at = NOW()
+			WHERE user_id = $1 AND strava_activity_id IS NOT NULL`},
+	}
+	for _, step := range steps {
+		if _, err := s.db.ExecContext(ctx, step.query, userID); err != nil {
+			return 0, fmt.Errorf("%s: %w", step.name, err)
+		}
+	}
+	return int(n), nil
+}
@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+// stravaPurgeTestDB holds activities and their source copies; the
+// connection itself is already gone.
+type stravaPurgeTestDB struct {
+	archiveTestDB
+	activities map[uuid.UUID]models.Activity
+	records    map[uuid.UUID][]models.ActivitySourceRecord
+}
+
+func (db *stravaPurgeTestDB) GetContext(_ context.Context, dest any, _ string, args ...any) error {
+	activity, ok := db.activities[asUUID(args)]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	switch d := dest.(type) {
+	case *models.Activity:
+		*d = activity
+		return nil
+	case *string:
+		*d = activity.Source
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (db *stravaPurgeTestDB) SelectContext(_ context.Context, dest any, _ string, args ...any) error {
+	switch d := dest.(type) {
+	case *[]uuid.UUID:
+		for id := range db.activities {
+			*d = append(*d, id)
+		}
+	case *[]models.ActivitySourceRecord:
+		*d = db.records[asUUID(args)]
+	}
+	return nil
+}
+
+func asUUID(args []any) uuid.UUID {
+	if len(args) == 0 {
+		return uuid.Nil
+	}
+	id, _ := args[0].(uuid.UUID)
+	return id
+}
+
+func TestDisconnectStravaDeletesStravaData(t *testing.T) {
+	userID, stravaOnly, shared := uuid.New(), uuid.New(), uuid.New()
+	start := time.Date(2026, 4, 2, 6, 30, 0, 0, time.UTC)
+	db := &stravaPurgeTestDB{
+		activities: map[uuid.UUID]models.Activity{
+			stravaOnly: {ID: stravaOnly, UserID: userID, Source: "strava", SourceActivityID: "s1",
+				ActivityType: models.ActivityTypeRun, StartTime: start, DistanceMeters: 8000, DurationSeconds: 2400},
+			shared: {ID: shared, UserID: userID, Source: "strava", SourceActivityID: "s2",
+				ActivityType: models.ActivityTypeRun, StartTime: start.AddDate(0, 0, 1), DistanceMeters: 12000, DurationSeconds: 3600},
+		},
+		records: map[uuid.UUID][]models.ActivitySourceRecord{},
+	}
+	db.records[shared] = []models.ActivitySourceRecord{
+		sourceRecord(t, shared, sync.RawActivity{Source: "strava", SourceID: "s2", StartTime: start.AddDate(0, 0, 1),
+			ActivityType: models.ActivityTypeRun, Name: "Long Run", Distance: 12000, Duration: 3600}),
+		sourceRecord(t, shared, sync.RawActivity{Source: "garmin", SourceID: "g2", StartTime: start.AddDate(0, 0, 1),
+			ActivityType: models.ActivityTypeRun, Name: "Running", Distance: 12040, Duration: 3605}),
+	}
+	svc := &StravaService{db: db, stravaClient: &mockStravaClient{}}
+
+	result, err := svc.DisconnectStrava(context.Background(), userID, StravaPurgeDelete)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Deleted != 1 || result.Rebuilt != 1 {
+		t.Fatalf("expected one delete and one rebuild, got %+v", result)
+	}
+
+	deletes := db.execsMatching("DELETE FROM activities")
+	if len(deletes) != 1 || deletes[0].args[0] != stravaOnly {
+		t.Fatalf("expected only the strava-only activity deleted, got %v", deletes)
+	}
+	if len(db.execsMatching("DELETE FROM personal_records")) != 1 || len(db.execsMatching("DELETE FROM training_calendar")) != 1 {
+		t.Fatal("expected derived records and calendar entries of the deleted activity to go")
+	}
+	updates := db.execsMatching("UPDATE activities SET")
+	if len(updates) != 1 || updates[0].args[0] != "garmin" || updates[0].args[1] != "g2" {
+		t.Fatalf("expected the shared activity to pass to garmin, got %v", updates)
+	}
+	if got := db.execsMatching("DELETE FROM activity_source_records"); len(got) != 1 || got[0].args[1] != "strava" {
+		t.Fatalf("expected the strava copy dropped, got %v", got)
+	}
+}
+
+func TestDisconnectStravaValidatesPurge(t *testing.T) {
+	svc := &StravaService{db: &stravaPurgeTestDB{}, stravaClient: &mockStravaClient{}}
+
+	if _, err := svc.DisconnectStrava(context.Background(), uuid.New(), "shred"); !errors.Is(err, ErrInvalidStravaPurge) {
+		t.Fatalf("expected ErrInvalidStravaPurge, got %v", err)
+	}
+	if _, err := svc.DisconnectStrava(context.Background(), uuid.New(), ""); !errors.Is(err, ErrStravaConnectionNotFound) {
+		t.Fatalf("expected a plain disconnect without a connection to fail, got %v", err)
+	}
+}
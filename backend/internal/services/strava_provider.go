@@ -0,0 +1,78 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+// StravaProvider adapts the Strava API to sync.DataProvider. Refreshes go
+// through StravaService so the provider shares its singleflight refresh
+// with the interactive sync.
+type StravaProvider struct {
+	svc *StravaService
+}
+
+var _ sync.DataProvider = (*StravaProvider)(nil)
+
+// NewStravaProvider creates a provider backed by svc.
+func NewStravaProvider(svc *StravaService) *StravaProvider {
+	return &StravaProvider{svc: svc}
+}
+
+// GetProviderName implements sync.DataProvider.
+func (p *StravaProvider) GetProviderName() string {
+	return "strava"
+}
+
+// FetchRecentActivities implements sync.DataProvider.
+func (p *StravaProvider) FetchRecentActivities(ctx context.Context, integration *models.ConnectedIntegration, since time.Time) ([]sync.RawActivity, error) {
+	return p.list(ctx, integration, strava.ActivityListParams{After: since})
+}
+
+// FetchAllActivities implements sync.DataProvider. It pages through the
+// whole history in one call; for large accounts prefer the backfill worker,
+// which spreads the same requests across Strava's rate-limit windows.
+func (p *StravaProvider) FetchAllActivities(ctx context.Context, integration *models.ConnectedIntegration) ([]sync.RawActivity, error) {
+	return p.list(ctx, integration, strava.ActivityListParams{Before: time.Now()})
+}
+
+// RefreshTokenIfNeeded implements sync.DataProvider.
+func (p *StravaProvider) RefreshTokenIfNeeded(ctx context.Context, integration *models.ConnectedIntegration) error {
+	fresh, err := p.svc.RefreshAccessToken(ctx, integration)
+	if err != nil {
+		return err
+	}
+	*integration = *fresh
+	return nil
+}
+
+func (p *StravaProvider) list(ctx context.Context, integration *models.ConnectedIntegration, params strava.ActivityListParams) ([]sync.RawActivity, error) {
+	params.PerPage = stravaBackfillPerPage
+	var out []sync.RawActivity
+	for params.Page = 1; ; params.Page++ {
+		activities, err := p.svc.stravaClient.ListActivities(ctx, integration.AccessToken, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, act := range activities {
+			raw, err := stravaRawActivity(act)
+			if errors.Is(err, errStravaActivityUnparseable) {
+				logger.FromContext(ctx).Warn("strava provider: skipping activity, bad date format",
+					"activity_id", act.ID,
+					"error", err,
+				)
+				continue
+			}
+			out = append(out, raw)
+		}
+		if len(activities) < params.PerPage {
+			return out, nil
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+	pkgstrava "github.com/korsana/backend/pkg/strava"
+)
+
+func TestStravaRawActivity(t *testing.T) {
+	race := 1
+	act := newActivity(7, time.Date(2026, time.April, 19, 13, 30, 0, 0, time.UTC))
+	act.StartDateLocal = "2026-04-19T09:30:00Z"
+	act.WorkoutType = &race
+	act.GearID = "g123"
+	act.AverageHeartrate = 171.6
+
+	raw, err := stravaRawActivity(act)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if raw.SourceID != "7" || raw.Source != "strava" {
+		t.Fatalf("unexpected source identity %q/%q", raw.Source, raw.SourceID)
+	}
+	if raw.ActivityType != models.ActivityTypeRun || raw.WorkoutType != models.WorkoutTypeRace {
+		t.Fatalf("expected a run flagged as race, got %q/%q", raw.ActivityType, raw.WorkoutType)
+	}
+	if raw.LocalDate == nil || raw.LocalDate.Format("2006-01-02") != "2026-04-19" {
+		t.Fatalf("unexpected local date %v", raw.LocalDate)
+	}
+	if raw.AvgPace != 300 {
+		t.Fatalf("expected 300 s/km, got %v", raw.AvgPace)
+	}
+	if raw.AvgHR == nil || *raw.AvgHR != 171 {
+		t.Fatalf("unexpected average HR %v", raw.AvgHR)
+	}
+
+	act.StartDate, act.StartDateLocal = "", ""
+	if _, err := stravaRawActivity(act); !errors.Is(err, errStravaActivityUnparseable) {
+		t.Fatalf("expected errStravaActivityUnparseable, got %v", err)
+	}
+}
+
+func TestStravaProviderFetchAllPagesUntilShortPage(t *testing.T) {
+	var pages []int
+	client := &mockStravaClient{
+		listActivitiesFn: func(_ context.Context, _ string, params pkgstrava.ActivityListParams) ([]pkgstrava.Activity, error) {
+			pages = append(pages, params.Page)
+			n := params.PerPage
+			if params.Page == 2 {
+				n = 3
+			}
+			out := make([]pkgstrava.Activity, n)
+			for i := range out {
+				out[i] = newActivity(int64(params.Page*1000+i), time.Now())
+			}
+			return out, nil
+		},
+	}
+	svc, _ := newConnectedStravaService(client)
+
+	raws, err := NewStravaProvider(svc).FetchAllActivities(context.Background(), &models.ConnectedIntegration{AccessToken: "access"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(pages) != 2 || len(raws) != stravaBackfillPerPage+3 {
+		t.Fatalf("expected 2 pages and %d activities, got pages %v and %d activities", stravaBackfillPerPage+3, pages, len(raws))
+	}
+}
@@ -0,0 +1,322 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+const (
+	// stravaReconcileWindow is how far back reconciliation re-reads. Edits
+	// and deletions on Strava almost always touch recent activities.
+	stravaReconcileWindow = 30 * 24 * time.Hour
+
+	// stravaReconcileDeleteMargin shields the oldest day of the window from
+	// deletion: an activity re-timed to just before the window would
+	// otherwise look deleted.
+	stravaReconcileDeleteMargin = 24 * time.Hour
+
+	stravaReconcilePerPage  = 200
+	stravaReconcileMaxPages = 3
+
+	// stravaReconcileInterval spaces scheduled reconciliations per athlete.
+	stravaReconcileInterval = 24 * time.Hour
+)
+
+// StravaReconcileResult summarizes a reconciliation pass.
+type StravaReconcileResult struct {
+	Checked int `json:"checked"`
+	Changed int `json:"changed"`
+	Added   int `json:"added"`
+	Removed int `json:"removed"`
+	// Partial is set when the window had more activities than one pass
+	// reads, or when the athlete didn't grant activity:read_all and the
+	// listing leaves out their private activities. Nothing is removed on a
+	// partial pass.
+	Partial bool `json:"partial"`
+}
+
+// stravaStoredActivity is a stored row that holds a Strava activity, either
+// as its owner or as a merged copy, with the fields Strava edits can change.
+type stravaStoredActivity struct {
+	StravaID        string     `db:"strava_id"`
+	ID              uuid.UUID  `db:"id"`
+	Source          string     `db:"source"`
+	Name            string     `db:"name"`
+	ActivityType    string     `db:"activity_type"`
+	StartTime       time.Time  `db:"start_time"`
+	LocalDate       *time.Time `db:"local_date"`
+	DistanceMeters  float64    `db:"distance_meters"`
+	DurationSeconds int        `db:"duration_seconds"`
+}
+
+// day is the calendar day the row is bucketed under in weekly summaries.
+func (a *stravaStoredActivity) day() time.Time {
+	if a.LocalDate != nil {
+		return *a.LocalDate
+	}
+	return a.StartTime
+}
+
+// loadStravaStored returns the rows holding Strava activities that match
+// filter, a condition on activities a with $2 as its argument.
+func (s *StravaService) loadStravaStored(ctx context.Context, userID uuid.UUID, filter string, arg any) ([]stravaStoredActivity, error) {
+	var rows []stravaStoredActivity
+	err := s.db.SelectContext(ctx, &rows, `
+		SELECT COALESCE(r.source_activity_id, a.source_activity_id) AS strava_id,
+			a.id, a.source, a.name, a.activity_type, a.start_time, a.local_date,
+			a.distance_meters, a.duration_seconds
+		FROM activities a
+		LEFT JOIN activity_source_records r ON r.activity_id = a.id AND r.source = 'strava'
+		WHERE a.user_id = $1
+		  AND (a.source = 'strava' OR r.activity_id IS NOT NULL)
+		  AND `+filter, userID, arg)
+	return rows, err
+}
+
+// deleteStravaActivity handles an activity deleted on Strava (or no longer
+// visible to us). Strava's copy is detached from every row holding it, which
+// deletes rows no other source recorded along with their cross-training
+// mirror, calendar entry and personal records, and the affected weeks are
+// summarized again.
+func (s *StravaService) deleteStravaActivity(ctx context.Context, userID uuid.UUID, activityID int64) error {
+	stored, err := s.loadStravaStored(ctx, userID,
+		"COALESCE(r.source_activity_id, a.source_activity_id) = $2", strconv.FormatInt(activityID, 10))
+	if err != nil {
+		return err
+	}
+	// Mirrors written before cross_training_sessions had activity_id don't
+	// cascade with the activity.
+	if _, err := s.db.ExecContext(ctx, `
+		DELETE FROM cross_training_sessions
+		WHERE user_id = $1 AND strava_activity_id = $2 AND activity_id IS NULL
+	`, userID, strconv.FormatInt(activityID, 10)); err != nil {
+		return err
+	}
+	if _, err := s.detachStrava(ctx, userID, stored); err != nil {
+		return err
+	}
+	return refreshSummaryWeeks(ctx, s.db, userID, storedDays(stored))
+}
+
+// detachStrava removes Strava's copy from each row, returning how many rows
+// were deleted outright.
+func (s *StravaService) detachStrava(ctx context.Context, userID uuid.UUID, stored []stravaStoredActivity) (int, error) {
+	deleted := 0
+	for _, row := range stored {
+		detached, err := sync.DetachSource(ctx, s.db, userID, row.ID, "strava")
+		if err != nil {
+			return deleted, err
+		}
+		if detached == sync.DetachedDeleted {
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// stravaListsPrivate reports whether the athlete granted activity:read_all,
+// so that activity listings include their private activities. Connections
+// made before scopes were recorded are assumed not to have it: until those
+// athletes reconnect, reconciliation removes nothing and deletions arrive
+// only by webhook.
+func stravaListsPrivate(conn *models.ConnectedIntegration) bool {
+	return conn.GrantedScopes != nil && strava.HasScope(*conn.GrantedScopes, strava.ScopeActivityReadAll)
+}
+
+func storedDays(stored []stravaStoredActivity) []time.Time {
+	days := make([]time.Time, 0, len(stored))
+	for i := range stored {
+		days = append(days, stored[i].day())
+	}
+	return days
+}
+
+// ReconcileActivities re-reads the athlete's last stravaReconcileWindow of
+// Strava activities and brings the stored rows in line: renamed, re-typed,
+// cropped or re-timed activities are updated, and activities no longer on
+// Strava are removed, provided the athlete granted activity:read_all.
+// Incremental sync stops at the newest stored activity, so without this
+// pass edits and deletions only arrive by webhook. Every call is recorded
+// in sync_runs.
+func (s *StravaService) ReconcileActivities(ctx context.Context, userID uuid.UUID) (_ *StravaReconcileResult, err error) {
+	run := startSyncRun(userID, "strava", models.SyncModeReconcile)
+	defer func() { run.finish(ctx, s.db, err) }()
+
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	conn, err = s.RefreshAccessToken(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Load the stored side first: anything the listing doesn't return is
+	// gone from Strava, but rows stored meanwhile by a webhook are not.
+	since := time.Now().Add(-stravaReconcileWindow)
+	stored, err := s.loadStravaStored(ctx, userID, "a.start_time >= $2", since)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]stravaStoredActivity, len(stored))
+	for _, row := range stored {
+		known[row.StravaID] = row
+	}
+
+	result := &StravaReconcileResult{Partial: true}
+	seen := make(map[string]bool)
+	var days []time.Time
+	var touched []*models.Activity
+	for page := 1; page <= stravaReconcileMaxPages; page++ {
+		activities, err := s.stravaClient.ListActivities(ctx, conn.AccessToken, strava.ActivityListParams{
+			After:   since,
+			Page:    page,
+			PerPage: stravaReconcilePerPage,
+		})
+		if err != nil {
+			return nil, err
+		}
+		run.PagesFetched++
+
+		for _, act := range activities {
+			sourceID := strconv.FormatInt(act.ID, 10)
+			seen[sourceID] = true
+			result.Checked++
+
+			upserted, err := s.storeStravaActivity(ctx, userID, act)
+			if errors.Is(err, errStravaActivityUnparseable) {
+				continue
+			}
+			run.tally(upserted, err)
+			if err != nil {
+				logger.FromContext(ctx).Error("strava reconcile: failed to upsert activity",
+					"activity_id", act.ID,
+					"error", err,
+				)
+				continue
+			}
+			current := upserted.Activity
+			if current == nil {
+				continue
+			}
+
+			prev, ok := known[sourceID]
+			if !ok {
+				result.Added++
+				days = append(days, activityDay(current))
+				touched = append(touched, current)
+				continue
+			}
+			if s.applyStravaEdit(ctx, &prev, current) {
+				result.Changed++
+				days = append(days, prev.day(), activityDay(current))
+				touched = append(touched, current)
+			}
+		}
+		if len(activities) < stravaReconcilePerPage {
+			result.Partial = false
+			break
+		}
+	}
+
+	if !stravaListsPrivate(conn) {
+		result.Partial = true
+	}
+	if result.Partial {
+		run.partial = true
+	} else {
+		cutoff := since.Add(stravaReconcileDeleteMargin)
+		var gone []stravaStoredActivity
+		for _, row := range stored {
+			if !seen[row.StravaID] && row.StartTime.After(cutoff) {
+				gone = append(gone, row)
+			}
+		}
+		if len(gone) > 0 {
+			if _, err := s.detachStrava(ctx, userID, gone); err != nil {
+				return nil, err
+			}
+			result.Removed = len(gone)
+			days = append(days, storedDays(gone)...)
+		}
+	}
+
+	s.enrichStoredActivities(ctx, conn.AccessToken, touched, stravaEnrichPerSync)
+	if err := refreshSummaryWeeks(ctx, s.db, userID, days); err != nil {
+		logger.FromContext(ctx).Warn("strava reconcile: weekly summaries failed", "error", err)
+	}
+	return result, nil
+}
+
+// applyStravaEdit compares a re-imported activity with its row as stored
+// before, reporting whether Strava's copy was edited. A cropped or re-timed
+// activity Strava owns has its details and streams marked stale so
+// enrichment downloads them again.
+func (s *StravaService) applyStravaEdit(ctx context.Context, prev *stravaStoredActivity, current *models.Activity) bool {
+	if !stravaRowChanged(prev, current) {
+		return false
+	}
+	cropped := !prev.StartTime.Equal(current.StartTime) ||
+		prev.DistanceMeters != current.DistanceMeters ||
+		prev.DurationSeconds != current.DurationSeconds
+	if cropped && current.Source == "strava" {
+		if err := s.resetStravaEnrichment(ctx, current.ID); err != nil {
+			logger.FromContext(ctx).Warn("strava: failed to reset enrichment of edited activity",
+				"activity_id", current.ID,
+				"error", err,
+			)
+		}
+	}
+	return true
+}
+
+// stravaRowChanged reports whether re-importing changed a field Strava
+// edits can touch.
+func stravaRowChanged(prev *stravaStoredActivity, current *models.Activity) bool {
+	return prev.Name != current.Name ||
+		prev.ActivityType != current.ActivityType ||
+		!prev.StartTime.Equal(current.StartTime) ||
+		prev.DistanceMeters != current.DistanceMeters ||
+		prev.DurationSeconds != current.DurationSeconds
+}
+
+func activityDay(a *models.Activity) time.Time {
+	if a.LocalDate != nil {
+		return *a.LocalDate
+	}
+	return a.StartTime
+}
+
+// resetStravaEnrichment marks a cropped activity's details and streams
+// stale so enrichment downloads them again.
+func (s *StravaService) resetStravaEnrichment(ctx context.Context, activityID uuid.UUID) error {
+	if _, err := s.db.ExecContext(ctx,
+		"UPDATE activities SET details_synced_at = NULL WHERE id = $1", activityID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, "DELETE FROM activity_streams WHERE activity_id = $1", activityID)
+	return err
+}
+
+// reconcileDue reports whether the athlete's last reconciliation is older
+// than stravaReconcileInterval.
+func (s *StravaService) reconcileDue(ctx context.Context, userID uuid.UUID) (bool, error) {
+	var due bool
+	err := s.db.GetContext(ctx, &due, `
+		SELECT NOT EXISTS (
+			SELECT 1 FROM sync_runs
+			WHERE user_id = $1 AND provider = 'strava' AND mode = $2
+			  AND started_at >= NOW() - INTERVAL '1 second' * $3
+		)
+	`, userID, models.SyncModeReconcile, stravaReconcileInterval.Seconds())
+	return due, err
+}
@@ -0,0 +1,107 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+// stravaReconcileTestDB answers the lookup of rows holding a Strava ID.
+type stravaReconcileTestDB struct {
+	stravaPurgeTestDB
+	stored []stravaStoredActivity
+}
+
+func (db *stravaReconcileTestDB) SelectContext(ctx context.Context, dest any, query string, args ...any) error {
+	if d, ok := dest.(*[]stravaStoredActivity); ok {
+		for _, row := range db.stored {
+			if row.StravaID == args[1] {
+				*d = append(*d, row)
+			}
+		}
+		return nil
+	}
+	return db.stravaPurgeTestDB.SelectContext(ctx, dest, query, args...)
+}
+
+func TestDeleteStravaActivityDetachesStravaCopies(t *testing.T) {
+	userID, stravaOnly, garminOwned := uuid.New(), uuid.New(), uuid.New()
+	start := time.Date(2026, 9, 8, 6, 30, 0, 0, time.UTC)
+	db := &stravaReconcileTestDB{stravaPurgeTestDB: stravaPurgeTestDB{
+		activities: map[uuid.UUID]models.Activity{
+			stravaOnly: {ID: stravaOnly, UserID: userID, Source: "strava", SourceActivityID: "101",
+				ActivityType: models.ActivityTypeRun, StartTime: start, DistanceMeters: 8000, DurationSeconds: 2400},
+			garminOwned: {ID: garminOwned, UserID: userID, Source: "garmin", SourceActivityID: "g2",
+				ActivityType: models.ActivityTypeRun, StartTime: start.AddDate(0, 0, -7), DistanceMeters: 12000, DurationSeconds: 3600},
+		},
+		records: map[uuid.UUID][]models.ActivitySourceRecord{},
+	}}
+	db.records[garminOwned] = []models.ActivitySourceRecord{
+		sourceRecord(t, garminOwned, sync.RawActivity{Source: "garmin", SourceID: "g2", StartTime: start.AddDate(0, 0, -7),
+			ActivityType: models.ActivityTypeRun, Name: "Running", Distance: 12000, Duration: 3600}),
+		sourceRecord(t, garminOwned, sync.RawActivity{Source: "strava", SourceID: "102", StartTime: start.AddDate(0, 0, -7),
+			ActivityType: models.ActivityTypeRun, Name: "Long Run", Distance: 12030, Duration: 3610}),
+	}
+	db.stored = []stravaStoredActivity{
+		{StravaID: "101", ID: stravaOnly, Source: "strava", StartTime: start},
+		{StravaID: "102", ID: garminOwned, Source: "garmin", StartTime: start.AddDate(0, 0, -7)},
+	}
+	svc := &StravaService{db: db, stravaClient: &mockStravaClient{}}
+
+	if err := svc.deleteStravaActivity(context.Background(), userID, 102); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.execsMatching("DELETE FROM activities"); len(got) != 0 {
+		t.Fatalf("expected the garmin-owned activity kept, got %v", got)
+	}
+	if got := db.execsMatching("DELETE FROM activity_source_records"); len(got) != 1 || got[0].args[0] != garminOwned {
+		t.Fatalf("expected only the strava copy dropped, got %v", got)
+	}
+
+	if err := svc.deleteStravaActivity(context.Background(), userID, 101); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.execsMatching("DELETE FROM activities"); len(got) != 1 || got[0].args[0] != stravaOnly {
+		t.Fatalf("expected the strava-only activity deleted, got %v", got)
+	}
+	if len(db.execsMatching("DELETE FROM personal_records")) != 1 {
+		t.Fatal("expected derived personal records deleted")
+	}
+
+	// Both weeks are summarized again and dropped if they emptied.
+	emptied := db.execsMatching("DELETE FROM weekly_summaries")
+	if len(emptied) != 2 {
+		t.Fatalf("expected each affected week rechecked, got %v", emptied)
+	}
+	if days := emptied[1].args[1].(pq.StringArray); len(days) != 1 || days[0] != "2026-09-08" {
+		t.Fatalf("expected the deleted activity's week, got %v", days)
+	}
+}
+
+func TestStravaListsPrivateNeedsActivityReadAll(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes *string
+		want   bool
+	}{
+		{name: "not recorded", want: false},
+		{name: "public activities only", scopes: ptr("read,activity:read,profile:read_all"), want: false},
+		{name: "read all", scopes: ptr("read,activity:read_all,profile:read_all"), want: true},
+		{name: "read all with spaces", scopes: ptr("read, activity:read_all"), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &models.ConnectedIntegration{GrantedScopes: tt.scopes}
+			if got := stravaListsPrivate(conn); got != tt.want {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
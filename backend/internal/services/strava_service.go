@@ -7,17 +7,20 @@ import (
 	"encoding/hex"
 	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/sync/singleflight"
 
 	"github.com/korsana/backend/internal/database"
 	"github.com/korsana/backend/internal/logger"
 	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/internal/tokencrypt"
 	"github.com/korsana/backend/pkg/strava"
 )
 
@@ -26,7 +29,6 @@ import (
 type stravaQuerier interface {
 	GetContext(ctx context.Context, dest any, query string, args ...any) error
 	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
-	NamedQueryContext(ctx context.Context, query string, arg any) (*sqlx.Rows, error)
 	SelectContext(ctx context.Context, dest any, query string, args ...any) error
 }
 
@@ -34,7 +36,12 @@ type stravaClient interface {
 	GetAuthorizationURL(state string) string
 	ExchangeToken(code string) (*strava.TokenResponse, error)
 	RefreshToken(ctx context.Context, refreshToken string) (*strava.TokenResponse, error)
+	Deauthorize(ctx context.Context, accessToken string) error
 	GetActivities(ctx context.Context, accessToken string, page int, perPage int) ([]strava.Activity, error)
+	ListActivities(ctx context.Context, accessToken string, params strava.ActivityListParams) ([]strava.Activity, error)
+	GetActivity(ctx context.Context, accessToken string, activityID int64) (*strava.DetailedActivity, error)
+	GetActivityStreams(ctx context.Context, accessToken string, activityID int64) (*strava.Streams, error)
+	RateLimitBudget(ctx context.Context) (*strava.Budget, error)
 }
 
 type stravaSyncPolicy struct {
@@ -64,6 +71,11 @@ var (
 	// ErrStravaRateLimited is returned when Strava replies with a 429.
 	ErrStravaRateLimited = errors.New("strava sync is temporarily rate limited")
 
+	// errStravaActivityUnparseable marks an activity skipped because Strava
+	// sent no parseable start date. Callers log and move on rather than
+	// counting it as a storage failure.
+	errStravaActivityUnparseable = errors.New("strava activity has no parseable start date")
+
 	// ErrStravaConnectionNotFound is returned when the user has no Strava
 	// connection on record (or the lookup failed in a way that's
 	// indistinguishable from absence).
@@ -76,29 +88,36 @@ type StravaService struct {
 	stravaClient stravaClient
 	redis        *redis.Client
 	calendarSvc  *CalendarService
+	tokenKeys    *tokencrypt.Keyring
 	refreshGroup singleflight.Group
+
+	// webhookWake nudges the webhook worker when a new event is queued so
+	// it doesn't wait for the next poll tick. Buffered with capacity 1.
+	webhookWake chan struct{}
 }
 
 // NewStravaService creates a new Strava service
-func NewStravaService(db *database.DB, client *strava.Client, redisClient *redis.Client, calendarService *CalendarService) *StravaService {
+func NewStravaService(db *database.DB, client *strava.Client, redisClient *redis.Client, calendarService *CalendarService, tokenKeys *tokencrypt.Keyring) *StravaService {
 	return &StravaService{
 		db:           db,
 		stravaClient: client,
 		redis:        redisClient,
 		calendarSvc:  calendarService,
+		tokenKeys:    tokenKeys,
+		webhookWake:  make(chan struct{}, 1),
 	}
 }
 
 func syncPolicyForHistory(hasExistingActivities bool) stravaSyncPolicy {
 	if hasExistingActivities {
 		return stravaSyncPolicy{
-			Mode:     "incremental",
+			Mode:     models.SyncModeIncremental,
 			MaxPages: stravaRecurringSyncMaxPages,
 			PerPage:  stravaSyncPerPage,
 		}
 	}
 	return stravaSyncPolicy{
-		Mode:     "initial_backfill",
+		Mode:     models.SyncModeInitialBackfill,
 		MaxPages: stravaInitialSyncMaxPages,
 		PerPage:  stravaSyncPerPage,
 	}
@@ -196,19 +215,10 @@ func (s *StravaService) LoginWithStrava(ctx context.Context, code string) (*mode
 	athleteID := tokenResp.Athlete.ID
 
 	// Check if this Strava account is already connected to a user.
-	var conn models.StravaConnection
-	err = s.db.GetContext(ctx, &conn,
-		"SELECT * FROM strava_connections WHERE strava_athlete_id = $1", athleteID)
+	conn, err := s.getConnectionByAthleteID(ctx, athleteID)
 	if err == nil {
 		// Existing user — update tokens and return.
-		updateQuery := `
-			UPDATE strava_connections
-			SET access_token = $1, refresh_token = $2, token_expires_at = $3, updated_at = NOW()
-			WHERE strava_athlete_id = $4
-		`
-		expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
-		if _, execErr := s.db.ExecContext(ctx, updateQuery,
-			tokenResp.AccessToken, tokenResp.RefreshToken, expiresAt, athleteID); execErr != nil {
+		if execErr := updateIntegrationTokens(ctx, s.db, s.tokenKeys, conn.ID, stravaTokens(tokenResp)); execErr != nil {
 			return nil, false, execErr
 		}
 
@@ -239,74 +249,90 @@ func (s *StravaService) GetAuthURL(ctx context.Context, userID uuid.UUID, return
 // to a different Korsana account.
 var ErrStravaAlreadyConnected = errors.New("strava account already connected to another user")
 
-// HandleCallback processes the OAuth callback and saves the connection.
+// HandleCallback processes the OAuth callback and saves the connection with
+// the scopes the athlete granted, as Strava reports them in the callback.
 // Returns ErrStravaAlreadyConnected if the athlete is already linked to a different user.
-func (s *StravaService) HandleCallback(ctx context.Context, userID uuid.UUID, code string) error {
+func (s *StravaService) HandleCallback(ctx context.Context, userID uuid.UUID, code, scope string) error {
 	// 1. Exchange code for token
 	tokenResp, err := s.stravaClient.ExchangeToken(code)
 	if err != nil {
 		return err
 	}
 
+	athleteID := strconv.FormatInt(tokenResp.Athlete.ID, 10)
+
 	// 2. Check if this athlete is already connected to a different account.
 	var existingUserID uuid.UUID
 	lookupErr := s.db.GetContext(ctx, &existingUserID,
-		"SELECT user_id FROM strava_connections WHERE strava_athlete_id = $1",
-		tokenResp.Athlete.ID,
+		"SELECT user_id FROM connected_integrations WHERE source = 'strava' AND external_user_id = $1",
+		athleteID,
 	)
 	if lookupErr == nil && existingUserID != userID {
 		return ErrStravaAlreadyConnected
 	}
 
-	// 3. Insert or refresh tokens (same user reconnecting is fine).
-	query := `
-		INSERT INTO strava_connections (
-			user_id, strava_athlete_id, access_token, refresh_token, token_expires_at, updated_at
-		) VALUES ($1, $2, $3, $4, $5, NOW())
-		ON CONFLICT (strava_athlete_id)
-		DO UPDATE SET
-			access_token = EXCLUDED.access_token,
-			refresh_token = EXCLUDED.refresh_token,
-			token_expires_at = EXCLUDED.token_expires_at,
-			updated_at = NOW()
-	`
-
-	expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
-	_, err = s.db.ExecContext(ctx, query,
-		userID,
-		tokenResp.Athlete.ID,
-		tokenResp.AccessToken,
-		tokenResp.RefreshToken,
-		expiresAt,
-	)
+	// 3. Insert or refresh tokens (same user reconnecting is fine). Strava
+	// outranks every other source, so it becomes the primary integration.
+	tokens := stravaTokens(tokenResp)
+	tokens.Scopes = &scope
+	if _, err := connectIntegration(ctx, s.db, s.tokenKeys, userID, "strava", athleteID, tokens); err != nil {
+		return err
+	}
 
+	// 4. Queue the full-history import. A job that failed earlier (typically
+	// because the athlete had revoked access) starts over on reconnect.
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO strava_backfill_jobs (connection_id, user_id, cursor_before)
+		SELECT id, user_id, NOW() FROM connected_integrations
+		WHERE source = 'strava' AND external_user_id = $1
+		ON CONFLICT (connection_id) DO UPDATE SET `+restartBackfillSet+`
+		WHERE strava_backfill_jobs.status = 'failed'
+	`, athleteID)
 	return err
 }
 
-// GetConnection retrieves the Strava connection for a user
-func (s *StravaService) GetConnection(ctx context.Context, userID uuid.UUID) (*models.StravaConnection, error) {
-	var conn models.StravaConnection
-	err := s.db.GetContext(ctx, &conn, "SELECT * FROM strava_connections WHERE user_id = $1", userID)
-	if err != nil {
-		return nil, ErrStravaConnectionNotFound
+// stravaTokens converts a Strava token response for the integration store.
+func stravaTokens(resp *strava.TokenResponse) integrationTokens {
+	refreshToken := resp.RefreshToken
+	expiresAt := time.Unix(resp.ExpiresAt, 0)
+	return integrationTokens{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: &refreshToken,
+		ExpiresAt:    &expiresAt,
 	}
-	return &conn, nil
 }
 
-func (s *StravaService) getConnectionByID(ctx context.Context, connectionID uuid.UUID) (*models.StravaConnection, error) {
-	var conn models.StravaConnection
-	err := s.db.GetContext(ctx, &conn, "SELECT * FROM strava_connections WHERE id = $1", connectionID)
+// StravaAthleteID returns the athlete ID stored on a Strava integration.
+func StravaAthleteID(conn *models.ConnectedIntegration) int64 {
+	if conn.ExternalUserID == nil {
+		return 0
+	}
+	id, _ := strconv.ParseInt(*conn.ExternalUserID, 10, 64)
+	return id
+}
+
+// GetConnection retrieves the Strava integration for a user
+func (s *StravaService) GetConnection(ctx context.Context, userID uuid.UUID) (*models.ConnectedIntegration, error) {
+	conn, err := getIntegration(ctx, s.db, s.tokenKeys, userID, "strava")
 	if err != nil {
-		return nil, err
+		return nil, ErrStravaConnectionNotFound
 	}
-	return &conn, nil
+	return conn, nil
+}
+
+func (s *StravaService) getConnectionByID(ctx context.Context, connectionID uuid.UUID) (*models.ConnectedIntegration, error) {
+	return getIntegrationByID(ctx, s.db, s.tokenKeys, connectionID)
+}
+
+func (s *StravaService) getConnectionByAthleteID(ctx context.Context, athleteID int64) (*models.ConnectedIntegration, error) {
+	return getIntegrationByExternalID(ctx, s.db, s.tokenKeys, "strava", strconv.FormatInt(athleteID, 10))
 }
 
-// RefreshAccessToken refreshes the Strava access token if expired
-func (s *StravaService) RefreshAccessToken(ctx context.Context, conn *models.StravaConnection) (*models.StravaConnection, error) {
-	// Check if token is expired or about to expire (within 5 minutes)
-	if time.Now().Before(conn.TokenExpiresAt.Add(-5 * time.Minute)) {
-		// Token still valid
+// RefreshAccessToken refreshes the Strava access token if expired. When
+// Strava rejects the refresh token the integration is flagged
+// reauth_required so the settings page can ask the athlete to reconnect.
+func (s *StravaService) RefreshAccessToken(ctx context.Context, conn *models.ConnectedIntegration) (*models.ConnectedIntegration, error) {
+	if integrationTokenFresh(conn) {
 		return conn, nil
 	}
 
@@ -315,36 +341,46 @@ func (s *StravaService) RefreshAccessToken(ctx context.Context, conn *models.Str
 		if loadErr == nil {
 			conn = latest
 		}
-		if time.Now().Before(conn.TokenExpiresAt.Add(-5 * time.Minute)) {
+		if integrationTokenFresh(conn) {
 			return conn, nil
 		}
+		if conn.RefreshToken == nil {
+			return nil, errors.New("strava integration has no refresh token")
+		}
 
-		tokenResp, refreshErr := s.stravaClient.RefreshToken(ctx, conn.RefreshToken)
+		tokenResp, refreshErr := s.stravaClient.RefreshToken(ctx, *conn.RefreshToken)
 		if refreshErr != nil {
+			var apiErr *strava.APIError
+			if errors.As(refreshErr, &apiErr) && (apiErr.StatusCode == 400 || apiErr.StatusCode == 401) {
+				if markErr := markIntegrationReauthRequired(ctx, s.db, conn.ID, refreshErr); markErr != nil {
+					logger.FromContext(ctx).Warn("strava: failed to flag integration for reauth",
+						"integration_id", conn.ID,
+						"error", markErr,
+					)
+				}
+				return nil, fmt.Errorf("%w: %w", ErrIntegrationReauthRequired, refreshErr)
+			}
 			return nil, refreshErr
 		}
 
-		query := `
-			UPDATE strava_connections
-			SET access_token = $1, refresh_token = $2, token_expires_at = $3, updated_at = NOW()
-			WHERE id = $4
-		`
-		expiresAt := time.Unix(tokenResp.ExpiresAt, 0)
-		if _, execErr := s.db.ExecContext(ctx, query, tokenResp.AccessToken, tokenResp.RefreshToken, expiresAt, conn.ID); execErr != nil {
+		tokens := stravaTokens(tokenResp)
+		if execErr := updateIntegrationTokens(ctx, s.db, s.tokenKeys, conn.ID, tokens); execErr != nil {
 			return nil, execErr
 		}
 
 		updated := *conn
-		updated.AccessToken = tokenResp.AccessToken
-		updated.RefreshToken = tokenResp.RefreshToken
-		updated.TokenExpiresAt = expiresAt
+		updated.AccessToken = tokens.AccessToken
+		updated.RefreshToken = tokens.RefreshToken
+		updated.TokenExpiresAt = tokens.ExpiresAt
+		updated.Status = models.IntegrationStatusActive
+		updated.LastError = nil
 		updated.UpdatedAt = time.Now()
 		return &updated, nil
 	})
 	if err != nil {
 		return nil, err
 	}
-	return result.(*models.StravaConnection), nil
+	return result.(*models.ConnectedIntegration), nil
 }
 
 // mapStravaType converts Strava activity types to internal types
@@ -449,7 +485,9 @@ func (s *StravaService) fetchActivitiesPageWithRetry(ctx context.Context, access
 		}
 
 		lastErr = err
-		if attempt == stravaRateLimitMaxRetries {
+		// A wait longer than the backoff cap (e.g. the governor's window
+		// reset) won't be over by the next attempt.
+		if attempt == stravaRateLimitMaxRetries || apiErr.RetryAfter > stravaRateLimitMaxBackoff {
 			break
 		}
 
@@ -538,9 +576,9 @@ func buildStravaSyncResult(count int, partial bool, policy stravaSyncPolicy, pag
 	}
 
 	switch {
-	case partial && count > 0 && policy.Mode == "initial_backfill":
+	case partial && count > 0 && policy.Mode == models.SyncModeInitialBackfill:
 		result.Status = "partial"
-		result.Message = fmt.Sprintf("Synced %d recent Strava activit%s. Older backlog will continue on later syncs.", count, pluralSuffix(count))
+		result.Message = fmt.Sprintf("Synced %d recent Strava activit%s. Older history is importing in the background.", count, pluralSuffix(count))
 	case partial && count > 0:
 		result.Status = "partial"
 		result.Message = fmt.Sprintf("Synced %d Strava activit%s before hitting a temporary API limit.", count, pluralSuffix(count))
@@ -555,8 +593,30 @@ func buildStravaSyncResult(count int, partial bool, policy stravaSyncPolicy, pag
 	return result
 }
 
-// SyncActivities fetches and stores recent activities from Strava using a bounded sync policy.
-func (s *StravaService) SyncActivities(ctx context.Context, userID uuid.UUID) (*StravaSyncResult, error) {
+// RateLimitBudget returns Strava's app-wide rate-limit standing as last
+// reported by the API, or nil when it isn't tracked.
+func (s *StravaService) RateLimitBudget(ctx context.Context) (*strava.Budget, error) {
+	return s.stravaClient.RateLimitBudget(ctx)
+}
+
+// allowsBackgroundWork reports whether the rate-limit budget has room for
+// deferrable requests. An untracked or unreadable budget doesn't hold work
+// back; Strava's own 429s still do.
+func (s *StravaService) allowsBackgroundWork(ctx context.Context) bool {
+	budget, err := s.stravaClient.RateLimitBudget(ctx)
+	if err != nil {
+		logger.FromContext(ctx).Warn("strava: failed to read rate-limit budget", "error", err)
+		return true
+	}
+	return budget == nil || budget.AllowBackground()
+}
+
+// SyncActivities fetches and stores recent activities from Strava using a
+// bounded sync policy. Every call is recorded in sync_runs.
+func (s *StravaService) SyncActivities(ctx context.Context, userID uuid.UUID) (_ *StravaSyncResult, err error) {
+	run := startSyncRun(userID, "strava", models.SyncModeIncremental)
+	defer func() { run.finish(ctx, s.db, err) }()
+
 	// Get connection
 	conn, err := s.GetConnection(ctx, userID)
 	if err != nil {
@@ -574,186 +634,52 @@ func (s *StravaService) SyncActivities(ctx context.Context, userID uuid.UUID) (*
 		return nil, err
 	}
 	policy := syncPolicyForHistory(latestSyncedAt != nil)
+	run.Mode = policy.Mode
 	activities, partial, pagesFetched, err := s.collectActivitiesForSync(ctx, conn.AccessToken, latestSyncedAt, policy)
+	run.PagesFetched, run.partial = pagesFetched, partial
 	if err != nil {
 		return nil, err
 	}
 
 	syncedCount := 0
-	insertFailCount := 0
+	var lastInsertErr error
+	stored := make([]*models.Activity, 0, len(activities))
 
 	for _, act := range activities {
-		startTime, err := parseStravaActivityTime(act)
-		if err != nil {
-			logger.FromContext(ctx).Warn("strava sync: skipping activity, bad date format",
-				"activity_id", act.ID,
-				"activity_name", act.Name,
-				"error", err,
-			)
-			continue
-		}
-
-		// local_date is the athlete's calendar bucket; downstream queries
-		// (calendar, weekly summaries) prefer it over start_time::date.
-		var localDatePtr *time.Time
-		if ld, ldErr := localDateFromStrava(act); ldErr == nil {
-			localDatePtr = &ld
-		} else {
-			logger.FromContext(ctx).Warn("strava sync: missing local date",
-				"activity_id", act.ID,
-				"error", ldErr,
-			)
-		}
-
-		internalType := mapStravaType(act.Type, act.SportType)
-
-		var avgPace float64
-		if models.DistanceBasedTypes[internalType] && act.Distance > 0 {
-			distanceKm := act.Distance / 1000.0
-			avgPace = float64(act.MovingTime) / distanceKm
-		}
-
-		var avgHR *int
-		if act.AverageHeartrate > 0 {
-			hr := int(act.AverageHeartrate)
-			avgHR = &hr
-		}
-
-		var elevGain *float64
-		if act.TotalElevationGain > 0 {
-			elevGain = &act.TotalElevationGain
-		}
-
-		var maxHR *int
-		if act.MaxHeartrate > 0 {
-			mhr := int(act.MaxHeartrate)
-			maxHR = &mhr
-		}
-
-		var cadence *float64
-		if act.AverageCadence > 0 {
-			cadence = &act.AverageCadence
-		}
-
-		var sufferScore *int
-		if act.SufferScore > 0 {
-			ss := act.SufferScore
-			sufferScore = &ss
-		}
-
-		activity := &models.Activity{
-			ID:                      uuid.New(),
-			UserID:                  userID,
-			Source:                  "strava",
-			SourceActivityID:        fmt.Sprintf("%d", act.ID),
-			ActivityType:            internalType,
-			Name:                    act.Name,
-			DistanceMeters:          act.Distance,
-			DurationSeconds:         act.MovingTime,
-			StartTime:               startTime,
-			LocalDate:               localDatePtr,
-			AveragePaceSecondsPerKm: avgPace,
-			AverageHeartRate:        avgHR,
-			MaxHeartRate:            maxHR,
-			ElevationGainMeters:     elevGain,
-			AverageCadence:          cadence,
-			SufferScore:             sufferScore,
-			SyncedAt:                time.Now(),
-		}
-
-		query := `
-			INSERT INTO activities (
-				id, user_id, source, source_activity_id, activity_type, name,
-				distance_meters, duration_seconds, start_time, local_date, average_pace_seconds_per_km,
-				average_heart_rate, max_heart_rate, elevation_gain_meters,
-				average_cadence, suffer_score, synced_at
-			) VALUES (
-				:id, :user_id, :source, :source_activity_id, :activity_type, :name,
-				:distance_meters, :duration_seconds, :start_time, :local_date, :average_pace_seconds_per_km,
-				:average_heart_rate, :max_heart_rate, :elevation_gain_meters,
-				:average_cadence, :suffer_score, :synced_at
-			)
-			ON CONFLICT (user_id, source, source_activity_id) DO UPDATE SET
-				name = EXCLUDED.name,
-				activity_type = EXCLUDED.activity_type,
-				distance_meters = EXCLUDED.distance_meters,
-				duration_seconds = EXCLUDED.duration_seconds,
-				start_time = EXCLUDED.start_time,
-				local_date = EXCLUDED.local_date,
-				average_pace_seconds_per_km = EXCLUDED.average_pace_seconds_per_km,
-				average_heart_rate = EXCLUDED.average_heart_rate,
-				max_heart_rate = EXCLUDED.max_heart_rate,
-				elevation_gain_meters = EXCLUDED.elevation_gain_meters,
-				average_cadence = EXCLUDED.average_cadence,
-				suffer_score = EXCLUDED.suffer_score,
-				synced_at = EXCLUDED.synced_at
-		`
-
-		// RETURNING id captures the real stored ID in one round-trip.
-		// ON CONFLICT DO UPDATE keeps the original row, so this avoids a
-		// separate SELECT that would otherwise double the DB calls per activity.
-		rows, err := s.db.NamedQueryContext(ctx, query+" RETURNING id", activity)
+		result, err := s.storeStravaActivity(ctx, userID, act)
 		if err != nil {
+			if errors.Is(err, errStravaActivityUnparseable) {
+				logger.FromContext(ctx).Warn("strava sync: skipping activity, bad date format",
+					"activity_id", act.ID,
+					"activity_name", act.Name,
+					"error", err,
+				)
+				run.Skipped++
+				continue
+			}
 			logger.FromContext(ctx).Error("strava sync: failed to upsert activity",
 				"activity_id", act.ID,
 				"activity_name", act.Name,
 				"error", err,
 			)
-			insertFailCount++
+			run.tally(nil, err)
+			lastInsertErr = err
 			continue
 		}
-		if rows.Next() {
-			var storedID uuid.UUID
-			if scanErr := rows.Scan(&storedID); scanErr == nil {
-				activity.ID = storedID
-			}
-		}
-		rows.Close()
-
-		if s.calendarSvc != nil {
-			_ = s.calendarSvc.AutoMatchActivity(ctx, userID, activity)
-		}
-
-		// Mirror non-run Strava activities into cross_training_sessions so the
-		// widget shows them without requiring manual entry. Uses strava_activity_id
-		// as the conflict key to make re-syncs idempotent.
-		if ctType := crossTrainingType(internalType); ctType != "" {
-			durationMins := act.MovingTime / 60
-			if durationMins < 1 {
-				durationMins = 1
-			}
-			stravaIDStr := fmt.Sprintf("%d", act.ID)
-			var distPtr *float64
-			if act.Distance > 0 {
-				distPtr = &act.Distance
-			}
-			// Bucket by the athlete's local calendar date so cross-training
-			// rows line up with the user's run history regardless of UTC offset.
-			ctDate := startTime.UTC().Truncate(24 * time.Hour)
-			if localDatePtr != nil {
-				ctDate = *localDatePtr
-			}
-			_, _ = s.db.ExecContext(ctx, `
-				INSERT INTO cross_training_sessions
-					(id, user_id, type, date, duration_minutes, distance_meters, source, strava_activity_id)
-				VALUES ($1, $2, $3, $4, $5, $6, 'strava', $7)
-				ON CONFLICT (strava_activity_id) DO UPDATE SET
-					type = EXCLUDED.type,
-					date = EXCLUDED.date,
-					duration_minutes = EXCLUDED.duration_minutes,
-					distance_meters = EXCLUDED.distance_meters
-			`, uuid.New(), userID, ctType, ctDate, durationMins, distPtr, stravaIDStr)
+		run.tally(result, nil)
+		if result.Activity == nil {
+			continue
 		}
-
 		syncedCount++
+		stored = append(stored, result.Activity)
 	}
 
 	// If every activity failed to insert, surface the error so the caller
 	// does not silently report zero synced when the real issue is a DB problem.
-	if insertFailCount > 0 && syncedCount == 0 && len(activities) > 0 {
+	if run.Failed > 0 && syncedCount == 0 && len(activities) > 0 {
 		return nil, fmt.Errorf(
-			"all %d activities failed to save - check server logs for details (hint: ensure migration 005 has been applied to your database)",
-			insertFailCount,
+			"all %d activities failed to save - check server logs for details (hint: ensure migration 005 has been applied to your database): %w",
+			run.Failed, lastInsertErr,
 		)
 	}
 
@@ -762,50 +688,172 @@ func (s *StravaService) SyncActivities(ctx context.Context, userID uuid.UUID) (*
 		_ = s.computeWeeklySummaries(ctx, userID)
 	}
 
+	s.enrichStoredActivities(ctx, conn.AccessToken, stored, stravaEnrichPerSync)
+
+	if err := markIntegrationSynced(ctx, s.db, conn.ID); err != nil {
+		logger.FromContext(ctx).Warn("strava sync: failed to record sync time", "error", err)
+	}
+
 	result := buildStravaSyncResult(syncedCount, partial, policy, pagesFetched)
 	return &result, nil
 }
 
-// computeWeeklySummaries aggregates activity data into weekly_summaries.
-// Buckets by local_date so totals match the calendar week as the athlete
-// lived it. Legacy rows without local_date fall back to start_time::date.
-func (s *StravaService) computeWeeklySummaries(ctx context.Context, userID uuid.UUID) error {
-	query := `
-		WITH bucketed AS (
-			SELECT
-				user_id,
-				distance_meters,
-				duration_seconds,
-				date_trunc('week', COALESCE(local_date, start_time::date))::date AS week_start
-			FROM activities
-			WHERE user_id = $1
-				AND COALESCE(local_date, start_time::date) >= (CURRENT_DATE - INTERVAL '8 weeks')
+// stravaRawActivity normalizes a Strava summary activity for
+// sync.UpsertActivity. Returns errStravaActivityUnparseable when the activity
+// has no usable start date; a missing local date only leaves LocalDate nil.
+func stravaRawActivity(act strava.Activity) (sync.RawActivity, error) {
+	startTime, err := parseStravaActivityTime(act)
+	if err != nil {
+		return sync.RawActivity{}, fmt.Errorf("%w: %v", errStravaActivityUnparseable, err)
+	}
+
+	internalType := mapStravaType(act.Type, act.SportType)
+	raw := sync.RawActivity{
+		SourceID:     strconv.FormatInt(act.ID, 10),
+		Source:       "strava",
+		StartTime:    startTime,
+		Duration:     act.MovingTime,
+		Distance:     act.Distance,
+		ActivityType: internalType,
+		WorkoutType:  strava.WorkoutTypeLabel(act.WorkoutType),
+		GearID:       act.GearID,
+		Name:         act.Name,
+	}
+
+	// local_date is the athlete's calendar bucket; downstream queries
+	// (calendar, weekly summaries) prefer it over start_time::date.
+	if ld, ldErr := localDateFromStrava(act); ldErr == nil {
+		raw.LocalDate = &ld
+	}
+
+	if models.DistanceBasedTypes[internalType] && act.Distance > 0 {
+		distanceKm := act.Distance / 1000.0
+		raw.AvgPace = float64(act.MovingTime) / distanceKm
+	}
+	if act.AverageHeartrate > 0 {
+		hr := int(act.AverageHeartrate)
+		raw.AvgHR = &hr
+	}
+	if act.MaxHeartrate > 0 {
+		mhr := int(act.MaxHeartrate)
+		raw.MaxHR = &mhr
+	}
+	if act.TotalElevationGain > 0 {
+		elev := act.TotalElevationGain
+		raw.ElevGain = &elev
+	}
+	if act.AverageCadence > 0 {
+		cadence := act.AverageCadence
+		raw.AvgCadence = &cadence
+	}
+	if act.SufferScore > 0 {
+		ss := act.SufferScore
+		raw.SufferScore = &ss
+	}
+	return raw, nil
+}
+
+// storeStravaActivity stores one Strava activity through sync.UpsertActivity,
+// which also matches it against the training calendar and mirrors non-run
+// types into cross_training_sessions. Shared by the bulk sync, backfill and
+// webhook worker so all paths store activities identically. The result's
+// Activity is nil when another copy of it already exists. Returns
+// errStravaActivityUnparseable when the activity has no usable start date.
+func (s *StravaService) storeStravaActivity(ctx context.Context, userID uuid.UUID, act strava.Activity) (*sync.UpsertResult, error) {
+	raw, err := stravaRawActivity(act)
+	if err != nil {
+		return nil, err
+	}
+	if raw.LocalDate == nil {
+		logger.FromContext(ctx).Warn("strava sync: missing local date",
+			"activity_id", act.ID,
 		)
-		INSERT INTO weekly_summaries (id, user_id, week_start, total_distance_meters, total_duration_seconds, run_count, average_pace_seconds_per_km, longest_run_meters, updated_at)
+	}
+
+	return sync.UpsertActivity(ctx, s.db, asCalendarMatcher(s.calendarSvc), userID, raw)
+}
+
+func (s *StravaService) computeWeeklySummaries(ctx context.Context, userID uuid.UUID) error {
+	return refreshWeeklySummaries(ctx, s.db, userID)
+}
+
+// weeklySummaryUpsert aggregates activity data into weekly_summaries. The
+// %s placeholder filters the bucketed activities; $1 is the user.
+const weeklySummaryUpsert = `
+	WITH bucketed AS (
 		SELECT
-			gen_random_uuid(),
 			user_id,
-			week_start,
-			SUM(distance_meters) AS total_distance_meters,
-			SUM(duration_seconds) AS total_duration_seconds,
-			COUNT(*) AS run_count,
-			CASE WHEN SUM(distance_meters) > 0
-				THEN SUM(duration_seconds) / (SUM(distance_meters) / 1000.0)
-				ELSE 0
-			END AS average_pace_seconds_per_km,
-			MAX(distance_meters) AS longest_run_meters,
-			NOW()
-		FROM bucketed
-		GROUP BY user_id, week_start
-		ON CONFLICT (user_id, week_start) DO UPDATE SET
-			total_distance_meters = EXCLUDED.total_distance_meters,
-			total_duration_seconds = EXCLUDED.total_duration_seconds,
-			run_count = EXCLUDED.run_count,
-			average_pace_seconds_per_km = EXCLUDED.average_pace_seconds_per_km,
-			longest_run_meters = EXCLUDED.longest_run_meters,
-			updated_at = NOW()
-	`
-	_, err := s.db.ExecContext(ctx, query, userID)
+			distance_meters,
+			duration_seconds,
+			date_trunc('week', COALESCE(local_date, start_time::date))::date AS week_start,
+			COALESCE(local_date, start_time::date) AS activity_day
+		FROM activities
+		WHERE user_id = $1
+			AND merged_into IS NULL
+	)
+	INSERT INTO weekly_summaries (id, user_id, week_start, total_distance_meters, total_duration_seconds, run_count, average_pace_seconds_per_km, longest_run_meters, updated_at)
+	SELECT
+		gen_random_uuid(),
+		user_id,
+		week_start,
+		SUM(distance_meters) AS total_distance_meters,
+		SUM(duration_seconds) AS total_duration_seconds,
+		COUNT(*) AS run_count,
+		CASE WHEN SUM(distance_meters) > 0
+			THEN SUM(duration_seconds) / (SUM(distance_meters) / 1000.0)
+			ELSE 0
+		END AS average_pace_seconds_per_km,
+		MAX(distance_meters) AS longest_run_meters,
+		NOW()
+	FROM bucketed
+	WHERE %s
+	GROUP BY user_id, week_start
+	ON CONFLICT (user_id, week_start) DO UPDATE SET
+		total_distance_meters = EXCLUDED.total_distance_meters,
+		total_duration_seconds = EXCLUDED.total_duration_seconds,
+		run_count = EXCLUDED.run_count,
+		average_pace_seconds_per_km = EXCLUDED.average_pace_seconds_per_km,
+		longest_run_meters = EXCLUDED.longest_run_meters,
+		updated_at = NOW()
+`
+
+// refreshWeeklySummaries aggregates the last eight weeks of activity data
+// into weekly_summaries. Buckets by local_date so totals match the calendar
+// week as the athlete lived it. Legacy rows without local_date fall back to
+// start_time::date.
+func refreshWeeklySummaries(ctx context.Context, db sync.Querier, userID uuid.UUID) error {
+	query := fmt.Sprintf(weeklySummaryUpsert, "activity_day >= (CURRENT_DATE - INTERVAL '8 weeks')")
+	_, err := db.ExecContext(ctx, query, userID)
+	return err
+}
+
+// refreshSummaryWeeks rebuilds the summaries of the weeks containing days,
+// at any age, and drops those left without activities. Edits and deletions
+// need it: refreshWeeklySummaries only upserts recent weeks that still
+// have activities.
+func refreshSummaryWeeks(ctx context.Context, db sync.Querier, userID uuid.UUID, days []time.Time) error {
+	if len(days) == 0 {
+		return nil
+	}
+	dates := make(pq.StringArray, len(days))
+	for i, day := range days {
+		dates[i] = day.Format("2006-01-02")
+	}
+
+	weeks := "week_start IN (SELECT date_trunc('week', d)::date FROM unnest($2::date[]) d)"
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(weeklySummaryUpsert, weeks), userID, dates); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		DELETE FROM weekly_summaries ws
+		WHERE ws.user_id = $1
+		  AND ws.`+weeks+`
+		  AND NOT EXISTS (
+			SELECT 1 FROM activities a
+			WHERE a.user_id = ws.user_id AND a.merged_into IS NULL
+			  AND date_trunc('week', COALESCE(a.local_date, a.start_time::date))::date = ws.week_start
+		  )
+	`, userID, dates)
 	return err
 }
 
@@ -816,7 +864,7 @@ func (s *StravaService) GetUserActivities(ctx context.Context, userID uuid.UUID,
 	}
 
 	var total int
-	err := s.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM activities WHERE user_id = $1", userID)
+	err := s.db.GetContext(ctx, &total, "SELECT COUNT(*) FROM activities WHERE user_id = $1 AND merged_into IS NULL", userID)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -824,7 +872,7 @@ func (s *StravaService) GetUserActivities(ctx context.Context, userID uuid.UUID,
 	var activities []models.Activity
 	query := `
 		SELECT * FROM activities
-		WHERE user_id = $1
+		WHERE user_id = $1 AND merged_into IS NULL
 		ORDER BY start_time DESC
 		LIMIT $2 OFFSET $3
 	`
@@ -835,32 +883,3 @@ func (s *StravaService) GetUserActivities(ctx context.Context, userID uuid.UUID,
 
 	return activities, total, nil
 }
-
-// DisconnectStrava removes the user's Strava connection
-func (s *StravaService) DisconnectStrava(ctx context.Context, userID uuid.UUID) error {
-	_, err := s.db.ExecContext(ctx, "DELETE FROM strava_connections WHERE user_id = $1", userID)
-	return err
-}
-
-// crossTrainingType maps an internal activity type to the cross_training_sessions
-// type string. Returns "" for run-type activities which should not be mirrored.
-func crossTrainingType(activityType string) string {
-	switch activityType {
-	case models.ActivityTypeCycling:
-		return "cycling"
-	case models.ActivityTypeSwimming:
-		return "swimming"
-	case models.ActivityTypeWeightLifting:
-		return "weight_lifting"
-	case models.ActivityTypeElliptical:
-		return "elliptical"
-	case models.ActivityTypeRowing:
-		return "rowing"
-	case models.ActivityTypeWalking:
-		return "walking"
-	case models.ActivityTypeHiking:
-		return "hiking"
-	default:
-		return ""
-	}
-}
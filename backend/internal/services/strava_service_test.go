@@ -14,7 +14,6 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 	"github.com/korsana/backend/internal/models"
 	pkgstrava "github.com/korsana/backend/pkg/strava"
 )
@@ -25,7 +24,7 @@ type mockStravaDB struct {
 	existingUserID uuid.UUID
 	getErr         error
 	execErr        error
-	connections    map[uuid.UUID]*models.StravaConnection
+	connections    map[uuid.UUID]*models.ConnectedIntegration
 	execCount      atomic.Int32
 }
 
@@ -41,22 +40,57 @@ func (m *mockStravaDB) GetContext(_ context.Context, dest any, _ string, args ..
 		}
 		*target = m.existingUserID
 		return nil
-	case *models.StravaConnection:
+	case *models.ConnectedIntegration:
 		if len(args) == 0 {
 			return sql.ErrNoRows
 		}
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		// connectIntegration upsert: (user_id, source, access, refresh, expires, external_user_id, scopes).
+		if len(args) == 7 {
+			conn := &models.ConnectedIntegration{
+				ID:             uuid.New(),
+				UserID:         args[0].(uuid.UUID),
+				Source:         args[1].(string),
+				AccessToken:    args[2].(string),
+				RefreshToken:   args[3].(*string),
+				TokenExpiresAt: args[4].(*time.Time),
+				ExternalUserID: ptr(args[5].(string)),
+				GrantedScopes:  args[6].(*string),
+				IsActive:       true,
+				Status:         models.IntegrationStatusActive,
+			}
+			if m.connections == nil {
+				m.connections = map[uuid.UUID]*models.ConnectedIntegration{}
+			}
+			m.connections[conn.ID] = conn
+			*target = *conn
+			return nil
+		}
+		// (source, external_user_id) lookups by athlete ID.
+		if len(args) == 2 {
+			if externalID, ok := args[1].(string); ok {
+				for _, conn := range m.connections {
+					if conn.ExternalUserID != nil && *conn.ExternalUserID == externalID {
+						*target = *conn
+						return nil
+					}
+				}
+				return sql.ErrNoRows
+			}
+		}
 		id, ok := args[0].(uuid.UUID)
 		if !ok {
 			return sql.ErrNoRows
 		}
-		m.mu.Lock()
-		defer m.mu.Unlock()
-		conn := m.connections[id]
-		if conn == nil {
-			return sql.ErrNoRows
+		// Lookups by integration ID, or by user ID and source.
+		for _, conn := range m.connections {
+			if conn.ID == id || conn.UserID == id {
+				*target = *conn
+				return nil
+			}
 		}
-		*target = *conn
-		return nil
+		return sql.ErrNoRows
 	case *sql.NullTime:
 		*target = sql.NullTime{}
 		return nil
@@ -65,7 +99,7 @@ func (m *mockStravaDB) GetContext(_ context.Context, dest any, _ string, args ..
 	}
 }
 
-func (m *mockStravaDB) ExecContext(_ context.Context, _ string, args ...any) (sql.Result, error) {
+func (m *mockStravaDB) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
 	m.execCount.Add(1)
 	if m.execErr != nil {
 		return nil, m.execErr
@@ -79,10 +113,10 @@ func (m *mockStravaDB) ExecContext(_ context.Context, _ string, args ...any) (sq
 				if accessToken, ok := args[0].(string); ok {
 					conn.AccessToken = accessToken
 				}
-				if refreshToken, ok := args[1].(string); ok {
+				if refreshToken, ok := args[1].(*string); ok {
 					conn.RefreshToken = refreshToken
 				}
-				if expiresAt, ok := args[2].(time.Time); ok {
+				if expiresAt, ok := args[2].(*time.Time); ok {
 					conn.TokenExpiresAt = expiresAt
 				}
 			}
@@ -92,10 +126,6 @@ func (m *mockStravaDB) ExecContext(_ context.Context, _ string, args ...any) (sq
 	return nil, nil
 }
 
-func (m *mockStravaDB) NamedQueryContext(_ context.Context, _ string, _ any) (*sqlx.Rows, error) {
-	return nil, nil
-}
-
 func (m *mockStravaDB) SelectContext(_ context.Context, _ any, _ string, _ ...any) error {
 	return nil
 }
@@ -105,6 +135,11 @@ type mockStravaClient struct {
 	exchangeTokenFn       func(code string) (*pkgstrava.TokenResponse, error)
 	refreshTokenFn        func(ctx context.Context, refreshToken string) (*pkgstrava.TokenResponse, error)
 	getActivitiesFn       func(ctx context.Context, accessToken string, page int, perPage int) ([]pkgstrava.Activity, error)
+	getActivityFn         func(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.DetailedActivity, error)
+	listActivitiesFn      func(ctx context.Context, accessToken string, params pkgstrava.ActivityListParams) ([]pkgstrava.Activity, error)
+	getActivityStreamsFn  func(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.Streams, error)
+	deauthorizeFn         func(ctx context.Context, accessToken string) error
+	budget                *pkgstrava.Budget
 }
 
 func (m *mockStravaClient) GetAuthorizationURL(state string) string {
@@ -135,6 +170,38 @@ func (m *mockStravaClient) GetActivities(ctx context.Context, accessToken string
 	return nil, errors.New("not implemented")
 }
 
+func (m *mockStravaClient) ListActivities(ctx context.Context, accessToken string, params pkgstrava.ActivityListParams) ([]pkgstrava.Activity, error) {
+	if m.listActivitiesFn != nil {
+		return m.listActivitiesFn(ctx, accessToken, params)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockStravaClient) GetActivity(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.DetailedActivity, error) {
+	if m.getActivityFn != nil {
+		return m.getActivityFn(ctx, accessToken, activityID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockStravaClient) GetActivityStreams(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.Streams, error) {
+	if m.getActivityStreamsFn != nil {
+		return m.getActivityStreamsFn(ctx, accessToken, activityID)
+	}
+	return nil, errors.New("not implemented")
+}
+
+func (m *mockStravaClient) Deauthorize(ctx context.Context, accessToken string) error {
+	if m.deauthorizeFn != nil {
+		return m.deauthorizeFn(ctx, accessToken)
+	}
+	return nil
+}
+
+func (m *mockStravaClient) RateLimitBudget(context.Context) (*pkgstrava.Budget, error) {
+	return m.budget, nil
+}
+
 // redirectTransport rewrites every outgoing request to target a specific httptest.Server.
 // This lets us intercept Strava's hardcoded token URL without changing production code.
 type redirectTransport struct {
@@ -164,6 +231,8 @@ func newStravaTokenServer(t *testing.T, athleteID int64) *httptest.Server {
 	}))
 }
 
+func ptr[T any](v T) *T { return &v }
+
 func newTestStravaService(db stravaQuerier, tokenServer *httptest.Server) *StravaService {
 	serverURL, _ := url.Parse(tokenServer.URL)
 	client := pkgstrava.NewClient("client-id", "client-secret", "http://localhost/callback")
@@ -200,11 +269,32 @@ func TestHandleCallback_NewAthlete(t *testing.T) {
 
 	svc := newTestStravaService(&mockStravaDB{existingUserID: uuid.Nil}, ts)
 
-	if err := svc.HandleCallback(context.Background(), uuid.New(), "auth-code"); err != nil {
+	if err := svc.HandleCallback(context.Background(), uuid.New(), "auth-code", "read,activity:read_all"); err != nil {
 		t.Errorf("want nil, got %v", err)
 	}
 }
 
+func TestHandleCallbackRecordsGrantedScopes(t *testing.T) {
+	const athleteID = int64(100)
+	ts := newStravaTokenServer(t, athleteID)
+	defer ts.Close()
+
+	db := &mockStravaDB{existingUserID: uuid.Nil}
+	svc := newTestStravaService(db, ts)
+
+	if err := svc.HandleCallback(context.Background(), uuid.New(), "auth-code", "read,activity:read"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, conn := range db.connections {
+		if conn.GrantedScopes == nil || *conn.GrantedScopes != "read,activity:read" {
+			t.Fatalf("expected the granted scopes recorded, got %v", conn.GrantedScopes)
+		}
+		if stravaListsPrivate(conn) {
+			t.Fatal("expected a connection without activity:read_all not to list private activities")
+		}
+	}
+}
+
 func TestHandleCallback_SameUserRefresh(t *testing.T) {
 	const athleteID = int64(100)
 	ts := newStravaTokenServer(t, athleteID)
@@ -213,7 +303,7 @@ func TestHandleCallback_SameUserRefresh(t *testing.T) {
 	userID := uuid.New()
 	svc := newTestStravaService(&mockStravaDB{existingUserID: userID}, ts)
 
-	if err := svc.HandleCallback(context.Background(), userID, "auth-code"); err != nil {
+	if err := svc.HandleCallback(context.Background(), userID, "auth-code", "read,activity:read_all"); err != nil {
 		t.Errorf("want nil, got %v", err)
 	}
 }
@@ -226,7 +316,7 @@ func TestHandleCallback_DifferentUserConflict(t *testing.T) {
 	otherUserID := uuid.New()
 	svc := newTestStravaService(&mockStravaDB{existingUserID: otherUserID}, ts)
 
-	err := svc.HandleCallback(context.Background(), uuid.New(), "auth-code")
+	err := svc.HandleCallback(context.Background(), uuid.New(), "auth-code", "read,activity:read_all")
 	if !errors.Is(err, ErrStravaAlreadyConnected) {
 		t.Errorf("want ErrStravaAlreadyConnected, got %v", err)
 	}
@@ -358,13 +448,14 @@ func TestCollectActivitiesForSyncReturnsPartialAfterLaterRateLimit(t *testing.T)
 func TestRefreshAccessTokenCoordinatesConcurrentRefresh(t *testing.T) {
 	connID := uuid.New()
 	db := &mockStravaDB{
-		connections: map[uuid.UUID]*models.StravaConnection{
+		connections: map[uuid.UUID]*models.ConnectedIntegration{
 			connID: {
 				ID:             connID,
 				UserID:         uuid.New(),
+				Source:         "strava",
 				AccessToken:    "old-access",
-				RefreshToken:   "old-refresh",
-				TokenExpiresAt: time.Now().Add(-time.Minute),
+				RefreshToken:   ptr("old-refresh"),
+				TokenExpiresAt: ptr(time.Now().Add(-time.Minute)),
 			},
 		},
 	}
@@ -384,7 +475,7 @@ func TestRefreshAccessTokenCoordinatesConcurrentRefresh(t *testing.T) {
 
 	seed := db.connections[connID]
 	ctx := context.Background()
-	results := make(chan *models.StravaConnection, 2)
+	results := make(chan *models.ConnectedIntegration, 2)
 	errs := make(chan error, 2)
 
 	for range 2 {
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+// stravaEnrichPerSync caps detail and stream downloads per interactive sync.
+// Each activity costs up to two requests, so an initial sync of 200
+// activities would otherwise burn several 15-minute windows; older runs are
+// fetched on demand.
+const stravaEnrichPerSync = 10
+
+// ErrActivityStreamsNotFound is returned when an activity has no stored
+// streams and none could be fetched from its source.
+var ErrActivityStreamsNotFound = errors.New("activity streams not found")
+
+// activityStreamsFromStrava converts Strava's stream payload to the storage
+// model. Returns nil when the activity has no time stream.
+func activityStreamsFromStrava(activity *models.Activity, st *strava.Streams) (*models.ActivityStreams, error) {
+	if st == nil || len(st.Time) == 0 {
+		return nil, nil
+	}
+
+	out := &models.ActivityStreams{
+		ActivityID:  activity.ID,
+		UserID:      activity.UserID,
+		Source:      activity.Source,
+		SampleCount: len(st.Time),
+		TimeOffsets: intsToArray(st.Time),
+		Distance:    pq.Float64Array(st.Distance),
+		HeartRate:   intsToArray(st.Heartrate),
+		Velocity:    pq.Float64Array(st.VelocitySmooth),
+		Cadence:     intsToArray(st.Cadence),
+		Altitude:    pq.Float64Array(st.Altitude),
+		Moving:      pq.BoolArray(st.Moving),
+	}
+	if len(st.LatLng) > 0 {
+		latlng, err := json.Marshal(st.LatLng)
+		if err != nil {
+			return nil, err
+		}
+		out.LatLng = latlng
+	}
+	return out, nil
+}
+
+func intsToArray(in []int) pq.Int64Array {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make(pq.Int64Array, len(in))
+	for i, v := range in {
+		out[i] = int64(v)
+	}
+	return out
+}
+
+// fetchStravaStreams downloads and stores the streams for a stored Strava
+// activity. A 404 (manual entry, or deleted upstream) is not an error.
+func (s *StravaService) fetchStravaStreams(ctx context.Context, accessToken string, activity *models.Activity) (*models.ActivityStreams, error) {
+	stravaID, err := strconv.ParseInt(activity.SourceActivityID, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := s.stravaClient.GetActivityStreams(ctx, accessToken, stravaID)
+	if err != nil {
+		var apiErr *strava.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	streams, err := activityStreamsFromStrava(activity, st)
+	if err != nil || streams == nil {
+		return nil, err
+	}
+	if err := sync.SaveActivityStreams(ctx, s.db, streams); err != nil {
+		return nil, err
+	}
+	return streams, nil
+}
+
+// FetchActivityStreams downloads streams on demand for one of the user's
+// Strava activities, e.g. an older run the sync cap skipped. Returns
+// ErrActivityStreamsNotFound for non-Strava activities or when Strava has
+// no streams for it.
+func (s *StravaService) FetchActivityStreams(ctx context.Context, userID, activityID uuid.UUID) error {
+	var activity models.Activity
+	err := s.db.GetContext(ctx, &activity, `
+		SELECT id, user_id, source, source_activity_id, activity_type
+		FROM activities WHERE id = $1 AND user_id = $2
+	`, activityID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrActivityNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if activity.Source != "strava" {
+		return ErrActivityStreamsNotFound
+	}
+
+	conn, err := s.GetConnection(ctx, userID)
+	if err != nil {
+		return ErrActivityStreamsNotFound
+	}
+	conn, err = s.RefreshAccessToken(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	streams, err := s.fetchStravaStreams(ctx, conn.AccessToken, &activity)
+	if err != nil {
+		return err
+	}
+	if streams == nil {
+		return ErrActivityStreamsNotFound
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/models"
+	pkgstrava "github.com/korsana/backend/pkg/strava"
+)
+
+func TestActivityStreamsFromStrava(t *testing.T) {
+	activity := &models.Activity{ID: uuid.New(), UserID: uuid.New(), Source: "strava"}
+
+	streams, err := activityStreamsFromStrava(activity, &pkgstrava.Streams{
+		Time:      []int{0, 1, 2},
+		Heartrate: []int{120, 122, 125},
+		LatLng:    [][2]float64{{51.5, -0.1}, {51.5, -0.1}, {51.6, -0.1}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if streams.SampleCount != 3 || streams.ActivityID != activity.ID || streams.Source != "strava" {
+		t.Fatalf("unexpected header fields: %+v", streams)
+	}
+	if len(streams.HeartRate) != 3 || streams.HeartRate[2] != 125 {
+		t.Fatalf("heart rate not converted: %v", streams.HeartRate)
+	}
+	if streams.Cadence != nil || streams.Altitude != nil {
+		t.Fatalf("missing streams should stay nil, got cadence=%v altitude=%v", streams.Cadence, streams.Altitude)
+	}
+	if string(streams.LatLng) != "[[51.5,-0.1],[51.5,-0.1],[51.6,-0.1]]" {
+		t.Fatalf("unexpected latlng JSON: %s", streams.LatLng)
+	}
+
+	empty, err := activityStreamsFromStrava(activity, &pkgstrava.Streams{})
+	if err != nil || empty != nil {
+		t.Fatalf("expected nil streams for an activity without a time stream, got %+v, %v", empty, err)
+	}
+}
+
+func TestEnrichStoredActivitiesSkipsRowsAnotherSourceOwns(t *testing.T) {
+	client := &mockStravaClient{
+		getActivityFn: func(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.DetailedActivity, error) {
+			t.Fatalf("expected no Strava detail fetch, got one for %d", activityID)
+			return nil, nil
+		},
+		getActivityStreamsFn: func(ctx context.Context, accessToken string, activityID int64) (*pkgstrava.Streams, error) {
+			t.Fatalf("expected no Strava stream fetch, got one for %d", activityID)
+			return nil, nil
+		},
+	}
+	svc := &StravaService{stravaClient: client}
+
+	// A Garmin run a Strava copy merged into keeps Garmin's ID.
+	garmin := &models.Activity{ID: uuid.New(), Source: "garmin", SourceActivityID: "21034567890", ActivityType: models.ActivityTypeRun}
+	svc.enrichStoredActivities(context.Background(), "token", []*models.Activity{garmin}, 5)
+}
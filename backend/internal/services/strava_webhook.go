@@ -0,0 +1,329 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+const (
+	stravaWebhookPollInterval   = 10 * time.Second
+	stravaWebhookProcessTimeout = 45 * time.Second
+	stravaWebhookClaimTimeout   = 5 * time.Minute
+	stravaWebhookMaxAttempts    = 5
+	stravaWebhookRetryBaseDelay = 30 * time.Second
+)
+
+// EnqueueWebhookEvent records a Strava push event for the webhook worker and
+// returns immediately. Redelivered events hit the unique key and are dropped,
+// so Strava's retries never cause a second import. Object types other than
+// activity/athlete are ignored.
+func (s *StravaService) EnqueueWebhookEvent(ctx context.Context, event strava.WebhookEvent) error {
+	if event.ObjectType != strava.WebhookObjectActivity && event.ObjectType != strava.WebhookObjectAthlete {
+		return nil
+	}
+
+	updates := event.Updates
+	if updates == nil {
+		updates = map[string]any{}
+	}
+	updatesJSON, err := json.Marshal(updates)
+	if err != nil {
+		return fmt.Errorf("encode webhook updates: %w", err)
+	}
+
+	var subscriptionID *int64
+	if event.SubscriptionID != 0 {
+		subscriptionID = &event.SubscriptionID
+	}
+
+	_, err = s.db.ExecContext(ctx, `
+		INSERT INTO strava_webhook_events (
+			object_type, object_id, aspect_type, owner_id, subscription_id, event_time, updates
+		) VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (object_type, object_id, aspect_type, owner_id, event_time) DO NOTHING
+	`, event.ObjectType, event.ObjectID, event.AspectType, event.OwnerID, subscriptionID, event.EventTime, updatesJSON)
+	if err != nil {
+		return err
+	}
+
+	s.wakeWebhookWorker()
+	return nil
+}
+
+func (s *StravaService) wakeWebhookWorker() {
+	select {
+	case s.webhookWake <- struct{}{}:
+	default:
+	}
+}
+
+// claimWebhookEvent atomically moves the oldest due event to processing.
+// SKIP LOCKED lets several API replicas run the worker without two of them
+// picking up the same event. Events stuck in processing past the claim
+// timeout (e.g. the replica died mid-import) become claimable again.
+func (s *StravaService) claimWebhookEvent(ctx context.Context) (*models.StravaWebhookEvent, error) {
+	var event models.StravaWebhookEvent
+	err := s.db.GetContext(ctx, &event, `
+		UPDATE strava_webhook_events
+		SET status = 'processing', claimed_at = NOW(), attempts = attempts + 1
+		WHERE id = (
+			SELECT id FROM strava_webhook_events
+			WHERE (status = 'pending' AND available_at <= NOW())
+			   OR (status = 'processing' AND claimed_at < NOW() - make_interval(secs => $1))
+			ORDER BY available_at ASC
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING *
+	`, stravaWebhookClaimTimeout.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &event, nil
+}
+
+// finishWebhookEvent records the outcome of one processing attempt.
+// Retryable failures go back to pending with a linear backoff until
+// stravaWebhookMaxAttempts is reached.
+func (s *StravaService) finishWebhookEvent(ctx context.Context, event *models.StravaWebhookEvent, procErr error) error {
+	if procErr == nil {
+		_, err := s.db.ExecContext(ctx, `
+			UPDATE strava_webhook_events
+			SET status = 'done', processed_at = NOW(), last_error = NULL
+			WHERE id = $1
+		`, event.ID)
+		return err
+	}
+
+	status := "failed"
+	delay := time.Duration(0)
+	if isRetryableStravaError(procErr) && event.Attempts < stravaWebhookMaxAttempts {
+		status = "pending"
+		delay = stravaWebhookRetryBaseDelay * time.Duration(event.Attempts)
+		var apiErr *strava.APIError
+		if errors.As(procErr, &apiErr) && apiErr.RetryAfter > delay {
+			delay = apiErr.RetryAfter
+		}
+	}
+
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE strava_webhook_events
+		SET status = $1, last_error = $2, available_at = NOW() + make_interval(secs => $3)
+		WHERE id = $4
+	`, status, procErr.Error(), delay.Seconds(), event.ID)
+	return err
+}
+
+// isRetryableStravaError reports whether a failed webhook import is worth
+// another attempt. Client errors other than 429 (bad token, forbidden) will
+// fail the same way next time, so they are not retried.
+func isRetryableStravaError(err error) bool {
+	var apiErr *strava.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode == http.StatusTooManyRequests || apiErr.StatusCode >= 500
+	}
+	return true
+}
+
+// ProcessWebhookEvent applies one queued Strava event to the athlete's data.
+// Events for athletes with no Korsana connection are acknowledged and dropped.
+func (s *StravaService) ProcessWebhookEvent(ctx context.Context, event *models.StravaWebhookEvent) error {
+	conn, err := s.getConnectionByAthleteID(ctx, event.OwnerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var updates map[string]any
+	if len(event.Updates) > 0 {
+		_ = json.Unmarshal(event.Updates, &updates)
+	}
+	payload := strava.WebhookEvent{
+		ObjectType: event.ObjectType,
+		ObjectID:   event.ObjectID,
+		AspectType: event.AspectType,
+		OwnerID:    event.OwnerID,
+		EventTime:  event.EventTime,
+		Updates:    updates,
+	}
+
+	switch {
+	case payload.IsDeauthorization():
+		return s.handleStravaDeauthorization(ctx, conn)
+	case payload.ObjectType != strava.WebhookObjectActivity:
+		return nil
+	case payload.AspectType == strava.WebhookAspectDelete:
+		return s.deleteStravaActivity(ctx, conn.UserID, payload.ObjectID)
+	case payload.AspectType == strava.WebhookAspectCreate, payload.AspectType == strava.WebhookAspectUpdate:
+		return s.importStravaActivity(ctx, conn, payload.ObjectID)
+	default:
+		return nil
+	}
+}
+
+// importStravaActivity fetches a single activity and stores it through the
+// same path as the bulk sync. A 404 means the activity is gone (or no longer
+// visible to us), which is handled like a delete. Each import is recorded
+// in sync_runs as a webhook run.
+func (s *StravaService) importStravaActivity(ctx context.Context, conn *models.ConnectedIntegration, activityID int64) (err error) {
+	run := startSyncRun(conn.UserID, "strava", models.SyncModeWebhook)
+	defer func() { run.finish(ctx, s.db, err) }()
+
+	conn, err = s.RefreshAccessToken(ctx, conn)
+	if err != nil {
+		return err
+	}
+
+	act, err := s.stravaClient.GetActivity(ctx, conn.AccessToken, activityID)
+	if err != nil {
+		var apiErr *strava.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound {
+			return s.deleteStravaActivity(ctx, conn.UserID, activityID)
+		}
+		return err
+	}
+
+	prev, err := s.loadStravaStored(ctx, conn.UserID,
+		"COALESCE(r.source_activity_id, a.source_activity_id) = $2", strconv.FormatInt(activityID, 10))
+	if err != nil {
+		return err
+	}
+
+	result, err := s.storeStravaActivity(ctx, conn.UserID, act.Activity)
+	run.tally(result, err)
+	if err != nil || result.Activity == nil {
+		return err
+	}
+	stored := result.Activity
+	// An update event for an edited activity: a re-timed one may also have
+	// left its old week.
+	var days []time.Time
+	for i := range prev {
+		if s.applyStravaEdit(ctx, &prev[i], stored) {
+			days = append(days, prev[i].day())
+		}
+	}
+	if err := s.storeStravaDetails(ctx, stored, act); err != nil {
+		return err
+	}
+	s.enrichStoredActivities(ctx, conn.AccessToken, []*models.Activity{stored}, 1)
+	if err := refreshSummaryWeeks(ctx, s.db, conn.UserID, days); err != nil {
+		return err
+	}
+	return s.computeWeeklySummaries(ctx, conn.UserID)
+}
+
+// handleStravaDeauthorization deletes the athlete's Strava data and drops the
+// connection once Strava confirms they revoked access, as Strava's API
+// agreement requires. The webhook endpoint is public, so the token is probed
+// first: a forged or stale event leaves a working connection untouched.
+func (s *StravaService) handleStravaDeauthorization(ctx context.Context, conn *models.ConnectedIntegration) error {
+	fresh, err := s.RefreshAccessToken(ctx, conn)
+	if err == nil {
+		_, err = s.stravaClient.GetActivities(ctx, fresh.AccessToken, 1, 1)
+	}
+	if err == nil {
+		logger.FromContext(ctx).Warn("strava webhook: ignoring deauthorization, token still valid",
+			"user_id", conn.UserID,
+		)
+		return nil
+	}
+
+	var apiErr *strava.APIError
+	if !errors.As(err, &apiErr) || (apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnauthorized) {
+		return err
+	}
+	if _, err := s.purgeStravaData(ctx, conn.UserID, StravaPurgeDelete); err != nil {
+		return err
+	}
+	return disconnectIntegration(ctx, s.db, conn.UserID, "strava")
+}
+
+// StravaWebhookWorker drains the strava_webhook_events queue. It wakes on
+// every enqueue and also polls, so retries and events queued by another
+// replica are picked up within stravaWebhookPollInterval.
+type StravaWebhookWorker struct {
+	svc          *StravaService
+	pollInterval time.Duration
+}
+
+// NewStravaWebhookWorker creates a worker bound to the Strava service.
+func NewStravaWebhookWorker(svc *StravaService) *StravaWebhookWorker {
+	return &StravaWebhookWorker{
+		svc:          svc,
+		pollInterval: stravaWebhookPollInterval,
+	}
+}
+
+// Run processes events until ctx is cancelled.
+func (w *StravaWebhookWorker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		w.drain(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-w.svc.webhookWake:
+		}
+	}
+}
+
+func (w *StravaWebhookWorker) drain(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := w.processNext(ctx)
+		if err != nil {
+			logger.FromContext(ctx).Error("strava webhook worker: queue error", "error", err)
+			return
+		}
+		if !processed {
+			return
+		}
+	}
+}
+
+// processNext claims and processes a single event. Returns false when the
+// queue has nothing due.
+func (w *StravaWebhookWorker) processNext(ctx context.Context) (bool, error) {
+	event, err := w.svc.claimWebhookEvent(ctx)
+	if err != nil || event == nil {
+		return false, err
+	}
+
+	log := logger.FromContext(ctx).With(
+		"event_id", event.ID,
+		"object_type", event.ObjectType,
+		"aspect_type", event.AspectType,
+		"object_id", event.ObjectID,
+		"owner_id", event.OwnerID,
+	)
+	eventCtx, cancel := context.WithTimeout(logger.WithLogger(ctx, log), stravaWebhookProcessTimeout)
+	procErr := w.svc.ProcessWebhookEvent(eventCtx, event)
+	cancel()
+
+	if procErr != nil {
+		log.Warn("strava webhook worker: event failed", "attempt", event.Attempts, "error", procErr)
+	}
+	if err := w.svc.finishWebhookEvent(ctx, event, procErr); err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,119 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/korsana/backend/internal/models"
+	pkgstrava "github.com/korsana/backend/pkg/strava"
+)
+
+func newConnectedStravaService(client *mockStravaClient) (*StravaService, *mockStravaDB) {
+	connID := uuid.New()
+	db := &mockStravaDB{
+		connections: map[uuid.UUID]*models.ConnectedIntegration{
+			connID: {
+				ID:             connID,
+				UserID:         uuid.New(),
+				Source:         "strava",
+				ExternalUserID: ptr("42"),
+				AccessToken:    "access",
+				RefreshToken:   ptr("refresh"),
+				TokenExpiresAt: ptr(time.Now().Add(time.Hour)),
+			},
+		},
+	}
+	return &StravaService{db: db, stravaClient: client}, db
+}
+
+func deauthEvent(ownerID int64) *models.StravaWebhookEvent {
+	updates, _ := json.Marshal(map[string]any{"authorized": "false"})
+	return &models.StravaWebhookEvent{
+		ObjectType: pkgstrava.WebhookObjectAthlete,
+		ObjectID:   ownerID,
+		AspectType: pkgstrava.WebhookAspectUpdate,
+		OwnerID:    ownerID,
+		Updates:    updates,
+	}
+}
+
+func TestProcessWebhookEventIgnoresUnknownAthlete(t *testing.T) {
+	client := &mockStravaClient{
+		getActivityFn: func(context.Context, string, int64) (*pkgstrava.DetailedActivity, error) {
+			t.Fatal("activity should not be fetched for an unknown athlete")
+			return nil, nil
+		},
+	}
+	svc, db := newConnectedStravaService(client)
+
+	err := svc.ProcessWebhookEvent(context.Background(), &models.StravaWebhookEvent{
+		ObjectType: pkgstrava.WebhookObjectActivity,
+		ObjectID:   1,
+		AspectType: pkgstrava.WebhookAspectCreate,
+		OwnerID:    999,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.execCount.Load(); got != 0 {
+		t.Fatalf("expected no writes, got %d", got)
+	}
+}
+
+func TestProcessWebhookEventIgnoresDeauthorizationWhileTokenWorks(t *testing.T) {
+	client := &mockStravaClient{
+		getActivitiesFn: func(context.Context, string, int, int) ([]pkgstrava.Activity, error) {
+			return nil, nil
+		},
+	}
+	svc, db := newConnectedStravaService(client)
+
+	if err := svc.ProcessWebhookEvent(context.Background(), deauthEvent(42)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.execCount.Load(); got != 0 {
+		t.Fatalf("expected connection to be kept, got %d writes", got)
+	}
+}
+
+func TestProcessWebhookEventDisconnectsRevokedAthlete(t *testing.T) {
+	client := &mockStravaClient{
+		getActivitiesFn: func(context.Context, string, int, int) ([]pkgstrava.Activity, error) {
+			return nil, &pkgstrava.APIError{StatusCode: http.StatusUnauthorized}
+		},
+	}
+	svc, db := newConnectedStravaService(client)
+
+	if err := svc.ProcessWebhookEvent(context.Background(), deauthEvent(42)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := db.execCount.Load(); got != 1 {
+		t.Fatalf("expected connection delete, got %d writes", got)
+	}
+}
+
+func TestIsRetryableStravaError(t *testing.T) {
+	cases := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"rate limited", &pkgstrava.APIError{StatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &pkgstrava.APIError{StatusCode: http.StatusBadGateway}, true},
+		{"unauthorized", &pkgstrava.APIError{StatusCode: http.StatusUnauthorized}, false},
+		{"forbidden", &pkgstrava.APIError{StatusCode: http.StatusForbidden}, false},
+		{"network", errors.New("connection reset"), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isRetryableStravaError(tc.err); got != tc.want {
+				t.Fatalf("isRetryableStravaError(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// CrossTrainingType maps an internal activity type to the cross_training_sessions
+// type string. Returns "" for run-type activities which should not be mirrored.
+func CrossTrainingType(activityType string) string {
+	switch activityType {
+	case models.ActivityTypeCycling:
+		return "cycling"
+	case models.ActivityTypeSwimming:
+		return "swimming"
+	case models.ActivityTypeWeightLifting:
+		return "weight_lifting"
+	case models.ActivityTypeElliptical:
+		return "elliptical"
+	case models.ActivityTypeRowing:
+		return "rowing"
+	case models.ActivityTypeWalking:
+		return "walking"
+	case models.ActivityTypeHiking:
+		return "hiking"
+	default:
+		return ""
+	}
+}
+
+// MirrorCrossTraining keeps the cross_training_sessions row for a stored
+// non-run activity in step with it, so the widget shows synced sessions
+// without manual entry. The row is keyed by activity_id, which makes
+// re-syncs idempotent; an activity that is now a run loses its mirror.
+func MirrorCrossTraining(ctx context.Context, db Querier, activity *models.Activity) error {
+	ctType := CrossTrainingType(activity.ActivityType)
+	if ctType == "" {
+		_, err := db.ExecContext(ctx,
+			"DELETE FROM cross_training_sessions WHERE activity_id = $1", activity.ID)
+		return err
+	}
+
+	durationMins := activity.DurationSeconds / 60
+	if durationMins < 1 {
+		durationMins = 1
+	}
+	var distPtr *float64
+	if activity.DistanceMeters > 0 {
+		dist := activity.DistanceMeters
+		distPtr = &dist
+	}
+	// Bucket by the athlete's local calendar date so cross-training
+	// rows line up with the user's run history regardless of UTC offset.
+	date := activity.StartTime.UTC().Truncate(24 * time.Hour)
+	if activity.LocalDate != nil {
+		date = *activity.LocalDate
+	}
+	// strava_activity_id predates activity_id and is still read by the
+	// cross-training API, so Strava mirrors keep filling it in.
+	var stravaID *string
+	if activity.Source == "strava" {
+		stravaID = &activity.SourceActivityID
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO cross_training_sessions
+			(id, user_id, type, date, duration_minutes, distance_meters, source, strava_activity_id, activity_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (activity_id) DO UPDATE SET
+			type = EXCLUDED.type,
+			date = EXCLUDED.date,
+			duration_minutes = EXCLUDED.duration_minutes,
+			distance_meters = EXCLUDED.distance_meters,
+			source = EXCLUDED.source,
+			strava_activity_id = EXCLUDED.strava_activity_id,
+			updated_at = NOW()
+	`, uuid.New(), activity.UserID, ctType, date, durationMins, distPtr,
+		activity.Source, stravaID, activity.ID)
+	return err
+}
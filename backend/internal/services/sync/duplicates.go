@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"context"
+	"time"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+const (
+	// duplicateWindow is how far apart two activities may start and still
+	// be suggested as one session. Manual entries often carry a rounded or
+	// guessed start time.
+	duplicateWindow = time.Hour
+
+	// duplicateTolerance is the relative difference in distance, or in
+	// duration when either side has no distance, that still counts as a
+	// match.
+	duplicateTolerance = 0.10
+)
+
+// RecordDuplicateCandidates stores a review pair for every visible activity
+// of the same athlete that looks like the same session as activity. The
+// automatic match in UpsertActivity is deliberately strict; this catches
+// what it lets through. Pairs already recorded, including dismissed ones,
+// are left alone.
+func RecordDuplicateCandidates(ctx context.Context, db Querier, activity *models.Activity) error {
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO activity_duplicates (user_id, activity_id, duplicate_activity_id, start_delta_seconds)
+		SELECT a.user_id, LEAST(a.id, $2), GREATEST(a.id, $2),
+			ROUND(ABS(EXTRACT(EPOCH FROM (a.start_time - $3))))
+		FROM activities a
+		WHERE a.user_id = $1
+		  AND a.id <> $2
+		  AND a.merged_into IS NULL
+		  AND NOT EXISTS (SELECT 1 FROM activities m WHERE m.id = $2 AND m.merged_into IS NOT NULL)
+		  AND ABS(EXTRACT(EPOCH FROM (a.start_time - $3))) < $6
+		  AND CASE
+			WHEN a.distance_meters > 0 AND $4 > 0
+				THEN ABS(a.distance_meters - $4) <= $7 * GREATEST(a.distance_meters, $4)
+			ELSE ABS(a.duration_seconds - $5) <= $7 * GREATEST(a.duration_seconds, $5)
+		  END
+		ON CONFLICT (activity_id, duplicate_activity_id) DO NOTHING
+	`, activity.UserID, activity.ID, activity.StartTime, activity.DistanceMeters, activity.DurationSeconds,
+		duplicateWindow.Seconds(), duplicateTolerance)
+	return err
+}
@@ -0,0 +1,84 @@
+package sync
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// SaveActivityLaps replaces an activity's laps. Rows are upserted by index
+// and any trailing laps from an earlier version of the activity removed, so
+// a re-import that fails halfway leaves a usable (if mixed) set.
+func SaveActivityLaps(ctx context.Context, db Querier, activityID uuid.UUID, laps []models.ActivityLap) error {
+	for _, lap := range laps {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO activity_laps (
+				activity_id, lap_index, name, distance_meters, elapsed_seconds,
+				moving_seconds, start_time, average_speed_mps, max_speed_mps,
+				average_heart_rate, max_heart_rate, average_cadence,
+				elevation_gain_meters, pace_zone
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+			ON CONFLICT (activity_id, lap_index) DO UPDATE SET
+				name = EXCLUDED.name,
+				distance_meters = EXCLUDED.distance_meters,
+				elapsed_seconds = EXCLUDED.elapsed_seconds,
+				moving_seconds = EXCLUDED.moving_seconds,
+				start_time = EXCLUDED.start_time,
+				average_speed_mps = EXCLUDED.average_speed_mps,
+				max_speed_mps = EXCLUDED.max_speed_mps,
+				average_heart_rate = EXCLUDED.average_heart_rate,
+				max_heart_rate = EXCLUDED.max_heart_rate,
+				average_cadence = EXCLUDED.average_cadence,
+				elevation_gain_meters = EXCLUDED.elevation_gain_meters,
+				pace_zone = EXCLUDED.pace_zone
+		`,
+			activityID, lap.LapIndex, lap.Name, lap.DistanceMeters, lap.ElapsedSeconds,
+			lap.MovingSeconds, lap.StartTime, lap.AverageSpeedMps, lap.MaxSpeedMps,
+			lap.AverageHeartRate, lap.MaxHeartRate, lap.AverageCadence,
+			lap.ElevationGainMeters, lap.PaceZone,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM activity_laps WHERE activity_id = $1 AND lap_index >= $2",
+		activityID, len(laps))
+	return err
+}
+
+// SaveActivitySplits replaces an activity's splits in one unit
+// (models.SplitUnitMetric or models.SplitUnitStandard).
+func SaveActivitySplits(ctx context.Context, db Querier, activityID uuid.UUID, unit string, splits []models.ActivitySplit) error {
+	for _, split := range splits {
+		_, err := db.ExecContext(ctx, `
+			INSERT INTO activity_splits (
+				activity_id, unit, split_index, distance_meters, elapsed_seconds,
+				moving_seconds, elevation_difference_meters, average_speed_mps,
+				average_grade_adjusted_speed_mps, average_heart_rate, pace_zone
+			) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (activity_id, unit, split_index) DO UPDATE SET
+				distance_meters = EXCLUDED.distance_meters,
+				elapsed_seconds = EXCLUDED.elapsed_seconds,
+				moving_seconds = EXCLUDED.moving_seconds,
+				elevation_difference_meters = EXCLUDED.elevation_difference_meters,
+				average_speed_mps = EXCLUDED.average_speed_mps,
+				average_grade_adjusted_speed_mps = EXCLUDED.average_grade_adjusted_speed_mps,
+				average_heart_rate = EXCLUDED.average_heart_rate,
+				pace_zone = EXCLUDED.pace_zone
+		`,
+			activityID, unit, split.SplitIndex, split.DistanceMeters, split.ElapsedSeconds,
+			split.MovingSeconds, split.ElevationDifferenceMeters, split.AverageSpeedMps,
+			split.AverageGradeAdjustedSpeedMps, split.AverageHeartRate, split.PaceZone,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	_, err := db.ExecContext(ctx,
+		"DELETE FROM activity_splits WHERE activity_id = $1 AND unit = $2 AND split_index >= $3",
+		activityID, unit, len(splits))
+	return err
+}
@@ -0,0 +1,321 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// fieldGroup reads and copies one field group of a RawActivity.
+type fieldGroup struct {
+	has  func(RawActivity) bool
+	copy func(dst *RawActivity, src RawActivity)
+}
+
+var fieldGroups = map[string]fieldGroup{
+	FieldName: {
+		has:  func(r RawActivity) bool { return r.Name != "" },
+		copy: func(d *RawActivity, s RawActivity) { d.Name = s.Name },
+	},
+	FieldDistance: {
+		has: func(r RawActivity) bool { return r.Distance > 0 || r.Duration > 0 },
+		copy: func(d *RawActivity, s RawActivity) {
+			d.Distance, d.Duration, d.AvgPace = s.Distance, s.Duration, s.AvgPace
+		},
+	},
+	FieldHeartRate: {
+		has:  func(r RawActivity) bool { return r.AvgHR != nil || r.MaxHR != nil },
+		copy: func(d *RawActivity, s RawActivity) { d.AvgHR, d.MaxHR = s.AvgHR, s.MaxHR },
+	},
+	FieldCadence: {
+		has:  func(r RawActivity) bool { return r.AvgCadence != nil },
+		copy: func(d *RawActivity, s RawActivity) { d.AvgCadence = s.AvgCadence },
+	},
+	FieldElevation: {
+		has:  func(r RawActivity) bool { return r.ElevGain != nil },
+		copy: func(d *RawActivity, s RawActivity) { d.ElevGain = s.ElevGain },
+	},
+	FieldSufferScore: {
+		has:  func(r RawActivity) bool { return r.SufferScore != nil },
+		copy: func(d *RawActivity, s RawActivity) { d.SufferScore = s.SufferScore },
+	},
+	FieldWorkoutType: {
+		has:  func(r RawActivity) bool { return r.WorkoutType != "" },
+		copy: func(d *RawActivity, s RawActivity) { d.WorkoutType = s.WorkoutType },
+	},
+	FieldGear: {
+		has:  func(r RawActivity) bool { return r.GearID != "" },
+		copy: func(d *RawActivity, s RawActivity) { d.GearID = s.GearID },
+	},
+	FieldDescription: {
+		has:  func(r RawActivity) bool { return r.Description != "" },
+		copy: func(d *RawActivity, s RawActivity) { d.Description = s.Description },
+	},
+	FieldDevice: {
+		has: func(r RawActivity) bool { return r.DeviceName != "" || r.Calories != nil },
+		copy: func(d *RawActivity, s RawActivity) {
+			d.DeviceName, d.Calories = s.DeviceName, s.Calories
+		},
+	},
+}
+
+// mergeRecords builds the activity from every source's copy. The owner
+// keeps the row's identity, start time, local date and activity type; each
+// field group comes from the highest-ranked copy that has it, with the
+// owner winning ties. records should include the owner's own copy.
+func mergeRecords(owner RawActivity, records []RawActivity, p Priorities) (RawActivity, models.FieldSources) {
+	merged := owner
+	provenance := models.FieldSources{}
+
+	for _, field := range Fields {
+		group := fieldGroups[field]
+		best, bestRank := -1, 0
+		for i, r := range records {
+			if !group.has(r) {
+				continue
+			}
+			rank := p.rankFor(field, r.Source)
+			isOwner := r.Source == owner.Source && r.SourceID == owner.SourceID
+			if best < 0 || rank < bestRank || (rank == bestRank && isOwner) {
+				best, bestRank = i, rank
+			}
+		}
+
+		var zero RawActivity
+		if best < 0 {
+			group.copy(&merged, zero)
+			continue
+		}
+		group.copy(&merged, records[best])
+		provenance[field] = records[best].Source
+	}
+	return merged, provenance
+}
+
+// saveSourceRecord stores raw as its source's copy of activityID.
+func saveSourceRecord(ctx context.Context, db Querier, activityID uuid.UUID, raw RawActivity) error {
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO activity_source_records (activity_id, source, source_activity_id, data)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (activity_id, source, source_activity_id) DO UPDATE SET
+			data = EXCLUDED.data,
+			updated_at = NOW()
+	`, activityID, raw.Source, raw.SourceID, data)
+	if err != nil {
+		return fmt.Errorf("save source record: %w", err)
+	}
+	return nil
+}
+
+// loadSourceRecords returns every source's copy of activityID, most
+// recently received first.
+func loadSourceRecords(ctx context.Context, db Querier, activityID uuid.UUID) ([]RawActivity, error) {
+	var rows []models.ActivitySourceRecord
+	if err := db.SelectContext(ctx, &rows, `
+		SELECT * FROM activity_source_records
+		WHERE activity_id = $1
+		ORDER BY updated_at DESC
+	`, activityID); err != nil {
+		return nil, fmt.Errorf("load source records: %w", err)
+	}
+
+	records := make([]RawActivity, 0, len(rows))
+	for _, row := range rows {
+		var raw RawActivity
+		if err := json.Unmarshal(row.Data, &raw); err != nil {
+			return nil, fmt.Errorf("decode source record %s/%s: %w", row.Source, row.SourceActivityID, err)
+		}
+		records = append(records, raw)
+	}
+	return records, nil
+}
+
+// mergeSourceRecords stores incoming as one more copy of activityID and
+// returns the row rebuilt from all copies. incomingOwns is true when
+// incoming replaces the row's source (update or upgrade); otherwise the
+// current source keeps the row and the result is nil if incoming supplies
+// no field the athlete prefers it for.
+func mergeSourceRecords(ctx context.Context, db Querier, userID, activityID uuid.UUID, incoming RawActivity, incomingOwns bool, p Priorities) (*models.Activity, error) {
+	var current models.Activity
+	err := db.GetContext(ctx, &current,
+		"SELECT * FROM activities WHERE id = $1 AND user_id = $2", activityID, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("load activity for merge: %w", err)
+	}
+	found := err == nil
+
+	records, err := loadSourceRecords(ctx, db, activityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 && found {
+		// Stored before source records existed: the row is its source's copy.
+		bootstrap := rawFromActivity(&current)
+		if err := saveSourceRecord(ctx, db, activityID, bootstrap); err != nil {
+			return nil, err
+		}
+		records = append(records, bootstrap)
+	}
+
+	owner := incoming
+	if !incomingOwns {
+		if !found {
+			return nil, nil
+		}
+		owner = rawFromActivity(&current)
+	}
+
+	replaced := false
+	for i, r := range records {
+		if r.Source == incoming.Source && r.SourceID == incoming.SourceID {
+			// Listings carry no details; keep those SaveDetails stored.
+			if incoming.Details == (Details{}) {
+				incoming.Details = r.Details
+			}
+			records[i] = incoming
+			replaced = true
+		}
+		if !incomingOwns && r.Source == owner.Source && r.SourceID == owner.SourceID {
+			owner = r
+		}
+	}
+	if !replaced {
+		records = append(records, incoming)
+	}
+	// The owner's copy goes first so ties between two copies from the same
+	// source resolve to it.
+	isOwner := func(r RawActivity) bool { return r.Source == owner.Source && r.SourceID == owner.SourceID }
+	sort.SliceStable(records, func(i, j int) bool {
+		return isOwner(records[i]) && !isOwner(records[j])
+	})
+
+	if err := saveSourceRecord(ctx, db, activityID, incoming); err != nil {
+		return nil, err
+	}
+
+	merged, provenance := mergeRecords(owner, records, p)
+	if !incomingOwns && !supplies(provenance, incoming.Source) {
+		return nil, nil
+	}
+
+	activity := merged.toActivity(userID)
+	activity.ID = activityID
+	activity.FieldSources = provenance
+	return activity, nil
+}
+
+// SaveDetails stores details on source's copy of activityID and rebuilds the
+// row's description and device groups from every copy, so a source that
+// does not own the row still only supplies what the athlete prefers it for.
+// It returns the rebuilt activity, or nil if the row or the copy is gone.
+func SaveDetails(ctx context.Context, db Querier, userID, activityID uuid.UUID, source, sourceID string, details Details) (*models.Activity, error) {
+	var current models.Activity
+	err := db.GetContext(ctx, &current,
+		"SELECT * FROM activities WHERE id = $1 AND user_id = $2", activityID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load activity for details: %w", err)
+	}
+
+	records, err := loadSourceRecords(ctx, db, activityID)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		// Stored before source records existed: the row is its source's copy.
+		records = []RawActivity{rawFromActivity(&current)}
+	}
+
+	owner := rawFromActivity(&current)
+	isOwner := func(r RawActivity) bool { return r.Source == owner.Source && r.SourceID == owner.SourceID }
+	found := false
+	for i := range records {
+		if records[i].Source == source && records[i].SourceID == sourceID {
+			records[i].Details = details
+			if err := saveSourceRecord(ctx, db, activityID, records[i]); err != nil {
+				return nil, err
+			}
+			found = true
+		}
+		if isOwner(records[i]) {
+			owner = records[i]
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+	sort.SliceStable(records, func(i, j int) bool {
+		return isOwner(records[i]) && !isOwner(records[j])
+	})
+
+	p, err := LoadPriorities(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load source priorities: %w", err)
+	}
+	merged, provenance := mergeRecords(owner, records, p)
+	activity := merged.toActivity(userID)
+	activity.ID = activityID
+	activity.FieldSources = provenance
+	if err := updateActivity(ctx, db, activity); err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// supplies reports whether source won any field group.
+func supplies(provenance models.FieldSources, source string) bool {
+	for _, s := range provenance {
+		if s == source {
+			return true
+		}
+	}
+	return false
+}
+
+// rawFromActivity is the inverse of toActivity, for rows stored before
+// each source's copy was kept.
+func rawFromActivity(a *models.Activity) RawActivity {
+	raw := RawActivity{
+		SourceID:     a.SourceActivityID,
+		Source:       a.Source,
+		StartTime:    a.StartTime,
+		LocalDate:    a.LocalDate,
+		Duration:     a.DurationSeconds,
+		Distance:     a.DistanceMeters,
+		AvgPace:      a.AveragePaceSecondsPerKm,
+		AvgHR:        a.AverageHeartRate,
+		MaxHR:        a.MaxHeartRate,
+		AvgCadence:   a.AverageCadence,
+		ElevGain:     a.ElevationGainMeters,
+		SufferScore:  a.SufferScore,
+		ActivityType: a.ActivityType,
+		Name:         a.Name,
+	}
+	if a.WorkoutType != nil {
+		raw.WorkoutType = *a.WorkoutType
+	}
+	if a.GearID != nil {
+		raw.GearID = *a.GearID
+	}
+	if a.Description != nil {
+		raw.Description = *a.Description
+	}
+	if a.DeviceName != nil {
+		raw.DeviceName = *a.DeviceName
+	}
+	raw.Calories = a.Calories
+	return raw
+}
@@ -1,12 +1,26 @@
 package sync
 
+import (
+	"context"
+	"math"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
 // sourcePriority defines the hierarchy. Lower number = higher priority.
-// Strava always wins. Manual always loses.
+// Strava always wins. Manual always loses. Uploaded files rank below the
+// API sources, which carry richer metadata for the same recording, and
+// Apple Health below files since its workouts have no laps or streams.
 var sourcePriority = map[string]int{
-	"strava": 1,
-	"coros":  2,
-	"garmin": 3,
-	"manual": 4,
+	"strava":       1,
+	"coros":        2,
+	"garmin":       3,
+	"file":         4,
+	"apple_health": 5,
+	"manual":       6,
 }
 
 // HigherPriority returns true if incoming should replace an activity
@@ -19,3 +33,108 @@ func HigherPriority(incoming, existing string) bool {
 	}
 	return inPri < exPri
 }
+
+// KnownSource reports whether source takes part in priority ordering.
+func KnownSource(source string) bool {
+	_, ok := sourcePriority[source]
+	return ok
+}
+
+// SourceOrder returns every known source, highest priority first.
+func SourceOrder() []string {
+	order := make([]string, 0, len(sourcePriority))
+	for source := range sourcePriority {
+		order = append(order, source)
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return sourcePriority[order[i]] < sourcePriority[order[j]]
+	})
+	return order
+}
+
+// Field groups that can be taken from different sources for one activity.
+// Start time, local date and activity type always come from the row's
+// owning source.
+const (
+	FieldName        = "name"
+	FieldDistance    = "distance" // distance, duration and pace together
+	FieldHeartRate   = "heart_rate"
+	FieldCadence     = "cadence"
+	FieldElevation   = "elevation"
+	FieldSufferScore = "suffer_score"
+	FieldWorkoutType = "workout_type"
+	FieldGear        = "gear"
+	FieldDescription = "description"
+	FieldDevice      = "device" // device name and its calorie estimate together
+
+	// PriorityDefault is the settings key for the activity-level order,
+	// which also applies to fields without their own.
+	PriorityDefault = "default"
+)
+
+// Fields lists the field groups in display order.
+var Fields = []string{
+	FieldName, FieldDistance, FieldHeartRate, FieldCadence,
+	FieldElevation, FieldSufferScore, FieldWorkoutType, FieldGear,
+	FieldDescription, FieldDevice,
+}
+
+// Priorities is one athlete's source order. The zero value is the global
+// order.
+type Priorities struct {
+	Default []string
+	Fields  map[string][]string
+}
+
+// LoadPriorities reads the athlete's settings from user_source_priorities.
+func LoadPriorities(ctx context.Context, db Querier, userID uuid.UUID) (Priorities, error) {
+	var rows []models.UserSourcePriority
+	if err := db.SelectContext(ctx, &rows,
+		"SELECT * FROM user_source_priorities WHERE user_id = $1", userID); err != nil {
+		return Priorities{}, err
+	}
+
+	var p Priorities
+	for _, row := range rows {
+		if row.Field == PriorityDefault {
+			p.Default = row.Sources
+			continue
+		}
+		if p.Fields == nil {
+			p.Fields = make(map[string][]string)
+		}
+		p.Fields[row.Field] = row.Sources
+	}
+	return p, nil
+}
+
+// HigherPriority is the athlete's version of the package-level
+// HigherPriority, for the activity as a whole.
+func (p Priorities) HigherPriority(incoming, existing string) bool {
+	if !KnownSource(incoming) || !KnownSource(existing) {
+		return false
+	}
+	return rank(p.Default, incoming) < rank(p.Default, existing)
+}
+
+// rankFor returns source's position for a field group. Lower is better.
+func (p Priorities) rankFor(field, source string) int {
+	if order, ok := p.Fields[field]; ok {
+		return rank(order, source)
+	}
+	return rank(p.Default, source)
+}
+
+// rank places source in order, with unlisted sources after it in the
+// global order and unknown sources last.
+func rank(order []string, source string) int {
+	for i, s := range order {
+		if s == source {
+			return i
+		}
+	}
+	if pri, ok := sourcePriority[source]; ok {
+		return len(order) + pri
+	}
+	return math.MaxInt
+}
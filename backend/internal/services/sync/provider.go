@@ -1,27 +1,43 @@
 package sync
 
 import (
+	"context"
 	"time"
 
 	"github.com/korsana/backend/internal/models"
 )
 
 // RawActivity is the normalized representation of an activity from any source.
-// Each DataProvider maps its platform-specific fields into this struct.
+// Each DataProvider maps its platform-specific fields into this struct. It
+// is also what activity_source_records stores for each source.
 type RawActivity struct {
-	SourceID    string
-	Source      string // "strava", "garmin", "coros", "manual"
-	StartTime   time.Time
-	Duration    int     // seconds
-	Distance    float64 // meters
-	AvgPace     float64 // seconds per km
-	AvgHR       *int
-	MaxHR       *int
-	AvgCadence  *float64
-	ElevGain    *float64
-	SufferScore *int
-	SportType   string // "run", "long_run", "workout", "race"
-	Name        string
+	SourceID     string     `json:"source_id"`
+	Source       string     `json:"source"` // "strava", "garmin", "coros", "file", "apple_health", "manual"
+	StartTime    time.Time  `json:"start_time"`
+	LocalDate    *time.Time `json:"local_date,omitempty"` // athlete's calendar date; nil if the source doesn't say
+	Duration     int        `json:"duration"`             // seconds
+	Distance     float64    `json:"distance"`             // meters
+	AvgPace      float64    `json:"avg_pace"`             // seconds per km
+	AvgHR        *int       `json:"avg_hr,omitempty"`
+	MaxHR        *int       `json:"max_hr,omitempty"`
+	AvgCadence   *float64   `json:"avg_cadence,omitempty"`
+	ElevGain     *float64   `json:"elev_gain,omitempty"`
+	SufferScore  *int       `json:"suffer_score,omitempty"`
+	ActivityType string     `json:"activity_type"`          // models.ActivityType*
+	WorkoutType  string     `json:"workout_type,omitempty"` // models.WorkoutType*, or "" for an unflagged session
+	GearID       string     `json:"gear_id,omitempty"`
+	Name         string     `json:"name"`
+
+	// Details come from a source's single-activity endpoint, not its
+	// listings; see SaveDetails.
+	Details
+}
+
+// Details are the fields only a full activity download returns.
+type Details struct {
+	Description string   `json:"description,omitempty"`
+	DeviceName  string   `json:"device_name,omitempty"`
+	Calories    *float64 `json:"calories,omitempty"`
 }
 
 // DataProvider is the contract every integration must satisfy.
@@ -33,14 +49,14 @@ type DataProvider interface {
 
 	// FetchRecentActivities returns activities since the given time.
 	// Used for incremental syncs triggered by webhooks or manual sync.
-	FetchRecentActivities(integration *models.ConnectedIntegration, since time.Time) ([]RawActivity, error)
+	FetchRecentActivities(ctx context.Context, integration *models.ConnectedIntegration, since time.Time) ([]RawActivity, error)
 
 	// FetchAllActivities returns the full activity history for the user.
 	// Used when a higher-priority source is first connected so its data
 	// can upgrade existing lower-priority rows.
-	FetchAllActivities(integration *models.ConnectedIntegration) ([]RawActivity, error)
+	FetchAllActivities(ctx context.Context, integration *models.ConnectedIntegration) ([]RawActivity, error)
 
 	// RefreshTokenIfNeeded checks expiry and refreshes the stored token when needed.
 	// Implementations should update the ConnectedIntegration row in the DB.
-	RefreshTokenIfNeeded(integration *models.ConnectedIntegration) error
+	RefreshTokenIfNeeded(ctx context.Context, integration *models.ConnectedIntegration) error
 }
@@ -0,0 +1,15 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Querier is the subset of database methods the sync helpers need.
+// *database.DB and *sqlx.DB satisfy it, as do the services' own querier
+// interfaces, so callers can pass whatever handle they already hold.
+type Querier interface {
+	GetContext(ctx context.Context, dest any, query string, args ...any) error
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	SelectContext(ctx context.Context, dest any, query string, args ...any) error
+}
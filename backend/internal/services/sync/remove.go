@@ -0,0 +1,189 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// Detached is what DetachSource did with an activity.
+type Detached string
+
+const (
+	// DetachedDeleted removed the row: no other source had recorded it.
+	DetachedDeleted Detached = "deleted"
+	// DetachedRebuilt rebuilt the row from the other sources' copies.
+	DetachedRebuilt Detached = "rebuilt"
+	// DetachedNone found nothing of the source's to remove.
+	DetachedNone Detached = "none"
+)
+
+// DeleteActivity removes an activity and what was derived from it. Laps,
+// splits, streams, source records and its cross-training mirror cascade
+// with the row. Personal records detected from it are deleted; calendar
+// entries it created are deleted and planned entries it completed are
+// planned again. Weekly summaries are left to the caller.
+func DeleteActivity(ctx context.Context, db Querier, userID, activityID uuid.UUID) error {
+	var source string
+	err := db.GetContext(ctx, &source,
+		"SELECT source FROM activities WHERE id = $1 AND user_id = $2", activityID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("load activity for delete: %w", err)
+	}
+
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM personal_records
+		WHERE user_id = $1 AND activity_id = $2 AND source <> 'manual'
+	`, userID, activityID); err != nil {
+		return fmt.Errorf("delete derived personal records: %w", err)
+	}
+	// AutoMatchActivity tags the entries it creates with the activity's
+	// source; anything else was planned before the activity matched it.
+	if _, err := db.ExecContext(ctx, `
+		DELETE FROM training_calendar
+		WHERE user_id = $1 AND completed_activity_id = $2 AND source = $3
+	`, userID, activityID, source); err != nil {
+		return fmt.Errorf("delete calendar entries: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, `
+		UPDATE training_calendar
+		SET status = 'planned', completed_activity_id = NULL, updated_at = NOW()
+		WHERE user_id = $1 AND completed_activity_id = $2
+	`, userID, activityID); err != nil {
+		return fmt.Errorf("reopen planned calendar entries: %w", err)
+	}
+	if _, err := db.ExecContext(ctx,
+		"DELETE FROM activities WHERE id = $1 AND user_id = $2", activityID, userID); err != nil {
+		return fmt.Errorf("delete activity: %w", err)
+	}
+	return nil
+}
+
+// DetachSource removes everything source contributed to an activity. If no
+// other source recorded it, the activity is deleted through DeleteActivity.
+// Otherwise the row is rebuilt from the remaining copies; when source owned
+// it, the best remaining copy in the athlete's default order takes over and
+// the old owner's laps, splits and streams are dropped.
+func DetachSource(ctx context.Context, db Querier, userID, activityID uuid.UUID, source string) (Detached, error) {
+	var current models.Activity
+	err := db.GetContext(ctx, &current,
+		"SELECT * FROM activities WHERE id = $1 AND user_id = $2", activityID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return DetachedNone, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("load activity for detach: %w", err)
+	}
+
+	records, err := loadSourceRecords(ctx, db, activityID)
+	if err != nil {
+		return "", err
+	}
+	if len(records) == 0 {
+		// Stored before source records existed: the row is its source's copy.
+		records = []RawActivity{rawFromActivity(&current)}
+	}
+	var remaining []RawActivity
+	for _, r := range records {
+		if r.Source != source {
+			remaining = append(remaining, r)
+		}
+	}
+	if len(remaining) == len(records) && current.Source != source {
+		return DetachedNone, nil
+	}
+	if len(remaining) == 0 {
+		if err := DeleteActivity(ctx, db, userID, activityID); err != nil {
+			return "", err
+		}
+		return DetachedDeleted, nil
+	}
+
+	p, err := LoadPriorities(ctx, db, userID)
+	if err != nil {
+		return "", fmt.Errorf("load source priorities: %w", err)
+	}
+	owned := current.Source == source
+	owner := rawFromActivity(&current)
+	if owned {
+		owner = remaining[0]
+		for _, r := range remaining[1:] {
+			if p.HigherPriority(r.Source, owner.Source) {
+				owner = r
+			}
+		}
+	}
+	isOwner := func(r RawActivity) bool { return r.Source == owner.Source && r.SourceID == owner.SourceID }
+	for _, r := range remaining {
+		if isOwner(r) {
+			owner = r
+		}
+	}
+	sort.SliceStable(remaining, func(i, j int) bool {
+		return isOwner(remaining[i]) && !isOwner(remaining[j])
+	})
+
+	merged, provenance := mergeRecords(owner, remaining, p)
+	activity := merged.toActivity(userID)
+	activity.ID = activityID
+	activity.FieldSources = provenance
+	if err := updateActivity(ctx, db, activity); err != nil {
+		return "", err
+	}
+
+	if owned {
+		for _, table := range []string{"activity_laps", "activity_splits", "activity_streams"} {
+			if _, err := db.ExecContext(ctx, "DELETE FROM "+table+" WHERE activity_id = $1", activityID); err != nil {
+				return "", fmt.Errorf("clear %s: %w", table, err)
+			}
+		}
+		if err := MirrorCrossTraining(ctx, db, activity); err != nil {
+			return "", err
+		}
+	}
+	if _, err := db.ExecContext(ctx,
+		"DELETE FROM activity_source_records WHERE activity_id = $1 AND source = $2",
+		activityID, source); err != nil {
+		return "", fmt.Errorf("delete source records: %w", err)
+	}
+	return DetachedRebuilt, nil
+}
+
+// RemoveSource detaches source from every activity of the athlete's it
+// contributed to, returning how many were deleted and rebuilt.
+func RemoveSource(ctx context.Context, db Querier, userID uuid.UUID, source string) (deleted, rebuilt int, err error) {
+	var ids []uuid.UUID
+	if err := db.SelectContext(ctx, &ids, `
+		SELECT a.id FROM activities a
+		WHERE a.user_id = $1
+		  AND (a.source = $2 OR EXISTS (
+			SELECT 1 FROM activity_source_records r
+			WHERE r.activity_id = a.id AND r.source = $2
+		  ))
+	`, userID, source); err != nil {
+		return 0, 0, fmt.Errorf("list %s activities: %w", source, err)
+	}
+
+	for _, id := range ids {
+		detached, err := DetachSource(ctx, db, userID, id, source)
+		if err != nil {
+			return deleted, rebuilt, fmt.Errorf("detach %s from activity %s: %w", source, id, err)
+		}
+		switch detached {
+		case DetachedDeleted:
+			deleted++
+		case DetachedRebuilt:
+			rebuilt++
+		}
+	}
+	return deleted, rebuilt, nil
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
 
 	"github.com/korsana/backend/internal/logger"
 	"github.com/korsana/backend/internal/models"
@@ -18,25 +17,26 @@ import (
 // This should always be called in a goroutine so the OAuth callback is not
 // blocked waiting for a potentially long sync.
 //
-//	go sync.UpgradeHistoricalActivities(ctx, db, userID, provider, integration)
+//	go sync.UpgradeHistoricalActivities(ctx, db, calendar, userID, provider, integration)
 func UpgradeHistoricalActivities(
 	ctx context.Context,
-	db *sqlx.DB,
+	db Querier,
+	calendar CalendarMatcher,
 	userID uuid.UUID,
 	provider DataProvider,
 	integration *models.ConnectedIntegration,
 ) error {
-	if err := provider.RefreshTokenIfNeeded(integration); err != nil {
+	if err := provider.RefreshTokenIfNeeded(ctx, integration); err != nil {
 		return err
 	}
 
-	activities, err := provider.FetchAllActivities(integration)
+	activities, err := provider.FetchAllActivities(ctx, integration)
 	if err != nil {
 		return err
 	}
 
 	for _, a := range activities {
-		if err := UpsertActivity(ctx, db, userID, a); err != nil {
+		if _, err := UpsertActivity(ctx, db, calendar, userID, a); err != nil {
 			// Log and continue — a partial re-sync is better than none.
 			logger.FromContext(ctx).Warn("sync: failed to upsert activity",
 				"source_id", a.SourceID,
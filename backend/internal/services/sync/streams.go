@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+	"errors"
+
+	"github.com/korsana/backend/internal/models"
+)
+
+// ErrStreamsMisaligned is returned when a stream's length differs from the
+// time stream it is indexed against.
+var ErrStreamsMisaligned = errors.New("activity streams are not index-aligned")
+
+// SaveActivityStreams stores (or replaces) the streams for an activity.
+// Streams with no time samples are ignored; manual entries have none.
+func SaveActivityStreams(ctx context.Context, db Querier, streams *models.ActivityStreams) error {
+	n := len(streams.TimeOffsets)
+	if n == 0 {
+		return nil
+	}
+	for _, l := range []int{
+		len(streams.Distance), len(streams.HeartRate), len(streams.Velocity),
+		len(streams.Cadence), len(streams.Altitude), len(streams.Moving),
+	} {
+		if l != 0 && l != n {
+			return ErrStreamsMisaligned
+		}
+	}
+
+	var latlng any
+	if len(streams.LatLng) > 0 {
+		latlng = []byte(streams.LatLng)
+	}
+
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO activity_streams (
+			activity_id, user_id, source, sample_count, time_offsets,
+			distance_meters, heart_rate, velocity_mps, cadence,
+			altitude_meters, latlng, moving
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+		ON CONFLICT (activity_id) DO UPDATE SET
+			source = EXCLUDED.source,
+			sample_count = EXCLUDED.sample_count,
+			time_offsets = EXCLUDED.time_offsets,
+			distance_meters = EXCLUDED.distance_meters,
+			heart_rate = EXCLUDED.heart_rate,
+			velocity_mps = EXCLUDED.velocity_mps,
+			cadence = EXCLUDED.cadence,
+			altitude_meters = EXCLUDED.altitude_meters,
+			latlng = EXCLUDED.latlng,
+			moving = EXCLUDED.moving,
+			updated_at = NOW()
+	`,
+		streams.ActivityID, streams.UserID, streams.Source, n, streams.TimeOffsets,
+		nullableArray(streams.Distance), nullableArray(streams.HeartRate),
+		nullableArray(streams.Velocity), nullableArray(streams.Cadence),
+		nullableArray(streams.Altitude), latlng, nullableArray(streams.Moving),
+	)
+	return err
+}
+
+// nullableArray maps an empty pq array to SQL NULL so "not recorded" is
+// distinguishable from a recorded-but-empty series.
+func nullableArray[T interface{ ~[]E }, E any](a T) any {
+	if len(a) == 0 {
+		return nil
+	}
+	return a
+}
@@ -8,119 +8,329 @@ import (
 	"time"
 
 	"github.com/google/uuid"
-	"github.com/jmoiron/sqlx"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
 )
 
-// upsertDecision is the outcome of ShouldInsertOrUpgrade.
-type upsertDecision string
+// Decision is what UpsertActivity did with an incoming activity.
+type Decision string
 
 const (
-	decisionInsert  upsertDecision = "insert"
-	decisionUpgrade upsertDecision = "upgrade"
-	decisionSkip    upsertDecision = "skip"
+	// DecisionInsert stored a new row.
+	DecisionInsert Decision = "insert"
+	// DecisionUpdate refreshed the row the same source stored earlier.
+	DecisionUpdate Decision = "update"
+	// DecisionUpgrade replaced a lower-priority source's copy in place.
+	DecisionUpgrade Decision = "upgrade"
+	// DecisionMerge kept a higher-priority source's row but took some
+	// fields from the incoming source, per the athlete's field priorities.
+	DecisionMerge Decision = "merge"
+	// DecisionSkip left a higher-priority source's row untouched. The
+	// incoming copy is still kept for later merges.
+	DecisionSkip Decision = "skip"
 )
 
+// CalendarMatcher links a stored activity to the athlete's training calendar.
+// *services.CalendarService satisfies it.
+type CalendarMatcher interface {
+	AutoMatchActivity(ctx context.Context, userID uuid.UUID, activity *models.Activity) error
+}
+
+// UpsertResult describes the outcome of UpsertActivity.
+type UpsertResult struct {
+	Decision Decision
+	// ActivityID is the stored row the incoming activity maps to, including
+	// the row that caused a skip.
+	ActivityID uuid.UUID
+	// Activity is the row as written. Nil when skipped.
+	Activity *models.Activity
+}
+
 // shouldInsertOrUpgrade checks whether an incoming activity should be inserted
-// fresh, used to upgrade an existing lower-priority row, or skipped entirely.
+// fresh, used to update or upgrade an existing row, or skipped entirely.
 //
-// Match criteria: same user, start time within 2 minutes, distance within 5%.
-func shouldInsertOrUpgrade(ctx context.Context, db *sqlx.DB, userID uuid.UUID, incoming RawActivity) (upsertDecision, uuid.UUID, error) {
+// A row from the same source with the same source ID is always updated, and
+// a row already holding that source ID's copy is always the match.
+// Otherwise the match criteria are: same user, start time within 2 minutes,
+// distance within 5%. Which source owns a matched row follows the athlete's
+// default source order.
+func shouldInsertOrUpgrade(ctx context.Context, db Querier, userID uuid.UUID, incoming RawActivity, p Priorities) (Decision, uuid.UUID, error) {
+	var exactID uuid.UUID
+	err := db.GetContext(ctx, &exactID, `
+		SELECT id FROM activities
+		WHERE user_id = $1 AND source = $2 AND source_activity_id = $3
+	`, userID, incoming.Source, incoming.SourceID)
+	if err == nil {
+		return DecisionUpdate, exactID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", uuid.Nil, fmt.Errorf("shouldInsertOrUpgrade exact match: %w", err)
+	}
+
+	var existing struct {
+		ID     uuid.UUID `db:"id"`
+		Source string    `db:"source"`
+	}
+
+	// A copy merged into another source's row. Matching it by ID keeps an
+	// activity edited at the source (cropped, re-timed) on the same row
+	// even once it no longer passes the fuzzy match below.
+	err = db.GetContext(ctx, &existing, `
+		SELECT a.id, a.source FROM activities a
+		JOIN activity_source_records r ON r.activity_id = a.id
+		WHERE a.user_id = $1 AND r.source = $2 AND r.source_activity_id = $3
+		LIMIT 1
+	`, userID, incoming.Source, incoming.SourceID)
+	if err == nil {
+		if p.HigherPriority(incoming.Source, existing.Source) {
+			return DecisionUpgrade, existing.ID, nil
+		}
+		return DecisionSkip, existing.ID, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", uuid.Nil, fmt.Errorf("shouldInsertOrUpgrade source record match: %w", err)
+	}
+
+	// The fuzzy match only pairs copies from different sources: two
+	// activities from one source are distinct however close they are, and
+	// a row already holding a copy from this source is another activity.
 	query := `
 		SELECT id, source FROM activities
 		WHERE user_id = $1
+		  AND merged_into IS NULL
 		  AND ABS(EXTRACT(EPOCH FROM (start_time - $2))) < 120
 		  AND ABS(distance_meters - $3) / NULLIF($3, 0) < 0.05
+		  AND source <> $4
+		  AND NOT EXISTS (
+			SELECT 1 FROM activity_source_records r
+			WHERE r.activity_id = activities.id AND r.source = $4
+		  )
 		LIMIT 1
 	`
-	var existing struct {
-		ID     uuid.UUID `db:"id"`
-		Source string    `db:"source"`
-	}
-
-	err := db.GetContext(ctx, &existing, query, userID, incoming.StartTime, incoming.Distance)
+	err = db.GetContext(ctx, &existing, query, userID, incoming.StartTime, incoming.Distance, incoming.Source)
 	if errors.Is(err, sql.ErrNoRows) {
-		return decisionInsert, uuid.Nil, nil
+		return DecisionInsert, uuid.Nil, nil
 	}
 	if err != nil {
 		return "", uuid.Nil, fmt.Errorf("shouldInsertOrUpgrade query: %w", err)
 	}
 
-	if HigherPriority(incoming.Source, existing.Source) {
-		return decisionUpgrade, existing.ID, nil
+	if p.HigherPriority(incoming.Source, existing.Source) {
+		return DecisionUpgrade, existing.ID, nil
 	}
-	return decisionSkip, existing.ID, nil
+	return DecisionSkip, existing.ID, nil
 }
 
 // UpsertActivity normalizes a RawActivity and applies the priority-aware
 // insert/upgrade/skip logic. On upgrade the existing row's ID is preserved
 // so calendar entries and AI coach history remain intact.
-func UpsertActivity(ctx context.Context, db *sqlx.DB, userID uuid.UUID, raw RawActivity) error {
-	decision, existingID, err := shouldInsertOrUpgrade(ctx, db, userID, raw)
+//
+// Each source's copy is kept in activity_source_records and the row takes
+// every field group from the best copy that has it, so a lower-priority
+// source can still supply, say, heart rate. field_sources records which
+// source won each group.
+//
+// Every written row is mirrored into cross_training_sessions (non-runs),
+// checked for looser duplicates to review and, when calendar is non-nil,
+// matched against the training calendar, so each source behaves the same
+// downstream.
+func UpsertActivity(ctx context.Context, db Querier, calendar CalendarMatcher, userID uuid.UUID, raw RawActivity) (*UpsertResult, error) {
+	priorities, err := LoadPriorities(ctx, db, userID)
+	if err != nil {
+		return nil, fmt.Errorf("load source priorities: %w", err)
+	}
+	decision, existingID, err := shouldInsertOrUpgrade(ctx, db, userID, raw, priorities)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	result := &UpsertResult{Decision: decision, ActivityID: existingID}
+	var activity *models.Activity
 	switch decision {
-	case decisionInsert:
-		return insertActivity(ctx, db, userID, raw)
-	case decisionUpgrade:
-		return upgradeActivity(ctx, db, existingID, raw)
-	case decisionSkip:
-		return nil
+	case DecisionInsert:
+		activity = raw.toActivity(userID)
+		_, activity.FieldSources = mergeRecords(raw, []RawActivity{raw}, priorities)
+		if err := insertActivity(ctx, db, activity); err != nil {
+			return nil, err
+		}
+		if err := saveSourceRecord(ctx, db, activity.ID, raw); err != nil {
+			return nil, err
+		}
 	default:
-		return nil
+		activity, err = mergeSourceRecords(ctx, db, userID, existingID, raw, decision != DecisionSkip, priorities)
+		if err != nil {
+			return nil, err
+		}
+		if activity == nil {
+			return result, nil
+		}
+		if decision == DecisionSkip {
+			result.Decision = DecisionMerge
+		}
+		if err := updateActivity(ctx, db, activity); err != nil {
+			return nil, err
+		}
+	}
+	result.ActivityID = activity.ID
+	result.Activity = activity
+
+	log := logger.FromContext(ctx)
+	if err := MirrorCrossTraining(ctx, db, activity); err != nil {
+		log.Warn("sync: failed to mirror cross-training session",
+			"activity_id", activity.ID,
+			"source", activity.Source,
+			"error", err,
+		)
+	}
+	if err := RecordDuplicateCandidates(ctx, db, activity); err != nil {
+		log.Warn("sync: failed to record duplicate candidates",
+			"activity_id", activity.ID,
+			"source", activity.Source,
+			"error", err,
+		)
 	}
+	if calendar != nil {
+		if err := calendar.AutoMatchActivity(ctx, userID, activity); err != nil {
+			log.Warn("sync: failed to match activity to calendar",
+				"activity_id", activity.ID,
+				"source", activity.Source,
+				"error", err,
+			)
+		}
+	}
+	return result, nil
+}
+
+func (raw RawActivity) toActivity(userID uuid.UUID) *models.Activity {
+	activity := &models.Activity{
+		ID:                      uuid.New(),
+		UserID:                  userID,
+		Source:                  raw.Source,
+		SourceActivityID:        raw.SourceID,
+		ActivityType:            raw.ActivityType,
+		Name:                    raw.Name,
+		DistanceMeters:          raw.Distance,
+		DurationSeconds:         raw.Duration,
+		StartTime:               raw.StartTime,
+		LocalDate:               raw.LocalDate,
+		AveragePaceSecondsPerKm: raw.AvgPace,
+		AverageHeartRate:        raw.AvgHR,
+		MaxHeartRate:            raw.MaxHR,
+		ElevationGainMeters:     raw.ElevGain,
+		AverageCadence:          raw.AvgCadence,
+		SufferScore:             raw.SufferScore,
+		SyncedAt:                time.Now(),
+	}
+	if raw.WorkoutType != "" {
+		workoutType := raw.WorkoutType
+		activity.WorkoutType = &workoutType
+	}
+	if raw.GearID != "" {
+		gearID := raw.GearID
+		activity.GearID = &gearID
+	}
+	if raw.Description != "" {
+		description := raw.Description
+		activity.Description = &description
+	}
+	if raw.DeviceName != "" {
+		deviceName := raw.DeviceName
+		activity.DeviceName = &deviceName
+	}
+	activity.Calories = raw.Calories
+	return activity
 }
 
-func insertActivity(ctx context.Context, db *sqlx.DB, userID uuid.UUID, raw RawActivity) error {
+// insertActivity stores a new row. A concurrent insert of the same source
+// activity (webhook and manual sync racing) turns into an update, and the
+// surviving row's ID is written back to activity.
+func insertActivity(ctx context.Context, db Querier, activity *models.Activity) error {
 	query := `
 		INSERT INTO activities (
 			id, user_id, source, source_activity_id, activity_type, name,
-			distance_meters, duration_seconds, start_time, average_pace_seconds_per_km,
-			average_heart_rate, max_heart_rate, elevation_gain_meters,
-			average_cadence, suffer_score, synced_at
+			distance_meters, duration_seconds, start_time, local_date,
+			average_pace_seconds_per_km, average_heart_rate, max_heart_rate,
+			elevation_gain_meters, average_cadence, suffer_score,
+			workout_type, gear_id, synced_at, field_sources,
+			description, device_name, calories
 		) VALUES (
 			$1, $2, $3, $4, $5, $6,
 			$7, $8, $9, $10,
 			$11, $12, $13,
-			$14, $15, $16
+			$14, $15, $16,
+			$17, $18, $19, $20,
+			$21, $22, $23
 		)
-		ON CONFLICT (user_id, source, source_activity_id) DO NOTHING
+		ON CONFLICT (user_id, source, source_activity_id) DO UPDATE SET
+			activity_type = EXCLUDED.activity_type,
+			name = EXCLUDED.name,
+			distance_meters = EXCLUDED.distance_meters,
+			duration_seconds = EXCLUDED.duration_seconds,
+			start_time = EXCLUDED.start_time,
+			local_date = EXCLUDED.local_date,
+			average_pace_seconds_per_km = EXCLUDED.average_pace_seconds_per_km,
+			average_heart_rate = EXCLUDED.average_heart_rate,
+			max_heart_rate = EXCLUDED.max_heart_rate,
+			elevation_gain_meters = EXCLUDED.elevation_gain_meters,
+			average_cadence = EXCLUDED.average_cadence,
+			suffer_score = EXCLUDED.suffer_score,
+			workout_type = EXCLUDED.workout_type,
+			gear_id = EXCLUDED.gear_id,
+			synced_at = EXCLUDED.synced_at,
+			field_sources = EXCLUDED.field_sources
+		RETURNING id
 	`
-	_, err := db.ExecContext(ctx, query,
-		uuid.New(), userID, raw.Source, raw.SourceID, raw.SportType, raw.Name,
-		raw.Distance, raw.Duration, raw.StartTime, raw.AvgPace,
-		raw.AvgHR, raw.MaxHR, raw.ElevGain,
-		raw.AvgCadence, raw.SufferScore, time.Now(),
+	return db.GetContext(ctx, &activity.ID, query,
+		activity.ID, activity.UserID, activity.Source, activity.SourceActivityID,
+		activity.ActivityType, activity.Name,
+		activity.DistanceMeters, activity.DurationSeconds, activity.StartTime, activity.LocalDate,
+		activity.AveragePaceSecondsPerKm, activity.AverageHeartRate, activity.MaxHeartRate,
+		activity.ElevationGainMeters, activity.AverageCadence, activity.SufferScore,
+		activity.WorkoutType, activity.GearID, activity.SyncedAt, activity.FieldSources,
+		activity.Description, activity.DeviceName, activity.Calories,
 	)
-	return err
 }
 
-func upgradeActivity(ctx context.Context, db *sqlx.DB, id uuid.UUID, raw RawActivity) error {
-	// Preserve the existing row ID so downstream references stay intact.
+// updateActivity overwrites an existing row in place, keeping its ID so
+// downstream references stay intact. Detail data belongs to the source that
+// produced it, so a change of source clears details_synced_at and the new
+// source's details are fetched on the next enrichment pass.
+func updateActivity(ctx context.Context, db Querier, activity *models.Activity) error {
 	query := `
 		UPDATE activities SET
-			source                    = $1,
-			source_activity_id        = $2,
-			activity_type             = $3,
-			name                      = $4,
-			distance_meters           = $5,
-			duration_seconds          = $6,
-			average_pace_seconds_per_km = $7,
-			average_heart_rate        = $8,
-			max_heart_rate            = $9,
-			elevation_gain_meters     = $10,
-			average_cadence           = $11,
-			suffer_score              = $12,
-			synced_at                 = $13
-		WHERE id = $14
+			details_synced_at           = CASE WHEN source = $1 THEN details_synced_at END,
+			source                      = $1,
+			source_activity_id          = $2,
+			activity_type               = $3,
+			name                        = $4,
+			distance_meters             = $5,
+			duration_seconds            = $6,
+			start_time                  = $7,
+			local_date                  = $8,
+			average_pace_seconds_per_km = $9,
+			average_heart_rate          = $10,
+			max_heart_rate              = $11,
+			elevation_gain_meters       = $12,
+			average_cadence             = $13,
+			suffer_score                = $14,
+			workout_type                = $15,
+			gear_id                     = $16,
+			synced_at                   = $17,
+			field_sources               = $19,
+			description                 = $20,
+			device_name                 = $21,
+			calories                    = $22
+		WHERE id = $18
 	`
 	_, err := db.ExecContext(ctx, query,
-		raw.Source, raw.SourceID, raw.SportType, raw.Name,
-		raw.Distance, raw.Duration, raw.AvgPace,
-		raw.AvgHR, raw.MaxHR, raw.ElevGain,
-		raw.AvgCadence, raw.SufferScore, time.Now(),
-		id,
+		activity.Source, activity.SourceActivityID, activity.ActivityType, activity.Name,
+		activity.DistanceMeters, activity.DurationSeconds, activity.StartTime, activity.LocalDate,
+		activity.AveragePaceSecondsPerKm, activity.AverageHeartRate, activity.MaxHeartRate,
+		activity.ElevationGainMeters, activity.AverageCadence, activity.SufferScore,
+		activity.WorkoutType, activity.GearID, activity.SyncedAt,
+		activity.ID, activity.FieldSources,
+		activity.Description, activity.DeviceName, activity.Calories,
 	)
 	return err
 }
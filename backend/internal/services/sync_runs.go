@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/coros"
+	"github.com/korsana/backend/pkg/garmin"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+// syncRunRecordTimeout bounds the sync_runs insert, which runs detached
+// from the sync's own context.
+const syncRunRecordTimeout = 5 * time.Second
+
+// syncRun accumulates one sync attempt for sync_runs.
+type syncRun struct {
+	models.SyncRun
+	// partial is set when the provider stopped the run early.
+	partial bool
+}
+
+func startSyncRun(userID uuid.UUID, provider, mode string) *syncRun {
+	return &syncRun{SyncRun: models.SyncRun{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Provider:  provider,
+		Mode:      mode,
+		StartedAt: time.Now(),
+	}}
+}
+
+// tally counts the outcome of one sync.UpsertActivity call.
+func (r *syncRun) tally(result *sync.UpsertResult, err error) {
+	if err != nil {
+		r.Failed++
+		return
+	}
+	switch result.Decision {
+	case sync.DecisionInsert:
+		r.Inserted++
+	case sync.DecisionSkip:
+		r.Skipped++
+	default:
+		r.Updated++
+	}
+}
+
+// finish stores the run with runErr as its outcome. The sync itself has
+// already happened, so a failure to store the run is only logged. The row
+// is written even when ctx has timed out or been cancelled: the scheduler
+// picks athletes by their last run, and a lost row would pick the same one
+// again.
+func (r *syncRun) finish(ctx context.Context, db sync.Querier, runErr error) {
+	r.FinishedAt = time.Now()
+	r.DurationMs = r.FinishedAt.Sub(r.StartedAt).Milliseconds()
+	switch {
+	case runErr != nil:
+		r.Status = models.SyncStatusFailed
+		class, msg := classifySyncError(runErr), runErr.Error()
+		r.ErrorClass, r.ErrorMessage = &class, &msg
+	case r.partial || r.Failed > 0:
+		r.Status = models.SyncStatusPartial
+	default:
+		r.Status = models.SyncStatusSuccess
+	}
+
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), syncRunRecordTimeout)
+	defer cancel()
+	_, err := db.ExecContext(recordCtx, `
+		INSERT INTO sync_runs (
+			id, user_id, provider, mode, status, pages_fetched,
+			inserted, updated, skipped, failed, error_class, error_message,
+			started_at, finished_at, duration_ms
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
+	`, r.ID, r.UserID, r.Provider, r.Mode, r.Status, r.PagesFetched,
+		r.Inserted, r.Updated, r.Skipped, r.Failed, r.ErrorClass, r.ErrorMessage,
+		r.StartedAt, r.FinishedAt, r.DurationMs)
+	if err != nil {
+		logger.FromContext(ctx).Warn("sync: failed to record sync run",
+			"user_id", r.UserID,
+			"provider", r.Provider,
+			"error", err,
+		)
+	}
+}
+
+// classifySyncError buckets a failed run's error into a models.SyncError*
+// class.
+func classifySyncError(err error) string {
+	if errors.Is(err, ErrStravaRateLimited) {
+		return models.SyncErrorRateLimited
+	}
+	if errors.Is(err, ErrIntegrationReauthRequired) {
+		return models.SyncErrorTokenRevoked
+	}
+	if errors.Is(err, ErrStravaConnectionNotFound) ||
+		errors.Is(err, ErrGarminConnectionNotFound) ||
+		errors.Is(err, ErrCorosConnectionNotFound) {
+		return models.SyncErrorNotConnected
+	}
+
+	status := 0
+	var stravaErr *strava.APIError
+	var garminErr *garmin.APIError
+	var corosErr *coros.APIError
+	switch {
+	case errors.As(err, &stravaErr):
+		status = stravaErr.StatusCode
+	case errors.As(err, &garminErr):
+		status = garminErr.StatusCode
+	case errors.As(err, &corosErr):
+		status = corosErr.StatusCode
+	}
+	switch {
+	case status == 429:
+		return models.SyncErrorRateLimited
+	case status == 401 || status == 403:
+		return models.SyncErrorTokenRevoked
+	case status != 0:
+		return models.SyncErrorProvider
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) || errors.Is(err, sql.ErrConnDone) || errors.Is(err, driver.ErrBadConn) {
+		return models.SyncErrorDatabase
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return models.SyncErrorProvider
+	}
+	return models.SyncErrorUnknown
+}
+
+// ListSyncRuns returns the user's sync attempts, newest first, optionally
+// for one provider, with the total count for pagination.
+func (s *IntegrationsService) ListSyncRuns(ctx context.Context, userID uuid.UUID, provider string, limit, offset int) ([]models.SyncRun, int, error) {
+	runs := []models.SyncRun{}
+	if err := s.db.SelectContext(ctx, &runs, `
+		SELECT * FROM sync_runs
+		WHERE user_id = $1 AND ($2 = '' OR provider = $2)
+		ORDER BY started_at DESC
+		LIMIT $3 OFFSET $4
+	`, userID, provider, limit, offset); err != nil {
+		return nil, 0, err
+	}
+
+	var total int
+	if err := s.db.GetContext(ctx, &total, `
+		SELECT COUNT(*) FROM sync_runs WHERE user_id = $1 AND ($2 = '' OR provider = $2)
+	`, userID, provider); err != nil {
+		return nil, 0, err
+	}
+	return runs, total, nil
+}
@@ -0,0 +1,89 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+	"github.com/korsana/backend/pkg/coros"
+	"github.com/korsana/backend/pkg/strava"
+)
+
+func TestClassifySyncError(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("%w: %v", ErrStravaRateLimited, errors.New("429")), models.SyncErrorRateLimited},
+		{&strava.APIError{StatusCode: 429}, models.SyncErrorRateLimited},
+		{fmt.Errorf("%w: %w", ErrIntegrationReauthRequired, &strava.APIError{StatusCode: 400}), models.SyncErrorTokenRevoked},
+		{&coros.APIError{StatusCode: 401}, models.SyncErrorTokenRevoked},
+		{ErrStravaConnectionNotFound, models.SyncErrorNotConnected},
+		{fmt.Errorf("all 3 activities failed to save: %w", &pq.Error{Code: "42P01"}), models.SyncErrorDatabase},
+		{&strava.APIError{StatusCode: 502}, models.SyncErrorProvider},
+		{errors.New("boom"), models.SyncErrorUnknown},
+	}
+	for _, tc := range cases {
+		if got := classifySyncError(tc.err); got != tc.want {
+			t.Errorf("classifySyncError(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestSyncRunFinish(t *testing.T) {
+	db := &archiveTestDB{}
+	run := startSyncRun(uuid.New(), "strava", models.SyncModeIncremental)
+	run.tally(&sync.UpsertResult{Decision: sync.DecisionInsert}, nil)
+	run.tally(&sync.UpsertResult{Decision: sync.DecisionMerge}, nil)
+	run.tally(&sync.UpsertResult{Decision: sync.DecisionSkip}, nil)
+	run.tally(nil, errors.New("insert failed"))
+	run.finish(context.Background(), db, nil)
+
+	if run.Inserted != 1 || run.Updated != 1 || run.Skipped != 1 || run.Failed != 1 {
+		t.Fatalf("unexpected counts: %+v", run.SyncRun)
+	}
+	if run.Status != models.SyncStatusPartial || run.ErrorClass != nil {
+		t.Fatalf("expected a partial run without error class, got %q %v", run.Status, run.ErrorClass)
+	}
+	if got := len(db.execsMatching("INSERT INTO sync_runs")); got != 1 {
+		t.Fatalf("expected the run to be stored, got %d writes", got)
+	}
+
+	failed := startSyncRun(uuid.New(), "coros", models.SyncModeWebhook)
+	failed.finish(context.Background(), db, ErrCorosConnectionNotFound)
+	if failed.Status != models.SyncStatusFailed || failed.ErrorClass == nil || *failed.ErrorClass != models.SyncErrorNotConnected {
+		t.Fatalf("expected a failed not_connected run, got %q %v", failed.Status, failed.ErrorClass)
+	}
+}
+
+// cancelAwareTestDB fails writes on a done context, as a real driver does.
+type cancelAwareTestDB struct {
+	archiveTestDB
+}
+
+func (db *cancelAwareTestDB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return db.archiveTestDB.ExecContext(ctx, query, args...)
+}
+
+func TestSyncRunFinishOutlivesCancelledSync(t *testing.T) {
+	db := &cancelAwareTestDB{}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	run := startSyncRun(uuid.New(), "strava", models.SyncModeIncremental)
+	run.finish(ctx, db, ctx.Err())
+
+	if got := len(db.execsMatching("INSERT INTO sync_runs")); got != 1 {
+		t.Fatalf("expected the run to be stored after cancellation, got %d writes", got)
+	}
+}
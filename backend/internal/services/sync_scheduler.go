@@ -0,0 +1,274 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/logger"
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/services/sync"
+)
+
+const (
+	// syncSchedulerLockKey is the Postgres advisory lock the scheduling
+	// replica holds. Only the holder dispatches syncs, so pacing and quota
+	// checks are global rather than per replica.
+	syncSchedulerLockKey int64 = 0x6b6f7273616e6101
+
+	// syncSchedulerTick spaces scheduled syncs: at most one per provider
+	// per tick.
+	syncSchedulerTick = 45 * time.Second
+
+	syncSchedulerTimeout = 2 * time.Minute
+
+	// syncScheduleInterval is how long after its last sync run (manual,
+	// webhook or scheduled) a connection is due again.
+	syncScheduleInterval = 6 * time.Hour
+
+	// Athletes with no activity for syncDormantAfter are dormant and only
+	// checked every syncDormantInterval, so a returning athlete is still
+	// picked up without spending quota on abandoned accounts.
+	syncDormantAfter    = 30 * 24 * time.Hour
+	syncDormantInterval = 7 * 24 * time.Hour
+
+	// Strava's default app-wide limit is 100 read requests per 15 minutes
+	// and 1,000 per day. A sync run costs one list request plus detail
+	// requests for whatever is new, so the budgets count runs of every
+	// mode from sync_runs and stop scheduling well before the limit,
+	// leaving the rest for interactive syncs, webhooks and the history
+	// backfill, which paces itself separately.
+	stravaWindowSyncBudget = 15
+	stravaDailySyncBudget  = 250
+	stravaRateLimitWindow  = 15 * time.Minute
+)
+
+// scheduledProvider is one source the scheduler syncs.
+type scheduledProvider struct {
+	source string
+	sync   func(ctx context.Context, userID uuid.UUID) error
+	// hasBudget, if set, reports whether the source's API quota has room
+	// for another run.
+	hasBudget func(ctx context.Context) (bool, error)
+}
+
+// SyncScheduler periodically runs incremental syncs for every active
+// connection, so athletes who never open the app still have fresh data for
+// digests and coaching. Every replica runs one; the replica holding the
+// advisory lock does the work and the others wait to take over.
+type SyncScheduler struct {
+	db        *database.DB
+	q         sync.Querier
+	providers []scheduledProvider
+	tick      time.Duration
+
+	// leader is the connection holding syncSchedulerLockKey. A session
+	// lock lives as long as its connection, so a crashed replica releases
+	// it automatically.
+	leader *sqlx.Conn
+	// pausedUntil stops a source after it reported a rate limit.
+	pausedUntil map[string]time.Time
+}
+
+// NewSyncScheduler creates a scheduler for Strava and, when configured,
+// COROS. Garmin pushes its data and needs no polling.
+func NewSyncScheduler(db *database.DB, strava *StravaService, coros *CorosService) *SyncScheduler {
+	w := &SyncScheduler{
+		db:          db,
+		q:           db,
+		tick:        syncSchedulerTick,
+		pausedUntil: make(map[string]time.Time),
+	}
+	w.providers = append(w.providers, scheduledProvider{
+		source: "strava",
+		// Once a day a scheduled sync also re-reads the recent window, so
+		// edits and deletions missed by webhooks still arrive.
+		sync: func(ctx context.Context, userID uuid.UUID) error {
+			if _, err := strava.SyncActivities(ctx, userID); err != nil {
+				return err
+			}
+			due, err := strava.reconcileDue(ctx, userID)
+			if err != nil || !due {
+				return err
+			}
+			_, err = strava.ReconcileActivities(ctx, userID)
+			return err
+		},
+		hasBudget: func(ctx context.Context) (bool, error) {
+			if !strava.allowsBackgroundWork(ctx) {
+				return false, nil
+			}
+			return w.stravaHasBudget(ctx)
+		},
+	})
+	if coros.Configured() {
+		w.providers = append(w.providers, scheduledProvider{
+			source: "coros",
+			sync: func(ctx context.Context, userID uuid.UUID) error {
+				_, err := coros.SyncActivities(ctx, userID)
+				return err
+			},
+		})
+	}
+	return w
+}
+
+// Run schedules syncs until ctx is cancelled.
+func (w *SyncScheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.tick)
+	defer ticker.Stop()
+	defer w.resign()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			leading, err := w.lead(ctx)
+			if err != nil {
+				logger.FromContext(ctx).Error("sync scheduler: leader lock failed", "error", err)
+				continue
+			}
+			if leading {
+				w.dispatch(ctx)
+			}
+		}
+	}
+}
+
+// lead reports whether this replica holds the scheduler lock, taking it
+// if it is free.
+func (w *SyncScheduler) lead(ctx context.Context) (bool, error) {
+	if w.leader != nil {
+		if err := w.leader.PingContext(ctx); err == nil {
+			return true, nil
+		}
+		w.leader.Close()
+		w.leader = nil
+	}
+
+	conn, err := w.db.Connx(ctx)
+	if err != nil {
+		return false, err
+	}
+	var locked bool
+	if err := conn.GetContext(ctx, &locked, "SELECT pg_try_advisory_lock($1)", syncSchedulerLockKey); err != nil {
+		conn.Close()
+		return false, err
+	}
+	if !locked {
+		conn.Close()
+		return false, nil
+	}
+	logger.FromContext(ctx).Info("sync scheduler: this replica is scheduling")
+	w.leader = conn
+	return true, nil
+}
+
+// resign releases the lock so another replica can take over at once.
+func (w *SyncScheduler) resign() {
+	if w.leader == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, _ = w.leader.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", syncSchedulerLockKey)
+	w.leader.Close()
+	w.leader = nil
+}
+
+// dispatch runs the next due sync for each provider.
+func (w *SyncScheduler) dispatch(ctx context.Context) {
+	for _, p := range w.providers {
+		if err := w.syncNext(ctx, p); err != nil {
+			logger.FromContext(ctx).Error("sync scheduler: dispatch failed",
+				"provider", p.source,
+				"error", err,
+			)
+		}
+	}
+}
+
+func (w *SyncScheduler) syncNext(ctx context.Context, p scheduledProvider) error {
+	if time.Now().Before(w.pausedUntil[p.source]) {
+		return nil
+	}
+	if p.hasBudget != nil {
+		ok, err := p.hasBudget(ctx)
+		if err != nil || !ok {
+			return err
+		}
+	}
+
+	userID, err := w.nextDue(ctx, p.source)
+	if err != nil || userID == uuid.Nil {
+		return err
+	}
+
+	log := logger.FromContext(ctx).With("provider", p.source, "user_id", userID)
+	syncCtx, cancel := context.WithTimeout(logger.WithLogger(ctx, log), syncSchedulerTimeout)
+	defer cancel()
+
+	// Failures are already in sync_runs, which also keeps the connection
+	// from being picked again until its next interval.
+	if err := p.sync(syncCtx, userID); err != nil {
+		log.Warn("sync scheduler: scheduled sync failed", "error", err)
+		if classifySyncError(err) == models.SyncErrorRateLimited {
+			w.pausedUntil[p.source] = time.Now().Truncate(stravaRateLimitWindow).Add(stravaRateLimitWindow)
+		}
+	}
+	return nil
+}
+
+// nextDue returns the active connection of source that has gone longest
+// without a sync run, if any is due.
+func (w *SyncScheduler) nextDue(ctx context.Context, source string) (uuid.UUID, error) {
+	var userID uuid.UUID
+	err := w.q.GetContext(ctx, &userID, `
+		SELECT ci.user_id
+		FROM connected_integrations ci
+		LEFT JOIN LATERAL (
+			SELECT MAX(started_at) AS last_run FROM sync_runs r
+			WHERE r.user_id = ci.user_id AND r.provider = ci.source
+		) runs ON true
+		LEFT JOIN LATERAL (
+			SELECT MAX(start_time) AS last_activity FROM activities a
+			WHERE a.user_id = ci.user_id
+		) acts ON true
+		WHERE ci.source = $1 AND ci.is_active AND ci.status = $2
+		  AND (runs.last_run IS NULL OR runs.last_run < NOW() - INTERVAL '1 second' * CASE
+				WHEN ci.connected_at < NOW() - INTERVAL '1 second' * $5
+				 AND (acts.last_activity IS NULL OR acts.last_activity < NOW() - INTERVAL '1 second' * $5)
+				THEN $4 ELSE $3 END)
+		ORDER BY runs.last_run NULLS FIRST
+		LIMIT 1
+	`, source, models.IntegrationStatusActive,
+		syncScheduleInterval.Seconds(), syncDormantInterval.Seconds(), syncDormantAfter.Seconds())
+	if errors.Is(err, sql.ErrNoRows) {
+		return uuid.Nil, nil
+	}
+	return userID, err
+}
+
+// stravaHasBudget checks the Strava runs of the current rate-limit window
+// and UTC day against the budgets. Strava resets both on those boundaries.
+func (w *SyncScheduler) stravaHasBudget(ctx context.Context) (bool, error) {
+	now := time.Now().UTC()
+	var used struct {
+		Window int `db:"window_runs"`
+		Day    int `db:"day_runs"`
+	}
+	if err := w.q.GetContext(ctx, &used, `
+		SELECT COUNT(*) FILTER (WHERE started_at >= $2) AS window_runs, COUNT(*) AS day_runs
+		FROM sync_runs
+		WHERE provider = 'strava' AND started_at >= $1
+	`, now.Truncate(24*time.Hour), now.Truncate(stravaRateLimitWindow)); err != nil {
+		return false, err
+	}
+	return used.Window < stravaWindowSyncBudget && used.Day < stravaDailySyncBudget, nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// schedulerTestDB returns due as the next connection to sync.
+type schedulerTestDB struct {
+	archiveTestDB
+	due uuid.UUID
+}
+
+func (db *schedulerTestDB) GetContext(_ context.Context, dest any, _ string, _ ...any) error {
+	if d, ok := dest.(*uuid.UUID); ok && db.due != uuid.Nil {
+		*d = db.due
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func TestSyncSchedulerSyncNext(t *testing.T) {
+	db := &schedulerTestDB{due: uuid.New()}
+	w := &SyncScheduler{q: db, pausedUntil: map[string]time.Time{}}
+
+	var synced []uuid.UUID
+	var syncErr error
+	budget := false
+	p := scheduledProvider{
+		source: "strava",
+		sync: func(_ context.Context, userID uuid.UUID) error {
+			synced = append(synced, userID)
+			return syncErr
+		},
+		hasBudget: func(context.Context) (bool, error) { return budget, nil },
+	}
+	ctx := context.Background()
+
+	if err := w.syncNext(ctx, p); err != nil || len(synced) != 0 {
+		t.Fatalf("expected no sync without budget, got %v %v", synced, err)
+	}
+
+	budget = true
+	if err := w.syncNext(ctx, p); err != nil || len(synced) != 1 || synced[0] != db.due {
+		t.Fatalf("expected the due athlete to sync, got %v %v", synced, err)
+	}
+
+	syncErr = ErrStravaRateLimited
+	if err := w.syncNext(ctx, p); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := w.syncNext(ctx, p); err != nil || len(synced) != 2 {
+		t.Fatalf("expected the rate limit to pause strava, got %d syncs", len(synced))
+	}
+
+	db.due = uuid.Nil
+	w.pausedUntil = map[string]time.Time{}
+	if err := w.syncNext(ctx, p); err != nil || len(synced) != 2 {
+		t.Fatalf("expected nothing to sync when no connection is due, got %d syncs", len(synced))
+	}
+}
@@ -0,0 +1,80 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/tokencrypt"
+)
+
+// TokenRotationResult summarizes a RotateIntegrationTokens pass.
+type TokenRotationResult struct {
+	Scanned   int `json:"scanned"`
+	Rotated   int `json:"rotated"`
+	Unchanged int `json:"unchanged"`
+	// Raced counts rows whose tokens were refreshed while the pass ran.
+	// The refresh already sealed them, but a rerun confirms it.
+	Raced int `json:"raced"`
+}
+
+// RotateIntegrationTokens re-seals every stored OAuth token that is still
+// plaintext or sealed with a key other than the keyring's active one. Once
+// a pass reports nothing left to rotate, retired keys can be dropped from
+// TOKEN_ENCRYPTION_KEYS. With dryRun set rows are counted but not written.
+func RotateIntegrationTokens(ctx context.Context, db integrationsQuerier, keys *tokencrypt.Keyring, dryRun bool) (*TokenRotationResult, error) {
+	if keys == nil {
+		return nil, fmt.Errorf("cannot rotate tokens: %w", tokencrypt.ErrNoKeyring)
+	}
+
+	var rows []models.ConnectedIntegration
+	if err := db.SelectContext(ctx, &rows, "SELECT * FROM connected_integrations ORDER BY id"); err != nil {
+		return nil, err
+	}
+
+	result := &TokenRotationResult{Scanned: len(rows)}
+	for _, row := range rows {
+		if !keys.NeedsRotation(row.AccessToken) && (row.RefreshToken == nil || !keys.NeedsRotation(*row.RefreshToken)) {
+			result.Unchanged++
+			continue
+		}
+		if dryRun {
+			result.Rotated++
+			continue
+		}
+
+		plain := integrationTokens{}
+		var err error
+		if plain.AccessToken, err = keys.Open(row.AccessToken); err != nil {
+			return result, fmt.Errorf("integration %s (%s): %w", row.ID, row.Source, err)
+		}
+		if row.RefreshToken != nil {
+			refresh, err := keys.Open(*row.RefreshToken)
+			if err != nil {
+				return result, fmt.Errorf("integration %s (%s): %w", row.ID, row.Source, err)
+			}
+			plain.RefreshToken = &refresh
+		}
+		sealed, err := plain.seal(keys)
+		if err != nil {
+			return result, err
+		}
+
+		// Only overwrite the values read above; a concurrent refresh has
+		// already stored newer tokens under the active key.
+		res, err := db.ExecContext(ctx, `
+			UPDATE connected_integrations
+			SET access_token = $1, refresh_token = $2
+			WHERE id = $3 AND access_token = $4 AND refresh_token IS NOT DISTINCT FROM $5
+		`, sealed.AccessToken, sealed.RefreshToken, row.ID, row.AccessToken, row.RefreshToken)
+		if err != nil {
+			return result, fmt.Errorf("integration %s (%s): %w", row.ID, row.Source, err)
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			result.Raced++
+			continue
+		}
+		result.Rotated++
+	}
+	return result, nil
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/korsana/backend/internal/models"
+	"github.com/korsana/backend/internal/tokencrypt"
+)
+
+// tokenStoreTestDB keeps connected_integrations rows in memory, as stored.
+type tokenStoreTestDB struct {
+	rows []*models.ConnectedIntegration
+}
+
+func (db *tokenStoreTestDB) GetContext(_ context.Context, dest any, query string, args ...any) error {
+	row := dest.(*models.ConnectedIntegration)
+	if strings.Contains(query, "INSERT INTO connected_integrations") {
+		stored := &models.ConnectedIntegration{
+			ID:           uuid.New(),
+			UserID:       args[0].(uuid.UUID),
+			Source:       args[1].(string),
+			AccessToken:  args[2].(string),
+			RefreshToken: args[3].(*string),
+		}
+		db.rows = append(db.rows, stored)
+		*row = *stored
+		return nil
+	}
+	for _, stored := range db.rows {
+		if stored.UserID == args[0] && stored.Source == args[1] {
+			*row = *stored
+			return nil
+		}
+	}
+	return sql.ErrNoRows
+}
+
+func (db *tokenStoreTestDB) SelectContext(_ context.Context, dest any, _ string, _ ...any) error {
+	rows := dest.(*[]models.ConnectedIntegration)
+	for _, stored := range db.rows {
+		*rows = append(*rows, *stored)
+	}
+	return nil
+}
+
+func (db *tokenStoreTestDB) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	if !strings.Contains(query, "access_token = $4") {
+		return driver.RowsAffected(1), nil
+	}
+	for _, stored := range db.rows {
+		if stored.ID == args[2] && stored.AccessToken == args[3] {
+			stored.AccessToken, stored.RefreshToken = args[0].(string), args[1].(*string)
+			return driver.RowsAffected(1), nil
+		}
+	}
+	return driver.RowsAffected(0), nil
+}
+
+// testKeyring builds a keyring from the named keys, active first,
+// generating any key not yet in keys.
+func testKeyring(t *testing.T, keys map[string]string, ids ...string) *tokencrypt.Keyring {
+	t.Helper()
+	var entries []string
+	for _, id := range ids {
+		if keys[id] == "" {
+			raw := make([]byte, 32)
+			if _, err := rand.Read(raw); err != nil {
+				t.Fatal(err)
+			}
+			keys[id] = base64.StdEncoding.EncodeToString(raw)
+		}
+		entries = append(entries, id+"="+keys[id])
+	}
+	keyring, err := tokencrypt.ParseKeyring(strings.Join(entries, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+func TestIntegrationTokensAreEncryptedAndRotated(t *testing.T) {
+	ctx := context.Background()
+	db := &tokenStoreTestDB{}
+	keys := map[string]string{}
+	oldKeys := testKeyring(t, keys, "k1")
+	userID := uuid.New()
+
+	refresh := "refresh-secret"
+	conn, err := connectIntegration(ctx, db, oldKeys, userID, "strava", "42",
+		integrationTokens{AccessToken: "access-secret", RefreshToken: &refresh})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if conn.AccessToken != "access-secret" || *conn.RefreshToken != refresh {
+		t.Fatalf("expected plaintext tokens back, got %q %q", conn.AccessToken, *conn.RefreshToken)
+	}
+	if id, _, _ := tokencrypt.KeyID(db.rows[0].AccessToken); id != "k1" || strings.Contains(*db.rows[0].RefreshToken, refresh) {
+		t.Fatalf("expected tokens sealed with k1, stored %q %q", db.rows[0].AccessToken, *db.rows[0].RefreshToken)
+	}
+
+	// A row from before encryption was enabled reads as plaintext.
+	legacyUser := uuid.New()
+	db.rows = append(db.rows, &models.ConnectedIntegration{ID: uuid.New(), UserID: legacyUser, Source: "coros", AccessToken: "legacy-access"})
+	if legacy, err := getIntegration(ctx, db, oldKeys, legacyUser, "coros"); err != nil || legacy.AccessToken != "legacy-access" {
+		t.Fatalf("expected legacy token to read as plaintext, got %+v, %v", legacy, err)
+	}
+
+	newKeys := testKeyring(t, keys, "k2", "k1")
+	result, err := RotateIntegrationTokens(ctx, db, newKeys, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Rotated != 2 || result.Unchanged != 0 {
+		t.Fatalf("expected both rows rotated, got %+v", result)
+	}
+
+	// Once rotated, the old key can be retired.
+	onlyNew := testKeyring(t, keys, "k2")
+	for _, stored := range db.rows {
+		if id, _, _ := tokencrypt.KeyID(stored.AccessToken); id != "k2" {
+			t.Fatalf("expected %s token sealed with k2, got %q", stored.Source, stored.AccessToken)
+		}
+	}
+	got, err := getIntegration(ctx, db, onlyNew, userID, "strava")
+	if err != nil || got.AccessToken != "access-secret" || *got.RefreshToken != refresh {
+		t.Fatalf("expected tokens to open with k2 alone, got %+v, %v", got, err)
+	}
+
+	again, err := RotateIntegrationTokens(ctx, db, onlyNew, false)
+	if err != nil || again.Rotated != 0 || again.Unchanged != 2 {
+		t.Fatalf("expected a second pass to be a no-op, got %+v, %v", again, err)
+	}
+}
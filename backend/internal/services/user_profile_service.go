@@ -15,6 +15,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/korsana/backend/internal/database"
+	"github.com/korsana/backend/internal/metrics"
 	"github.com/korsana/backend/internal/models"
 )
 
@@ -251,6 +252,7 @@ func (s *UserProfileService) DetectPRsFromStrava(ctx context.Context, userID uui
 		directQ := `
 			SELECT * FROM activities
 			WHERE user_id = $1
+			  AND merged_into IS NULL
 			  AND activity_type = ANY($2)
 			  AND distance_meters >= $3
 			  AND distance_meters <= $4
@@ -273,6 +275,7 @@ func (s *UserProfileService) DetectPRsFromStrava(ctx context.Context, userID uui
 		splitQ := `
 			SELECT * FROM activities
 			WHERE user_id = $1
+			  AND merged_into IS NULL
 			  AND activity_type = ANY($2)
 			  AND distance_meters > $3
 			  AND average_pace_seconds_per_km > 0
@@ -349,8 +352,8 @@ func (s *UserProfileService) GetTrainingZones(ctx context.Context, userID uuid.U
 		}
 
 		if zoneType == "hr" {
-			max := 190
-			rest := 60
+			max := metrics.DefaultMaxHR
+			rest := metrics.DefaultRestingHR
 			if profile.MaxHeartRate != nil {
 				max = *profile.MaxHeartRate
 			}
@@ -385,8 +388,7 @@ func (s *UserProfileService) GetTrainingZones(ctx context.Context, userID uuid.U
 				}
 			}
 		} else if zoneType == "pace" {
-			// Basic threshold pace fallback is 5:00/km = 300s
-			calcZones := s.CalculatePaceZones(300)
+			calcZones := s.CalculatePaceZones(s.thresholdPaceFromPRs(ctx, userID))
 			for i, cz := range calcZones {
 				z := models.TrainingZone{
 					ID:               uuid.New(),
@@ -461,24 +463,35 @@ type CalculatedZone struct {
 	Max         *int
 }
 
+var (
+	hrZoneDescriptions = []string{
+		"Easy effort, promotes recovery",
+		"Endurance building, conversational pace",
+		"Moderately hard, comfortably hard",
+		"Hard effort, barely sustainable for an hour",
+		"All out, very hard",
+	}
+	paceZoneDescriptions = []string{
+		"Very relaxed running",
+		"Steady endurance pace",
+		"Comfortably hard running",
+		"Race pace effort",
+		"Fast, short interval pace",
+	}
+)
+
 // CalculateHRZones calculates Karvonen HR zones using max and resting HR.
 func (s *UserProfileService) CalculateHRZones(maxHR, restingHR int) []CalculatedZone {
-	hrr := float64(maxHR - restingHR)
-	rest := float64(restingHR)
-
-	z1Max := int(rest+hrr*0.60) - 1
-	z2Max := int(rest+hrr*0.70) - 1
-	z3Max := int(rest+hrr*0.80) - 1
-	z4Max := int(rest+hrr*0.90) - 1
-	z5Max := maxHR
-
-	return []CalculatedZone{
-		{"Recovery", "Easy effort, promotes recovery", int(rest + hrr*0.50), &z1Max},
-		{"Aerobic", "Endurance building, conversational pace", z1Max + 1, &z2Max},
-		{"Tempo", "Moderately hard, comfortably hard", z2Max + 1, &z3Max},
-		{"Threshold", "Hard effort, barely sustainable for an hour", z3Max + 1, &z4Max},
-		{"Anaerobic", "All out, very hard", z4Max + 1, &z5Max},
+	return describeZones(metrics.KarvonenHRZones(restingHR, maxHR), hrZoneDescriptions)
+}
+
+// describeZones pairs calculated zones with their descriptions.
+func describeZones(zones []metrics.Zone, descriptions []string) []CalculatedZone {
+	out := make([]CalculatedZone, len(zones))
+	for i, z := range zones {
+		out[i] = CalculatedZone{Label: z.Label, Description: descriptions[i], Min: z.Min, Max: z.Max}
 	}
+	return out
 }
 
 // CalculateAndSaveZones re-derives zones from current profile data and persists them.
@@ -494,8 +507,8 @@ func (s *UserProfileService) CalculateAndSaveZones(ctx context.Context, userID u
 
 	switch zoneType {
 	case "hr":
-		maxHR := 190
-		restHR := 60
+		maxHR := metrics.DefaultMaxHR
+		restHR := metrics.DefaultRestingHR
 		if profile.MaxHeartRate != nil {
 			maxHR = *profile.MaxHeartRate
 		}
@@ -545,52 +558,13 @@ func (s *UserProfileService) CalculateAndSaveZones(ctx context.Context, userID u
 // Preference: 10K PR (threshold ≈ 10K pace + 15 s/km), then 5K (threshold ≈ 5K pace + 30 s/km).
 func (s *UserProfileService) thresholdPaceFromPRs(ctx context.Context, userID uuid.UUID) int {
 	prs, err := s.GetPersonalRecords(ctx, userID)
-	if err != nil || len(prs) == 0 {
-		return 300 // default 5:00/km
-	}
-
-	prMap := make(map[string]models.PersonalRecord, len(prs))
-	for _, pr := range prs {
-		prMap[pr.Label] = pr
-	}
-
-	if pr, ok := prMap["10K"]; ok && pr.TimeSeconds > 0 {
-		pacePerKm := pr.TimeSeconds / 10
-		return pacePerKm + 15
-	}
-	if pr, ok := prMap["5K"]; ok && pr.TimeSeconds > 0 {
-		pacePerKm := pr.TimeSeconds / 5
-		return pacePerKm + 30
+	if err != nil {
+		return metrics.DefaultThresholdPace
 	}
-
-	return 300
+	return metrics.ThresholdPaceFromPRs(prs)
 }
 
 // CalculatePaceZones maps threshold %s for Pace Zones. Minimum = Faster, Maximum = Slower.
 func (s *UserProfileService) CalculatePaceZones(thresholdPace int) []CalculatedZone {
-	th := float64(thresholdPace)
-
-	// Since pace is time per distance, a larger percentage (e.g. 129%) means SLOWER pace.
-	// Z1: >129%
-	z1Min := int(th * 1.29)
-	// Z2: 114-129%
-	z2Min := int(th * 1.14)
-	z2Max := z1Min - 1
-	// Z3: 106-114%
-	z3Min := int(th * 1.06)
-	z3Max := z2Min - 1
-	// Z4: 99-106%
-	z4Min := int(th * 0.99)
-	z4Max := z3Min - 1
-	// Z5: <99%
-	z5Min := 0
-	z5Max := z4Min - 1
-
-	return []CalculatedZone{
-		{"Recovery Pace", "Very relaxed running", z1Min, nil},
-		{"Aerobic Pace", "Steady endurance pace", z2Min, &z2Max},
-		{"Tempo Pace", "Comfortably hard running", z3Min, &z3Max},
-		{"Threshold Pace", "Race pace effort", z4Min, &z4Max},
-		{"Anaerobic Pace", "Fast, short interval pace", z5Min, &z5Max},
-	}
+	return describeZones(metrics.ThresholdPaceZones(thresholdPace), paceZoneDescriptions)
 }
@@ -0,0 +1,148 @@
+// Package tokencrypt encrypts third-party OAuth tokens before they are
+// stored. Each value is sealed with AES-256-GCM and prefixed with the ID of
+// the key that sealed it, so keys can be rotated without a flag day: new
+// values use the active key while older keys stay available for reading
+// until the rotate-tokens command has re-encrypted every row.
+package tokencrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// prefix marks a sealed value: "enc:<key id>:<base64 nonce+ciphertext>".
+// Provider tokens never start with it, so values stored before encryption
+// was enabled are recognised as plaintext.
+const prefix = "enc:"
+
+var (
+	// ErrUnknownKey is returned when a value was sealed with a key that is
+	// not in the keyring.
+	ErrUnknownKey = errors.New("tokencrypt: value sealed with an unknown key")
+	// ErrNoKeyring is returned when a sealed value is read without a keyring.
+	ErrNoKeyring = errors.New("tokencrypt: no keyring configured")
+)
+
+// Keyring holds the token encryption keys. The first key is active and
+// seals new values; every key can open values sealed with it.
+//
+// A nil *Keyring stores values as plaintext. That is only allowed outside
+// production (see config.Config.TokenEncryptionKeys).
+type Keyring struct {
+	activeID string
+	keys     map[string]cipher.AEAD
+}
+
+// ParseKeyring parses a comma-separated list of id=base64key pairs, active
+// key first, e.g. "2026b=...,2026a=...". Keys must decode to 32 bytes. An
+// empty spec yields a nil keyring.
+func ParseKeyring(spec string) (*Keyring, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, nil
+	}
+
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for _, entry := range strings.Split(spec, ",") {
+		id, encoded, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok || id == "" || strings.Contains(id, ":") {
+			return nil, fmt.Errorf("tokencrypt: malformed key entry %q, want id=base64key", entry)
+		}
+		if _, dup := k.keys[id]; dup {
+			return nil, fmt.Errorf("tokencrypt: key %q listed twice", id)
+		}
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("tokencrypt: key %q is not valid base64: %w", id, err)
+		}
+		if len(raw) != 32 {
+			return nil, fmt.Errorf("tokencrypt: key %q is %d bytes, want 32", id, len(raw))
+		}
+		block, err := aes.NewCipher(raw)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		k.keys[id] = aead
+		if k.activeID == "" {
+			k.activeID = id
+		}
+	}
+	return k, nil
+}
+
+// ActiveKeyID is the ID of the key that seals new values.
+func (k *Keyring) ActiveKeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.activeID
+}
+
+// Seal encrypts plaintext with the active key.
+func (k *Keyring) Seal(plaintext string) (string, error) {
+	if k == nil {
+		return plaintext, nil
+	}
+	aead := k.keys[k.activeID]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, []byte(plaintext), []byte(k.activeID))
+	return prefix + k.activeID + ":" + base64.RawStdEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a value produced by Seal. Values without the sealed prefix
+// were stored before encryption was enabled and are returned unchanged.
+func (k *Keyring) Open(value string) (string, error) {
+	id, sealed, ok := KeyID(value)
+	if !ok {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoKeyring
+	}
+	aead, known := k.keys[id]
+	if !known {
+		return "", fmt.Errorf("%w %q", ErrUnknownKey, id)
+	}
+	raw, err := base64.RawStdEncoding.DecodeString(sealed)
+	if err != nil || len(raw) < aead.NonceSize() {
+		return "", fmt.Errorf("tokencrypt: malformed value sealed with key %q", id)
+	}
+	nonce, ciphertext := raw[:aead.NonceSize()], raw[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, []byte(id))
+	if err != nil {
+		return "", fmt.Errorf("tokencrypt: value sealed with key %q failed to decrypt: %w", id, err)
+	}
+	return string(plaintext), nil
+}
+
+// NeedsRotation reports whether value should be re-sealed: it is plaintext
+// or was sealed with a key other than the active one.
+func (k *Keyring) NeedsRotation(value string) bool {
+	if k == nil {
+		return false
+	}
+	id, _, ok := KeyID(value)
+	return !ok || id != k.activeID
+}
+
+// KeyID splits a sealed value into its key ID and payload. ok is false for
+// plaintext.
+func KeyID(value string) (id, sealed string, ok bool) {
+	rest, found := strings.CutPrefix(value, prefix)
+	if !found {
+		return "", "", false
+	}
+	return strings.Cut(rest, ":")
+}
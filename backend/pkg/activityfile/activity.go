@@ -0,0 +1,82 @@
+// Package activityfile decodes activity files exported by watches and bike
+// computers into a format-neutral Activity.
+package activityfile
+
+import (
+	"errors"
+	"time"
+)
+
+// Sport values reported by the decoders. Sports without a counterpart are
+// reported as SportOther.
+const (
+	SportRunning       = "running"
+	SportCycling       = "cycling"
+	SportSwimming      = "swimming"
+	SportWalking       = "walking"
+	SportHiking        = "hiking"
+	SportRowing        = "rowing"
+	SportElliptical    = "elliptical"
+	SportStairClimbing = "stair_climbing"
+	SportStrength      = "strength_training"
+	SportOther         = "other"
+)
+
+// ErrNoActivity is returned when a file decodes but holds no recorded
+// activity (a course, workout or settings file, for example).
+var ErrNoActivity = errors.New("file contains no activity")
+
+// Activity is one recorded session. Zero numeric values mean the file did
+// not record them.
+type Activity struct {
+	Format       string // "fit", "gpx" or "tcx"
+	Manufacturer string // device maker when the file names one
+	Sport        string // Sport* constant
+	Name         string
+	StartTime    time.Time
+	// LocalOffset is the athlete's UTC offset at the start, or nil when the
+	// file does not record local time.
+	LocalOffset    *time.Duration
+	ElapsedSeconds float64
+	MovingSeconds  float64 // timer time, excluding pauses
+	DistanceMeters float64
+	AvgHeartRate   int
+	MaxHeartRate   int
+	AvgCadence     float64 // rpm, or strides per minute (one leg) for runs
+	ElevationGain  float64 // meters
+	Calories       int
+	Laps           []Lap
+	Samples        []Sample
+}
+
+// Lap is one lap of an Activity.
+type Lap struct {
+	StartTime      time.Time
+	ElapsedSeconds float64
+	MovingSeconds  float64
+	DistanceMeters float64
+	AvgSpeed       float64 // m/s
+	MaxSpeed       float64 // m/s
+	AvgHeartRate   int
+	MaxHeartRate   int
+	AvgCadence     float64
+	ElevationGain  float64
+}
+
+// Sample is one recorded data point. Nil fields were not recorded at that
+// point.
+type Sample struct {
+	Time      time.Time
+	Position  *LatLng
+	Distance  *float64 // meters from the start
+	Speed     *float64 // m/s
+	Altitude  *float64 // meters
+	HeartRate *int
+	Cadence   *int
+}
+
+// LatLng is a position in decimal degrees.
+type LatLng struct {
+	Lat float64
+	Lng float64
+}
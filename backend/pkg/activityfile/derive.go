@@ -0,0 +1,238 @@
+package activityfile
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	// earthRadiusMeters is the mean Earth radius used for haversine
+	// distances.
+	earthRadiusMeters = 6371008.8
+
+	// minMovingSpeed is the slowest pace (m/s, about 33 min/km) counted as
+	// moving. Slower stretches between two points are treated as a pause,
+	// which also covers long gaps where the device stopped recording.
+	minMovingSpeed = 0.5
+
+	// elevationSmoothingWindow is the number of samples averaged around each
+	// altitude reading before climbs are summed.
+	elevationSmoothingWindow = 5
+
+	// elevationGainThreshold is the rise (m) the smoothed altitude must make
+	// over its last low point before it counts, so sensor jitter on flat
+	// ground doesn't add up.
+	elevationGainThreshold = 2.0
+)
+
+// deriveTotals fills in whatever a track-only format (GPX, or TCX without
+// lap totals) left unset: per-sample distance and speed from positions, then
+// the activity's elapsed and moving time, distance, climb, heart rate and
+// cadence. Values the file recorded are kept.
+func deriveTotals(act *Activity) {
+	samples := act.Samples
+	if len(samples) == 0 {
+		return
+	}
+	if act.StartTime.IsZero() {
+		act.StartTime = samples[0].Time
+	}
+	deriveSampleDistances(samples)
+	deriveSampleSpeeds(samples)
+
+	if act.ElapsedSeconds == 0 {
+		act.ElapsedSeconds = samples[len(samples)-1].Time.Sub(act.StartTime).Seconds()
+	}
+	if act.DistanceMeters == 0 {
+		for i := len(samples) - 1; i >= 0; i-- {
+			if samples[i].Distance != nil {
+				act.DistanceMeters = *samples[i].Distance
+				break
+			}
+		}
+	}
+	if act.MovingSeconds == 0 {
+		act.MovingSeconds = movingSeconds(samples)
+	}
+	if act.MovingSeconds == 0 {
+		// Nothing to detect pauses from (no distance at all).
+		act.MovingSeconds = act.ElapsedSeconds
+	}
+	if act.ElevationGain == 0 {
+		act.ElevationGain = elevationGain(samples)
+	}
+
+	var hrSum, hrCount, maxHR, cadSum, cadCount int
+	for _, s := range samples {
+		if s.HeartRate != nil && *s.HeartRate > 0 {
+			hrSum += *s.HeartRate
+			hrCount++
+			maxHR = max(maxHR, *s.HeartRate)
+		}
+		if s.Cadence != nil && *s.Cadence > 0 {
+			cadSum += *s.Cadence
+			cadCount++
+		}
+	}
+	if act.AvgHeartRate == 0 && hrCount > 0 {
+		act.AvgHeartRate = int(math.Round(float64(hrSum) / float64(hrCount)))
+	}
+	if act.MaxHeartRate == 0 {
+		act.MaxHeartRate = maxHR
+	}
+	if act.AvgCadence == 0 && cadCount > 0 {
+		act.AvgCadence = math.Round(float64(cadSum)/float64(cadCount)*10) / 10
+	}
+}
+
+// deriveSampleDistances sets cumulative haversine distance on tracks that
+// have positions but no recorded distance.
+func deriveSampleDistances(samples []Sample) {
+	for _, s := range samples {
+		if s.Distance != nil {
+			return
+		}
+	}
+
+	var total float64
+	var prev *LatLng
+	hasPositions := false
+	for i := range samples {
+		if p := samples[i].Position; p != nil {
+			if prev != nil {
+				total += haversine(*prev, *p)
+			}
+			prev = p
+			hasPositions = true
+		}
+		if hasPositions {
+			dist := total
+			samples[i].Distance = &dist
+		}
+	}
+}
+
+// deriveSampleSpeeds sets each sample's speed from the distance covered
+// since the previous sample, when the file recorded none.
+func deriveSampleSpeeds(samples []Sample) {
+	for _, s := range samples {
+		if s.Speed != nil {
+			return
+		}
+	}
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		dt := cur.Time.Sub(prev.Time).Seconds()
+		if prev.Distance == nil || cur.Distance == nil || dt <= 0 {
+			continue
+		}
+		speed := (*cur.Distance - *prev.Distance) / dt
+		samples[i].Speed = &speed
+	}
+}
+
+// movingSeconds sums the time between consecutive samples that covered
+// ground at minMovingSpeed or faster.
+func movingSeconds(samples []Sample) float64 {
+	var moving float64
+	for i := 1; i < len(samples); i++ {
+		prev, cur := samples[i-1], samples[i]
+		dt := cur.Time.Sub(prev.Time).Seconds()
+		if prev.Distance == nil || cur.Distance == nil || dt <= 0 {
+			continue
+		}
+		if (*cur.Distance-*prev.Distance)/dt >= minMovingSpeed {
+			moving += dt
+		}
+	}
+	return moving
+}
+
+// elevationGain sums climbs on a moving-average altitude, counting a climb
+// once it rises elevationGainThreshold above the lowest point since the
+// last counted climb.
+func elevationGain(samples []Sample) float64 {
+	var altitudes []float64
+	for _, s := range samples {
+		if s.Altitude != nil {
+			altitudes = append(altitudes, *s.Altitude)
+		}
+	}
+	if len(altitudes) < 2 {
+		return 0
+	}
+
+	half := elevationSmoothingWindow / 2
+	smoothed := make([]float64, len(altitudes))
+	for i := range altitudes {
+		lo, hi := max(0, i-half), min(len(altitudes), i+half+1)
+		var sum float64
+		for _, a := range altitudes[lo:hi] {
+			sum += a
+		}
+		smoothed[i] = sum / float64(hi-lo)
+	}
+
+	var gain float64
+	anchor := smoothed[0]
+	for _, a := range smoothed[1:] {
+		switch {
+		case a-anchor >= elevationGainThreshold:
+			gain += a - anchor
+			anchor = a
+		case a < anchor:
+			anchor = a
+		}
+	}
+	return math.Round(gain*10) / 10
+}
+
+// haversine returns the great-circle distance between two points in
+// meters.
+func haversine(a, b LatLng) float64 {
+	lat1, lat2 := a.Lat*math.Pi/180, b.Lat*math.Pi/180
+	dLat := lat2 - lat1
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
+
+// sportFromName maps the free-text sport of GPX <type> and TCX Sport
+// attributes ("running", "Run", "Biking", "VirtualRide", ...).
+func sportFromName(name string) string {
+	n := strings.ToLower(name)
+	switch {
+	case strings.Contains(n, "run"):
+		return SportRunning
+	case strings.Contains(n, "rid"), strings.Contains(n, "bik"), strings.Contains(n, "cycl"):
+		return SportCycling
+	case strings.Contains(n, "swim"):
+		return SportSwimming
+	case strings.Contains(n, "walk"):
+		return SportWalking
+	case strings.Contains(n, "hik"):
+		return SportHiking
+	case strings.Contains(n, "row"):
+		return SportRowing
+	case strings.Contains(n, "elliptical"):
+		return SportElliptical
+	case strings.Contains(n, "stair"):
+		return SportStairClimbing
+	case strings.Contains(n, "strength"), strings.Contains(n, "weight"):
+		return SportStrength
+	default:
+		return SportOther
+	}
+}
+
+// manufacturerFromCreator guesses the device maker from a GPX creator or
+// TCX author name, e.g. "Garmin Connect" or "COROS PACE 3".
+func manufacturerFromCreator(creator string) string {
+	c := strings.ToLower(creator)
+	for _, m := range fitManufacturers {
+		if strings.Contains(c, m) {
+			return m
+		}
+	}
+	return ""
+}
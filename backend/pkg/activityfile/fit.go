@@ -0,0 +1,479 @@
+package activityfile
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// fitEpoch is the FIT timestamp origin (1989-12-31T00:00:00Z) in unix
+// seconds.
+const fitEpoch = 631065600
+
+// Global message numbers from the FIT profile that the decoder reads.
+const (
+	fitMesgFileID   = 0
+	fitMesgSession  = 18
+	fitMesgLap      = 19
+	fitMesgRecord   = 20
+	fitMesgActivity = 34
+)
+
+const (
+	fitFieldTimestamp = 253
+	fitFileActivity   = 4 // file_id.type of an activity file
+)
+
+// ErrInvalidFIT is returned for data that is not a well-formed FIT file.
+var ErrInvalidFIT = errors.New("not a valid FIT file")
+
+// fitManufacturers names the device makers whose files we expect to see.
+var fitManufacturers = map[int]string{
+	1:   "garmin",
+	23:  "suunto",
+	32:  "wahoo",
+	123: "polar",
+	294: "coros",
+}
+
+type fitFieldDef struct {
+	num      byte
+	size     int
+	baseType byte
+}
+
+type fitDefinition struct {
+	global    uint16
+	bigEndian bool
+	fields    []fitFieldDef
+	devSize   int // bytes of developer fields, which are skipped
+}
+
+// fitMessage holds a data message's valid scalar fields, unscaled.
+type fitMessage map[byte]float64
+
+type fitDecoder struct {
+	data          []byte
+	pos           int
+	end           int
+	defs          [16]*fitDefinition
+	lastTimestamp uint32
+}
+
+// DecodeFIT decodes a FIT activity file. It returns one Activity per
+// session; multisport files therefore yield several.
+func DecodeFIT(data []byte) ([]Activity, error) {
+	if len(data) < 12 {
+		return nil, ErrInvalidFIT
+	}
+	headerSize := int(data[0])
+	if headerSize < 12 || len(data) < headerSize || string(data[8:12]) != ".FIT" {
+		return nil, ErrInvalidFIT
+	}
+	end := headerSize + int(binary.LittleEndian.Uint32(data[4:8]))
+	if end+2 > len(data) {
+		return nil, fmt.Errorf("%w: file is truncated", ErrInvalidFIT)
+	}
+	if fitCRC(data[:end]) != binary.LittleEndian.Uint16(data[end:end+2]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", ErrInvalidFIT)
+	}
+
+	d := &fitDecoder{data: data, pos: headerSize, end: end}
+	var (
+		fileID   fitMessage
+		activity fitMessage
+		sessions []fitMessage
+		laps     []fitMessage
+		records  []fitMessage
+	)
+	for d.pos < d.end {
+		global, msg, err := d.next()
+		if err != nil {
+			return nil, err
+		}
+		if msg == nil {
+			continue
+		}
+		switch global {
+		case fitMesgFileID:
+			fileID = msg
+		case fitMesgActivity:
+			activity = msg
+		case fitMesgSession:
+			sessions = append(sessions, msg)
+		case fitMesgLap:
+			laps = append(laps, msg)
+		case fitMesgRecord:
+			records = append(records, msg)
+		}
+	}
+
+	if fileType, ok := fileID[0]; ok && fileType != fitFileActivity {
+		return nil, ErrNoActivity
+	}
+	if len(sessions) == 0 {
+		if len(records) == 0 {
+			return nil, ErrNoActivity
+		}
+		sessions = []fitMessage{sessionFromRecords(records)}
+	}
+
+	manufacturer := fitManufacturers[int(fileID[1])]
+	var localOffset *time.Duration
+	if local, ok := activity[5]; ok {
+		if ts, ok := activity[fitFieldTimestamp]; ok {
+			off := time.Duration(local-ts) * time.Second
+			localOffset = &off
+		}
+	}
+
+	out := make([]Activity, 0, len(sessions))
+	for _, s := range sessions {
+		act := Activity{
+			Format:         "fit",
+			Manufacturer:   manufacturer,
+			Sport:          fitSport(int(s[5]), int(s[6])),
+			ElapsedSeconds: s[7] / 1000,
+			MovingSeconds:  s[8] / 1000,
+			DistanceMeters: s[9] / 100,
+			Calories:       int(s[11]),
+			AvgHeartRate:   int(s[16]),
+			MaxHeartRate:   int(s[17]),
+			AvgCadence:     s[18] + s[92]/128,
+			ElevationGain:  s[22],
+			LocalOffset:    localOffset,
+		}
+		if start, ok := s[2]; ok {
+			act.StartTime = fitTime(start)
+		} else {
+			act.StartTime = fitTime(s[fitFieldTimestamp]).Add(-time.Duration(act.ElapsedSeconds * float64(time.Second)))
+		}
+		if act.MovingSeconds == 0 {
+			act.MovingSeconds = act.ElapsedSeconds
+		}
+
+		// Laps and records belong to the session whose time span they fall
+		// in; a single-session file takes them all.
+		sessionEnd := act.StartTime.Add(time.Duration(act.ElapsedSeconds * float64(time.Second)))
+		within := func(t time.Time) bool {
+			return len(sessions) == 1 || (!t.Before(act.StartTime) && !t.After(sessionEnd))
+		}
+		for _, l := range laps {
+			lap := fitLap(l)
+			if within(lap.StartTime) {
+				act.Laps = append(act.Laps, lap)
+			}
+		}
+		for _, r := range records {
+			sample := fitSample(r)
+			if within(sample.Time) {
+				act.Samples = append(act.Samples, sample)
+			}
+		}
+		out = append(out, act)
+	}
+	return out, nil
+}
+
+// next reads one record. Definition records return a nil message.
+func (d *fitDecoder) next() (uint16, fitMessage, error) {
+	header := d.data[d.pos]
+	d.pos++
+
+	switch {
+	case header&0x80 != 0:
+		// Compressed timestamp header: the low five bits are a rolling
+		// offset from the last full timestamp.
+		offset := uint32(header & 0x1F)
+		ts := d.lastTimestamp&^0x1F | offset
+		if offset < d.lastTimestamp&0x1F {
+			ts += 0x20
+		}
+		global, msg, err := d.readData((header >> 5) & 0x03)
+		if msg != nil {
+			if _, ok := msg[fitFieldTimestamp]; !ok {
+				msg[fitFieldTimestamp] = float64(ts)
+			}
+			d.lastTimestamp = ts
+		}
+		return global, msg, err
+	case header&0x40 != 0:
+		return 0, nil, d.readDefinition(header&0x0F, header&0x20 != 0)
+	default:
+		return d.readData(header & 0x0F)
+	}
+}
+
+func (d *fitDecoder) readDefinition(local byte, hasDevFields bool) error {
+	if d.pos+5 > d.end {
+		return fmt.Errorf("%w: truncated definition", ErrInvalidFIT)
+	}
+	def := &fitDefinition{bigEndian: d.data[d.pos+1] == 1}
+	if def.bigEndian {
+		def.global = binary.BigEndian.Uint16(d.data[d.pos+2:])
+	} else {
+		def.global = binary.LittleEndian.Uint16(d.data[d.pos+2:])
+	}
+	numFields := int(d.data[d.pos+4])
+	d.pos += 5
+
+	if d.pos+numFields*3 > d.end {
+		return fmt.Errorf("%w: truncated definition", ErrInvalidFIT)
+	}
+	for i := 0; i < numFields; i++ {
+		def.fields = append(def.fields, fitFieldDef{
+			num:      d.data[d.pos],
+			size:     int(d.data[d.pos+1]),
+			baseType: d.data[d.pos+2] & 0x1F,
+		})
+		d.pos += 3
+	}
+
+	if hasDevFields {
+		if d.pos >= d.end {
+			return fmt.Errorf("%w: truncated definition", ErrInvalidFIT)
+		}
+		numDev := int(d.data[d.pos])
+		d.pos++
+		if d.pos+numDev*3 > d.end {
+			return fmt.Errorf("%w: truncated definition", ErrInvalidFIT)
+		}
+		for i := 0; i < numDev; i++ {
+			def.devSize += int(d.data[d.pos+1])
+			d.pos += 3
+		}
+	}
+
+	d.defs[local] = def
+	return nil
+}
+
+func (d *fitDecoder) readData(local byte) (uint16, fitMessage, error) {
+	def := d.defs[local]
+	if def == nil {
+		return 0, nil, fmt.Errorf("%w: data for undefined local message %d", ErrInvalidFIT, local)
+	}
+
+	msg := fitMessage{}
+	for _, f := range def.fields {
+		if d.pos+f.size > d.end {
+			return 0, nil, fmt.Errorf("%w: truncated data message", ErrInvalidFIT)
+		}
+		if v, ok := fitValue(d.data[d.pos:d.pos+f.size], f.baseType, def.bigEndian); ok {
+			msg[f.num] = v
+		}
+		d.pos += f.size
+	}
+	if d.pos+def.devSize > d.end {
+		return 0, nil, fmt.Errorf("%w: truncated data message", ErrInvalidFIT)
+	}
+	d.pos += def.devSize
+
+	if ts, ok := msg[fitFieldTimestamp]; ok {
+		d.lastTimestamp = uint32(ts)
+	}
+	return def.global, msg, nil
+}
+
+// fitValue decodes a scalar field. It reports false for invalid (unset)
+// values, strings and arrays, none of which the decoder uses.
+func fitValue(b []byte, baseType byte, bigEndian bool) (float64, bool) {
+	var size int
+	switch baseType {
+	case 0, 1, 2, 10, 13: // enum, sint8, uint8, uint8z, byte
+		size = 1
+	case 3, 4, 11: // sint16, uint16, uint16z
+		size = 2
+	case 5, 6, 8, 12: // sint32, uint32, float32, uint32z
+		size = 4
+	case 9, 14, 15, 16: // float64, sint64, uint64, uint64z
+		size = 8
+	default:
+		return 0, false
+	}
+	if len(b) != size {
+		return 0, false
+	}
+
+	var raw uint64
+	for i := 0; i < size; i++ {
+		shift := 8 * i
+		if bigEndian {
+			shift = 8 * (size - 1 - i)
+		}
+		raw |= uint64(b[i]) << shift
+	}
+	allOnes := uint64(1)<<(8*size) - 1
+	if size == 8 {
+		allOnes = math.MaxUint64
+	}
+
+	switch baseType {
+	case 10, 11, 12, 16:
+		return float64(raw), raw != 0
+	case 1, 3, 5, 14:
+		if raw == allOnes>>1 {
+			return 0, false
+		}
+		// Sign-extend from the field width.
+		shift := 64 - 8*size
+		return float64(int64(raw<<shift) >> shift), true
+	case 8:
+		if raw == allOnes {
+			return 0, false
+		}
+		return float64(math.Float32frombits(uint32(raw))), true
+	case 9:
+		if raw == allOnes {
+			return 0, false
+		}
+		return math.Float64frombits(raw), true
+	default:
+		return float64(raw), raw != allOnes
+	}
+}
+
+func fitTime(v float64) time.Time {
+	return time.Unix(int64(v)+fitEpoch, 0).UTC()
+}
+
+func fitLap(m fitMessage) Lap {
+	lap := Lap{
+		StartTime:      fitTime(m[2]),
+		ElapsedSeconds: m[7] / 1000,
+		MovingSeconds:  m[8] / 1000,
+		DistanceMeters: m[9] / 100,
+		AvgSpeed:       m[13] / 1000,
+		MaxSpeed:       m[14] / 1000,
+		AvgHeartRate:   int(m[15]),
+		MaxHeartRate:   int(m[16]),
+		AvgCadence:     m[17],
+		ElevationGain:  m[21],
+	}
+	// Enhanced fields are 32-bit versions of the same values; newer
+	// devices may write only those.
+	if v, ok := m[110]; ok {
+		lap.AvgSpeed = v / 1000
+	}
+	if v, ok := m[111]; ok {
+		lap.MaxSpeed = v / 1000
+	}
+	return lap
+}
+
+func fitSample(m fitMessage) Sample {
+	s := Sample{Time: fitTime(m[fitFieldTimestamp])}
+	lat, hasLat := m[0]
+	lng, hasLng := m[1]
+	if hasLat && hasLng {
+		s.Position = &LatLng{Lat: semicirclesToDegrees(lat), Lng: semicirclesToDegrees(lng)}
+	}
+	if v, ok := m[5]; ok {
+		dist := v / 100
+		s.Distance = &dist
+	}
+	if v, ok := m[73]; ok {
+		speed := v / 1000
+		s.Speed = &speed
+	} else if v, ok := m[6]; ok {
+		speed := v / 1000
+		s.Speed = &speed
+	}
+	if v, ok := m[78]; ok {
+		alt := v/5 - 500
+		s.Altitude = &alt
+	} else if v, ok := m[2]; ok {
+		alt := v/5 - 500
+		s.Altitude = &alt
+	}
+	if v, ok := m[3]; ok {
+		hr := int(v)
+		s.HeartRate = &hr
+	}
+	if v, ok := m[4]; ok {
+		cadence := int(v)
+		s.Cadence = &cadence
+	}
+	return s
+}
+
+// sessionFromRecords summarizes records for files that were written without
+// a session message, which some older devices and converters do.
+func sessionFromRecords(records []fitMessage) fitMessage {
+	first := records[0][fitFieldTimestamp]
+	last := records[len(records)-1][fitFieldTimestamp]
+	s := fitMessage{
+		2: first,
+		7: (last - first) * 1000,
+	}
+
+	var hrSum, hrCount, maxHR, maxDist float64
+	for _, r := range records {
+		if hr, ok := r[3]; ok {
+			hrSum += hr
+			hrCount++
+			maxHR = max(maxHR, hr)
+		}
+		if dist, ok := r[5]; ok {
+			maxDist = max(maxDist, dist)
+		}
+	}
+	s[9] = maxDist
+	if hrCount > 0 {
+		s[16] = math.Round(hrSum / hrCount)
+		s[17] = maxHR
+	}
+	return s
+}
+
+// fitSport maps the FIT sport and sub_sport enums.
+func fitSport(sport, subSport int) string {
+	switch sport {
+	case 1: // running, including treadmill and trail
+		return SportRunning
+	case 2: // cycling, including indoor trainer
+		return SportCycling
+	case 5:
+		return SportSwimming
+	case 11:
+		return SportWalking
+	case 17:
+		return SportHiking
+	case 15:
+		return SportRowing
+	case 4, 10: // fitness_equipment, training
+		switch subSport {
+		case 14: // indoor_rowing
+			return SportRowing
+		case 15:
+			return SportElliptical
+		case 16:
+			return SportStairClimbing
+		case 20:
+			return SportStrength
+		}
+	}
+	return SportOther
+}
+
+func semicirclesToDegrees(v float64) float64 {
+	return v * 180 / (1 << 31)
+}
+
+// fitCRC computes the FIT checksum (CRC-16, polynomial 0xA001).
+func fitCRC(data []byte) uint16 {
+	var crc uint16
+	for _, b := range data {
+		crc ^= uint16(b)
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0xA001
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
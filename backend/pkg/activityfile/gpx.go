@@ -0,0 +1,94 @@
+package activityfile
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrInvalidGPX is returned for data that is not a readable GPX document.
+var ErrInvalidGPX = errors.New("not a valid GPX file")
+
+// gpxFile covers the GPX 1.1 fields we read. Heart rate and cadence come
+// from Garmin's TrackPointExtension, which Strava, COROS and Wahoo exports
+// also use; tags match by local name, so any namespace prefix works.
+type gpxFile struct {
+	Creator  string `xml:"creator,attr"`
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []gpxTrack `xml:"trk"`
+}
+
+type gpxTrack struct {
+	Name     string `xml:"name"`
+	Type     string `xml:"type"`
+	Segments []struct {
+		Points []gpxPoint `xml:"trkpt"`
+	} `xml:"trkseg"`
+}
+
+type gpxPoint struct {
+	Lat       float64  `xml:"lat,attr"`
+	Lon       float64  `xml:"lon,attr"`
+	Elevation *float64 `xml:"ele"`
+	Time      string   `xml:"time"`
+	HeartRate *int     `xml:"extensions>TrackPointExtension>hr"`
+	Cadence   *int     `xml:"extensions>TrackPointExtension>cad"`
+}
+
+// DecodeGPX decodes a GPX file. Each track with timestamps becomes one
+// Activity; distance, moving time, climb and heart-rate figures are derived
+// from the points since GPX records no totals.
+func DecodeGPX(data []byte) ([]Activity, error) {
+	var doc gpxFile
+	if err := xml.Unmarshal(trimXML(data), &doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGPX, err)
+	}
+
+	manufacturer := manufacturerFromCreator(doc.Creator)
+	var out []Activity
+	for _, trk := range doc.Tracks {
+		act := Activity{
+			Format:       "gpx",
+			Manufacturer: manufacturer,
+			Sport:        sportFromName(trk.Type),
+			Name:         trk.Name,
+		}
+		if act.Name == "" {
+			act.Name = doc.Metadata.Name
+		}
+		for _, seg := range trk.Segments {
+			for _, pt := range seg.Points {
+				t, err := time.Parse(time.RFC3339, pt.Time)
+				if err != nil {
+					continue
+				}
+				act.Samples = append(act.Samples, Sample{
+					Time:      t.UTC(),
+					Position:  &LatLng{Lat: pt.Lat, Lng: pt.Lon},
+					Altitude:  pt.Elevation,
+					HeartRate: pt.HeartRate,
+					Cadence:   pt.Cadence,
+				})
+			}
+		}
+		if len(act.Samples) < 2 {
+			continue
+		}
+		deriveTotals(&act)
+		out = append(out, act)
+	}
+	if len(out) == 0 {
+		return nil, ErrNoActivity
+	}
+	return out, nil
+}
+
+// trimXML drops a UTF-8 byte-order mark and leading whitespace, which some
+// exporters write before the XML declaration and encoding/xml rejects.
+func trimXML(data []byte) []byte {
+	return bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))
+}
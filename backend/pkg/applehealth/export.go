@@ -0,0 +1,258 @@
+// Package applehealth reads the export.xml written by the Health app's
+// "Export All Health Data". Exports run to several gigabytes, so the file
+// is streamed and only workouts and the wellness samples Korsana uses are
+// returned.
+package applehealth
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExportFile is the document's name inside the export ZIP, which nests it
+// under "apple_health_export/".
+const ExportFile = "export.xml"
+
+// Sample types returned by Decoder.Next.
+const (
+	TypeRestingHeartRate = "HKQuantityTypeIdentifierRestingHeartRate"
+	TypeHRVSDNN          = "HKQuantityTypeIdentifierHeartRateVariabilitySDNN"
+)
+
+const (
+	workoutTypePrefix = "HKWorkoutActivityType"
+	typeHeartRate     = "HKQuantityTypeIdentifierHeartRate"
+	typeDistance      = "HKQuantityTypeIdentifierDistance" // + WalkingRunning, Cycling, Swimming...
+	metaElevation     = "HKElevationAscended"
+	metaIndoor        = "HKIndoorWorkout"
+
+	// dateLayout is used by every date attribute. The offset is the device's
+	// at the time of recording.
+	dateLayout = "2006-01-02 15:04:05 -0700"
+)
+
+// ErrInvalidExport is returned when the document is not Health export XML.
+var ErrInvalidExport = errors.New("not an Apple Health export")
+
+// Workout is one <Workout> element. Start and End keep the offset they were
+// recorded with, so Start's date is the athlete's local date.
+type Workout struct {
+	// ActivityType is the HKWorkoutActivityType without its prefix, e.g.
+	// "Running" or "TraditionalStrengthTraining".
+	ActivityType   string
+	SourceName     string // app that wrote the workout, e.g. "Apple Watch"
+	Start, End     time.Time
+	Duration       time.Duration // active time, excluding pauses
+	DistanceMeters float64
+	EnergyKcal     float64
+	AvgHeartRate   float64
+	MaxHeartRate   float64
+	ElevationGain  float64 // meters
+	Indoor         bool
+}
+
+// Sample is a resting heart rate (count/min) or HRV SDNN (ms) record.
+type Sample struct {
+	Type       string
+	SourceName string
+	Start      time.Time
+	Value      float64
+}
+
+// Item holds exactly one of a workout or a sample.
+type Item struct {
+	Workout *Workout
+	Sample  *Sample
+}
+
+// Decoder streams items from an export.xml.
+type Decoder struct {
+	xml     *xml.Decoder
+	started bool
+}
+
+// NewDecoder returns a Decoder reading from r.
+func NewDecoder(r io.Reader) *Decoder {
+	return &Decoder{xml: xml.NewDecoder(r)}
+}
+
+// Next returns the next workout or sample in document order, or io.EOF
+// after the last one. Malformed dates and values skip the element rather
+// than failing the file.
+func (d *Decoder) Next() (Item, error) {
+	for {
+		tok, err := d.xml.Token()
+		if errors.Is(err, io.EOF) {
+			if !d.started {
+				return Item{}, ErrInvalidExport
+			}
+			return Item{}, io.EOF
+		}
+		if err != nil {
+			return Item{}, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !d.started {
+			if start.Name.Local != "HealthData" {
+				return Item{}, ErrInvalidExport
+			}
+			d.started = true
+			continue
+		}
+
+		switch start.Name.Local {
+		case "Record":
+			sample, ok := parseSample(start)
+			if err := d.xml.Skip(); err != nil {
+				return Item{}, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+			}
+			if ok {
+				return Item{Sample: &sample}, nil
+			}
+		case "Workout":
+			workout, ok, err := d.readWorkout(start)
+			if err != nil {
+				return Item{}, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+			}
+			if ok {
+				return Item{Workout: &workout}, nil
+			}
+		default:
+			// Me, ExportDate, ActivitySummary, Correlation and friends.
+			if err := d.xml.Skip(); err != nil {
+				return Item{}, fmt.Errorf("%w: %v", ErrInvalidExport, err)
+			}
+		}
+	}
+}
+
+func parseSample(el xml.StartElement) (Sample, bool) {
+	attrs := attrMap(el)
+	typ := attrs["type"]
+	if typ != TypeRestingHeartRate && typ != TypeHRVSDNN {
+		return Sample{}, false
+	}
+	start, err := time.Parse(dateLayout, attrs["startDate"])
+	if err != nil {
+		return Sample{}, false
+	}
+	value, err := strconv.ParseFloat(attrs["value"], 64)
+	if err != nil || value <= 0 {
+		return Sample{}, false
+	}
+	return Sample{Type: typ, SourceName: attrs["sourceName"], Start: start, Value: value}, true
+}
+
+// readWorkout reads a <Workout> and its children up to the end element.
+// Exports before iOS 16 carry distance on the element itself; later ones
+// only in a WorkoutStatistics child.
+func (d *Decoder) readWorkout(el xml.StartElement) (Workout, bool, error) {
+	attrs := attrMap(el)
+	w := Workout{
+		ActivityType: strings.TrimPrefix(attrs["workoutActivityType"], workoutTypePrefix),
+		SourceName:   attrs["sourceName"],
+	}
+	start, startErr := time.Parse(dateLayout, attrs["startDate"])
+	end, endErr := time.Parse(dateLayout, attrs["endDate"])
+	w.Start, w.End = start, end
+	if v, err := strconv.ParseFloat(attrs["duration"], 64); err == nil {
+		w.Duration = time.Duration(v * durationScale(attrs["durationUnit"]) * float64(time.Second))
+	}
+	if v, err := strconv.ParseFloat(attrs["totalDistance"], 64); err == nil {
+		w.DistanceMeters = v * lengthScale(attrs["totalDistanceUnit"])
+	}
+	if v, err := strconv.ParseFloat(attrs["totalEnergyBurned"], 64); err == nil {
+		w.EnergyKcal = v
+	}
+
+	for depth := 0; ; {
+		tok, err := d.xml.Token()
+		if err != nil {
+			return Workout{}, false, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			depth++
+			child := attrMap(t)
+			switch {
+			case t.Name.Local == "WorkoutStatistics" && child["type"] == typeHeartRate:
+				w.AvgHeartRate, _ = strconv.ParseFloat(child["average"], 64)
+				w.MaxHeartRate, _ = strconv.ParseFloat(child["maximum"], 64)
+			case t.Name.Local == "WorkoutStatistics" && strings.HasPrefix(child["type"], typeDistance) && w.DistanceMeters == 0:
+				if v, err := strconv.ParseFloat(child["sum"], 64); err == nil {
+					w.DistanceMeters = v * lengthScale(child["unit"])
+				}
+			case t.Name.Local == "MetadataEntry" && child["key"] == metaElevation:
+				w.ElevationGain = quantityMeters(child["value"])
+			case t.Name.Local == "MetadataEntry" && child["key"] == metaIndoor:
+				w.Indoor = child["value"] == "1"
+			}
+		case xml.EndElement:
+			if depth == 0 {
+				ok := startErr == nil && endErr == nil && w.ActivityType != ""
+				if w.Duration <= 0 {
+					w.Duration = w.End.Sub(w.Start)
+				}
+				return w, ok && w.Duration > 0, nil
+			}
+			depth--
+		}
+	}
+}
+
+func attrMap(el xml.StartElement) map[string]string {
+	m := make(map[string]string, len(el.Attr))
+	for _, a := range el.Attr {
+		m[a.Name.Local] = a.Value
+	}
+	return m
+}
+
+// durationScale converts a Health duration unit to seconds.
+func durationScale(unit string) float64 {
+	switch unit {
+	case "s":
+		return 1
+	case "hr", "h":
+		return 3600
+	default: // "min"
+		return 60
+	}
+}
+
+// lengthScale converts a Health length unit to meters.
+func lengthScale(unit string) float64 {
+	switch unit {
+	case "km":
+		return 1000
+	case "mi":
+		return 1609.344
+	case "yd":
+		return 0.9144
+	case "ft":
+		return 0.3048
+	case "cm":
+		return 0.01
+	default: // "m"
+		return 1
+	}
+}
+
+// quantityMeters parses a metadata quantity such as "4520 cm".
+func quantityMeters(s string) float64 {
+	value, unit, _ := strings.Cut(strings.TrimSpace(s), " ")
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0
+	}
+	return v * lengthScale(unit)
+}
@@ -0,0 +1,240 @@
+package coros
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultBaseURL = "https://open.coros.com"
+
+	// resultOK is the result code COROS returns on success. Failures also
+	// come back as HTTP 200 with a different code.
+	resultOK = "0000"
+
+	// MaxListWindow is the widest date range the workout list accepts.
+	MaxListWindow = 30 * 24 * time.Hour
+
+	// AccessTokenLifetime is how long an access token stays valid after it
+	// is issued or refreshed.
+	AccessTokenLifetime = 30 * 24 * time.Hour
+)
+
+// Client handles COROS Open API communication. BaseURL defaults to the
+// production host and can be pointed at a local fake server.
+type Client struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	BaseURL      string
+	HTTPClient   *http.Client
+}
+
+// APIError captures COROS API failures: either a non-200 response or a
+// 200 whose result code is not "0000".
+type APIError struct {
+	StatusCode int
+	Result     string
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.Result != "" {
+		return fmt.Sprintf("coros api returned result %s: %s", e.Result, e.Message)
+	}
+	return fmt.Sprintf("coros api returned status: %d", e.StatusCode)
+}
+
+// NewClient creates a new COROS API client
+func NewClient(clientID, clientSecret, redirectURI string) *Client {
+	return &Client{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		BaseURL:      defaultBaseURL,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Configured reports whether app credentials were provided.
+func (c *Client) Configured() bool {
+	return c.ClientID != "" && c.ClientSecret != ""
+}
+
+// TokenResponse represents the OAuth token response. OpenID identifies the
+// COROS user and is required on every data request.
+type TokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"` // seconds
+	OpenID       string `json:"openId"`
+}
+
+// GetAuthorizationURL returns the URL to redirect users to for COROS OAuth
+func (c *Client) GetAuthorizationURL(state string) string {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("redirect_uri", c.RedirectURI)
+	params.Add("response_type", "code")
+	if state != "" {
+		params.Add("state", state)
+	}
+	return fmt.Sprintf("%s/oauth2/authorize?%s", c.BaseURL, params.Encode())
+}
+
+// ExchangeToken exchanges the authorization code for tokens
+func (c *Client) ExchangeToken(ctx context.Context, code string) (*TokenResponse, error) {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+	params.Add("redirect_uri", c.RedirectURI)
+	params.Add("grant_type", "authorization_code")
+	params.Add("code", code)
+
+	body, err := c.postForm(ctx, "/oauth2/accesstoken", params)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(body).Decode(&tokenResp); err != nil {
+		return nil, err
+	}
+	if tokenResp.AccessToken == "" {
+		return nil, &APIError{StatusCode: http.StatusOK, Message: "token response has no access_token"}
+	}
+	return &tokenResp, nil
+}
+
+// RefreshToken extends the validity of the current access token by
+// AccessTokenLifetime. COROS keeps the same access and refresh tokens.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) error {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+	params.Add("grant_type", "refresh_token")
+	params.Add("refresh_token", refreshToken)
+
+	body, err := c.postForm(ctx, "/oauth2/refresh-token", params)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	var envelope struct {
+		Result  string `json:"result"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(body).Decode(&envelope); err != nil {
+		return err
+	}
+	if envelope.Result != resultOK {
+		return &APIError{StatusCode: http.StatusOK, Result: envelope.Result, Message: envelope.Message}
+	}
+	return nil
+}
+
+// Workout is one entry of the COROS workout list.
+type Workout struct {
+	LabelID       string  `json:"labelId"`
+	Mode          int     `json:"mode"`    // sport code
+	SubMode       int     `json:"subMode"` // variant within the sport (outdoor, indoor, ...)
+	Name          string  `json:"name"`
+	StartTime     int64   `json:"startTime"`     // unix seconds
+	EndTime       int64   `json:"endTime"`       // unix seconds
+	StartTimezone int     `json:"startTimezone"` // UTC offset in 15-minute units
+	Distance      float64 `json:"distance"`      // meters
+	Duration      int     `json:"duration"`      // seconds of activity time
+	AvgHeartRate  int     `json:"avgHeartRate"`
+	MaxHeartRate  int     `json:"maxHeartRate"`
+	AvgCadence    float64 `json:"avgFrequency"` // steps per minute for runs
+	ElevationGain float64 `json:"ascent"`       // meters
+	Calories      float64 `json:"calorie"`
+	DeviceName    string  `json:"deviceName"`
+}
+
+// ListWorkouts returns the user's workouts that started between start and
+// end (inclusive, by calendar day). The range may not exceed MaxListWindow.
+func (c *Client) ListWorkouts(ctx context.Context, accessToken, openID string, start, end time.Time) ([]Workout, error) {
+	params := url.Values{}
+	params.Add("token", accessToken)
+	params.Add("openId", openID)
+	params.Add("startDate", start.UTC().Format("20060102"))
+	params.Add("endDate", end.UTC().Format("20060102"))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.BaseURL+"/v2/coros/sport/list?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readAPIError(resp)
+	}
+
+	var envelope struct {
+		Result  string    `json:"result"`
+		Message string    `json:"message"`
+		Data    []Workout `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Result != resultOK {
+		return nil, &APIError{StatusCode: resp.StatusCode, Result: envelope.Result, Message: envelope.Message}
+	}
+	return envelope.Data, nil
+}
+
+// Deauthorize revokes the user's authorization for this app.
+func (c *Client) Deauthorize(ctx context.Context, accessToken string) error {
+	params := url.Values{}
+	params.Add("token", accessToken)
+
+	body, err := c.postForm(ctx, "/oauth2/deauthorize", params)
+	if err != nil {
+		return err
+	}
+	body.Close()
+	return nil
+}
+
+func (c *Client) postForm(ctx context.Context, path string, params url.Values) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.BaseURL+path, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, readAPIError(resp)
+	}
+	return resp.Body, nil
+}
+
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+	}
+}
@@ -0,0 +1,331 @@
+package garmin
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAuthURL  = "https://connect.garmin.com/oauth2Confirm"
+	defaultTokenURL = "https://diauth.garmin.com/di-oauth2-service/oauth/token"
+	defaultAPIURL   = "https://apis.garmin.com"
+
+	wellnessPath = "/wellness-api/rest"
+
+	// MaxUploadWindow is the widest upload-time range the pull endpoints
+	// accept in one request.
+	MaxUploadWindow = 24 * time.Hour
+
+	// MaxBackfillWindow is the widest summary-time range one backfill
+	// request may cover.
+	MaxBackfillWindow = 90 * 24 * time.Hour
+)
+
+// Client handles Garmin Connect (Health API) communication. The URLs default
+// to Garmin's production hosts and can be pointed at a local fake server.
+type Client struct {
+	ClientID     string
+	ClientSecret string
+	RedirectURI  string
+	AuthURL      string
+	TokenURL     string
+	APIURL       string
+	HTTPClient   *http.Client
+}
+
+// APIError captures Garmin API failures.
+type APIError struct {
+	StatusCode int
+	RetryAfter time.Duration
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	if e == nil {
+		return ""
+	}
+	if e.RetryAfter > 0 {
+		return fmt.Sprintf("garmin api returned status %d (retry after %s)", e.StatusCode, e.RetryAfter)
+	}
+	return fmt.Sprintf("garmin api returned status: %d", e.StatusCode)
+}
+
+func readAPIError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+	apiErr := &APIError{
+		StatusCode: resp.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+	if seconds, err := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); err == nil && seconds > 0 {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+	return apiErr
+}
+
+// NewClient creates a new Garmin API client
+func NewClient(clientID, clientSecret, redirectURI string) *Client {
+	return &Client{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURI:  redirectURI,
+		AuthURL:      defaultAuthURL,
+		TokenURL:     defaultTokenURL,
+		APIURL:       defaultAPIURL,
+		HTTPClient:   &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Configured reports whether app credentials were provided.
+func (c *Client) Configured() bool {
+	return c.ClientID != "" && c.ClientSecret != ""
+}
+
+// TokenResponse represents the OAuth 2.0 token response
+type TokenResponse struct {
+	AccessToken           string `json:"access_token"`
+	TokenType             string `json:"token_type"`
+	RefreshToken          string `json:"refresh_token"`
+	ExpiresIn             int    `json:"expires_in"`               // seconds
+	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"` // seconds
+	Scope                 string `json:"scope"`
+}
+
+// CodeChallenge derives the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// GetAuthorizationURL returns the URL to redirect users to for Garmin OAuth.
+// Garmin requires PKCE; codeVerifier must be kept for ExchangeToken.
+func (c *Client) GetAuthorizationURL(state, codeVerifier string) string {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("response_type", "code")
+	params.Add("redirect_uri", c.RedirectURI)
+	params.Add("code_challenge", CodeChallenge(codeVerifier))
+	params.Add("code_challenge_method", "S256")
+	if state != "" {
+		params.Add("state", state)
+	}
+
+	return fmt.Sprintf("%s?%s", c.AuthURL, params.Encode())
+}
+
+// ExchangeToken exchanges the authorization code for tokens
+func (c *Client) ExchangeToken(ctx context.Context, code, codeVerifier string) (*TokenResponse, error) {
+	params := url.Values{}
+	params.Add("grant_type", "authorization_code")
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+	params.Add("code", code)
+	params.Add("code_verifier", codeVerifier)
+	params.Add("redirect_uri", c.RedirectURI)
+	return c.requestToken(ctx, params)
+}
+
+// RefreshToken exchanges a refresh token for a new access token. Garmin
+// rotates refresh tokens, so the returned one replaces the stored one.
+func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
+	params := url.Values{}
+	params.Add("grant_type", "refresh_token")
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+	params.Add("refresh_token", refreshToken)
+	return c.requestToken(ctx, params)
+}
+
+func (c *Client) requestToken(ctx context.Context, params url.Values) (*TokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readAPIError(resp)
+	}
+
+	var tokenResp TokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokenResp); err != nil {
+		return nil, err
+	}
+	return &tokenResp, nil
+}
+
+// GetUserID returns the Garmin user ID behind accessToken. It is stable
+// across re-authorizations and is what notifications are keyed by.
+func (c *Client) GetUserID(ctx context.Context, accessToken string) (string, error) {
+	var body struct {
+		UserID string `json:"userId"`
+	}
+	if err := c.get(ctx, accessToken, c.APIURL+wellnessPath+"/user/id", &body); err != nil {
+		return "", err
+	}
+	if body.UserID == "" {
+		return "", fmt.Errorf("garmin returned an empty user id")
+	}
+	return body.UserID, nil
+}
+
+// Activity is a Health API activity summary.
+type Activity struct {
+	SummaryID                         string  `json:"summaryId"`
+	ActivityID                        int64   `json:"activityId"`
+	ActivityName                      string  `json:"activityName"`
+	ActivityType                      string  `json:"activityType"` // "RUNNING", "TRAIL_RUNNING", ...
+	StartTimeInSeconds                int64   `json:"startTimeInSeconds"`
+	StartTimeOffsetInSeconds          int     `json:"startTimeOffsetInSeconds"` // local time minus UTC
+	DurationInSeconds                 int     `json:"durationInSeconds"`
+	DistanceInMeters                  float64 `json:"distanceInMeters"`
+	AverageHeartRateInBeatsPerMinute  int     `json:"averageHeartRateInBeatsPerMinute"`
+	MaxHeartRateInBeatsPerMinute      int     `json:"maxHeartRateInBeatsPerMinute"`
+	AverageRunCadenceInStepsPerMinute float64 `json:"averageRunCadenceInStepsPerMinute"`
+	AverageBikeCadenceInRPM           float64 `json:"averageBikeCadenceInRoundsPerMinute"`
+	TotalElevationGainInMeters        float64 `json:"totalElevationGainInMeters"`
+	ActiveKilocalories                int     `json:"activeKilocalories"`
+	DeviceName                        string  `json:"deviceName"`
+	Manual                            bool    `json:"manual"`
+	IsParent                          bool    `json:"isParent"` // multisport container; its legs arrive separately
+}
+
+// ActivityDetail is an activityDetails record: the summary plus laps.
+// Samples are not decoded.
+type ActivityDetail struct {
+	SummaryID  string   `json:"summaryId"`
+	ActivityID int64    `json:"activityId"`
+	Summary    Activity `json:"summary"`
+	Laps       []Lap    `json:"laps"`
+}
+
+// Lap marks a lap boundary within an activity detail.
+type Lap struct {
+	StartTimeInSeconds int64 `json:"startTimeInSeconds"`
+}
+
+// ListActivities returns activity summaries uploaded in [start, end). The
+// range may not exceed MaxUploadWindow.
+func (c *Client) ListActivities(ctx context.Context, accessToken string, start, end time.Time) ([]Activity, error) {
+	var activities []Activity
+	if err := c.get(ctx, accessToken, c.uploadRangeURL("/activities", start, end), &activities); err != nil {
+		return nil, err
+	}
+	return activities, nil
+}
+
+// ListActivityDetails returns activity details uploaded in [start, end).
+// The range may not exceed MaxUploadWindow.
+func (c *Client) ListActivityDetails(ctx context.Context, accessToken string, start, end time.Time) ([]ActivityDetail, error) {
+	var details []ActivityDetail
+	if err := c.get(ctx, accessToken, c.uploadRangeURL("/activityDetails", start, end), &details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// FetchCallback pulls the records a ping notification points at. The
+// callback URL must be on the configured API host; anything else is refused
+// so a forged ping can't make us send the access token elsewhere.
+func (c *Client) FetchCallback(ctx context.Context, accessToken, callbackURL string, dest any) error {
+	target, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("garmin callback url: %w", err)
+	}
+	api, err := url.Parse(c.APIURL)
+	if err != nil {
+		return err
+	}
+	if target.Scheme != api.Scheme || target.Host != api.Host || !strings.HasPrefix(target.Path, wellnessPath+"/") {
+		return fmt.Errorf("garmin callback url %q is not on %s", callbackURL, c.APIURL)
+	}
+	return c.get(ctx, accessToken, target.String(), dest)
+}
+
+// RequestBackfill asks Garmin to re-deliver activity summaries whose start
+// time falls in [start, end). Garmin answers 202 and sends the data later
+// through the usual notifications. The range may not exceed
+// MaxBackfillWindow.
+func (c *Client) RequestBackfill(ctx context.Context, accessToken string, start, end time.Time) error {
+	params := url.Values{}
+	params.Add("summaryStartTimeInSeconds", strconv.FormatInt(start.Unix(), 10))
+	params.Add("summaryEndTimeInSeconds", strconv.FormatInt(end.Unix(), 10))
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.APIURL+wellnessPath+"/backfill/activities?"+params.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// 409 means an identical backfill is already queued.
+	if resp.StatusCode != http.StatusAccepted && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusConflict {
+		return readAPIError(resp)
+	}
+	return nil
+}
+
+// DeleteUserRegistration revokes the user's authorization for this app.
+func (c *Client) DeleteUserRegistration(ctx context.Context, accessToken string) error {
+	req, err := http.NewRequestWithContext(ctx, "DELETE", c.APIURL+wellnessPath+"/user/registration", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	return nil
+}
+
+func (c *Client) uploadRangeURL(path string, start, end time.Time) string {
+	params := url.Values{}
+	params.Add("uploadStartTimeInSeconds", strconv.FormatInt(start.Unix(), 10))
+	params.Add("uploadEndTimeInSeconds", strconv.FormatInt(end.Unix(), 10))
+	return c.APIURL + wellnessPath + path + "?" + params.Encode()
+}
+
+func (c *Client) get(ctx context.Context, accessToken, endpoint string, dest any) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	return json.NewDecoder(resp.Body).Decode(dest)
+}
@@ -0,0 +1,41 @@
+package garmin
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Summary types Korsana subscribes to in the Garmin developer portal. Each
+// one is a top-level key in a notification body.
+const (
+	SummaryActivities        = "activities"
+	SummaryActivityDetails   = "activityDetails"
+	SummaryDeregistrations   = "deregistrations"
+	SummaryUserPermissions   = "userPermissionsChange"
+	PermissionActivityExport = "ACTIVITY_EXPORT"
+)
+
+// Notification is the body Garmin POSTs to the notification endpoint: one
+// array of records per summary type. Records are kept raw so they can be
+// queued as received.
+type Notification map[string][]json.RawMessage
+
+// Record holds the fields shared by every notification record. Ping records
+// carry a CallbackURL to pull the data from; push records carry the summary
+// itself, which decodes as Activity or ActivityDetail.
+type Record struct {
+	UserID      string   `json:"userId"`
+	CallbackURL string   `json:"callbackURL"`
+	Permissions []string `json:"permissions"` // userPermissionsChange only
+}
+
+// IsPing reports whether the record only points at data to pull.
+func (r Record) IsPing() bool {
+	return r.CallbackURL != ""
+}
+
+// AllowsActivityExport reports whether a permissions change still lets us
+// read activities.
+func (r Record) AllowsActivityExport() bool {
+	return slices.Contains(r.Permissions, PermissionActivityExport)
+}
@@ -0,0 +1,157 @@
+package strava
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchiveActivitiesFile is the activity index at the root of the bulk
+// export archive Strava emails from Settings > My Account.
+const ArchiveActivitiesFile = "activities.csv"
+
+// archiveDateLayouts are the "Activity Date" formats seen in exports. The
+// value is UTC in both.
+var archiveDateLayouts = []string{
+	"Jan 2, 2006, 3:04:05 PM",
+	"2006-01-02 15:04:05",
+}
+
+// ArchiveEntry is one row of activities.csv, converted to the API's
+// Activity shape so it can share the API import path. StartDateLocal is
+// left empty: the archive records no time zone.
+type ArchiveEntry struct {
+	Row      int // line number in the CSV, header is 1
+	Activity Activity
+	// Filename is the original upload's path inside the archive, e.g.
+	// "activities/123.fit.gz". Empty for manual entries.
+	Filename string
+}
+
+// ArchiveRowError describes a row that could not be read.
+type ArchiveRowError struct {
+	Row int
+	Err error
+}
+
+func (e *ArchiveRowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.Row, e.Err)
+}
+
+// ReadArchiveActivities parses activities.csv. Unreadable rows are returned
+// as ArchiveRowErrors alongside the rows that parsed; only a missing or
+// malformed header fails the whole file.
+//
+// Exports repeat some headers: the first "Distance" is in the athlete's
+// display unit (km), the detailed block later in the row repeats it in
+// meters. The last occurrence of a header wins.
+func ReadArchiveActivities(r io.Reader) ([]ArchiveEntry, []ArchiveRowError, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s header: %w", ArchiveActivitiesFile, err)
+	}
+	columns := map[string]int{}
+	distanceColumns := 0
+	for i, name := range header {
+		name = strings.TrimSpace(strings.TrimPrefix(name, "\ufeff"))
+		columns[name] = i
+		if name == "Distance" {
+			distanceColumns++
+		}
+	}
+	for _, required := range []string{"Activity ID", "Activity Date", "Activity Type"} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("%s has no %q column", ArchiveActivitiesFile, required)
+		}
+	}
+
+	var entries []ArchiveEntry
+	var rowErrs []ArchiveRowError
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			rowErrs = append(rowErrs, ArchiveRowError{Row: row, Err: err})
+			continue
+		}
+		entry, err := archiveEntry(record, columns, distanceColumns)
+		if err != nil {
+			rowErrs = append(rowErrs, ArchiveRowError{Row: row, Err: err})
+			continue
+		}
+		entry.Row = row
+		entries = append(entries, entry)
+	}
+	return entries, rowErrs, nil
+}
+
+func archiveEntry(record []string, columns map[string]int, distanceColumns int) (ArchiveEntry, error) {
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(name string) float64 {
+		v, _ := strconv.ParseFloat(strings.ReplaceAll(field(name), ",", ""), 64)
+		return v
+	}
+
+	id, err := strconv.ParseInt(field("Activity ID"), 10, 64)
+	if err != nil {
+		return ArchiveEntry{}, fmt.Errorf("invalid activity id %q", field("Activity ID"))
+	}
+	var start time.Time
+	for _, layout := range archiveDateLayouts {
+		if start, err = time.Parse(layout, field("Activity Date")); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		return ArchiveEntry{}, fmt.Errorf("invalid activity date %q", field("Activity Date"))
+	}
+
+	distance := number("Distance")
+	if distanceColumns == 1 {
+		distance *= 1000
+	}
+	elapsed := int(number("Elapsed Time"))
+	moving := int(number("Moving Time"))
+	if moving == 0 {
+		moving = elapsed
+	}
+
+	// The CSV spells types for display ("Weight Training", "E-Bike Ride");
+	// the API uses the same words run together.
+	activityType := strings.NewReplacer(" ", "", "-", "").Replace(field("Activity Type"))
+
+	return ArchiveEntry{
+		Activity: Activity{
+			ID:                 id,
+			Name:               field("Activity Name"),
+			Distance:           distance,
+			MovingTime:         moving,
+			ElapsedTime:        elapsed,
+			TotalElevationGain: number("Elevation Gain"),
+			Type:               activityType,
+			SportType:          activityType,
+			StartDate:          start.UTC().Format(time.RFC3339),
+			AverageHeartrate:   number("Average Heart Rate"),
+			MaxHeartrate:       number("Max Heart Rate"),
+			AverageCadence:     number("Average Cadence"),
+			SufferScore:        int(number("Relative Effort")),
+		},
+		Filename: field("Filename"),
+	}, nil
+}
@@ -13,18 +13,39 @@ import (
 )
 
 const (
-	baseURL  = "https://www.strava.com/api/v3"
-	authURL  = "https://www.strava.com/oauth/authorize"
-	tokenURL = "https://www.strava.com/oauth/token"
-	scope    = "read,activity:read_all,profile:read_all"
+	baseURL             = "https://www.strava.com/api/v3"
+	authURL             = "https://www.strava.com/oauth/authorize"
+	tokenURL            = "https://www.strava.com/oauth/token"
+	deauthorizeURL      = "https://www.strava.com/oauth/deauthorize"
+	pushSubscriptionURL = baseURL + "/push_subscriptions"
+	scope               = "read,activity:read_all,profile:read_all"
 )
 
+// ScopeActivityReadAll lets the API list an athlete's private activities
+// as well as their public ones.
+const ScopeActivityReadAll = "activity:read_all"
+
+// HasScope reports whether a comma-separated scope list, as Strava passes
+// it to the OAuth callback, includes want.
+func HasScope(granted, want string) bool {
+	for _, s := range strings.Split(granted, ",") {
+		if strings.TrimSpace(s) == want {
+			return true
+		}
+	}
+	return false
+}
+
 // Client handles Strava API communication
 type Client struct {
 	ClientID     string
 	ClientSecret string
 	RedirectURI  string
 	HTTPClient   *http.Client
+
+	// Governor, if set, tracks the app-wide rate limit across instances
+	// and refuses API requests once it is used up.
+	Governor *Governor
 }
 
 // APIError captures Strava API failures with enough detail for bounded retry logic.
@@ -70,6 +91,41 @@ func readAPIError(resp *http.Response) error {
 	}
 }
 
+// do sends an API request. With a Governor, a request is refused with a
+// synthetic 429 while a rate-limit window is used up, and every response's
+// rate-limit headers are recorded. Redis trouble never blocks a request.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.Governor != nil {
+		if budget, err := c.Governor.Budget(req.Context()); err == nil {
+			if exhausted, until := budget.Exhausted(); exhausted {
+				return nil, &APIError{
+					StatusCode: http.StatusTooManyRequests,
+					RetryAfter: time.Until(until),
+					Body:       "app-wide rate limit used up",
+				}
+			}
+		}
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if c.Governor != nil {
+		_ = c.Governor.Observe(req.Context(), resp.Header)
+	}
+	return resp, nil
+}
+
+// RateLimitBudget returns the last known rate-limit standing, or nil when
+// the client has no Governor.
+func (c *Client) RateLimitBudget(ctx context.Context) (*Budget, error) {
+	if c.Governor == nil {
+		return nil, nil
+	}
+	return c.Governor.Budget(ctx)
+}
+
 // NewClient creates a new Strava API client
 func NewClient(clientID, clientSecret, redirectURI string) *Client {
 	return &Client{
@@ -152,26 +208,113 @@ type Activity struct {
 	MaxHeartrate       float64 `json:"max_heartrate"`
 	AverageCadence     float64 `json:"average_cadence"`
 	SufferScore        int     `json:"suffer_score"`
+	WorkoutType        *int    `json:"workout_type"` // see WorkoutTypeLabel
+	GearID             string  `json:"gear_id"`
+}
+
+// DetailedActivity is the /activities/{id} payload: the summary fields plus
+// laps, splits and athlete-entered details the list endpoint omits.
+type DetailedActivity struct {
+	Activity
+	Description    string  `json:"description"`
+	Calories       float64 `json:"calories"` // kcal
+	DeviceName     string  `json:"device_name"`
+	Laps           []Lap   `json:"laps"`
+	SplitsMetric   []Split `json:"splits_metric"`   // per kilometer
+	SplitsStandard []Split `json:"splits_standard"` // per mile
+}
+
+// Lap is a device or manual lap within an activity.
+type Lap struct {
+	LapIndex           int     `json:"lap_index"`
+	Name               string  `json:"name"`
+	Distance           float64 `json:"distance"`      // meters
+	ElapsedTime        int     `json:"elapsed_time"`  // seconds
+	MovingTime         int     `json:"moving_time"`   // seconds
+	StartDate          string  `json:"start_date"`    // ISO 8601 UTC
+	AverageSpeed       float64 `json:"average_speed"` // m/s
+	MaxSpeed           float64 `json:"max_speed"`     // m/s
+	AverageHeartrate   float64 `json:"average_heartrate"`
+	MaxHeartrate       float64 `json:"max_heartrate"`
+	AverageCadence     float64 `json:"average_cadence"`
+	TotalElevationGain float64 `json:"total_elevation_gain"`
+	PaceZone           int     `json:"pace_zone"`
+}
+
+// Split is an even-distance split (1 km or 1 mile) computed by Strava.
+type Split struct {
+	Split                     int     `json:"split"` // 1-based
+	Distance                  float64 `json:"distance"`
+	ElapsedTime               int     `json:"elapsed_time"`
+	MovingTime                int     `json:"moving_time"`
+	ElevationDifference       float64 `json:"elevation_difference"`
+	AverageSpeed              float64 `json:"average_speed"`
+	AverageGradeAdjustedSpeed float64 `json:"average_grade_adjusted_speed"`
+	AverageHeartrate          float64 `json:"average_heartrate"`
+	PaceZone                  int     `json:"pace_zone"`
+}
+
+// WorkoutTypeLabel maps Strava's numeric workout_type to "race", "long_run"
+// or "workout". Runs use codes 0–3 and rides 10–12; the default types (0, 10)
+// and unknown codes map to "".
+func WorkoutTypeLabel(code *int) string {
+	if code == nil {
+		return ""
+	}
+	switch *code {
+	case 1, 11:
+		return "race"
+	case 2:
+		return "long_run"
+	case 3, 12:
+		return "workout"
+	default:
+		return ""
+	}
 }
 
 // GetActivities fetches recent activities for the authenticated athlete
 func (c *Client) GetActivities(ctx context.Context, accessToken string, page int, perPage int) ([]Activity, error) {
-	if perPage == 0 {
-		perPage = 30
+	return c.ListActivities(ctx, accessToken, ActivityListParams{Page: page, PerPage: perPage})
+}
+
+// ActivityListParams filters /athlete/activities. Before and After are
+// exclusive bounds on start time; zero values are omitted.
+type ActivityListParams struct {
+	Before  time.Time
+	After   time.Time
+	Page    int
+	PerPage int // Strava caps this at 200
+}
+
+// ListActivities fetches one page of the athlete's activities, newest first.
+func (c *Client) ListActivities(ctx context.Context, accessToken string, p ActivityListParams) ([]Activity, error) {
+	if p.PerPage == 0 {
+		p.PerPage = 30
 	}
-	if page == 0 {
-		page = 1
+	if p.Page == 0 {
+		p.Page = 1
 	}
 
-	url := fmt.Sprintf("%s/athlete/activities?page=%d&per_page=%d", baseURL, page, perPage)
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	params := url.Values{}
+	params.Add("page", strconv.Itoa(p.Page))
+	params.Add("per_page", strconv.Itoa(p.PerPage))
+	if !p.Before.IsZero() {
+		params.Add("before", strconv.FormatInt(p.Before.Unix(), 10))
+	}
+	if !p.After.IsZero() {
+		params.Add("after", strconv.FormatInt(p.After.Unix(), 10))
+	}
+
+	endpoint := fmt.Sprintf("%s/athlete/activities?%s", baseURL, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
 
-	resp, err := c.HTTPClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -189,6 +332,36 @@ func (c *Client) GetActivities(ctx context.Context, accessToken string, page int
 	return activities, nil
 }
 
+// GetActivity fetches a single activity by its Strava ID, including laps,
+// splits and the detail-only fields. Used by the webhook worker, which only
+// receives the activity ID in the push event, and by sync enrichment.
+func (c *Client) GetActivity(ctx context.Context, accessToken string, activityID int64) (*DetailedActivity, error) {
+	endpoint := fmt.Sprintf("%s/activities/%d", baseURL, activityID)
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readAPIError(resp)
+	}
+
+	var activity DetailedActivity
+	if err := json.NewDecoder(resp.Body).Decode(&activity); err != nil {
+		return nil, err
+	}
+
+	return &activity, nil
+}
+
 // RefreshToken refreshes an expired access token
 func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenResponse, error) {
 	params := url.Values{}
@@ -219,3 +392,27 @@ func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*TokenR
 
 	return &tokenResp, nil
 }
+
+// Deauthorize revokes the athlete's grant for this app, invalidating every
+// access and refresh token issued under it. Strava then sends an athlete
+// deauthorization webhook event.
+func (c *Client) Deauthorize(ctx context.Context, accessToken string) error {
+	params := url.Values{}
+	params.Add("access_token", accessToken)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", deauthorizeURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package strava
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// ShortWindow is Strava's short rate-limit window. Both windows reset
+	// on UTC boundaries: every quarter hour and at midnight.
+	ShortWindow = 15 * time.Minute
+	LongWindow  = 24 * time.Hour
+
+	// Background work only runs while at least this share of every window
+	// is left, so interactive syncs and webhooks keep the remainder.
+	backgroundShortReserve = 0.3
+	backgroundLongReserve  = 0.2
+
+	rateLimitKey = "strava:ratelimit"
+)
+
+// RateLimitWindow is one window of Strava's app-wide rate limit.
+type RateLimitWindow struct {
+	Limit    int       `json:"limit"`
+	Usage    int       `json:"usage"`
+	ResetsAt time.Time `json:"resets_at"`
+}
+
+// Remaining is the number of requests left in the window.
+func (w RateLimitWindow) Remaining() int {
+	if w.Usage >= w.Limit {
+		return 0
+	}
+	return w.Limit - w.Usage
+}
+
+// known reports whether Strava has told us this window's limit.
+func (w RateLimitWindow) known() bool { return w.Limit > 0 }
+
+// Budget is the app's last known standing against Strava's rate limits.
+// Overall limits apply to every request, read limits to GETs only. A
+// window with a zero Limit has not been reported yet.
+type Budget struct {
+	Short     RateLimitWindow `json:"short"`
+	Long      RateLimitWindow `json:"long"`
+	ReadShort RateLimitWindow `json:"read_short"`
+	ReadLong  RateLimitWindow `json:"read_long"`
+	UpdatedAt *time.Time      `json:"updated_at,omitempty"`
+}
+
+func (b *Budget) windows() []RateLimitWindow {
+	return []RateLimitWindow{b.Short, b.Long, b.ReadShort, b.ReadLong}
+}
+
+// Exhausted reports whether any window is used up, and when the last of
+// the exhausted windows resets.
+func (b *Budget) Exhausted() (bool, time.Time) {
+	var until time.Time
+	for _, w := range b.windows() {
+		if w.known() && w.Remaining() == 0 && w.ResetsAt.After(until) {
+			until = w.ResetsAt
+		}
+	}
+	return !until.IsZero(), until
+}
+
+// AllowBackground reports whether enough of every window is left for
+// deferrable work such as history backfills and scheduled syncs.
+func (b *Budget) AllowBackground() bool {
+	check := func(w RateLimitWindow, reserve float64) bool {
+		return !w.known() || float64(w.Remaining()) >= reserve*float64(w.Limit)
+	}
+	return check(b.Short, backgroundShortReserve) && check(b.Long, backgroundLongReserve) &&
+		check(b.ReadShort, backgroundShortReserve) && check(b.ReadLong, backgroundLongReserve)
+}
+
+// Governor tracks Strava's rate limits across every server instance. Each
+// response's X-RateLimit headers are written to Redis, and requests are
+// refused locally once a window is used up instead of spending a 429.
+type Governor struct {
+	redis *redis.Client
+	now   func() time.Time
+}
+
+// NewGovernor creates a Governor backed by rdb.
+func NewGovernor(rdb *redis.Client) *Governor {
+	return &Governor{redis: rdb, now: time.Now}
+}
+
+// observeScript stores each reported window. Responses race each other, so
+// within the same window the higher usage wins; a new window replaces it.
+var observeScript = redis.NewScript(`
+for i = 1, #ARGV - 1, 4 do
+	local name, start, limit, usage = ARGV[i], ARGV[i+1], ARGV[i+2], tonumber(ARGV[i+3])
+	if redis.call('HGET', KEYS[1], name .. ':start') == start then
+		local prev = tonumber(redis.call('HGET', KEYS[1], name .. ':usage') or '0')
+		if prev > usage then usage = prev end
+	end
+	redis.call('HSET', KEYS[1], name .. ':start', start, name .. ':limit', limit, name .. ':usage', usage)
+end
+redis.call('HSET', KEYS[1], 'updated_at', ARGV[#ARGV])
+redis.call('EXPIRE', KEYS[1], 172800)
+return 1
+`)
+
+// Observe records the rate-limit headers of a Strava response. Responses
+// without them are ignored.
+func (g *Governor) Observe(ctx context.Context, h http.Header) error {
+	now := g.now().UTC()
+	var args []any
+	for _, prefix := range []string{"X-RateLimit", "X-ReadRateLimit"} {
+		limits := parseRateLimitHeader(h.Get(prefix + "-Limit"))
+		usage := parseRateLimitHeader(h.Get(prefix + "-Usage"))
+		if len(limits) != 2 || len(usage) != 2 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimPrefix(prefix, "X-"))
+		args = append(args,
+			name+":short", windowStart(now, ShortWindow).Unix(), limits[0], usage[0],
+			name+":long", windowStart(now, LongWindow).Unix(), limits[1], usage[1],
+		)
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	args = append(args, now.Unix())
+	return observeScript.Run(ctx, g.redis, []string{rateLimitKey}, args...).Err()
+}
+
+// Budget returns the current standing. Usage recorded in an earlier window
+// counts as zero, since Strava has reset it since.
+func (g *Governor) Budget(ctx context.Context) (*Budget, error) {
+	fields, err := g.redis.HGetAll(ctx, rateLimitKey).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	now := g.now().UTC()
+	window := func(name string, length time.Duration) RateLimitWindow {
+		start := windowStart(now, length)
+		w := RateLimitWindow{ResetsAt: start.Add(length)}
+		w.Limit, _ = strconv.Atoi(fields[name+":limit"])
+		if fields[name+":start"] == strconv.FormatInt(start.Unix(), 10) {
+			w.Usage, _ = strconv.Atoi(fields[name+":usage"])
+		}
+		return w
+	}
+	b := &Budget{
+		Short:     window("ratelimit:short", ShortWindow),
+		Long:      window("ratelimit:long", LongWindow),
+		ReadShort: window("readratelimit:short", ShortWindow),
+		ReadLong:  window("readratelimit:long", LongWindow),
+	}
+	if ts, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		updated := time.Unix(ts, 0).UTC()
+		b.UpdatedAt = &updated
+	}
+	return b, nil
+}
+
+// windowStart is the UTC boundary at which the window containing now began.
+func windowStart(now time.Time, length time.Duration) time.Time {
+	return now.UTC().Truncate(length)
+}
+
+// parseRateLimitHeader parses Strava's "short,long" header values.
+func parseRateLimitHeader(v string) []int {
+	parts := strings.Split(v, ",")
+	out := make([]int, 0, len(parts))
+	for _, p := range parts {
+		n, err := strconv.Atoi(strings.TrimSpace(p))
+		if err != nil {
+			return nil
+		}
+		out = append(out, n)
+	}
+	return out
+}
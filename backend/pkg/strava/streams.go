@@ -0,0 +1,85 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// streamKeys are the stream types requested for every activity. Strava
+// silently omits any the activity doesn't have (e.g. latlng on a treadmill).
+var streamKeys = []string{
+	"time", "distance", "heartrate", "velocity_smooth",
+	"cadence", "altitude", "latlng", "moving",
+}
+
+// Streams holds an activity's per-sample data. Every non-empty slice has the
+// same length as Time and is indexed by sample.
+type Streams struct {
+	Time           []int        // seconds since activity start
+	Distance       []float64    // meters, cumulative
+	Heartrate      []int        // bpm
+	VelocitySmooth []float64    // m/s
+	Cadence        []int        // steps per minute for one leg on runs
+	Altitude       []float64    // meters
+	LatLng         [][2]float64 // [lat, lng]
+	Moving         []bool
+}
+
+// streamsResponse mirrors the key_by_type=true payload: one object per
+// stream type, each carrying its samples in "data".
+type streamsResponse struct {
+	Time           struct{ Data []int }        `json:"time"`
+	Distance       struct{ Data []float64 }    `json:"distance"`
+	Heartrate      struct{ Data []int }        `json:"heartrate"`
+	VelocitySmooth struct{ Data []float64 }    `json:"velocity_smooth"`
+	Cadence        struct{ Data []int }        `json:"cadence"`
+	Altitude       struct{ Data []float64 }    `json:"altitude"`
+	LatLng         struct{ Data [][2]float64 } `json:"latlng"`
+	Moving         struct{ Data []bool }       `json:"moving"`
+}
+
+// GetActivityStreams fetches the second-by-second streams for an activity.
+// Manual entries have no streams and come back with an empty Time slice.
+func (c *Client) GetActivityStreams(ctx context.Context, accessToken string, activityID int64) (*Streams, error) {
+	params := url.Values{}
+	params.Add("keys", strings.Join(streamKeys, ","))
+	params.Add("key_by_type", "true")
+
+	endpoint := fmt.Sprintf("%s/activities/%d/streams?%s", baseURL, activityID, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", accessToken))
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readAPIError(resp)
+	}
+
+	var raw streamsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	return &Streams{
+		Time:           raw.Time.Data,
+		Distance:       raw.Distance.Data,
+		Heartrate:      raw.Heartrate.Data,
+		VelocitySmooth: raw.VelocitySmooth.Data,
+		Cadence:        raw.Cadence.Data,
+		Altitude:       raw.Altitude.Data,
+		LatLng:         raw.LatLng.Data,
+		Moving:         raw.Moving.Data,
+	}, nil
+}
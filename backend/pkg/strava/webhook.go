@@ -0,0 +1,137 @@
+package strava
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Webhook object and aspect types sent by Strava's push subscription API.
+const (
+	WebhookObjectActivity = "activity"
+	WebhookObjectAthlete  = "athlete"
+
+	WebhookAspectCreate = "create"
+	WebhookAspectUpdate = "update"
+	WebhookAspectDelete = "delete"
+)
+
+// WebhookEvent is the payload Strava POSTs to the subscription callback URL.
+// Only IDs are delivered; the activity itself must be fetched separately.
+type WebhookEvent struct {
+	ObjectType     string         `json:"object_type"` // "activity" or "athlete"
+	ObjectID       int64          `json:"object_id"`
+	AspectType     string         `json:"aspect_type"` // "create", "update", "delete"
+	OwnerID        int64          `json:"owner_id"`    // athlete ID
+	SubscriptionID int64          `json:"subscription_id"`
+	EventTime      int64          `json:"event_time"` // unix seconds
+	Updates        map[string]any `json:"updates"`
+}
+
+// IsDeauthorization reports whether the event signals that the athlete
+// revoked Korsana's access from their Strava settings.
+func (e WebhookEvent) IsDeauthorization() bool {
+	if e.ObjectType != WebhookObjectAthlete || e.AspectType != WebhookAspectUpdate {
+		return false
+	}
+	authorized, ok := e.Updates["authorized"]
+	if !ok {
+		return false
+	}
+	return strings.EqualFold(fmt.Sprint(authorized), "false")
+}
+
+// PushSubscription describes an existing webhook subscription for this app.
+type PushSubscription struct {
+	ID          int64  `json:"id"`
+	CallbackURL string `json:"callback_url"`
+	CreatedAt   string `json:"created_at"`
+	UpdatedAt   string `json:"updated_at"`
+}
+
+// ListPushSubscriptions returns the app's webhook subscriptions. Strava allows
+// at most one per application.
+func (c *Client) ListPushSubscriptions(ctx context.Context) ([]PushSubscription, error) {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", pushSubscriptionURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, readAPIError(resp)
+	}
+
+	var subs []PushSubscription
+	if err := json.NewDecoder(resp.Body).Decode(&subs); err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// CreatePushSubscription registers callbackURL with Strava. Strava validates
+// the callback synchronously by issuing the GET challenge with verifyToken,
+// so the API server must already be reachable at callbackURL.
+func (c *Client) CreatePushSubscription(ctx context.Context, callbackURL, verifyToken string) (*PushSubscription, error) {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+	params.Add("callback_url", callbackURL)
+	params.Add("verify_token", verifyToken)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", pushSubscriptionURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return nil, readAPIError(resp)
+	}
+
+	var sub PushSubscription
+	if err := json.NewDecoder(resp.Body).Decode(&sub); err != nil {
+		return nil, err
+	}
+	sub.CallbackURL = callbackURL
+	return &sub, nil
+}
+
+// DeletePushSubscription removes the webhook subscription with the given ID.
+func (c *Client) DeletePushSubscription(ctx context.Context, id int64) error {
+	params := url.Values{}
+	params.Add("client_id", c.ClientID)
+	params.Add("client_secret", c.ClientSecret)
+
+	endpoint := fmt.Sprintf("%s/%d?%s", pushSubscriptionURL, id, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return readAPIError(resp)
+	}
+	return nil
+}
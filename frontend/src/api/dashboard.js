@@ -2,6 +2,13 @@ import api from './client';
 
 export const dashboardAPI = {
   get: () => api.get('/dashboard').then(r => r.data),
+  loadProjection: () => api.get('/dashboard/load-projection').then(r => r.data),
+  taperProposal: (params) => api.get('/dashboard/taper', { params }).then(r => r.data),
+  acceptTaper: (proposal) => api.post('/calendar/taper/accept', {
+    tsb_min: proposal.target_tsb_min,
+    tsb_max: proposal.target_tsb_max,
+    changes: proposal.changes,
+  }).then(r => r.data),
 };
 
 export const crossTrainingAPI = {
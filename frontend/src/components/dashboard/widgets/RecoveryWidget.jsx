@@ -61,6 +61,11 @@ export default function RecoveryWidget({ data, stravaConnected, onConnect }) {
             <div className="font-sans text-[10px] text-[var(--color-text-muted)]">Next quality session</div>
             <div className="font-mono text-base font-bold mt-0.5" style={{ color }}>{data.next_quality_day}</div>
           </div>
+          {data.hard_hr_threshold > 0 && (
+            <div className="font-sans text-[10px] text-[var(--color-text-muted)] mt-2">
+              Hard = avg HR ≥ {data.hard_hr_threshold} bpm (your Z4)
+            </div>
+          )}
         </div>
       </div>
     </div>
@@ -1038,12 +1038,22 @@ const Dashboard = () => {
   }, [activities]);
 
   const effortDist = useMemo(() => {
+    const colors = ['#5CC8FF', '#2ECC8B', '#F5A623', '#E8634A', '#E84A4A'];
+    // The dashboard API uses the athlete's own zones and buckets runs with
+    // HR streams second by second, so interval sessions land correctly.
+    const serverZones = dashboardData?.hr_zones?.zones;
+    if (serverZones?.length === 5) {
+      return serverZones.map((z, i) => ({
+        zone: z.name, label: z.bpm, color: colors[i], mins: z.minutes, pct: Math.round(z.pct),
+      }));
+    }
+    // Until the dashboard loads, estimate from run averages with fixed bounds.
     const zones = [
-      { zone: 'Z1 Easy',    label: '< 130 bpm', color: '#5CC8FF', min: 0,   max: 130, mins: 0 },
-      { zone: 'Z2 Aerobic', label: '130–148',   color: '#2ECC8B', min: 130, max: 148, mins: 0 },
-      { zone: 'Z3 Tempo',   label: '148–162',   color: '#F5A623', min: 148, max: 162, mins: 0 },
-      { zone: 'Z4 Hard',    label: '162–174',   color: '#E8634A', min: 162, max: 174, mins: 0 },
-      { zone: 'Z5 Max',     label: '174+',      color: '#E84A4A', min: 174, max: 999, mins: 0 },
+      { zone: 'Z1 Easy',    label: '< 130 bpm', color: colors[0], min: 0,   max: 130, mins: 0 },
+      { zone: 'Z2 Aerobic', label: '130–148',   color: colors[1], min: 130, max: 148, mins: 0 },
+      { zone: 'Z3 Tempo',   label: '148–162',   color: colors[2], min: 148, max: 162, mins: 0 },
+      { zone: 'Z4 Hard',    label: '162–174',   color: colors[3], min: 162, max: 174, mins: 0 },
+      { zone: 'Z5 Max',     label: '174+',      color: colors[4], min: 174, max: 999, mins: 0 },
     ];
     const sevenAgo = new Date(today);
     sevenAgo.setDate(sevenAgo.getDate() - 7);
@@ -1056,7 +1066,7 @@ const Dashboard = () => {
     });
     const total = zones.reduce((s, z) => s + z.mins, 0) || 1;
     return zones.map(z => ({ ...z, pct: Math.round((z.mins / total) * 100) }));
-  }, [activities]);
+  }, [activities, dashboardData]);
 
   const todayEntries = useMemo(() =>
     weekEntries.filter(e => e.date === todayISO),
@@ -10,16 +10,8 @@ CREATE TABLE IF NOT EXISTS users (
     updated_at TIMESTAMP DEFAULT NOW()
 );
 
-CREATE TABLE IF NOT EXISTS strava_connections (
-    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
-    user_id UUID REFERENCES users(id) ON DELETE CASCADE,
-    strava_athlete_id BIGINT UNIQUE NOT NULL,
-    access_token TEXT NOT NULL,
-    refresh_token TEXT NOT NULL,
-    token_expires_at TIMESTAMP NOT NULL,
-    created_at TIMESTAMP DEFAULT NOW(),
-    updated_at TIMESTAMP DEFAULT NOW()
-);
+-- Strava tokens live in connected_integrations (section 005) since
+-- migration 027; strava_connections is no longer created.
 
 CREATE TABLE IF NOT EXISTS race_goals (
     id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
@@ -125,6 +117,12 @@ CREATE TABLE IF NOT EXISTS connected_integrations (
     is_primary       BOOLEAN NOT NULL DEFAULT false,
     connected_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     last_synced_at   TIMESTAMPTZ,
+    -- 027: every source's tokens, Strava included, and whether they work.
+    status           VARCHAR(20) NOT NULL DEFAULT 'active',
+    last_error       TEXT,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    -- 036: OAuth scopes granted at connect time, comma-separated.
+    granted_scopes   TEXT,
     UNIQUE(user_id, source)
 );
 
@@ -132,6 +130,10 @@ CREATE UNIQUE INDEX IF NOT EXISTS idx_one_primary_per_user
     ON connected_integrations(user_id)
     WHERE is_primary = true;
 
+CREATE UNIQUE INDEX IF NOT EXISTS idx_connected_integrations_source_external
+    ON connected_integrations (source, external_user_id)
+    WHERE external_user_id IS NOT NULL;
+
 CREATE UNIQUE INDEX IF NOT EXISTS idx_activities_user_source_id
     ON activities(user_id, source, source_activity_id);
 